@@ -0,0 +1,363 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// AggregateParameters describes an aggregate stream combining several input devices.
+type AggregateParameters struct {
+	// Inputs are the devices to combine. Their channels appear in the given order.
+	Inputs          []StreamDeviceParameters
+	SampleRate      float64
+	SampleFormat    SampleFormat
+	FramesPerBuffer uint64
+	Flags           StreamFlags
+	// Master is the index of the input whose clock the others are synchronized to.
+	Master int
+	// Latency is the amount of audio buffered to align the inputs. Zero selects 20ms.
+	// It must hold at least FramesPerBuffer frames.
+	Latency time.Duration
+	// Planar makes the stream deliver one buffer per channel (InS) instead of interleaved frames (In).
+	Planar bool
+}
+
+// AggregateStream captures from several input devices as if they were one device.
+// The inputs are aligned by their InputBufferAdcTime and their clock drift
+// relative to the master input is compensated by resampling.
+type AggregateStream[T Sample] struct {
+	params     AggregateParameters
+	inputs     []*aggregateInput[T]
+	master     *aggregateInput[T]
+	channels   int
+	target     int
+	primed     bool
+	in         []T
+	inS        [][]T
+	block      []T
+	mix        []float64
+	frameCount int
+	timeInfo   StreamCallbackTimeInfo
+	callback   func(*AggregateStream[T]) StreamCallbackResult
+}
+
+type aggregateInput[T Sample] struct {
+	stream     *Stream[T]
+	channels   int
+	offset     int
+	ring       *ringBuffer[T]
+	rate       rateEstimator
+	end        atomic.Int64  // ADC time right after the last buffered frame
+	seq        atomic.Uint64 // odd while write updates the ring and end together
+	resampler  *resampler
+	controller driftController
+	ratio      atomic.Uint64
+	frame      []T
+	next       func([]float64) bool
+}
+
+// OpenAggregateStream opens an input stream for every device of params and
+// delivers their combined channels to callback, which runs on the master stream's thread.
+// The inputs must use interleaved samples in the format of T, or Float32 if T is float64.
+func OpenAggregateStream[T Sample](
+	params AggregateParameters,
+	callback func(*AggregateStream[T]) StreamCallbackResult,
+) (*AggregateStream[T], error) {
+	if callback == nil {
+		return nil, streamError("open aggregate", nil, nil, NullCallback)
+	}
+	if len(params.Inputs) == 0 || params.Master < 0 || params.Master >= len(params.Inputs) {
+		return nil, streamError("open aggregate", nil, nil, InvalidDevice)
+	}
+	if params.SampleFormat != sampleFormatOf[T]() {
+		return nil, streamError("open aggregate", nil, nil, SampleFormatNotSupported)
+	}
+	if params.Latency <= 0 {
+		params.Latency = 20 * time.Millisecond
+	}
+	a := &AggregateStream[T]{
+		params:   params,
+		target:   int(math.Ceil(params.Latency.Seconds() * params.SampleRate)),
+		callback: callback,
+	}
+	// The master delivers a whole buffer from the target buffered frames.
+	if uint64(a.target) < params.FramesPerBuffer {
+		return nil, streamError("open aggregate", nil, nil, BufferTooBig)
+	}
+	for _, p := range params.Inputs {
+		input := &aggregateInput[T]{
+			channels:   p.ChannelCount,
+			offset:     a.channels,
+			ring:       newRingBuffer[T](4 * a.target * p.ChannelCount),
+			resampler:  newResampler(p.ChannelCount),
+			controller: driftController{target: float64(a.target)},
+			frame:      make([]T, p.ChannelCount),
+		}
+		input.next = input.nextFrame
+		input.ratio.Store(math.Float64bits(1))
+		a.channels += p.ChannelCount
+		a.inputs = append(a.inputs, input)
+	}
+	a.master = a.inputs[params.Master]
+	if params.Planar {
+		a.inS = make([][]T, a.channels)
+	}
+	for i, p := range params.Inputs {
+		input := a.inputs[i]
+		streamParams := &StreamParameters{
+			Input:           p,
+			SampleRate:      params.SampleRate,
+			SampleFormat:    params.SampleFormat,
+			FramesPerBuffer: params.FramesPerBuffer,
+			Flags:           params.Flags,
+		}
+		cb := input.process
+		if input == a.master {
+			cb = a.processMaster
+		}
+		s, err := OpenStream(streamParams, cb, nil)
+		if err != nil {
+			_ = a.Close()
+			return nil, err
+		}
+		input.stream = s
+	}
+	return a, nil
+}
+
+// Start starts the input streams, the master last.
+func (a *AggregateStream[T]) Start() error {
+	for _, input := range a.inputs {
+		if input == a.master {
+			continue
+		}
+		if err := input.stream.Start(); err != nil {
+			_ = a.Abort()
+			return err
+		}
+	}
+	if err := a.master.stream.Start(); err != nil {
+		_ = a.Abort()
+		return err
+	}
+	return nil
+}
+
+// Stop stops all input streams.
+func (a *AggregateStream[T]) Stop() error {
+	var errs []error
+	for _, input := range a.inputs {
+		errs = append(errs, input.stream.Stop())
+	}
+	return errors.Join(errs...)
+}
+
+// Abort aborts all input streams.
+func (a *AggregateStream[T]) Abort() error {
+	var errs []error
+	for _, input := range a.inputs {
+		if input.stream != nil && !input.stream.IsStopped() {
+			errs = append(errs, input.stream.Abort())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all input streams.
+func (a *AggregateStream[T]) Close() error {
+	var errs []error
+	for _, input := range a.inputs {
+		if input.stream != nil {
+			errs = append(errs, input.stream.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Streams returns the underlying input streams.
+func (a *AggregateStream[T]) Streams() []*Stream[T] {
+	streams := make([]*Stream[T], len(a.inputs))
+	for i, input := range a.inputs {
+		streams[i] = input.stream
+	}
+	return streams
+}
+
+// ChannelCount returns the total number of channels.
+func (a *AggregateStream[T]) ChannelCount() int {
+	return a.channels
+}
+
+// In returns the interleaved frames of all inputs inside the callback.
+func (a *AggregateStream[T]) In() []T {
+	return a.in
+}
+
+// InS returns one buffer per channel inside the callback if the stream is planar.
+func (a *AggregateStream[T]) InS() [][]T {
+	return a.inS
+}
+
+// FrameCount returns the number of frames of the current buffer.
+func (a *AggregateStream[T]) FrameCount() int {
+	return a.frameCount
+}
+
+// TimeInfo returns the timing of the current buffer on the master clock.
+// InputBufferAdcTime accounts for the alignment latency.
+func (a *AggregateStream[T]) TimeInfo() StreamCallbackTimeInfo {
+	return a.timeInfo
+}
+
+// Ratio returns the resampling ratio applied to the given input.
+func (a *AggregateStream[T]) Ratio(input int) float64 {
+	return math.Float64frombits(a.inputs[input].ratio.Load())
+}
+
+// Drift returns the estimated clock drift of the given input relative to the master in parts per million,
+// or 0 if it is not known yet.
+func (a *AggregateStream[T]) Drift(input int) float64 {
+	r, m := a.inputs[input].rate.value(), a.master.rate.value()
+	if r == 0 || m == 0 {
+		return 0
+	}
+	return (r/m - 1) * 1e6
+}
+
+// process buffers the frames of a non-master input.
+func (input *aggregateInput[T]) process(s *Stream[T]) StreamCallbackResult {
+	input.write(s)
+	return Continue
+}
+
+func (input *aggregateInput[T]) write(s *Stream[T]) {
+	t := callbackTime(s.TimeInfo().InputBufferAdcTime, s)
+	input.rate.update(t, s.FrameCount())
+	in := s.In()
+	n := min(len(in), input.ring.free()/input.channels*input.channels)
+	input.seq.Add(1)
+	input.ring.write(in[:n])
+	input.end.Store(int64(t + frameDuration(s.FrameCount(), s.params.SampleRate)))
+	input.seq.Add(1)
+}
+
+// head returns the number of buffered frames and the ADC time of the oldest one.
+// It must be called by the reader of the ring, so that only write changes them meanwhile.
+func (input *aggregateInput[T]) head(sampleRate float64) (int, time.Duration) {
+	for {
+		seq := input.seq.Load()
+		if seq&1 != 0 {
+			continue
+		}
+		n := input.ring.len()
+		end := time.Duration(input.end.Load())
+		if input.seq.Load() == seq {
+			frames := n / input.channels
+			return frames, end - frameDuration(frames, sampleRate)
+		}
+	}
+}
+
+func (input *aggregateInput[T]) nextFrame(frame []float64) bool {
+	if input.ring.read(input.frame) < input.channels {
+		return false
+	}
+	for i, v := range input.frame {
+		frame[i] = ToFloat64(v)
+	}
+	return true
+}
+
+func (a *AggregateStream[T]) processMaster(s *Stream[T]) StreamCallbackResult {
+	m := a.master
+	m.write(s)
+	if !a.primed && !a.align() {
+		return Continue
+	}
+	frames := s.FrameCount()
+	a.frameCount = frames
+	a.timeInfo = s.TimeInfo()
+	a.timeInfo.InputBufferAdcTime -= frameDuration(a.target, a.params.SampleRate)
+	if a.params.Planar {
+		for ch := range a.inS {
+			a.inS[ch] = resize(a.inS[ch], frames)
+		}
+	} else {
+		a.in = resize(a.in, frames*a.channels)
+	}
+	dt := float64(frames) / a.params.SampleRate
+	for _, input := range a.inputs {
+		n := frames * input.channels
+		a.mix = resize(a.mix, n)
+		if input == m {
+			a.block = resize(a.block, n)
+			if m.ring.read(a.block) < n {
+				a.primed = false
+				return Continue
+			}
+			for i, v := range a.block {
+				a.mix[i] = ToFloat64(v)
+			}
+		} else {
+			fill := input.ring.len() / input.channels
+			drift := 0.0
+			if r, mr := input.rate.value(), m.rate.value(); r != 0 && mr != 0 {
+				drift = r / mr
+			}
+			ratio := input.controller.ratio(drift, fill, dt)
+			input.ratio.Store(math.Float64bits(ratio))
+			if input.resampler.process(a.mix, ratio, input.next) > 0 {
+				a.primed = false
+			}
+		}
+		a.deliver(input)
+	}
+	return a.callback(a)
+}
+
+// deliver copies the frames of an input from the mix buffer into the aggregate buffers.
+func (a *AggregateStream[T]) deliver(input *aggregateInput[T]) {
+	c := input.channels
+	for i, v := range a.mix {
+		frame, ch := i/c, input.offset+i%c
+		if a.params.Planar {
+			a.inS[ch][frame] = FromFloat64[T](v)
+		} else {
+			a.in[frame*a.channels+ch] = FromFloat64[T](v)
+		}
+	}
+}
+
+// align discards buffered frames so that the oldest frame of every input has the same
+// ADC time and the master buffers exactly the target latency. It reports false if
+// not all inputs have buffered enough frames yet.
+func (a *AggregateStream[T]) align() bool {
+	rate := a.params.SampleRate
+	frames, head := a.master.head(rate)
+	if frames < a.target {
+		return false
+	}
+	a.master.ring.discard((frames - a.target) * a.master.channels)
+	head += frameDuration(frames-a.target, rate)
+	for _, input := range a.inputs {
+		if input == a.master {
+			continue
+		}
+		n, h := input.head(rate)
+		skip := int(math.Round((head - h).Seconds() * rate))
+		if skip < 0 || n-skip < a.target/2 {
+			return false
+		}
+		input.ring.discard(skip * input.channels)
+		input.controller.reset(n - skip)
+		input.resampler.reset()
+	}
+	a.primed = true
+	return true
+}
+
+func frameDuration(frames int, sampleRate float64) time.Duration {
+	return time.Duration(float64(frames) / sampleRate * float64(time.Second))
+}
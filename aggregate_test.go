@@ -0,0 +1,106 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// playConstant plays the given level on each channel of the output device.
+func playConstant(t *testing.T, device *DeviceInfo, levels ...float32) {
+	t.Helper()
+	openTestStream(t, &StreamParameters{
+		Output:          StreamDeviceParameters{Device: device, ChannelCount: len(levels)},
+		SampleRate:      48000,
+		SampleFormat:    Float32,
+		FramesPerBuffer: 256,
+	}, func(s *Stream[float32]) StreamCallbackResult {
+		for i := range s.Out() {
+			s.Out()[i] = levels[i%len(levels)]
+		}
+		return Continue
+	})
+}
+
+func aggregateParams(t *testing.T) AggregateParameters {
+	t.Helper()
+	a := NewLoopback(LoopbackOptions{Name: t.Name() + " A", Channels: 2})
+	t.Cleanup(a.Close)
+	b := NewLoopback(LoopbackOptions{Name: t.Name() + " B", Channels: 1, Drift: 100})
+	t.Cleanup(b.Close)
+	playConstant(t, a.Output(), 0.25, -0.5)
+	playConstant(t, b.Output(), 0.125)
+	return AggregateParameters{
+		Inputs: []StreamDeviceParameters{
+			{Device: a.Input(), ChannelCount: 2},
+			{Device: b.Input(), ChannelCount: 1},
+		},
+		SampleRate:      48000,
+		SampleFormat:    Float32,
+		FramesPerBuffer: 256,
+	}
+}
+
+func TestOpenAggregateStreamErrors(t *testing.T) {
+	params := aggregateParams(t)
+	if _, err := OpenAggregateStream[float32](params, nil); !errors.Is(err, NullCallback) {
+		t.Errorf("nil callback: %v, want NullCallback", err)
+	}
+	callback := func(*AggregateStream[int16]) StreamCallbackResult { return Continue }
+	if _, err := OpenAggregateStream(params, callback); !errors.Is(err, SampleFormatNotSupported) {
+		t.Errorf("int16 samples on a Float32 stream: %v, want SampleFormatNotSupported", err)
+	}
+	params.Master = 2
+	if _, err := OpenAggregateStream(params, callback); !errors.Is(err, InvalidDevice) {
+		t.Errorf("master out of range: %v, want InvalidDevice", err)
+	}
+	params.Master = 0
+	params.Latency = time.Millisecond
+	if _, err := OpenAggregateStream[float32](params, func(*AggregateStream[float32]) StreamCallbackResult { return Continue }); !errors.Is(err, BufferTooBig) {
+		t.Errorf("latency of 48 frames with buffers of 256 frames: %v, want BufferTooBig", err)
+	}
+}
+
+func TestAggregateStream(t *testing.T) {
+	for _, planar := range []bool{false, true} {
+		params := aggregateParams(t)
+		params.Planar = planar
+		var frame [3]atomic.Uint32
+		a, err := OpenAggregateStream(params, func(a *AggregateStream[float32]) StreamCallbackResult {
+			for ch := range frame {
+				var v float32
+				if planar {
+					v = a.InS()[ch][a.FrameCount()-1]
+				} else {
+					v = a.In()[len(a.In())-a.ChannelCount()+ch]
+				}
+				frame[ch].Store(math.Float32bits(v))
+			}
+			return Continue
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.ChannelCount() != 3 {
+			t.Errorf("ChannelCount = %d, want 3", a.ChannelCount())
+		}
+		if err = a.Start(); err != nil {
+			t.Fatal(err)
+		}
+		want := []float32{0.25, -0.5, 0.125}
+		deadline := time.Now().Add(5 * time.Second)
+		for ch := range frame {
+			for math.Abs(float64(math.Float32frombits(frame[ch].Load())-want[ch])) > 1e-6 {
+				if time.Now().After(deadline) {
+					t.Fatalf("planar %v: channel %d = %v, want %v", planar, ch, math.Float32frombits(frame[ch].Load()), want[ch])
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+		if err = errors.Join(a.Stop(), a.Close()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+// Package alsa exposes the ALSA specific extensions of PortAudio.
+package alsa
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <stdlib.h>
+#include <portaudio.h>
+#include <pa_linux_alsa.h>
+*/
+import "C"
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/internal/hostapi"
+)
+
+// StreamInfo holds ALSA specific stream parameters. It implements portaudio.HostApiSpecificStreamInfo.
+type StreamInfo struct {
+	info *C.PaAlsaStreamInfo
+}
+
+// NewStreamInfo creates stream parameters that open the given ALSA PCM,
+// e.g. "plughw:1,0" or "null", instead of an enumerated device.
+func NewStreamInfo(deviceString string) *StreamInfo {
+	info := (*C.PaAlsaStreamInfo)(C.malloc(C.sizeof_PaAlsaStreamInfo))
+	C.PaAlsa_InitializeStreamInfo(info)
+	info.deviceString = C.CString(deviceString)
+	s := &StreamInfo{info}
+	runtime.AddCleanup(s, freeStreamInfo, info)
+	return s
+}
+
+func freeStreamInfo(info *C.PaAlsaStreamInfo) {
+	C.free(unsafe.Pointer(info.deviceString))
+	C.free(unsafe.Pointer(info))
+}
+
+// DeviceString returns the ALSA PCM name.
+func (s *StreamInfo) DeviceString() string {
+	return C.GoString(s.info.deviceString)
+}
+
+// StreamInfo implements portaudio.HostApiSpecificStreamInfo.
+func (s *StreamInfo) StreamInfo() unsafe.Pointer {
+	return unsafe.Pointer(s.info)
+}
+
+// DeviceParameters returns stream device parameters for the given ALSA PCM name.
+// The PCM does not need to be in the enumerated device list.
+func DeviceParameters(deviceString string, channelCount int, suggestedLatency time.Duration) pa.StreamDeviceParameters {
+	return pa.StreamDeviceParameters{
+		Device: &pa.DeviceInfo{
+			Index:             pa.UseHostApiSpecificDeviceSpecification,
+			Name:              deviceString,
+			MaxInputChannels:  channelCount,
+			MaxOutputChannels: channelCount,
+			HostApi:           &pa.HostApiInfo{Type: pa.ALSA, Name: "ALSA"},
+		},
+		ChannelCount:              channelCount,
+		SuggestedLatency:          suggestedLatency,
+		HostApiSpecificStreamInfo: NewStreamInfo(deviceString),
+	}
+}
+
+// EnableRealtimeScheduling instructs PortAudio to use realtime scheduling for the stream's audio thread.
+// It must be called before the stream is started.
+func EnableRealtimeScheduling[T any](s *pa.Stream[T], enable bool) error {
+	stream, err := paStream(s)
+	if err != nil {
+		return err
+	}
+	e := C.int(0)
+	if enable {
+		e = 1
+	}
+	C.PaAlsa_EnableRealtimeScheduling(stream, e)
+	return nil
+}
+
+// StreamInputCard returns the index of the ALSA card used for the input of the stream.
+func StreamInputCard[T any](s *pa.Stream[T]) (int, error) {
+	stream, err := paStream(s)
+	if err != nil {
+		return 0, err
+	}
+	var card C.int
+	if err := C.PaAlsa_GetStreamInputCard(stream, &card); err != C.paNoError {
+		return 0, hostapi.Error(int(err))
+	}
+	return int(card), nil
+}
+
+// StreamOutputCard returns the index of the ALSA card used for the output of the stream.
+func StreamOutputCard[T any](s *pa.Stream[T]) (int, error) {
+	stream, err := paStream(s)
+	if err != nil {
+		return 0, err
+	}
+	var card C.int
+	if err := C.PaAlsa_GetStreamOutputCard(stream, &card); err != C.paNoError {
+		return 0, hostapi.Error(int(err))
+	}
+	return int(card), nil
+}
+
+// paStream returns the PortAudio stream of s. Streams on virtual devices
+// have none and are reported as IncompatibleStreamHostApi.
+func paStream(s any) (unsafe.Pointer, error) {
+	stream := hostapi.Stream(s)
+	if stream == nil {
+		return nil, pa.IncompatibleStreamHostApi
+	}
+	return stream, nil
+}
+
+// SetNumPeriods sets the number of periods (buffer fragments) used for streams opened afterwards.
+func SetNumPeriods(numPeriods int) error {
+	return hostapi.Error(int(C.PaAlsa_SetNumPeriods(C.int(numPeriods))))
+}
+
+// SetRetriesBusy sets how many times opening a busy device is retried.
+func SetRetriesBusy(retries int) error {
+	return hostapi.Error(int(C.PaAlsa_SetRetriesBusy(C.int(retries))))
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package alsa
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+func TestMain(m *testing.M) {
+	if err := pa.Initialize(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = pa.Terminate()
+	os.Exit(code)
+}
+
+func TestDeviceParameters(t *testing.T) {
+	p := DeviceParameters("plughw:1,0", 2, 10*time.Millisecond)
+	if p.Device.Index != pa.UseHostApiSpecificDeviceSpecification || p.Device.Name != "plughw:1,0" {
+		t.Errorf("device = %+v", p.Device)
+	}
+	if p.Device.HostApi.Type != pa.ALSA || p.ChannelCount != 2 || p.SuggestedLatency != 10*time.Millisecond {
+		t.Errorf("parameters = %+v", p)
+	}
+	info, ok := p.HostApiSpecificStreamInfo.(*StreamInfo)
+	if !ok || info.DeviceString() != "plughw:1,0" {
+		t.Errorf("stream info = %#v", p.HostApiSpecificStreamInfo)
+	}
+}
+
+func TestVirtualStream(t *testing.T) {
+	l := pa.NewLoopback(pa.LoopbackOptions{})
+	defer l.Close()
+	s, err := pa.OpenStream[float32](&pa.StreamParameters{
+		Input:           pa.StreamDeviceParameters{Device: l.Input(), ChannelCount: 2},
+		Output:          pa.StreamDeviceParameters{Device: l.Output(), ChannelCount: 2},
+		SampleRate:      48000,
+		SampleFormat:    pa.Float32,
+		FramesPerBuffer: 256,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := EnableRealtimeScheduling(s, true); !errors.Is(err, pa.IncompatibleStreamHostApi) {
+		t.Errorf("EnableRealtimeScheduling: %v", err)
+	}
+	if _, err := StreamInputCard(s); !errors.Is(err, pa.IncompatibleStreamHostApi) {
+		t.Errorf("StreamInputCard: %v", err)
+	}
+	if _, err := StreamOutputCard(s); !errors.Is(err, pa.IncompatibleStreamHostApi) {
+		t.Errorf("StreamOutputCard: %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package audiofile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// AIFF-C compression types supported for reading and writing.
+const (
+	CompressionNone   = "NONE" // big-endian integers
+	CompressionTwos   = "twos" // big-endian integers, as NONE
+	CompressionSowt   = "sowt" // little-endian integers
+	CompressionFloat  = "fl32" // big-endian 32-bit floats
+	CompressionRaw    = "raw " // offset-binary 8-bit integers
+	compressionFloatU = "FL32"
+)
+
+// aifcVersion is the timestamp of the AIFF-C version 1 specification.
+const aifcVersion = 0xA2805140
+
+var compressionNames = map[string]string{
+	CompressionNone:  "not compressed",
+	CompressionTwos:  "",
+	CompressionSowt:  "",
+	CompressionFloat: "32-bit floating point",
+	CompressionRaw:   "",
+}
+
+// AIFF is an AIFF or AIFF-C file.
+type AIFF struct {
+	pcm
+	compression string
+	ws          io.WriteSeeker
+	header      int64 // offset of the FORM chunk in ws
+	commFrames  int64 // offset of the frame count of the COMM chunk in ws
+	closed      bool
+}
+
+// OpenAIFF opens an AIFF or AIFF-C file for reading. Files whose sound data
+// precedes the COMM chunk can only be read from an io.Seeker.
+func OpenAIFF(r io.Reader) (*AIFF, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	form := string(header[8:12])
+	if string(header[:4]) != "FORM" || form != "AIFF" && form != "AIFC" {
+		return nil, errors.New("audiofile: not an AIFF file")
+	}
+	s, seekable := r.(io.Seeker)
+	f := &AIFF{compression: CompressionNone}
+	var commSeen bool
+	var data int64 = -1
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if err == io.EOF && data >= 0 {
+				break
+			}
+			return nil, fmt.Errorf("audiofile: truncated AIFF file: %w", err)
+		}
+		id, size := string(chunk[:4]), int64(binary.BigEndian.Uint32(chunk[4:]))
+		padded := size + size&1
+		switch id {
+		case "COMM":
+			buf := make([]byte, padded)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			if err := f.parseCOMM(buf, form == "AIFC"); err != nil {
+				return nil, err
+			}
+			commSeen = true
+		case "SSND":
+			var ssnd [8]byte
+			if _, err := io.ReadFull(r, ssnd[:]); err != nil {
+				return nil, err
+			}
+			skip := int64(binary.BigEndian.Uint32(ssnd[:4]))
+			if !seekable {
+				if !commSeen {
+					return nil, errors.New("audiofile: sound data precedes COMM chunk in unseekable file")
+				}
+				if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+					return nil, err
+				}
+				f.r = r
+				return f, nil
+			}
+			pos, err := s.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			data = pos + skip
+			if _, err = s.Seek(pos-8+padded, io.SeekStart); err != nil {
+				return nil, err
+			}
+		default:
+			if seekable {
+				if _, err := s.Seek(padded, io.SeekCurrent); err != nil {
+					return nil, err
+				}
+			} else if _, err := io.CopyN(io.Discard, r, padded); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !commSeen {
+		return nil, errors.New("audiofile: AIFF file has no COMM chunk")
+	}
+	if _, err := s.Seek(data, io.SeekStart); err != nil {
+		return nil, err
+	}
+	f.r = r
+	f.start = data
+	return f, nil
+}
+
+func (f *AIFF) parseCOMM(buf []byte, aifc bool) error {
+	if len(buf) < 18 || aifc && len(buf) < 22 {
+		return errors.New("audiofile: short COMM chunk")
+	}
+	channels := int(binary.BigEndian.Uint16(buf))
+	frames := int64(binary.BigEndian.Uint32(buf[2:]))
+	bits := int(binary.BigEndian.Uint16(buf[6:]))
+	rate := decodeExtended(buf[8:18])
+	if aifc {
+		f.compression = string(buf[18:22])
+	}
+	order := binary.ByteOrder(binary.BigEndian)
+	var format pa.SampleFormat
+	switch f.compression {
+	case CompressionNone, CompressionTwos, CompressionSowt:
+		// Samples are left-justified in whole bytes, so a container of the right width scales them correctly.
+		switch (bits + 7) / 8 {
+		case 1:
+			format = pa.Int8
+		case 2:
+			format = pa.Int16
+		case 3:
+			format = pa.Int24
+		case 4:
+			format = pa.Int32
+		}
+		if f.compression == CompressionSowt {
+			order = binary.LittleEndian
+		}
+	case CompressionRaw:
+		if bits == 8 {
+			format = pa.UInt8
+		}
+	case CompressionFloat, compressionFloatU:
+		format = pa.Float32
+	default:
+		return fmt.Errorf("%w: AIFF-C compression %q", ErrFormat, f.compression)
+	}
+	p, err := newPCM(Format{SampleFormat: format, Channels: channels, SampleRate: rate}, order)
+	if err != nil {
+		return err
+	}
+	p.frames = frames
+	f.pcm = p
+	return nil
+}
+
+// CreateAIFF creates a file for writing. An empty compression type writes
+// a plain AIFF file, otherwise an AIFF-C file with one of the Compression types.
+// The chunk sizes are completed by Close.
+func CreateAIFF(w io.WriteSeeker, format Format, compression string) (*AIFF, error) {
+	order := binary.ByteOrder(binary.BigEndian)
+	var ok bool
+	switch compression {
+	case "", CompressionNone, CompressionTwos, CompressionSowt:
+		ok = format.SampleFormat != pa.Float32 && format.SampleFormat != pa.UInt8
+		if compression == CompressionSowt {
+			order = binary.LittleEndian
+		}
+	case CompressionFloat:
+		ok = format.SampleFormat == pa.Float32
+	case CompressionRaw:
+		ok = format.SampleFormat == pa.UInt8
+	default:
+		return nil, fmt.Errorf("%w: AIFF-C compression %q", ErrFormat, compression)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %v with AIFF-C compression %q", ErrFormat, format.SampleFormat, compression)
+	}
+	p, err := newPCM(format, order)
+	if err != nil {
+		return nil, err
+	}
+	p.frames = 0
+	header, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	f := &AIFF{pcm: p, compression: compression, ws: w, header: header}
+	buf := []byte("FORM\x00\x00\x00\x00AIFF")
+	comm := make([]byte, 18)
+	binary.BigEndian.PutUint16(comm, uint16(format.Channels))
+	binary.BigEndian.PutUint16(comm[6:], uint16(8*p.width))
+	encodeExtended(comm[8:], format.SampleRate)
+	if compression != "" {
+		buf = []byte("FORM\x00\x00\x00\x00AIFCFVER\x00\x00\x00\x04")
+		buf = binary.BigEndian.AppendUint32(buf, aifcVersion)
+		name := compressionNames[compression]
+		comm = append(comm, compression...)
+		comm = append(comm, byte(len(name)))
+		comm = append(comm, name...)
+		if len(name)&1 == 0 {
+			comm = append(comm, 0)
+		}
+	}
+	buf = append(buf, "COMM"...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(comm)))
+	f.commFrames = header + int64(len(buf)) + 2
+	buf = append(buf, comm...)
+	buf = append(buf, "SSND\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)
+	if _, err = w.Write(buf); err != nil {
+		return nil, err
+	}
+	f.w = w
+	f.start = header + int64(len(buf))
+	return f, nil
+}
+
+// Compression returns the AIFF-C compression type, which is CompressionNone for plain AIFF files.
+func (f *AIFF) Compression() string {
+	if f.compression == "" {
+		return CompressionNone
+	}
+	return f.compression
+}
+
+// Frames returns the number of frames of the file or written so far.
+func (f *AIFF) Frames() int64 {
+	if f.w != nil {
+		return f.pos
+	}
+	return f.frames
+}
+
+// Close pads the sound data and completes the chunk sizes of a file opened for writing.
+func (f *AIFF) Close() error {
+	if f.ws == nil || f.closed {
+		return nil
+	}
+	f.closed = true
+	data := f.pos * int64(f.format.Channels*f.width)
+	end := f.start + data
+	if data&1 != 0 {
+		if _, err := f.ws.Write([]byte{0}); err != nil {
+			return err
+		}
+		end++
+	}
+	patch := func(offset int64, v uint32) error {
+		if _, err := f.ws.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := f.ws.Write(binary.BigEndian.AppendUint32(nil, v))
+		return err
+	}
+	if err := patch(f.header+4, uint32(end-f.header-8)); err != nil {
+		return err
+	}
+	if err := patch(f.commFrames, uint32(f.pos)); err != nil {
+		return err
+	}
+	if err := patch(f.start-12, uint32(data+8)); err != nil {
+		return err
+	}
+	_, err := f.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// decodeExtended decodes an IEEE 754 80-bit extended precision number.
+func decodeExtended(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b) & 0x7fff)
+	mantissa := binary.BigEndian.Uint64(b[2:])
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	v := math.Ldexp(float64(mantissa), exponent-16383-63)
+	if b[0]&0x80 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// encodeExtended encodes a non-negative number as IEEE 754 80-bit extended precision.
+func encodeExtended(b []byte, v float64) {
+	clear(b[:10])
+	if v <= 0 {
+		return
+	}
+	frac, exp := math.Frexp(v) // v = frac * 2^exp with frac in [0.5, 1)
+	binary.BigEndian.PutUint16(b, uint16(exp-1+16383))
+	binary.BigEndian.PutUint64(b[2:], uint64(math.Ldexp(frac, 64)))
+}
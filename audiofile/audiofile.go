@@ -0,0 +1,291 @@
+// Package audiofile reads and writes audio files behind a common AudioFile interface:
+// AIFF, AIFF-C, headerless raw PCM and FLAC.
+//
+// Samples are exchanged as interleaved float64 values where the full scale of
+// integer formats maps to [-1, 1), which is exact for every supported format.
+// Source and RecordStream connect files to portaudio streams of any sample type.
+package audiofile
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Errors returned for unsupported or malformed files.
+var (
+	ErrFormat      = errors.New("audiofile: unsupported sample format")
+	ErrNotSeekable = errors.New("audiofile: file is not seekable")
+	ErrReadOnly    = errors.New("audiofile: file is opened for reading")
+	ErrWriteOnly   = errors.New("audiofile: file is opened for writing")
+)
+
+// Format describes the samples of a file.
+type Format struct {
+	// SampleFormat is one of Int8, UInt8, Int16, Int24, Int32 and Float32, interleaved.
+	SampleFormat pa.SampleFormat
+	Channels     int
+	SampleRate   float64
+}
+
+// AudioFile is an audio file opened either for reading or for writing.
+type AudioFile interface {
+	Format() Format
+	// Frames returns the number of frames, or -1 if it is unknown.
+	Frames() int64
+	// ReadFrames decodes interleaved samples into buf and returns their number,
+	// a multiple of the channel count. It returns io.EOF at the end of the file.
+	ReadFrames(buf []float64) (int, error)
+	// WriteFrames encodes interleaved samples. Their number must be a multiple of the channel count.
+	WriteFrames(buf []float64) error
+	// SeekFrame moves to the given frame of a file opened for reading.
+	SeekFrame(frame int64) error
+	// Close completes a file opened for writing. It does not close the underlying file.
+	Close() error
+}
+
+// sampleWidth returns the size of a sample in bytes, or zero if the format is not supported.
+func sampleWidth(format pa.SampleFormat) int {
+	switch format {
+	case pa.Float32, pa.Int32:
+		return 4
+	case pa.Int24:
+		return 3
+	case pa.Int16:
+		return 2
+	case pa.Int8, pa.UInt8:
+		return 1
+	}
+	return 0
+}
+
+// pcm reads and writes interleaved PCM samples in a region of a file.
+type pcm struct {
+	format Format
+	order  binary.ByteOrder
+	width  int
+	r      io.Reader
+	w      io.Writer
+	start  int64 // offset of the first frame, if the file is seekable
+	frames int64 // number of frames, -1 if unknown
+	pos    int64 // current frame
+	buf    []byte
+}
+
+func newPCM(format Format, order binary.ByteOrder) (pcm, error) {
+	width := sampleWidth(format.SampleFormat)
+	if width == 0 || format.Channels <= 0 {
+		return pcm{}, ErrFormat
+	}
+	return pcm{format: format, order: order, width: width, frames: -1}, nil
+}
+
+func (p *pcm) Format() Format {
+	return p.format
+}
+
+func (p *pcm) Frames() int64 {
+	return p.frames
+}
+
+func (p *pcm) ReadFrames(buf []float64) (int, error) {
+	if p.r == nil {
+		return 0, ErrWriteOnly
+	}
+	channels := p.format.Channels
+	frames := len(buf) / channels
+	if p.frames >= 0 {
+		frames = int(min(int64(frames), p.frames-p.pos))
+		if frames == 0 && len(buf) >= channels {
+			return 0, io.EOF
+		}
+	}
+	frameSize := channels * p.width
+	p.buf = resize(p.buf, frames*frameSize)
+	n, err := io.ReadFull(p.r, p.buf)
+	frames = n / frameSize
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	for i := range frames * channels {
+		buf[i] = p.decode(p.buf[i*p.width:])
+	}
+	p.pos += int64(frames)
+	return frames * channels, err
+}
+
+func (p *pcm) WriteFrames(buf []float64) error {
+	if p.w == nil {
+		return ErrReadOnly
+	}
+	if len(buf)%p.format.Channels != 0 {
+		return errors.New("audiofile: samples are not a multiple of the channel count")
+	}
+	p.buf = resize(p.buf, len(buf)*p.width)
+	for i, v := range buf {
+		p.encode(p.buf[i*p.width:], v)
+	}
+	if _, err := p.w.Write(p.buf); err != nil {
+		return err
+	}
+	p.pos += int64(len(buf) / p.format.Channels)
+	return nil
+}
+
+func (p *pcm) SeekFrame(frame int64) error {
+	s, ok := p.r.(io.Seeker)
+	if !ok {
+		if p.r == nil {
+			return ErrWriteOnly
+		}
+		return ErrNotSeekable
+	}
+	if frame < 0 || p.frames >= 0 && frame > p.frames {
+		return errors.New("audiofile: seek out of range")
+	}
+	if _, err := s.Seek(p.start+frame*int64(p.format.Channels*p.width), io.SeekStart); err != nil {
+		return err
+	}
+	p.pos = frame
+	return nil
+}
+
+// decode decodes a sample at the beginning of b.
+func (p *pcm) decode(b []byte) float64 {
+	switch p.format.SampleFormat {
+	case pa.Float32:
+		return float64(math.Float32frombits(p.order.Uint32(b)))
+	case pa.Int32:
+		return pa.ToFloat64(int32(p.order.Uint32(b)))
+	case pa.Int24:
+		var v uint32
+		if p.order == binary.BigEndian {
+			v = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8
+		} else {
+			v = uint32(b[2])<<24 | uint32(b[1])<<16 | uint32(b[0])<<8
+		}
+		return pa.ToFloat64(int32(v))
+	case pa.Int16:
+		return pa.ToFloat64(int16(p.order.Uint16(b)))
+	case pa.Int8:
+		return pa.ToFloat64(int8(b[0]))
+	default:
+		return pa.ToFloat64(b[0])
+	}
+}
+
+// encode encodes a sample at the beginning of b.
+func (p *pcm) encode(b []byte, v float64) {
+	switch p.format.SampleFormat {
+	case pa.Float32:
+		p.order.PutUint32(b, math.Float32bits(float32(v)))
+	case pa.Int32:
+		p.order.PutUint32(b, uint32(pa.FromFloat64[int32](v)))
+	case pa.Int24:
+		x := uint32(pa.FromFloat64[int32](v))
+		if p.order == binary.BigEndian {
+			b[0], b[1], b[2] = byte(x>>24), byte(x>>16), byte(x>>8)
+		} else {
+			b[0], b[1], b[2] = byte(x>>8), byte(x>>16), byte(x>>24)
+		}
+	case pa.Int16:
+		p.order.PutUint16(b, uint16(pa.FromFloat64[int16](v)))
+	case pa.Int8:
+		b[0] = byte(pa.FromFloat64[int8](v))
+	default:
+		b[0] = pa.FromFloat64[uint8](v)
+	}
+}
+
+// Source reads an audio file as a portaudio.Source of any sample type,
+// e.g. to play it from an output stream callback.
+type Source[T pa.Sample] struct {
+	f   AudioFile
+	buf []float64
+}
+
+// NewSource creates a source reading f.
+func NewSource[T pa.Sample](f AudioFile) *Source[T] {
+	return &Source[T]{f: f}
+}
+
+// ReadSamples decodes interleaved samples into buf. It returns io.EOF at the end of the file.
+func (s *Source[T]) ReadSamples(buf []T) (int, error) {
+	s.buf = resize(s.buf, len(buf))
+	n, err := s.f.ReadFrames(s.buf)
+	for i, v := range s.buf[:n] {
+		buf[i] = pa.FromFloat64[T](v)
+	}
+	return n, err
+}
+
+// WriteSamples encodes interleaved samples of any sample type into f.
+func WriteSamples[T pa.Sample](f AudioFile, buf []T) error {
+	samples := make([]float64, len(buf))
+	for i, v := range buf {
+		samples[i] = pa.ToFloat64(v)
+	}
+	return f.WriteFrames(samples)
+}
+
+// RecordStream reads frames frames from a blocking input stream and writes them to f.
+// Transient errors such as input overflows are ignored, so an overflow leaves a gap in the recording.
+func RecordStream[T pa.Sample](s *pa.Stream[T], f AudioFile, frames int) error {
+	channels := f.Format().Channels
+	var samples []float64
+	for frames > 0 {
+		buf, err := s.Read()
+		if err != nil {
+			if pa.IsTransient(err) {
+				continue
+			}
+			return err
+		}
+		if len(buf) == 0 {
+			return errors.New("audiofile: stream returned no samples")
+		}
+		n := min(len(buf), frames*channels)
+		samples = resize(samples, n)
+		for i, v := range buf[:n] {
+			samples[i] = pa.ToFloat64(v)
+		}
+		if err = f.WriteFrames(samples); err != nil {
+			return err
+		}
+		frames -= n / channels
+	}
+	return nil
+}
+
+// resize returns buf with the given length, reallocating it only if its capacity is too small.
+func resize[T any](buf []T, size int) []T {
+	if cap(buf) < size {
+		return make([]T, size)
+	}
+	return buf[:size]
+}
+
+// Open detects the type of a FLAC, AIFF or AIFF-C file and opens it for reading.
+func Open(r io.ReadSeeker) (AudioFile, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	var magic [4]byte
+	if _, err = io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch string(magic[:]) {
+	case "fLaC":
+		return OpenFLAC(r)
+	case "FORM":
+		return OpenAIFF(r)
+	}
+	return nil, errors.New("audiofile: unknown file type")
+}
@@ -0,0 +1,360 @@
+package audiofile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// testSamples returns interleaved samples exact in every sample format.
+func testSamples(frames, channels int) []float64 {
+	x := make([]float64, frames*channels)
+	for i := range x {
+		x[i] = float64(i*37%256-128) / 128
+	}
+	return x
+}
+
+// create returns a new file in the test's temporary directory.
+func create(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func write(t *testing.T, f AudioFile, samples []float64) {
+	t.Helper()
+	// Write in pieces to cover appending.
+	for len(samples) > 0 {
+		n := min(len(samples), 99*f.Format().Channels)
+		if err := f.WriteFrames(samples[:n]); err != nil {
+			t.Fatal(err)
+		}
+		samples = samples[n:]
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAll(t *testing.T, f AudioFile) []float64 {
+	t.Helper()
+	var out []float64
+	buf := make([]float64, 64*f.Format().Channels)
+	for {
+		n, err := f.ReadFrames(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAIFFRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		compression string
+		format      pa.SampleFormat
+	}{
+		{"", pa.Int8},
+		{"", pa.Int16},
+		{"", pa.Int24},
+		{"", pa.Int32},
+		{CompressionNone, pa.Int16},
+		{CompressionTwos, pa.Int24},
+		{CompressionSowt, pa.Int16},
+		{CompressionSowt, pa.Int24},
+		{CompressionSowt, pa.Int32},
+		{CompressionFloat, pa.Float32},
+		{CompressionRaw, pa.UInt8},
+	} {
+		t.Run(test.compression+test.format.String(), func(t *testing.T) {
+			format := Format{SampleFormat: test.format, Channels: 3, SampleRate: 44100}
+			// An odd number of frames makes 8-bit sound data odd-sized and padded.
+			samples := testSamples(1001, 3)
+			file := create(t)
+			w, err := CreateAIFF(file, format, test.compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(t, w, samples)
+			if w.Frames() != 1001 {
+				t.Errorf("wrote %d frames, want 1001", w.Frames())
+			}
+			size, _ := file.Seek(0, io.SeekEnd)
+			if size%2 != 0 {
+				t.Errorf("file size %d is odd", size)
+			}
+
+			if _, err = file.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			f, err := Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := f.(*AIFF)
+			if r.Format() != format {
+				t.Errorf("format %+v, want %+v", r.Format(), format)
+			}
+			if want := test.compression; want != "" && r.Compression() != want ||
+				want == "" && r.Compression() != CompressionNone {
+				t.Errorf("compression %q, want %q", r.Compression(), want)
+			}
+			if r.Frames() != 1001 {
+				t.Errorf("read %d frames from COMM, want 1001", r.Frames())
+			}
+			if got := readAll(t, r); !slices.Equal(got, samples) {
+				t.Fatal("samples differ")
+			}
+			if err = r.SeekFrame(500); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]float64, 6)
+			if _, err = r.ReadFrames(buf); err != nil {
+				t.Fatal(err)
+			}
+			if want := samples[1500:1506]; !slices.Equal(buf, want) {
+				t.Errorf("after seeking read %v, want %v", buf, want)
+			}
+		})
+	}
+}
+
+func TestAIFFLayout(t *testing.T) {
+	file := create(t)
+	w, err := CreateAIFF(file, Format{SampleFormat: pa.Int16, Channels: 1, SampleRate: 44100}, CompressionSowt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, []float64{0.5, -0.25})
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("FORM\x00\x00\x00\x44AIFCFVER\x00\x00\x00\x04\xa2\x80\x51\x40COMM\x00\x00\x00\x18" +
+		"\x00\x01\x00\x00\x00\x02\x00\x10" +
+		"\x40\x0e\xac\x44\x00\x00\x00\x00\x00\x00" + // 44100 as 80-bit extended
+		"sowt\x00\x00" +
+		"SSND\x00\x00\x00\x0c\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x40\x00\xe0") // little-endian samples
+	if !bytes.Equal(data, want) {
+		t.Errorf("got\n% x\nwant\n% x", data, want)
+	}
+}
+
+// aiffChunk returns a chunk padded to an even size.
+func aiffChunk(id string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32([]byte(id), uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestAIFFChunkOrder(t *testing.T) {
+	comm := []byte("\x00\x01\x00\x00\x00\x03\x00\x08\x40\x0b\xfa\x00\x00\x00\x00\x00\x00\x00") // mono, 3 frames, 8 bits, 8000Hz
+	ssnd := []byte("\x00\x00\x00\x02\x00\x00\x00\x00\xff\xff\x40\x80\xc0")                     // offset 2
+	var body []byte
+	body = append(body, "AIFF"...)
+	body = append(body, aiffChunk("NAME", []byte("odd"))...)
+	body = append(body, aiffChunk("SSND", ssnd)...)
+	body = append(body, aiffChunk("COMM", comm)...)
+	file := aiffChunk("FORM", body)
+	want := []float64{0.5, -1, -0.5}
+
+	f, err := OpenAIFF(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := f.Format(); format.SampleRate != 8000 || format.SampleFormat != pa.Int8 {
+		t.Errorf("format %+v", format)
+	}
+	if got := readAll(t, f); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Without seeking, the COMM chunk must come first.
+	if _, err = OpenAIFF(io.MultiReader(bytes.NewReader(file))); err == nil {
+		t.Error("opened an unseekable file with the sound data before COMM")
+	}
+	body = append(append([]byte("AIFF"), aiffChunk("COMM", comm)...), aiffChunk("SSND", ssnd)...)
+	f, err = OpenAIFF(io.MultiReader(bytes.NewReader(aiffChunk("FORM", body))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, f); !slices.Equal(got, want) {
+		t.Errorf("unseekable: got %v, want %v", got, want)
+	}
+	if err = f.SeekFrame(0); !errors.Is(err, ErrNotSeekable) {
+		t.Errorf("SeekFrame: got %v, want %v", err, ErrNotSeekable)
+	}
+}
+
+func TestCreateAIFFErrors(t *testing.T) {
+	for _, test := range []struct {
+		compression string
+		format      pa.SampleFormat
+	}{
+		{"", pa.Float32},
+		{"", pa.UInt8},
+		{CompressionFloat, pa.Int16},
+		{CompressionRaw, pa.Int8},
+		{"ulaw", pa.Int16},
+	} {
+		_, err := CreateAIFF(create(t), Format{SampleFormat: test.format, Channels: 1, SampleRate: 8000}, test.compression)
+		if !errors.Is(err, ErrFormat) {
+			t.Errorf("%q %v: got %v, want %v", test.compression, test.format, err, ErrFormat)
+		}
+	}
+}
+
+func TestRawRoundTrip(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, format := range []pa.SampleFormat{pa.Int8, pa.UInt8, pa.Int16, pa.Int24, pa.Int32, pa.Float32} {
+			t.Run(order.String()+format.String(), func(t *testing.T) {
+				f := Format{SampleFormat: format, Channels: 2, SampleRate: 48000}
+				samples := testSamples(300, 2)
+				var buf bytes.Buffer
+				w, err := CreateRaw(&buf, f, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				write(t, w, samples)
+				if want := 600 * sampleWidth(format); buf.Len() != want {
+					t.Errorf("wrote %d bytes, want %d", buf.Len(), want)
+				}
+				r, err := OpenRaw(bytes.NewReader(buf.Bytes()), f, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.Frames() != 300 {
+					t.Errorf("%d frames, want 300", r.Frames())
+				}
+				if got := readAll(t, r); !slices.Equal(got, samples) {
+					t.Fatal("samples differ")
+				}
+				// Without seeking, the length is unknown.
+				r, err = OpenRaw(io.MultiReader(bytes.NewReader(buf.Bytes())), f, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readAll(t, r); r.Frames() != -1 || !slices.Equal(got, samples) {
+					t.Errorf("unseekable: %d frames, samples equal %v", r.Frames(), slices.Equal(got, samples))
+				}
+			})
+		}
+	}
+}
+
+func TestRawByteOrder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := CreateRaw(&buf, Format{SampleFormat: pa.Int24, Channels: 1, SampleRate: 8000}, binary.BigEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, []float64{0.5, -1})
+	if want := []byte{0x40, 0, 0, 0x80, 0, 0}; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got % x, want % x", buf.Bytes(), want)
+	}
+	if err = w.SeekFrame(0); !errors.Is(err, ErrWriteOnly) {
+		t.Errorf("SeekFrame: got %v, want %v", err, ErrWriteOnly)
+	}
+	if _, err = w.ReadFrames(make([]float64, 1)); !errors.Is(err, ErrWriteOnly) {
+		t.Errorf("ReadFrames: got %v, want %v", err, ErrWriteOnly)
+	}
+}
+
+func TestFLACRoundTrip(t *testing.T) {
+	for _, format := range []pa.SampleFormat{pa.Int16, pa.Int24} {
+		t.Run(format.String(), func(t *testing.T) {
+			f := Format{SampleFormat: format, Channels: 2, SampleRate: 48000}
+			samples := testSamples(5000, 2)
+			file := create(t)
+			w, err := CreateFLAC(file, f, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(t, w, samples)
+			if _, err = file.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Format() != f || r.Frames() != 5000 {
+				t.Errorf("format %+v with %d frames, want %+v with 5000", r.Format(), r.Frames(), f)
+			}
+			if got := readAll(t, r); !slices.Equal(got, samples) {
+				t.Fatal("samples differ")
+			}
+			if err = r.SeekFrame(4000); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]float64, 2)
+			if _, err = r.ReadFrames(buf); err != nil {
+				t.Fatal(err)
+			}
+			if want := samples[8000:8002]; !slices.Equal(buf, want) {
+				t.Errorf("after seeking read %v, want %v", buf, want)
+			}
+		})
+	}
+	if _, err := CreateFLAC(io.Discard, Format{SampleFormat: pa.Float32, Channels: 1, SampleRate: 8000}, nil); !errors.Is(err, ErrFormat) {
+		t.Errorf("Float32: got %v, want %v", err, ErrFormat)
+	}
+}
+
+func TestSource(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := CreateRaw(&buf, Format{SampleFormat: pa.Int16, Channels: 1, SampleRate: 8000}, binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteSamples(w, []int16{0, 16384, -32768}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRaw(&buf, w.Format(), binary.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]float32, 4)
+	n, err := NewSource[float32](r).ReadSamples(got)
+	if n != 3 || err != io.EOF {
+		t.Fatalf("read %d samples, %v", n, err)
+	}
+	if want := []float32{0, 0.5, -1}; !slices.Equal(got[:n], want) {
+		t.Errorf("got %v, want %v", got[:n], want)
+	}
+}
+
+func TestOpenUnknown(t *testing.T) {
+	if _, err := Open(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE"))); err == nil {
+		t.Error("opened a WAVE file")
+	}
+}
+
+func TestExtended(t *testing.T) {
+	for _, v := range []float64{0, 1, 8000, 22050, 44100, 48000, 96000, 12345.678} {
+		var b [10]byte
+		encodeExtended(b[:], v)
+		if got := decodeExtended(b[:]); got != v {
+			t.Errorf("%v decodes as %v", v, got)
+		}
+	}
+}
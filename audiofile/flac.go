@@ -0,0 +1,135 @@
+package audiofile
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/flac"
+)
+
+// FLAC adapts a flac.Decoder or flac.Encoder to the AudioFile interface.
+type FLAC struct {
+	d      *flac.Decoder
+	e      *flac.Encoder
+	format Format
+	frames int64
+	scale  float64
+	buf    []int32
+}
+
+// OpenFLAC opens a FLAC stream for reading. SeekFrame requires r to be an io.Seeker.
+func OpenFLAC(r io.Reader) (*FLAC, error) {
+	d, err := flac.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	info := d.Info()
+	f := &FLAC{
+		d:      d,
+		format: Format{SampleFormat: flacFormat(info.BitsPerSample), Channels: info.Channels, SampleRate: float64(info.SampleRate)},
+		frames: int64(info.TotalSamples),
+		scale:  math.Ldexp(1, 1-info.BitsPerSample),
+	}
+	if info.TotalSamples == 0 {
+		f.frames = -1
+	}
+	return f, nil
+}
+
+// CreateFLAC creates a FLAC stream for writing with the bit depth of the format,
+// which must be an integer format.
+func CreateFLAC(w io.Writer, format Format, comments *flac.Comments) (*FLAC, error) {
+	bits := 8 * sampleWidth(format.SampleFormat)
+	if bits == 0 || format.SampleFormat == pa.Float32 || format.SampleFormat == pa.UInt8 {
+		return nil, ErrFormat
+	}
+	e, err := flac.NewEncoder(w, flac.EncoderOptions{
+		SampleRate:    int(format.SampleRate),
+		Channels:      format.Channels,
+		BitsPerSample: bits,
+		Comments:      comments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FLAC{e: e, format: format}, nil
+}
+
+// flacFormat returns the narrowest sample format holding samples of the given bit depth.
+func flacFormat(bits int) pa.SampleFormat {
+	switch {
+	case bits <= 8:
+		return pa.Int8
+	case bits <= 16:
+		return pa.Int16
+	case bits <= 24:
+		return pa.Int24
+	}
+	return pa.Int32
+}
+
+// Decoder returns the underlying decoder, or nil for a file opened for writing.
+func (f *FLAC) Decoder() *flac.Decoder {
+	return f.d
+}
+
+func (f *FLAC) Format() Format {
+	return f.format
+}
+
+// Frames returns the number of frames of the file or written so far.
+func (f *FLAC) Frames() int64 {
+	return f.frames
+}
+
+func (f *FLAC) ReadFrames(buf []float64) (int, error) {
+	if f.d == nil {
+		return 0, ErrWriteOnly
+	}
+	f.buf = resize(f.buf, len(buf))
+	n, err := f.d.Read(f.buf)
+	for i, v := range f.buf[:n] {
+		buf[i] = float64(v) * f.scale
+	}
+	return n, err
+}
+
+func (f *FLAC) WriteFrames(buf []float64) error {
+	if f.e == nil {
+		return ErrReadOnly
+	}
+	if len(buf)%f.format.Channels != 0 {
+		return errors.New("audiofile: samples are not a multiple of the channel count")
+	}
+	if err := flac.WriteSamples(f.e, buf); err != nil {
+		return err
+	}
+	f.frames += int64(len(buf) / f.format.Channels)
+	return nil
+}
+
+func (f *FLAC) SeekFrame(frame int64) error {
+	if f.d == nil {
+		return ErrWriteOnly
+	}
+	if frame < 0 {
+		return errors.New("audiofile: seek out of range")
+	}
+	if err := f.d.Seek(uint64(frame)); err != nil {
+		if errors.Is(err, flac.ErrNotSeekable) {
+			return ErrNotSeekable
+		}
+		return err
+	}
+	return nil
+}
+
+// Close flushes a stream opened for writing.
+func (f *FLAC) Close() error {
+	if f.e == nil {
+		return nil
+	}
+	return f.e.Close()
+}
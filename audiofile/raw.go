@@ -0,0 +1,61 @@
+package audiofile
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Raw is a headerless PCM file whose layout is given by a Format and a byte order.
+type Raw struct {
+	pcm
+}
+
+// OpenRaw opens raw PCM samples for reading. If r is an io.Seeker,
+// the number of frames is derived from its size and SeekFrame can be used.
+func OpenRaw(r io.Reader, format Format, order binary.ByteOrder) (*Raw, error) {
+	p, err := newPCM(format, order)
+	if err != nil {
+		return nil, err
+	}
+	p.r = r
+	if s, ok := r.(io.Seeker); ok {
+		start, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = s.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		p.start = start
+		p.frames = (end - start) / int64(format.Channels*p.width)
+	}
+	return &Raw{p}, nil
+}
+
+// CreateRaw creates raw PCM samples for writing.
+func CreateRaw(w io.Writer, format Format, order binary.ByteOrder) (*Raw, error) {
+	p, err := newPCM(format, order)
+	if err != nil {
+		return nil, err
+	}
+	p.w = w
+	p.frames = 0
+	return &Raw{p}, nil
+}
+
+// Frames returns the number of frames read from a file of unknown length
+// or written so far.
+func (f *Raw) Frames() int64 {
+	if f.w != nil {
+		return f.pos
+	}
+	return f.frames
+}
+
+func (f *Raw) Close() error {
+	return nil
+}
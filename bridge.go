@@ -0,0 +1,203 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// BridgeOptions configures a DuplexBridge.
+type BridgeOptions struct {
+	// Latency is the amount of audio buffered between the input and the output stream.
+	// Zero selects 20ms.
+	Latency time.Duration
+}
+
+// DuplexBridge connects the input of one device to the output of another device
+// through two independent streams. The clock drift between the devices is estimated
+// from the callback timestamps and the buffer fill level and compensated by
+// resampling, so that the latency of the path stays constant.
+type DuplexBridge[T Sample] struct {
+	input, output *Stream[T]
+	channels      int
+	ring          *ringBuffer[T]
+	resampler     *resampler
+	controller    driftController
+	inRate        rateEstimator
+	outRate       rateEstimator
+	frame         []T
+	mix           []float64
+	next          func([]float64) bool
+	primed        bool
+	ratio         atomic.Uint64
+	underruns     atomic.Uint64
+	overruns      atomic.Uint64
+	// Process, if set before Start, is called from the output callback
+	// with the interleaved frames about to be played.
+	Process func(out []T)
+}
+
+// OpenDuplexBridge opens an input stream on params.Input and an output stream on params.Output
+// and bridges them. Both devices must use the same channel count and interleaved samples
+// in the format of T, or Float32 if T is float64.
+func OpenDuplexBridge[T Sample](params *StreamParameters, options BridgeOptions) (*DuplexBridge[T], error) {
+	if !params.Input.Exists() || !params.Output.Exists() || params.Input.ChannelCount != params.Output.ChannelCount {
+		return nil, streamError("open bridge", params.device(), params, InvalidChannelCount)
+	}
+	if params.SampleFormat != sampleFormatOf[T]() || params.RawOutput {
+		return nil, streamError("open bridge", params.device(), params, SampleFormatNotSupported)
+	}
+	if options.Latency <= 0 {
+		options.Latency = 20 * time.Millisecond
+	}
+	channels := params.Input.ChannelCount
+	target := math.Ceil(options.Latency.Seconds() * params.SampleRate)
+	b := &DuplexBridge[T]{
+		channels:   channels,
+		ring:       newRingBuffer[T](4 * int(target) * channels),
+		resampler:  newResampler(channels),
+		controller: driftController{target: target},
+		frame:      make([]T, channels),
+	}
+	b.next = b.nextFrame
+	b.ratio.Store(math.Float64bits(1))
+	inParams, outParams := *params, *params
+	inParams.Output = StreamDeviceParameters{}
+	outParams.Input = StreamDeviceParameters{}
+	var err error
+	if b.input, err = OpenStream(&inParams, b.processInput, nil); err != nil {
+		return nil, err
+	}
+	if b.output, err = OpenStream(&outParams, b.processOutput, nil); err != nil {
+		_ = b.input.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Start starts the output and the input stream.
+func (b *DuplexBridge[T]) Start() error {
+	if err := b.output.Start(); err != nil {
+		return err
+	}
+	if err := b.input.Start(); err != nil {
+		_ = b.output.Abort()
+		return err
+	}
+	return nil
+}
+
+// Stop stops both streams.
+func (b *DuplexBridge[T]) Stop() error {
+	return errors.Join(b.input.Stop(), b.output.Stop())
+}
+
+// Close closes both streams.
+func (b *DuplexBridge[T]) Close() error {
+	return errors.Join(b.input.Close(), b.output.Close())
+}
+
+// Input returns the input stream.
+func (b *DuplexBridge[T]) Input() *Stream[T] {
+	return b.input
+}
+
+// Output returns the output stream.
+func (b *DuplexBridge[T]) Output() *Stream[T] {
+	return b.output
+}
+
+// Ratio returns the current resampling ratio, i.e. the number of input frames per output frame.
+func (b *DuplexBridge[T]) Ratio() float64 {
+	return math.Float64frombits(b.ratio.Load())
+}
+
+// Drift returns the estimated clock drift of the input device
+// relative to the output device in parts per million, or 0 if it is not known yet.
+func (b *DuplexBridge[T]) Drift() float64 {
+	in, out := b.inRate.value(), b.outRate.value()
+	if in == 0 || out == 0 {
+		return 0
+	}
+	return (in/out - 1) * 1e6
+}
+
+// Buffered returns the number of frames buffered between the streams.
+func (b *DuplexBridge[T]) Buffered() int {
+	return b.ring.len() / b.channels
+}
+
+// Underruns returns how many times the output ran out of buffered input.
+func (b *DuplexBridge[T]) Underruns() uint64 {
+	return b.underruns.Load()
+}
+
+// Overruns returns how many times input was dropped because the buffer was full.
+func (b *DuplexBridge[T]) Overruns() uint64 {
+	return b.overruns.Load()
+}
+
+func (b *DuplexBridge[T]) processInput(s *Stream[T]) StreamCallbackResult {
+	b.inRate.update(callbackTime(s.TimeInfo().InputBufferAdcTime, s), s.FrameCount())
+	in := s.In()
+	n := min(len(in), b.ring.free()/b.channels*b.channels)
+	if b.ring.write(in[:n]) < len(in) {
+		b.overruns.Add(1)
+	}
+	return Continue
+}
+
+func (b *DuplexBridge[T]) processOutput(s *Stream[T]) StreamCallbackResult {
+	frames := s.FrameCount()
+	b.outRate.update(callbackTime(s.TimeInfo().OutputBufferDacTime, s), frames)
+	out := s.Out()
+	fill := b.ring.len() / b.channels
+	if !b.primed {
+		if fill < int(b.controller.target) {
+			clear(out)
+			return Continue
+		}
+		b.primed = true
+		b.controller.reset(fill)
+		b.resampler.reset()
+	}
+	drift := 0.0
+	if in, outRate := b.inRate.value(), b.outRate.value(); in != 0 && outRate != 0 {
+		drift = in / outRate
+	}
+	ratio := b.controller.ratio(drift, fill, float64(frames)/s.params.SampleRate)
+	b.ratio.Store(math.Float64bits(ratio))
+	b.mix = resize(b.mix, len(out))
+	if b.resampler.process(b.mix, ratio, b.next) > 0 {
+		b.underruns.Add(1)
+		b.primed = false
+	}
+	for i, v := range b.mix {
+		out[i] = FromFloat64[T](v)
+	}
+	if b.Process != nil {
+		b.Process(out)
+	}
+	return Continue
+}
+
+// nextFrame feeds the resampler with the next buffered input frame.
+func (b *DuplexBridge[T]) nextFrame(frame []float64) bool {
+	if b.ring.read(b.frame) < b.channels {
+		return false
+	}
+	for i, v := range b.frame {
+		frame[i] = ToFloat64(v)
+	}
+	return true
+}
+
+// callbackTime returns the given callback timestamp or, if the host API
+// does not provide it, the current time of the stream.
+func callbackTime[T any](t time.Duration, s *Stream[T]) time.Duration {
+	if t != 0 {
+		return t
+	}
+	return s.TimeInfo().CurrentTime
+}
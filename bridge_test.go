@@ -0,0 +1,155 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSampleFormatOf(t *testing.T) {
+	for _, tt := range []struct {
+		got, want SampleFormat
+	}{
+		{sampleFormatOf[int8](), Int8},
+		{sampleFormatOf[uint8](), UInt8},
+		{sampleFormatOf[int16](), Int16},
+		{sampleFormatOf[int32](), Int32},
+		{sampleFormatOf[float32](), Float32},
+		{sampleFormatOf[float64](), Float32},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("sampleFormatOf = %v, want %v", tt.got, tt.want)
+		}
+	}
+}
+
+func TestResampler(t *testing.T) {
+	ramp := func() func([]float64) bool {
+		n := 0.0
+		return func(frame []float64) bool {
+			frame[0], frame[1] = n, -n
+			n++
+			return true
+		}
+	}
+	for _, ratio := range []float64{1, 0.5, 1.25} {
+		r := newResampler(2)
+		out := make([]float64, 2*64)
+		if missing := r.process(out, ratio, ramp()); missing != 0 {
+			t.Errorf("ratio %v: %d frames missing", ratio, missing)
+		}
+		// Cubic interpolation reproduces a linear ramp, apart from the first
+		// frames which are interpolated with the cleared history.
+		for f := 4; f < 64; f++ {
+			want := float64(f) * ratio
+			if math.Abs(out[2*f]-want) > 1e-9 || math.Abs(out[2*f+1]+want) > 1e-9 {
+				t.Fatalf("ratio %v: frame %d = %v, %v, want %v", ratio, f, out[2*f], out[2*f+1], want)
+			}
+		}
+	}
+	r := newResampler(1)
+	out := make([]float64, 8)
+	held := 0
+	missing := r.process(out, 1, func(frame []float64) bool {
+		if held == 3 {
+			return false
+		}
+		held++
+		frame[0] = float64(held)
+		return true
+	})
+	// The first output frame takes 3 input frames, each further one takes 1.
+	if missing != 7 || out[7] != 3 {
+		t.Errorf("missing = %d, last = %v, want 7 missing frames and the last frame held", missing, out[7])
+	}
+}
+
+func TestDriftController(t *testing.T) {
+	const (
+		target = 960
+		frames = 256
+		dt     = frames / 48000.0
+		drift  = 1 + 500e-6
+	)
+	for _, known := range []bool{true, false} {
+		d := driftController{target: target}
+		d.reset(target)
+		fill := float64(target)
+		for i := range int(120 / dt) {
+			fill += frames * drift
+			estimate := 0.0
+			if known {
+				estimate = drift
+			}
+			if i > int(100/dt) && math.Abs(fill-target) > 0.01*target {
+				t.Fatalf("known drift %v: fill %v after %v", known, fill, time.Duration(float64(i)*dt*float64(time.Second)))
+			}
+			fill -= frames * d.ratio(estimate, int(fill), dt)
+		}
+	}
+}
+
+func TestDuplexBridgeSampleFormat(t *testing.T) {
+	params := loopbackParams(t, Float32, 2)
+	if _, err := OpenDuplexBridge[int16](params, BridgeOptions{}); !errors.Is(err, SampleFormatNotSupported) {
+		t.Errorf("int16 samples on a Float32 stream: %v", err)
+	}
+	params.SampleFormat = Float32 | NonInterleaved
+	if _, err := OpenDuplexBridge[float32](params, BridgeOptions{}); !errors.Is(err, SampleFormatNotSupported) {
+		t.Errorf("NonInterleaved stream: %v", err)
+	}
+	params.SampleFormat = Int16
+	b, err := OpenDuplexBridge[int16](params, BridgeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Close()
+}
+
+func TestDuplexBridge(t *testing.T) {
+	source := NewLoopback(LoopbackOptions{Name: "BridgeSource", Channels: 1, Drift: 200})
+	defer source.Close()
+	sink := NewLoopback(LoopbackOptions{Name: "BridgeSink", Channels: 1})
+	defer sink.Close()
+	mono := func(in, out *DeviceInfo) *StreamParameters {
+		p := &StreamParameters{SampleRate: 48000, SampleFormat: Float32, FramesPerBuffer: 256}
+		if in != nil {
+			p.Input = StreamDeviceParameters{Device: in, ChannelCount: 1}
+		}
+		if out != nil {
+			p.Output = StreamDeviceParameters{Device: out, ChannelCount: 1}
+		}
+		return p
+	}
+	openTestStream(t, mono(nil, source.Output()), func(s *Stream[float32]) StreamCallbackResult {
+		for i := range s.Out() {
+			s.Out()[i] = 0.25
+		}
+		return Continue
+	})
+	b, err := OpenDuplexBridge[float32](mono(source.Input(), sink.Output()), BridgeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err = b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var last atomic.Uint32
+	openTestStream(t, mono(sink.Input(), nil), func(s *Stream[float32]) StreamCallbackResult {
+		last.Store(math.Float32bits(s.In()[len(s.In())-1]))
+		return Continue
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for math.Abs(float64(math.Float32frombits(last.Load()))-0.25) > 1e-6 {
+		if time.Now().After(deadline) {
+			t.Fatalf("bridged sample = %v, want 0.25", math.Float32frombits(last.Load()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if r := b.Ratio(); math.Abs(r-1) > maxRatioDivergence {
+		t.Errorf("Ratio = %v", r)
+	}
+}
@@ -0,0 +1,85 @@
+// Command palatency measures the round-trip latency of an audio interface.
+//
+// Connect an output of the interface to an input, with a cable or a loudspeaker
+// and a microphone, and run
+//
+//	palatency -in "USB Audio" -out "USB Audio" -latency low
+//
+// The measured latency is printed next to the latency reported by PortAudio.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+func main() {
+	var (
+		in         = flag.String("in", "", "input device name (default device if empty)")
+		out        = flag.String("out", "", "output device name (default device if empty)")
+		hostApi    = flag.String("hostapi", "", "host API of the devices")
+		rate       = flag.Float64("rate", 0, "sample rate (default rate of the devices if zero)")
+		latency    = flag.String("latency", pa.LowLatency, `suggested latency: "low", "high" or a duration`)
+		frames     = flag.Uint64("frames", 0, "frames per buffer (chosen by PortAudio if zero)")
+		signal     = flag.String("signal", "mls", `test signal: "mls" or "chirp"`)
+		runs       = flag.Int("runs", 5, "number of measurements")
+		atten      = flag.Float64("attenuation", 12, "attenuation of the test signal in dB below full scale")
+		maxLatency = flag.Duration("max", time.Second, "largest latency to detect")
+	)
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("palatency: ")
+
+	options := pa.LatencyOptions{Runs: *runs, Attenuation: *atten, MaxLatency: *maxLatency}
+	switch *signal {
+	case "mls":
+		options.Signal = pa.MLS
+	case "chirp":
+		options.Signal = pa.Chirp
+	default:
+		log.Fatalf("unknown signal %q", *signal)
+	}
+	profile := &pa.StreamProfile{
+		Input:           &pa.StreamDeviceProfile{Device: *in, Latency: *latency},
+		Output:          &pa.StreamDeviceProfile{Device: *out, Latency: *latency},
+		SampleRate:      *rate,
+		SampleFormat:    pa.Float32,
+		FramesPerBuffer: *frames,
+	}
+	if *hostApi != "" {
+		t, err := pa.ParseHostApiType(*hostApi)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profile.Input.HostApi, profile.Output.HostApi = &t, &t
+	}
+
+	if err := pa.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+	defer pa.Terminate()
+	params, err := profile.Resolve()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Input:  %s (%s)\n", params.Input.Device.Name, params.Input.Device.HostApi.Name)
+	fmt.Printf("Output: %s (%s)\n", params.Output.Device.Name, params.Output.Device.HostApi.Name)
+	fmt.Printf("Sample rate: %g Hz\n", params.SampleRate)
+	report, err := pa.MeasureLatency(params, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, d := range report.Runs {
+		fmt.Printf("Run %d: %v\n", i+1, d)
+	}
+	if failed := options.Runs - len(report.Runs); failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d runs did not detect the signal\n", failed, options.Runs)
+	}
+	fmt.Printf("Measured: %v (min %v, max %v, jitter %v)\n", report.Measured, report.Min, report.Max, report.Jitter)
+	fmt.Printf("Reported: %v (input %v, output %v)\n", report.Reported(), report.ReportedInput, report.ReportedOutput)
+}
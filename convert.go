@@ -0,0 +1,140 @@
+package portaudio
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// isFloat64 reports whether samples of type T are float64 values.
+func isFloat64[T any]() bool {
+	return reflect.TypeFor[T]().Kind() == reflect.Float64
+}
+
+// needsConversion reports whether a stream of client sample type T
+// exchanges samples with the device in another format.
+// Currently float64 client samples are converted to and from Float32 device samples.
+func needsConversion[T any](params *StreamParameters) bool {
+	return !params.RawOutput && isFloat64[T]() && params.SampleFormat&^NonInterleaved == Float32
+}
+
+// checkSampleType returns SampleFormatNotSupported unless the samples of type T
+// have the size of the device samples. Raw output streams exchange the device samples as bytes
+// and converted float64 samples are exchanged as Float32.
+func checkSampleType[T any](params *StreamParameters) error {
+	size := int(unsafe.Sizeof(*new(T)))
+	if params.RawOutput && size == 1 || needsConversion[T](params) {
+		return nil
+	}
+	if params.RawOutput || size != sampleBytes(params.SampleFormat) {
+		return SampleFormatNotSupported
+	}
+	return nil
+}
+
+// float64s reinterprets a slice of float64 client samples.
+func float64s[T any](buf []T) []float64 {
+	return unsafe.Slice((*float64)(unsafe.Pointer(unsafe.SliceData(buf))), len(buf))
+}
+
+// resize returns buf with the given length, reallocating it only if its capacity is too small.
+func resize[T any](buf []T, size int) []T {
+	if cap(buf) < size {
+		return make([]T, size)
+	}
+	return buf[:size]
+}
+
+// convertIn converts size Float32 device samples at ptr into buf.
+func convertIn[T any](buf []T, ptr unsafe.Pointer, size int) []T {
+	buf = resize(buf, size)
+	dst := float64s(buf)
+	for i, v := range unsafe.Slice((*float32)(ptr), size) {
+		dst[i] = float64(v)
+	}
+	return buf
+}
+
+// convertOut converts the client samples of buf into Float32 device samples at ptr.
+func convertOut[T any](ptr unsafe.Pointer, buf []T) {
+	dst := unsafe.Slice((*float32)(ptr), len(buf))
+	for i, v := range float64s(buf) {
+		dst[i] = float32(v)
+	}
+}
+
+// deviceSampleSize returns the size in bytes of the samples exchanged with the device.
+func (s *Stream[T]) deviceSampleSize() int {
+	if s.convert {
+		return int(unsafe.Sizeof(float32(0)))
+	}
+	return int(unsafe.Sizeof(*new(T)))
+}
+
+func makePlanar[T any](channels, size int) [][]T {
+	bufs := make([][]T, channels)
+	for c := range bufs {
+		bufs[c] = make([]T, size)
+	}
+	return bufs
+}
+
+// loadPlanar copies the samples of a NonInterleaved device buffer into bufs, converting them if needed.
+func (s *Stream[T]) loadPlanar(bufs [][]T, ptr unsafe.Pointer) {
+	for c, innerPtr := range unsafe.Slice((*unsafe.Pointer)(ptr), len(bufs)) {
+		if s.convert {
+			bufs[c] = convertIn(bufs[c], innerPtr, len(bufs[c]))
+		} else {
+			copy(bufs[c], unsafe.Slice((*T)(innerPtr), len(bufs[c])))
+		}
+	}
+}
+
+// storePlanar copies the samples of bufs into a NonInterleaved device buffer, converting them if needed.
+func (s *Stream[T]) storePlanar(ptr unsafe.Pointer, bufs [][]T) {
+	for c, innerPtr := range unsafe.Slice((*unsafe.Pointer)(ptr), len(bufs)) {
+		if s.convert {
+			convertOut(innerPtr, bufs[c])
+		} else {
+			copy(unsafe.Slice((*T)(innerPtr), len(bufs[c])), bufs[c])
+		}
+	}
+}
+
+func (s *Stream[T]) convertInBuffer(ptr unsafe.Pointer) {
+	size := s.frameCount * s.inSize
+	if s.params.SampleFormat.IsInterleaved() {
+		s.in = convertIn(s.in, ptr, size)
+		return
+	}
+	for i, innerPtr := range unsafe.Slice((*unsafe.Pointer)(ptr), s.params.Input.ChannelCount) {
+		s.inS[i] = convertIn(s.inS[i], innerPtr, size)
+	}
+}
+
+func (s *Stream[T]) prepareOutBuffer(ptr unsafe.Pointer) {
+	s.devOut = ptr
+	size := s.frameCount * s.outSize
+	if s.params.SampleFormat.IsInterleaved() {
+		s.out = resize(s.out, size)
+		clear(s.out)
+		return
+	}
+	for i := range s.outS {
+		s.outS[i] = resize(s.outS[i], size)
+		clear(s.outS[i])
+	}
+}
+
+// flushOutBuffer converts the samples written by the callback into the device buffer.
+func (s *Stream[T]) flushOutBuffer() {
+	if s.devOut == nil {
+		return
+	}
+	if s.params.SampleFormat.IsInterleaved() {
+		convertOut(s.devOut, s.out)
+		return
+	}
+	for i, innerPtr := range unsafe.Slice((*unsafe.Pointer)(s.devOut), len(s.outS)) {
+		convertOut(innerPtr, s.outS[i])
+	}
+}
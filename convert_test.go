@@ -0,0 +1,162 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loopbackParams returns the parameters of a duplex stream through a new Loopback.
+func loopbackParams(t *testing.T, format SampleFormat, channels int) *StreamParameters {
+	t.Helper()
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: channels})
+	t.Cleanup(l.Close)
+	return &StreamParameters{
+		Input:           StreamDeviceParameters{Device: l.Input(), ChannelCount: channels},
+		Output:          StreamDeviceParameters{Device: l.Output(), ChannelCount: channels},
+		SampleRate:      48000,
+		SampleFormat:    format,
+		FramesPerBuffer: 256,
+	}
+}
+
+func openTestStream[T any](t *testing.T, params *StreamParameters, callback func(*Stream[T]) StreamCallbackResult) *Stream[T] {
+	t.Helper()
+	s, err := OpenStream(params, callback, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// levels are the constant sample values sent on each channel; they are exact in float32.
+var levels = []float64{0.25, -0.5}
+
+func TestBlockingFloat64Conversion(t *testing.T) {
+	s := openTestStream[float64](t, loopbackParams(t, Float32, 2), nil)
+	out := make([]float64, 256*2)
+	for i := range out {
+		out[i] = levels[i%2]
+	}
+	var in []float64
+	for range 10 {
+		if err := s.Write(out); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if in, err = s.Read(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, v := range in {
+		if v != levels[i%2] {
+			t.Fatalf("sample %d = %v, want %v", i, v, levels[i%2])
+		}
+	}
+}
+
+func TestBlockingNonInterleaved(t *testing.T) {
+	t.Run("float64", func(t *testing.T) { testBlockingNonInterleaved[float64](t, Float32, 1) })
+	t.Run("float32", func(t *testing.T) { testBlockingNonInterleaved[float32](t, Float32, 1) })
+	t.Run("int16", func(t *testing.T) { testBlockingNonInterleaved[int16](t, Int16, 32768) })
+}
+
+func testBlockingNonInterleaved[T float32 | float64 | int16](t *testing.T, format SampleFormat, scale float64) {
+	s := openTestStream[T](t, loopbackParams(t, format|NonInterleaved, 2), nil)
+	out := make([][]T, 2)
+	for c := range out {
+		out[c] = make([]T, 256)
+		for f := range out[c] {
+			out[c][f] = T(levels[c] * scale)
+		}
+	}
+	var in [][]T
+	for range 10 {
+		if err := s.WriteS(out); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if in, err = s.ReadS(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for c := range in {
+		for f, v := range in[c] {
+			if v != out[c][f] {
+				t.Fatalf("channel %d frame %d = %v, want %v", c, f, v, out[c][f])
+			}
+		}
+	}
+}
+
+func TestCallbackFloat64Conversion(t *testing.T) {
+	for _, format := range []SampleFormat{Float32, Float32 | NonInterleaved} {
+		t.Run(format.String(), func(t *testing.T) {
+			var last [2]atomic.Uint64
+			openTestStream(t, loopbackParams(t, format, 2), func(s *Stream[float64]) StreamCallbackResult {
+				if format.IsNonInterleaved() {
+					for c := range s.OutS() {
+						for f := range s.OutS()[c] {
+							s.OutS()[c][f] = levels[c]
+						}
+						last[c].Store(math.Float64bits(s.InS()[c][len(s.InS()[c])-1]))
+					}
+					return Continue
+				}
+				for i := range s.Out() {
+					s.Out()[i] = levels[i%2]
+				}
+				for c := range 2 {
+					last[c].Store(math.Float64bits(s.In()[len(s.In())-2+c]))
+				}
+				return Continue
+			})
+			deadline := time.Now().Add(5 * time.Second)
+			for c := range last {
+				for math.Float64frombits(last[c].Load()) != levels[c] {
+					if time.Now().After(deadline) {
+						t.Fatalf("channel %d = %v, want %v", c, math.Float64frombits(last[c].Load()), levels[c])
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+func TestSampleTypeMismatch(t *testing.T) {
+	for _, test := range []struct {
+		format SampleFormat
+		raw    bool
+		check  func(*StreamParameters) error
+		want   error
+	}{
+		{Int16, false, checkSampleType[int16], nil},
+		{Int16 | NonInterleaved, false, checkSampleType[int16], nil},
+		{Float32, false, checkSampleType[float64], nil},
+		{Int32, true, checkSampleType[byte], nil},
+		{Int16, false, checkSampleType[float64], SampleFormatNotSupported},
+		{Float32, false, checkSampleType[int16], SampleFormatNotSupported},
+		{Int24, false, checkSampleType[int32], SampleFormatNotSupported},
+		{Int16, false, checkSampleType[int64], SampleFormatNotSupported},
+		{Int16, true, checkSampleType[int16], SampleFormatNotSupported},
+	} {
+		if err := test.check(&StreamParameters{SampleFormat: test.format, RawOutput: test.raw}); err != test.want {
+			t.Errorf("%v, raw %v: got %v, want %v", test.format, test.raw, err, test.want)
+		}
+	}
+
+	params := loopbackParams(t, Int16, 2)
+	s, err := OpenStream[float64](params, nil, nil)
+	if !errors.Is(err, SampleFormatNotSupported) {
+		_ = s.Close()
+		t.Fatalf("float64 samples on an Int16 stream: %v, want SampleFormatNotSupported", err)
+	}
+	openTestStream[int16](t, params, nil)
+}
@@ -18,14 +18,21 @@ type DeviceInfo struct {
 	DefaultHighOutputLatency time.Duration
 	DefaultSampleRate        float64
 	HostApi                  *HostApiInfo
+	virtual                  virtualDevice
 }
 
 // Device returns a pointer to a DeviceInfo structure containing information about the specified device.
 // If the device parameter is out of range the function returns nil.
+// Virtual devices are listed after the PortAudio devices, but their Index is assigned
+// when they are created and does not change when RescanDevices finds other devices.
+// Device accepts both their position in the list and their Index.
 func Device(index int) *DeviceInfo {
+	if index >= virtualDeviceBase {
+		return virtualDeviceInfo(index)
+	}
 	info := C.Pa_GetDeviceInfo(C.PaDeviceIndex(index))
 	if info == nil {
-		return nil
+		return virtualDeviceAt(index)
 	}
 	return &DeviceInfo{
 		Index:                    index,
@@ -41,22 +48,28 @@ func Device(index int) *DeviceInfo {
 	}
 }
 
-// DeviceCount returns the number of available devices.
+// DeviceCount returns the number of available devices, including virtual devices such as Loopback.
 // The number of available devices may be zero.
 func DeviceCount() int {
-	return int(C.Pa_GetDeviceCount())
+	count := int(C.Pa_GetDeviceCount())
+	if count < 0 {
+		return count
+	}
+	return count + virtualDeviceCount()
 }
 
 // DefaultInputDeviceIndex returns the index of the default input device.
+// The default respects the DeviceEnv and HostApiEnv environment variables and PreferHostApis.
 // The result can be used in the inputDevice parameter to OpenStream().
 func DefaultInputDeviceIndex() int {
-	return int(C.Pa_GetDefaultInputDevice())
+	return defaultDeviceIndex(true)
 }
 
 // DefaultOutputDeviceIndex returns the index of the default output device.
+// The default respects the DeviceEnv and HostApiEnv environment variables and PreferHostApis.
 // The result can be used in the outputDevice parameter to OpenStream().
 func DefaultOutputDeviceIndex() int {
-	return int(C.Pa_GetDefaultOutputDevice())
+	return defaultDeviceIndex(false)
 }
 
 // DefaultInputDevice returns information about the default input device
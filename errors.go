@@ -0,0 +1,93 @@
+package portaudio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StreamError records a failed stream operation together with
+// the device and the parameters the operation was performed with.
+type StreamError struct {
+	Op     string
+	Device *DeviceInfo
+	Params *StreamParameters
+	Err    error
+}
+
+func (e *StreamError) Error() string {
+	if e.Device == nil {
+		return fmt.Sprintf("portaudio: %s: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("portaudio: %s (device %d %q): %s", e.Op, e.Device.Index, e.Device.Name, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+func streamError(op string, device *DeviceInfo, params *StreamParameters, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StreamError{op, device, params, err}
+}
+
+// CallbackPanicError is reported by Stream.Err when a user callback panicked.
+type CallbackPanicError struct {
+	// Callback names the callback that panicked.
+	Callback string
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *CallbackPanicError) Error() string {
+	return fmt.Sprintf("portaudio: stream %s panicked: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *CallbackPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Host error codes reported when a device disappears while a stream is running.
+const (
+	alsaNoDevice        = -19         // -ENODEV
+	alsaIOError         = -5          // -EIO
+	coreAudioBadDevice  = 0x21646576  // kAudioHardwareBadDeviceError ('!dev')
+	wasapiDeviceInvalid = -2004287484 // AUDCLNT_E_DEVICE_INVALIDATED (0x88890004)
+	directSoundNoDriver = -2005401480 // DSERR_NODRIVER (0x88780078)
+)
+
+// IsTransient reports whether err is caused by a condition that may go away
+// by itself, so that retrying the operation makes sense.
+func IsTransient(err error) bool {
+	return errors.Is(err, TimedOut) ||
+		errors.Is(err, InputOverflowed) ||
+		errors.Is(err, OutputUnderflowed)
+}
+
+// IsDeviceLost reports whether err indicates that the device used by a stream
+// is no longer available, e.g. because it has been unplugged.
+func IsDeviceLost(err error) bool {
+	if errors.Is(err, DeviceUnavailable) {
+		return true
+	}
+	var info HostErrorInfo
+	if !errors.As(err, &info) {
+		return false
+	}
+	switch info.HostApiType {
+	case ALSA, OSS:
+		return info.Code == alsaNoDevice || info.Code == alsaIOError
+	case CoreAudio:
+		return info.Code == coreAudioBadDevice
+	case WASAPI:
+		return info.Code == wasapiDeviceInvalid
+	case DirectSound:
+		return info.Code == directSoundNoDriver
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package portaudio
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStreamErrorText(t *testing.T) {
+	device := &DeviceInfo{Index: 3, Name: "Mic"}
+	err := streamError("read", device, nil, InputOverflowed)
+	if want := `portaudio: read (device 3 "Mic"): ` + InputOverflowed.Error(); err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+	if !errors.Is(err, InputOverflowed) || errors.Unwrap(err) != InputOverflowed {
+		t.Errorf("%v does not wrap %v", err, InputOverflowed)
+	}
+	if err = streamError("stop", nil, nil, TimedOut); err.Error() != "portaudio: stop: "+TimedOut.Error() {
+		t.Errorf("got %q without a device", err)
+	}
+	if err = streamError("stop", nil, nil, nil); err != nil {
+		t.Errorf("got %v for no error", err)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{TimedOut, true},
+		{InputOverflowed, true},
+		{streamError("write", nil, nil, OutputUnderflowed), true},
+		{DeviceUnavailable, false},
+		{InvalidSampleRate, false},
+		{errors.New("other"), false},
+		{nil, false},
+	} {
+		if got := IsTransient(test.err); got != test.want {
+			t.Errorf("IsTransient(%v) = %v", test.err, got)
+		}
+	}
+}
+
+func TestIsDeviceLost(t *testing.T) {
+	host := func(api HostApiType, code int) error {
+		return streamError("read", nil, nil, HostErrorInfo{HostApiType: api, Code: code})
+	}
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{DeviceUnavailable, true},
+		{streamError("start", nil, nil, DeviceUnavailable), true},
+		{host(ALSA, alsaNoDevice), true},
+		{host(ALSA, alsaIOError), true},
+		{host(OSS, alsaNoDevice), true},
+		{host(ALSA, -32), false},
+		{host(CoreAudio, coreAudioBadDevice), true},
+		{host(WASAPI, wasapiDeviceInvalid), true},
+		{host(DirectSound, directSoundNoDriver), true},
+		{host(JACK, alsaNoDevice), false},
+		{TimedOut, false},
+		{nil, false},
+	} {
+		if got := IsDeviceLost(test.err); got != test.want {
+			t.Errorf("IsDeviceLost(%v) = %v", test.err, got)
+		}
+	}
+}
+
+func TestOpenStreamError(t *testing.T) {
+	params := loopbackParams(t, Float32, 1)
+	params.SampleRate = 44100
+	_, err := OpenStream[float32](params, nil, nil)
+	var se *StreamError
+	if !errors.As(err, &se) {
+		t.Fatalf("got %v, want a StreamError", err)
+	}
+	if se.Op != "open" || se.Device != params.Input.Device || se.Params != params {
+		t.Errorf("got %+v", se)
+	}
+	if !errors.Is(err, InvalidSampleRate) {
+		t.Errorf("%v does not wrap %v", err, InvalidSampleRate)
+	}
+	want := fmt.Sprintf("portaudio: open (device %d %q): %s", se.Device.Index, se.Device.Name, InvalidSampleRate)
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
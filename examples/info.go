@@ -25,6 +25,7 @@ func main() {
 	fmt.Printf("Sample size of formt Int32: %d\n", pa.SampleSize(pa.Int32))
 
 	params := pa.DefaultLowLatencyParameters()
+	fmt.Printf("Default low latency profile: %s\n", toString(pa.NewStreamProfile(params)))
 	fmt.Printf("Format supported (%d Hz): %t\n", int(params.SampleRate), pa.IsFormatSupported(params))
 	params.SampleRate = 10
 	fmt.Printf("Format supported (%d Hz): %t\n", int(params.SampleRate), pa.IsFormatSupported(params))
@@ -3,10 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
 	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/gen"
 )
 
 func main() {
@@ -19,16 +19,15 @@ func main() {
 
 func noise() {
 	params := pa.HighLatencyParameters(nil, pa.DefaultOutputDevice())
+	params.SampleFormat = pa.Int32
+	source := gen.NewSource[int32](gen.NewNoise(gen.White, uint64(time.Now().UnixNano())), params.Output.ChannelCount, -6)
 	stream, err := pa.OpenStream(
 		params,
-		func(s *pa.Stream[uint32]) pa.StreamCallbackResult {
-			out := s.Out()
-			for i := range s.Out() {
-				out[i] = rand.Uint32()
-			}
+		func(s *pa.Stream[int32]) pa.StreamCallbackResult {
+			source.ReadSamples(s.Out())
 			return pa.Continue
 		},
-		func(s *pa.Stream[uint32]) {
+		func(s *pa.Stream[int32]) {
 			fmt.Println("Stream is finished!")
 		},
 	)
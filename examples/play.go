@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/mp3"
+	"github.com/URALINNOVATSIYA/portaudio/player"
+	"github.com/URALINNOVATSIYA/portaudio/vorbis"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: play file.mp3|file.ogg")
+	}
+	check(pa.Initialize())
+
+	play(os.Args[1])
+
+	check(pa.Terminate())
+}
+
+func play(name string) {
+	var d interface {
+		player.Decoder
+		Close() error
+	}
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ogg", ".oga":
+		d, err = vorbis.Open(name)
+	default:
+		d, err = mp3.Open(name)
+	}
+	check(err)
+	defer d.Close()
+	fmt.Printf("Playing %s (%v, %d Hz, %d channels)\n", name, d.Duration(), d.SampleRate(), d.Channels())
+	check(player.Play(d))
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,16 +2,15 @@ package main
 
 import (
 	"log"
-	"math"
 	"time"
 
 	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/gen"
 )
 
 type stereoSine struct {
 	*pa.Stream[float32]
-	stepL, phaseL float64
-	stepR, phaseR float64
+	left, right *gen.Source[float32]
 }
 
 func newStereoSine(freqL, freqR float64) *stereoSine {
@@ -19,7 +18,10 @@ func newStereoSine(freqL, freqR float64) *stereoSine {
 	params := pa.HighLatencyParameters(nil, pa.DefaultOutputDevice())
 	params.Output.ChannelCount = 2
 	params.SampleFormat = pa.Float32 | pa.NonInterleaved
-	s := &stereoSine{nil, freqL / params.SampleRate, 0, freqR / params.SampleRate, 0}
+	s := &stereoSine{
+		left:  gen.NewSource[float32](gen.NewOscillator(gen.Sine, freqL, params.SampleRate), 1, 0),
+		right: gen.NewSource[float32](gen.NewOscillator(gen.Sine, freqR, params.SampleRate), 1, 0),
+	}
 	s.Stream, err = pa.OpenStream(params, s.processAudio, nil)
 	check(err)
 	return s
@@ -27,12 +29,8 @@ func newStereoSine(freqL, freqR float64) *stereoSine {
 
 func (g *stereoSine) processAudio(s *pa.Stream[float32]) pa.StreamCallbackResult {
 	out := s.OutS()
-	for i := range out[0] {
-		out[0][i] = float32(math.Sin(2 * math.Pi * g.phaseL))
-		_, g.phaseL = math.Modf(g.phaseL + g.stepL)
-		out[1][i] = float32(math.Sin(2 * math.Pi * g.phaseR))
-		_, g.phaseR = math.Modf(g.phaseR + g.stepR)
-	}
+	g.left.ReadPlanar(out[:1])
+	g.right.ReadPlanar(out[1:])
 	return pa.Continue
 }
 
@@ -0,0 +1,66 @@
+package portaudio
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// fft transforms x in place with an iterative radix-2 FFT.
+// The length of x must be a power of two. The inverse transform is scaled by 1/len(x).
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	shift := 64 - uint(bits.TrailingZeros(uint(n)))
+	for i := range x {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range size / 2 {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
+
+// crossCorrelate returns the cross-correlation c[k] = sum x[n+k]·y[n] for k in [0, len(x)).
+func crossCorrelate(x, y []float64) []float64 {
+	n := 1
+	for n < len(x)+len(y) {
+		n <<= 1
+	}
+	a, b := make([]complex128, n), make([]complex128, n)
+	for i, v := range x {
+		a[i] = complex(v, 0)
+	}
+	for i, v := range y {
+		b[i] = complex(v, 0)
+	}
+	fft(a, false)
+	fft(b, false)
+	for i := range a {
+		a[i] *= cmplx.Conj(b[i])
+	}
+	fft(a, true)
+	c := make([]float64, len(x))
+	for i := range c {
+		c[i] = real(a[i])
+	}
+	return c
+}
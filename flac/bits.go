@@ -0,0 +1,164 @@
+package flac
+
+import (
+	"io"
+	"math/bits"
+)
+
+// bitReader reads bits MSB first and computes the CRCs of the bytes it consumes.
+type bitReader struct {
+	r     io.ByteReader
+	x     uint64 // the low n bits are unread
+	n     uint
+	crc8  byte
+	crc16 uint16
+}
+
+func (b *bitReader) resetCRC() {
+	b.crc8, b.crc16 = 0, 0
+}
+
+func (b *bitReader) fill() error {
+	c, err := b.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	b.crc8 = crc8(b.crc8, c)
+	b.crc16 = crc16(b.crc16, c)
+	b.x = b.x<<8 | uint64(c)
+	b.n += 8
+	return nil
+}
+
+// read reads n ≤ 32 bits.
+func (b *bitReader) read(n uint) (uint64, error) {
+	for b.n < n {
+		if err := b.fill(); err != nil {
+			return 0, err
+		}
+	}
+	b.n -= n
+	v := b.x >> b.n & (1<<n - 1)
+	b.x &= 1<<b.n - 1
+	return v, nil
+}
+
+// readSigned reads a two's complement number of n ≤ 32 bits.
+func (b *bitReader) readSigned(n uint) (int64, error) {
+	v, err := b.read(n)
+	if n == 0 {
+		return 0, err
+	}
+	return int64(v<<(64-n)) >> (64 - n), err
+}
+
+// readUnary counts the zero bits before the next one bit.
+func (b *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if b.n == 0 {
+			if err := b.fill(); err != nil {
+				return 0, err
+			}
+		}
+		if b.x == 0 {
+			count += uint64(b.n)
+			b.n = 0
+			continue
+		}
+		zeros := uint(bits.LeadingZeros64(b.x)) - (64 - b.n)
+		count += uint64(zeros)
+		b.n -= zeros + 1
+		b.x &= 1<<b.n - 1
+		return count, nil
+	}
+}
+
+// align skips the bits up to the next byte boundary.
+func (b *bitReader) align() {
+	b.n -= b.n % 8
+	b.x &= 1<<b.n - 1
+}
+
+// bitWriter writes bits MSB first into a byte slice.
+type bitWriter struct {
+	buf []byte
+	x   uint64 // the low n bits are pending
+	n   uint
+}
+
+// write writes the low n ≤ 32 bits of v.
+func (w *bitWriter) write(v uint64, n uint) {
+	w.x = w.x<<n | v&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		w.n -= 8
+		w.buf = append(w.buf, byte(w.x>>w.n))
+	}
+	w.x &= 1<<w.n - 1
+}
+
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.write(uint64(v), n)
+}
+
+// writeUnary writes n zero bits followed by a one bit.
+func (w *bitWriter) writeUnary(n uint64) {
+	for ; n >= 32; n -= 32 {
+		w.write(0, 32)
+	}
+	w.write(1, uint(n)+1)
+}
+
+// align pads with zero bits up to the next byte boundary.
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.write(0, 8-w.n)
+	}
+}
+
+// readUTF8 reads a number coded like UTF-8 with up to 36 bits, as used in frame headers.
+func readUTF8(b *bitReader) (uint64, error) {
+	c, err := b.read(8)
+	if err != nil {
+		return 0, err
+	}
+	n := bits.LeadingZeros8(^uint8(c))
+	switch {
+	case n == 0:
+		return c, nil
+	case n == 1 || n > 7:
+		return 0, corrupt("invalid frame number")
+	}
+	v := c & (0x7f >> n)
+	for range n - 1 {
+		c, err = b.read(8)
+		if err != nil {
+			return 0, err
+		}
+		if c&0xc0 != 0x80 {
+			return 0, corrupt("invalid frame number")
+		}
+		v = v<<6 | c&0x3f
+	}
+	return v, nil
+}
+
+// appendUTF8 appends a number coded like UTF-8 with up to 36 bits.
+func appendUTF8(w *bitWriter, v uint64) {
+	if v < 0x80 {
+		w.write(v, 8)
+		return
+	}
+	n := 2
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	w.write(0xff<<(8-n)&0xff|v>>(6*(n-1)), 8)
+	for i := n - 2; i >= 0; i-- {
+		w.write(0x80|v>>(6*i)&0x3f, 8)
+	}
+}
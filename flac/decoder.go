@@ -0,0 +1,541 @@
+package flac
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// Decoder decodes a FLAC stream into interleaved integer samples.
+type Decoder struct {
+	r          io.Reader
+	br         *bufio.Reader
+	bits       bitReader
+	info       StreamInfo
+	comments   *Comments
+	seekTable  []seekPoint
+	firstFrame int64 // offset of the first frame in r, or -1 if r is not seekable
+
+	block    [][]int32 // the decoded frame
+	blockLen int
+	blockPos int
+	position uint64 // per channel sample number of the next sample returned by Read
+}
+
+// NewDecoder reads the metadata of a FLAC stream. If r is an io.Seeker, Seek can be used.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{r: r, br: bufio.NewReader(r), firstFrame: -1}
+	d.bits.r = d.br
+	var head [4]byte
+	if _, err := io.ReadFull(d.br, head[:]); err != nil || string(head[:]) != magic {
+		return nil, ErrNoFLAC
+	}
+	seenInfo := false
+	for last := false; !last; {
+		var header [4]byte
+		if _, err := io.ReadFull(d.br, header[:]); err != nil {
+			return nil, corrupt("truncated metadata")
+		}
+		last = header[0]&0x80 != 0
+		kind := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		data := make([]byte, size)
+		if _, err := io.ReadFull(d.br, data); err != nil {
+			return nil, corrupt("truncated metadata")
+		}
+		var err error
+		switch kind {
+		case blockStreamInfo:
+			err = d.parseStreamInfo(data)
+			seenInfo = true
+		case blockSeekTable:
+			err = d.parseSeekTable(data)
+		case blockVorbisComment:
+			d.comments, err = parseComments(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !seenInfo {
+		return nil, corrupt("missing STREAMINFO")
+	}
+	if s, ok := r.(io.Seeker); ok {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			// The bufio.Reader has read ahead of the metadata.
+			d.firstFrame = start - int64(d.br.Buffered())
+		}
+	}
+	d.block = make([][]int32, d.info.Channels)
+	for i := range d.block {
+		d.block[i] = make([]int32, d.info.MaxBlockSize)
+	}
+	return d, nil
+}
+
+func (d *Decoder) parseStreamInfo(data []byte) error {
+	if len(data) < 34 {
+		return corrupt("short STREAMINFO")
+	}
+	info := &d.info
+	info.MinBlockSize = int(binary.BigEndian.Uint16(data[0:]))
+	info.MaxBlockSize = int(binary.BigEndian.Uint16(data[2:]))
+	info.MinFrameSize = int(data[4])<<16 | int(data[5])<<8 | int(data[6])
+	info.MaxFrameSize = int(data[7])<<16 | int(data[8])<<8 | int(data[9])
+	x := binary.BigEndian.Uint64(data[10:])
+	info.SampleRate = int(x >> 44)
+	info.Channels = int(x>>41&7) + 1
+	info.BitsPerSample = int(x>>36&0x1f) + 1
+	info.TotalSamples = x & (1<<36 - 1)
+	copy(info.MD5[:], data[18:34])
+	if info.MaxBlockSize < 16 || info.SampleRate == 0 || info.BitsPerSample < 4 {
+		return corrupt("invalid STREAMINFO")
+	}
+	return nil
+}
+
+func (d *Decoder) parseSeekTable(data []byte) error {
+	for ; len(data) >= 18; data = data[18:] {
+		p := seekPoint{
+			sample: binary.BigEndian.Uint64(data),
+			offset: binary.BigEndian.Uint64(data[8:]),
+			frames: binary.BigEndian.Uint16(data[16:]),
+		}
+		if p.sample != placeholderPoint {
+			d.seekTable = append(d.seekTable, p)
+		}
+	}
+	return nil
+}
+
+func parseComments(data []byte) (*Comments, error) {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return "", false
+		}
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		return s, true
+	}
+	vendor, ok := next()
+	if !ok || len(data) < 4 {
+		return nil, corrupt("invalid VORBIS_COMMENT")
+	}
+	c := &Comments{Vendor: vendor}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for range count {
+		f, ok := next()
+		if !ok {
+			return nil, corrupt("invalid VORBIS_COMMENT")
+		}
+		c.Fields = append(c.Fields, f)
+	}
+	return c, nil
+}
+
+// Info returns the STREAMINFO of the stream.
+func (d *Decoder) Info() StreamInfo {
+	return d.info
+}
+
+// Comments returns the Vorbis comments of the stream, or nil if it has none.
+func (d *Decoder) Comments() *Comments {
+	return d.comments
+}
+
+// Position returns the per channel number of the next sample returned by Read.
+func (d *Decoder) Position() uint64 {
+	return d.position
+}
+
+// Read decodes interleaved samples into buf and returns their number, a multiple of the channel count.
+// The samples are signed integers of Info().BitsPerSample bits. Read returns io.EOF at the end of the stream.
+func (d *Decoder) Read(buf []int32) (int, error) {
+	channels := d.info.Channels
+	n := 0
+	for n+channels <= len(buf) {
+		if d.blockPos == d.blockLen {
+			if err := d.decodeFrame(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		frames := min(d.blockLen-d.blockPos, (len(buf)-n)/channels)
+		for f := range frames {
+			for c := range channels {
+				buf[n+f*channels+c] = d.block[c][d.blockPos+f]
+			}
+		}
+		d.blockPos += frames
+		d.position += uint64(frames)
+		n += frames * channels
+	}
+	return n, nil
+}
+
+// Seek moves to the given per channel sample number. The reader must be an io.Seeker.
+// The seek table is used if present, otherwise the stream is decoded from the beginning.
+func (d *Decoder) Seek(sample uint64) error {
+	s, ok := d.r.(io.Seeker)
+	if !ok || d.firstFrame < 0 {
+		return ErrNotSeekable
+	}
+	var start seekPoint
+	for _, p := range d.seekTable {
+		if p.sample <= sample && p.sample >= start.sample {
+			start = p
+		}
+	}
+	// Move back unless the target lies ahead in the current stream.
+	if sample < d.position || start.sample > d.position-uint64(d.blockPos) {
+		if _, err := s.Seek(d.firstFrame+int64(start.offset), io.SeekStart); err != nil {
+			return err
+		}
+		d.br.Reset(d.r)
+		d.bits = bitReader{r: d.br}
+		d.position, d.blockPos, d.blockLen = start.sample, 0, 0
+	}
+	for {
+		if d.blockPos == d.blockLen {
+			if err := d.decodeFrame(); err != nil {
+				return err
+			}
+		}
+		if ahead := sample - d.position; ahead < uint64(d.blockLen-d.blockPos) {
+			d.blockPos += int(ahead)
+			d.position = sample
+			return nil
+		}
+		d.position += uint64(d.blockLen - d.blockPos)
+		d.blockPos = d.blockLen
+	}
+}
+
+// decodeFrame decodes the next frame into block.
+func (d *Decoder) decodeFrame() error {
+	b := &d.bits
+	b.align()
+	b.resetCRC()
+	sync, err := b.read(15)
+	if err == io.ErrUnexpectedEOF && b.n == 0 {
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	if sync != 0x7ffc {
+		return corrupt("lost frame sync")
+	}
+	if _, err = b.read(1); err != nil { // blocking strategy, the sample number is not needed
+		return err
+	}
+	codes, err := b.read(16)
+	if err != nil {
+		return err
+	}
+	sizeCode, rateCode := codes>>12, codes>>8&0xf
+	assignment, depthCode := int(codes>>4&0xf), codes>>1&7
+	if _, err = readUTF8(b); err != nil {
+		return err
+	}
+	blockSize := blockSizes[sizeCode]
+	switch sizeCode {
+	case 0:
+		return corrupt("reserved block size")
+	case 6:
+		v, err := b.read(8)
+		if err != nil {
+			return err
+		}
+		blockSize = int(v) + 1
+	case 7:
+		v, err := b.read(16)
+		if err != nil {
+			return err
+		}
+		blockSize = int(v) + 1
+	}
+	switch rateCode {
+	case 12:
+		_, err = b.read(8)
+	case 13, 14:
+		_, err = b.read(16)
+	case 15:
+		err = corrupt("invalid sample rate")
+	}
+	if err != nil {
+		return err
+	}
+	crc := b.crc8
+	if v, err := b.read(8); err != nil {
+		return err
+	} else if byte(v) != crc {
+		return corrupt("frame header CRC mismatch")
+	}
+	bps := d.info.BitsPerSample
+	if depthCode != 0 {
+		if bps = sampleSizes[depthCode]; bps == 0 {
+			return corrupt("reserved sample size")
+		}
+	}
+	channels := assignment + 1
+	if assignment >= leftSide {
+		if assignment > midSide {
+			return corrupt("reserved channel assignment")
+		}
+		channels = 2
+	}
+	if channels != d.info.Channels {
+		return corrupt("frame has %d channels instead of %d", channels, d.info.Channels)
+	}
+	if blockSize > len(d.block[0]) {
+		for i := range d.block {
+			d.block[i] = make([]int32, blockSize)
+		}
+	}
+	for c := range channels {
+		depth := uint(bps)
+		if assignment == leftSide && c == 1 || assignment == rightSide && c == 0 || assignment == midSide && c == 1 {
+			depth++ // side channel
+		}
+		if err = d.decodeSubframe(d.block[c][:blockSize], depth); err != nil {
+			return err
+		}
+	}
+	b.align()
+	crc16 := b.crc16
+	if v, err := b.read(16); err != nil {
+		return err
+	} else if uint16(v) != crc16 {
+		return corrupt("frame CRC mismatch")
+	}
+	decorrelate(d.block, blockSize, assignment)
+	d.blockLen, d.blockPos = blockSize, 0
+	return nil
+}
+
+// decorrelate restores left and right channels from side channels.
+func decorrelate(block [][]int32, n, assignment int) {
+	switch assignment {
+	case leftSide:
+		left, side := block[0][:n], block[1][:n]
+		for i := range left {
+			side[i] = left[i] - side[i]
+		}
+	case rightSide:
+		side, right := block[0][:n], block[1][:n]
+		for i := range side {
+			side[i] += right[i]
+		}
+	case midSide:
+		mid, side := block[0][:n], block[1][:n]
+		for i := range mid {
+			m := int64(mid[i])<<1 | int64(side[i])&1
+			s := int64(side[i])
+			mid[i], side[i] = int32((m+s)>>1), int32((m-s)>>1)
+		}
+	}
+}
+
+func (d *Decoder) decodeSubframe(out []int32, bps uint) error {
+	b := &d.bits
+	header, err := b.read(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return corrupt("invalid subframe padding")
+	}
+	kind := header >> 1 & 0x3f
+	var wasted uint
+	if header&1 != 0 {
+		k, err := b.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(k) + 1
+		if wasted >= bps {
+			return corrupt("invalid wasted bits")
+		}
+		bps -= wasted
+	}
+	switch {
+	case kind == 0:
+		v, err := b.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = int32(v)
+		}
+	case kind == 1:
+		for i := range out {
+			v, err := b.readSigned(bps)
+			if err != nil {
+				return err
+			}
+			out[i] = int32(v)
+		}
+	case kind >= 8 && kind <= 12:
+		order := int(kind - 8)
+		if err = d.decodeFixed(out, order, bps); err != nil {
+			return err
+		}
+	case kind >= 32:
+		order := int(kind-32) + 1
+		if err = d.decodeLPC(out, order, bps); err != nil {
+			return err
+		}
+	default:
+		return corrupt("reserved subframe type %d", kind)
+	}
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// readWarmup reads the unencoded warm-up samples of a predictor.
+func (d *Decoder) readWarmup(out []int32, order int, bps uint) error {
+	if order > len(out) {
+		return corrupt("predictor order exceeds block size")
+	}
+	for i := range order {
+		v, err := d.bits.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		out[i] = int32(v)
+	}
+	return nil
+}
+
+func (d *Decoder) decodeFixed(out []int32, order int, bps uint) error {
+	if err := d.readWarmup(out, order, bps); err != nil {
+		return err
+	}
+	if err := d.decodeResidual(out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		var p int64
+		switch order {
+		case 1:
+			p = int64(out[i-1])
+		case 2:
+			p = 2*int64(out[i-1]) - int64(out[i-2])
+		case 3:
+			p = 3*int64(out[i-1]) - 3*int64(out[i-2]) + int64(out[i-3])
+		case 4:
+			p = 4*int64(out[i-1]) - 6*int64(out[i-2]) + 4*int64(out[i-3]) - int64(out[i-4])
+		}
+		out[i] = int32(p + int64(out[i]))
+	}
+	return nil
+}
+
+func (d *Decoder) decodeLPC(out []int32, order int, bps uint) error {
+	b := &d.bits
+	if err := d.readWarmup(out, order, bps); err != nil {
+		return err
+	}
+	v, err := b.read(4)
+	if err != nil {
+		return err
+	}
+	if v == 15 {
+		return corrupt("invalid LPC precision")
+	}
+	precision := uint(v) + 1
+	shift, err := b.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return corrupt("negative LPC shift")
+	}
+	var coefs [32]int64
+	for i := range order {
+		if coefs[i], err = b.readSigned(precision); err != nil {
+			return err
+		}
+	}
+	if err := d.decodeResidual(out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		var p int64
+		for j := range order {
+			p += coefs[j] * int64(out[i-1-j])
+		}
+		out[i] = int32(p>>shift + int64(out[i]))
+	}
+	return nil
+}
+
+// decodeResidual decodes the residual of a predictor into out[order:].
+func (d *Decoder) decodeResidual(out []int32, order int) error {
+	b := &d.bits
+	method, err := b.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return corrupt("reserved residual coding method")
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partitionOrder, err := b.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	if len(out)%partitions != 0 || len(out)>>partitionOrder < order {
+		return corrupt("invalid partition order")
+	}
+	i := order
+	for p := range partitions {
+		end := (p + 1) * len(out) >> partitionOrder
+		param, err := b.read(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			n, err := b.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				v, err := b.readSigned(uint(n))
+				if err != nil {
+					return err
+				}
+				out[i] = int32(v)
+			}
+			continue
+		}
+		k := uint(param)
+		for ; i < end; i++ {
+			q, err := b.readUnary()
+			if err != nil {
+				return err
+			}
+			r, err := b.read(k)
+			if err != nil {
+				return err
+			}
+			u := q<<k | r
+			out[i] = int32(u>>1) ^ -int32(u&1)
+		}
+	}
+	return nil
+}
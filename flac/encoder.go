@@ -0,0 +1,729 @@
+package flac
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const (
+	vendor = "portaudio-go flac"
+	// seekPoints is the number of seek points reserved when the output is seekable.
+	seekPoints = 100
+	// lpcPrecision is the precision of the quantized LPC coefficients in bits.
+	lpcPrecision = 12
+	// maxPartitionOrder limits the partitioning of the residual.
+	maxPartitionOrder = 8
+)
+
+// EncoderOptions configures an Encoder.
+type EncoderOptions struct {
+	SampleRate int
+	Channels   int
+	// BitsPerSample of the samples passed to Write, from 4 to 32. Zero selects 16.
+	BitsPerSample int
+	// BlockSize is the number of samples per channel in a frame. Zero selects 4096.
+	BlockSize int
+	// MaxLPCOrder limits the order of the LPC predictors, up to 32. Zero selects 8,
+	// a negative value restricts the encoder to fixed predictors.
+	MaxLPCOrder int
+	// Comments are written as Vorbis comments. The vendor string is set by the encoder.
+	Comments *Comments
+}
+
+// Encoder encodes interleaved integer samples into a FLAC stream.
+// If the output is an io.WriteSeeker, Close completes the STREAMINFO
+// with the length and MD5 signature and writes a seek table.
+type Encoder struct {
+	w       io.Writer
+	ws      io.WriteSeeker
+	options EncoderOptions
+	start   int64 // offset of the stream in ws
+
+	block    [][]int32 // buffered samples per channel
+	buffered int
+	frame    uint64
+	total    uint64
+	md5      hash.Hash
+	md5buf   []byte
+
+	frameSizes  [2]int // min and max
+	frameOffset uint64 // of the next frame relative to the first frame
+	seekTable   []seekPoint
+	seekOffset  int64 // of the seek table relative to start
+
+	bits      bitWriter
+	converted []int32 // samples converted by WriteSamples
+	mid       []int32
+	side      []int32
+	scratch   []int32
+	residue   []int32
+	coded     []uint32
+	window    []float64
+	closed    bool
+}
+
+// NewEncoder writes the metadata of a FLAC stream to w and returns an encoder for its frames.
+func NewEncoder(w io.Writer, options EncoderOptions) (*Encoder, error) {
+	if options.BitsPerSample == 0 {
+		options.BitsPerSample = 16
+	}
+	if options.BlockSize == 0 {
+		options.BlockSize = 4096
+	}
+	if options.MaxLPCOrder == 0 {
+		options.MaxLPCOrder = 8
+	}
+	switch {
+	case options.Channels < 1 || options.Channels > 8:
+		return nil, fmt.Errorf("flac: %d channels: %w", options.Channels, ErrUnsupported)
+	case options.BitsPerSample < 4 || options.BitsPerSample > 32:
+		return nil, fmt.Errorf("flac: %d bits per sample: %w", options.BitsPerSample, ErrUnsupported)
+	case options.SampleRate < 1 || options.SampleRate >= 1<<20:
+		return nil, fmt.Errorf("flac: sample rate %d: %w", options.SampleRate, ErrUnsupported)
+	case options.BlockSize < 16 || options.BlockSize > 65535:
+		return nil, fmt.Errorf("flac: block size %d: %w", options.BlockSize, ErrUnsupported)
+	case options.MaxLPCOrder > 32:
+		return nil, fmt.Errorf("flac: LPC order %d: %w", options.MaxLPCOrder, ErrUnsupported)
+	}
+	e := &Encoder{
+		w:          w,
+		options:    options,
+		md5:        md5.New(),
+		frameSizes: [2]int{math.MaxInt, 0},
+		block:      make([][]int32, options.Channels),
+	}
+	for c := range e.block {
+		e.block[c] = make([]int32, options.BlockSize)
+	}
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if start, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			e.ws, e.start = ws, start
+		}
+	}
+	if err := e.writeMetadata(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Encoder) writeMetadata() error {
+	buf := []byte(magic)
+	buf = appendBlockHeader(buf, blockStreamInfo, 34, false)
+	buf = e.appendStreamInfo(buf)
+	comments := e.options.Comments
+	if comments == nil {
+		comments = &Comments{}
+	}
+	size := 4 + len(vendor) + 4
+	for _, f := range comments.Fields {
+		size += 4 + len(f)
+	}
+	buf = appendBlockHeader(buf, blockVorbisComment, size, e.ws == nil)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vendor)))
+	buf = append(buf, vendor...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(comments.Fields)))
+	for _, f := range comments.Fields {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(f)))
+		buf = append(buf, f...)
+	}
+	if e.ws != nil {
+		e.seekOffset = int64(len(buf))
+		buf = appendBlockHeader(buf, blockSeekTable, 18*seekPoints, true)
+		buf = appendSeekTable(buf, nil)
+	}
+	_, err := e.w.Write(buf)
+	return err
+}
+
+func appendBlockHeader(buf []byte, kind byte, size int, last bool) []byte {
+	if last {
+		kind |= 0x80
+	}
+	return append(buf, kind, byte(size>>16), byte(size>>8), byte(size))
+}
+
+func (e *Encoder) appendStreamInfo(buf []byte) []byte {
+	o := &e.options
+	buf = binary.BigEndian.AppendUint16(buf, uint16(o.BlockSize))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(o.BlockSize))
+	minFrame, maxFrame := 0, e.frameSizes[1]
+	if maxFrame > 0 {
+		minFrame = e.frameSizes[0]
+	}
+	buf = append(buf, byte(minFrame>>16), byte(minFrame>>8), byte(minFrame))
+	buf = append(buf, byte(maxFrame>>16), byte(maxFrame>>8), byte(maxFrame))
+	x := uint64(o.SampleRate)<<44 | uint64(o.Channels-1)<<41 | uint64(o.BitsPerSample-1)<<36 | e.total&(1<<36-1)
+	buf = binary.BigEndian.AppendUint64(buf, x)
+	if e.closed {
+		return e.md5.Sum(buf)
+	}
+	return append(buf, make([]byte, 16)...)
+}
+
+// appendSeekTable appends seekPoints points chosen evenly from the frames, padded with placeholders.
+func appendSeekTable(buf []byte, frames []seekPoint) []byte {
+	points := 0
+	for i := range seekPoints {
+		if len(frames) == 0 {
+			break
+		}
+		p := frames[i*len(frames)/seekPoints]
+		if i > 0 && i*len(frames)/seekPoints == (i-1)*len(frames)/seekPoints {
+			continue
+		}
+		buf = binary.BigEndian.AppendUint64(buf, p.sample)
+		buf = binary.BigEndian.AppendUint64(buf, p.offset)
+		buf = binary.BigEndian.AppendUint16(buf, p.frames)
+		points++
+	}
+	for ; points < seekPoints; points++ {
+		buf = binary.BigEndian.AppendUint64(buf, placeholderPoint)
+		buf = append(buf, make([]byte, 10)...)
+	}
+	return buf
+}
+
+// Write encodes interleaved samples of Options.BitsPerSample bits.
+// The number of samples must be a multiple of the channel count.
+func (e *Encoder) Write(samples []int32) error {
+	if e.closed {
+		return errors.New("flac: write to closed encoder")
+	}
+	channels := e.options.Channels
+	if len(samples)%channels != 0 {
+		return fmt.Errorf("flac: %d samples are not a multiple of %d channels", len(samples), channels)
+	}
+	for len(samples) > 0 {
+		frames := min(len(samples)/channels, e.options.BlockSize-e.buffered)
+		for f := range frames {
+			for c := range channels {
+				e.block[c][e.buffered+f] = samples[f*channels+c]
+			}
+		}
+		e.updateMD5(samples[:frames*channels])
+		samples = samples[frames*channels:]
+		if e.buffered += frames; e.buffered == e.options.BlockSize {
+			if err := e.encodeFrame(e.buffered); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateMD5 adds samples to the MD5 signature, which covers
+// the interleaved samples as little endian integers of whole bytes.
+func (e *Encoder) updateMD5(samples []int32) {
+	width := (e.options.BitsPerSample + 7) / 8
+	e.md5buf = resize(e.md5buf, len(samples)*width)
+	for i, v := range samples {
+		for b := range width {
+			e.md5buf[i*width+b] = byte(v >> (8 * b))
+		}
+	}
+	e.md5.Write(e.md5buf)
+}
+
+// Close encodes the buffered samples and completes the metadata if the output is seekable.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	if e.buffered > 0 {
+		if err := e.encodeFrame(e.buffered); err != nil {
+			return err
+		}
+	}
+	e.closed = true
+	if e.ws == nil {
+		return nil
+	}
+	end, err := e.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = e.ws.Seek(e.start+int64(len(magic))+4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = e.ws.Write(e.appendStreamInfo(nil)); err != nil {
+		return err
+	}
+	if _, err = e.ws.Seek(e.start+e.seekOffset+4, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = e.ws.Write(appendSeekTable(nil, e.seekTable)); err != nil {
+		return err
+	}
+	_, err = e.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// encodeFrame encodes the n buffered samples of each channel.
+func (e *Encoder) encodeFrame(n int) error {
+	o := &e.options
+	w := &e.bits
+	w.buf = w.buf[:0]
+	channels := make([][]int32, 0, 8)
+	for _, b := range e.block {
+		channels = append(channels, b[:n])
+	}
+	assignment := o.Channels - 1
+	if o.Channels == 2 && o.BitsPerSample < 32 {
+		assignment = e.decorrelate(channels, n)
+	}
+
+	w.write(0xfff8, 16)
+	sizeCode, sizeBits := uint64(7), uint(16)
+	if n <= 256 {
+		sizeCode, sizeBits = 6, 8
+	}
+	for code, size := range blockSizes {
+		if size == n {
+			sizeCode, sizeBits = uint64(code), 0
+			break
+		}
+	}
+	rateCode := uint64(0)
+	for code, rate := range sampleRates {
+		if rate == o.SampleRate && code > 0 {
+			rateCode = uint64(code)
+		}
+	}
+	depthCode := uint64(0)
+	for code, depth := range sampleSizes {
+		if depth == o.BitsPerSample && code > 0 {
+			depthCode = uint64(code)
+		}
+	}
+	w.write(sizeCode, 4)
+	w.write(rateCode, 4)
+	w.write(uint64(assignment), 4)
+	w.write(depthCode, 3)
+	w.write(0, 1)
+	appendUTF8(w, e.frame)
+	if sizeBits > 0 {
+		w.write(uint64(n-1), sizeBits)
+	}
+	var crc byte
+	for _, b := range w.buf {
+		crc = crc8(crc, b)
+	}
+	w.write(uint64(crc), 8)
+
+	for c, x := range channels {
+		bps := uint(o.BitsPerSample)
+		if assignment == leftSide && c == 1 || assignment == rightSide && c == 0 || assignment == midSide && c == 1 {
+			bps++
+		}
+		e.encodeSubframe(x, bps)
+	}
+	w.align()
+	var crc16v uint16
+	for _, b := range w.buf {
+		crc16v = crc16(crc16v, b)
+	}
+	w.write(uint64(crc16v), 16)
+
+	if _, err := e.w.Write(w.buf); err != nil {
+		return err
+	}
+	e.seekTable = append(e.seekTable, seekPoint{sample: e.total, offset: e.frameOffset, frames: uint16(n)})
+	e.frameSizes[0] = min(e.frameSizes[0], len(w.buf))
+	e.frameSizes[1] = max(e.frameSizes[1], len(w.buf))
+	e.frameOffset += uint64(len(w.buf))
+	e.total += uint64(n)
+	e.frame++
+	e.buffered = 0
+	return nil
+}
+
+// decorrelate chooses the cheapest stereo coding, replaces the channels accordingly
+// and returns the channel assignment.
+func (e *Encoder) decorrelate(channels [][]int32, n int) int {
+	left, right := channels[0], channels[1]
+	e.mid, e.side = resize(e.mid, n), resize(e.side, n)
+	for i := range n {
+		e.mid[i] = int32((int64(left[i]) + int64(right[i])) >> 1)
+		e.side[i] = left[i] - right[i]
+	}
+	l, r := estimateBits(left), estimateBits(right)
+	m, s := estimateBits(e.mid), estimateBits(e.side)
+	best, assignment := l+r, 1
+	if l+s < best {
+		best, assignment = l+s, leftSide
+	}
+	if r+s < best {
+		best, assignment = r+s, rightSide
+	}
+	if m+s < best {
+		assignment = midSide
+	}
+	switch assignment {
+	case leftSide:
+		channels[1] = e.side
+	case rightSide:
+		channels[0] = e.side
+	case midSide:
+		channels[0], channels[1] = e.mid, e.side
+	}
+	return assignment
+}
+
+// estimateBits estimates the size of a channel from its smallest fixed predictor residual.
+func estimateBits(x []int32) uint64 {
+	var sums [5]uint64
+	for i := 4; i < len(x); i++ {
+		e0 := int64(x[i])
+		e1 := e0 - int64(x[i-1])
+		e2 := e1 - (int64(x[i-1]) - int64(x[i-2]))
+		e3 := e2 - (int64(x[i-1]) - 2*int64(x[i-2]) + int64(x[i-3]))
+		e4 := e3 - (int64(x[i-1]) - 3*int64(x[i-2]) + 3*int64(x[i-3]) - int64(x[i-4]))
+		for o, v := range [5]int64{e0, e1, e2, e3, e4} {
+			sums[o] += uint64(max(v, -v))
+		}
+	}
+	best := sums[0]
+	for _, s := range sums[1:] {
+		best = min(best, s)
+	}
+	return best
+}
+
+// encodeSubframe encodes a channel with the cheapest of the subframe types.
+func (e *Encoder) encodeSubframe(x []int32, bps uint) {
+	w := &e.bits
+	var or int32
+	constant := true
+	for _, v := range x {
+		or |= v
+		constant = constant && v == x[0]
+	}
+	if constant {
+		w.write(0, 8)
+		w.writeSigned(int64(x[0]), bps)
+		return
+	}
+	wasted := uint(bits.TrailingZeros32(uint32(or)))
+	if wasted > 0 {
+		e.scratch = resize(e.scratch, len(x))
+		for i, v := range x {
+			e.scratch[i] = v >> wasted
+		}
+		x = e.scratch
+		bps -= wasted
+	}
+	writeHeader := func(kind uint64) {
+		w.write(kind<<1|b2u(wasted > 0), 8)
+		if wasted > 0 {
+			w.writeUnary(uint64(wasted - 1))
+		}
+	}
+
+	bestBits := uint64(len(x)) * uint64(bps)
+	bestKind := -1 // verbatim
+	fixedOrder, fixedCoding := 0, riceCoding{}
+	for order := range min(5, len(x)) {
+		coding, ok := e.fixedResidual(x, order)
+		if !ok {
+			continue
+		}
+		if cost := uint64(order)*uint64(bps) + coding.bits; cost < bestBits {
+			bestBits, bestKind, fixedOrder, fixedCoding = cost, 0, order, coding
+		}
+	}
+	var lpc quantizedLPC
+	if maxOrder := min(e.options.MaxLPCOrder, len(x)-1); maxOrder > 0 {
+		for _, candidate := range e.lpcCandidates(x, maxOrder) {
+			coding, ok := e.lpcResidual(x, &candidate)
+			if !ok {
+				continue
+			}
+			cost := uint64(candidate.order)*uint64(bps+lpcPrecision) + 9 + coding.bits
+			if cost < bestBits {
+				bestBits, bestKind, lpc = cost, 1, candidate
+				lpc.coding = coding
+			}
+		}
+	}
+	switch bestKind {
+	case 0:
+		writeHeader(8 + uint64(fixedOrder))
+		for _, v := range x[:fixedOrder] {
+			w.writeSigned(int64(v), bps)
+		}
+		e.fixedResidual(x, fixedOrder)
+		e.writeResidual(fixedCoding, fixedOrder, len(x))
+	case 1:
+		writeHeader(32 + uint64(lpc.order-1))
+		for _, v := range x[:lpc.order] {
+			w.writeSigned(int64(v), bps)
+		}
+		w.write(lpcPrecision-1, 4)
+		w.writeSigned(int64(lpc.shift), 5)
+		for _, c := range lpc.coefs[:lpc.order] {
+			w.writeSigned(int64(c), lpcPrecision)
+		}
+		e.lpcResidual(x, &lpc)
+		e.writeResidual(lpc.coding, lpc.order, len(x))
+	default:
+		writeHeader(1)
+		for _, v := range x {
+			w.writeSigned(int64(v), bps)
+		}
+	}
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fixedResidual computes the residual of a fixed predictor into coded and its Rice coding.
+func (e *Encoder) fixedResidual(x []int32, order int) (riceCoding, bool) {
+	e.coded = resize(e.coded, len(x))
+	for i := order; i < len(x); i++ {
+		var p int64
+		switch order {
+		case 1:
+			p = int64(x[i-1])
+		case 2:
+			p = 2*int64(x[i-1]) - int64(x[i-2])
+		case 3:
+			p = 3*int64(x[i-1]) - 3*int64(x[i-2]) + int64(x[i-3])
+		case 4:
+			p = 4*int64(x[i-1]) - 6*int64(x[i-2]) + 4*int64(x[i-3]) - int64(x[i-4])
+		}
+		r := int64(x[i]) - p
+		if r < math.MinInt32+1 || r > math.MaxInt32 {
+			return riceCoding{}, false
+		}
+		e.coded[i] = zigzag(int32(r))
+	}
+	return chooseRice(e.coded, order), true
+}
+
+func zigzag(v int32) uint32 {
+	return uint32(v<<1) ^ uint32(v>>31)
+}
+
+// quantizedLPC is an LPC predictor with quantized coefficients.
+type quantizedLPC struct {
+	order  int
+	shift  int
+	coefs  [32]int32
+	coding riceCoding
+}
+
+// lpcCandidates computes predictors of several orders up to maxOrder by the Levinson-Durbin recursion.
+func (e *Encoder) lpcCandidates(x []int32, maxOrder int) []quantizedLPC {
+	n := len(x)
+	e.window = resize(e.window, n)
+	// Tukey window with 50% tapering.
+	taper := n / 4
+	for i := range n {
+		w := 1.0
+		if i < taper {
+			w = 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(taper))
+		} else if i >= n-taper {
+			w = 0.5 - 0.5*math.Cos(math.Pi*float64(n-1-i)/float64(taper))
+		}
+		e.window[i] = w * float64(x[i])
+	}
+	var autoc [33]float64
+	for lag := 0; lag <= maxOrder; lag++ {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += e.window[i] * e.window[i-lag]
+		}
+		autoc[lag] = sum
+	}
+	if autoc[0] == 0 {
+		return nil
+	}
+	var candidates []quantizedLPC
+	var lpc, tmp [32]float64
+	err := autoc[0]
+	for i := range maxOrder {
+		acc := autoc[i+1]
+		for j := range i {
+			acc -= lpc[j] * autoc[i-j]
+		}
+		k := acc / err
+		tmp = lpc
+		lpc[i] = k
+		for j := range i {
+			lpc[j] = tmp[j] - k*tmp[i-1-j]
+		}
+		if err *= 1 - k*k; err <= 0 {
+			break
+		}
+		order := i + 1
+		if order == maxOrder || order&(order-1) == 0 {
+			if q, ok := quantizeLPC(lpc[:order]); ok {
+				candidates = append(candidates, q)
+			}
+		}
+	}
+	return candidates
+}
+
+// quantizeLPC quantizes coefficients to lpcPrecision bits with error feedback.
+func quantizeLPC(lpc []float64) (quantizedLPC, bool) {
+	var cmax float64
+	for _, c := range lpc {
+		cmax = max(cmax, math.Abs(c))
+	}
+	if cmax == 0 || math.IsNaN(cmax) || math.IsInf(cmax, 0) {
+		return quantizedLPC{}, false
+	}
+	_, exp := math.Frexp(cmax)
+	shift := lpcPrecision - 1 - exp
+	if shift < 0 {
+		return quantizedLPC{}, false
+	}
+	shift = min(shift, 15)
+	q := quantizedLPC{order: len(lpc), shift: shift}
+	limit := float64(int(1)<<(lpcPrecision-1)) - 1
+	var residue float64
+	for i, c := range lpc {
+		residue += c * float64(int(1)<<shift)
+		v := math.Max(-limit-1, math.Min(limit, math.Round(residue)))
+		residue -= v
+		q.coefs[i] = int32(v)
+	}
+	return q, true
+}
+
+// lpcResidual computes the residual of an LPC predictor into coded and its Rice coding.
+func (e *Encoder) lpcResidual(x []int32, q *quantizedLPC) (riceCoding, bool) {
+	e.coded = resize(e.coded, len(x))
+	for i := q.order; i < len(x); i++ {
+		var p int64
+		for j, c := range q.coefs[:q.order] {
+			p += int64(c) * int64(x[i-1-j])
+		}
+		r := int64(x[i]) - p>>q.shift
+		if r < -(1<<30) || r >= 1<<30 {
+			return riceCoding{}, false
+		}
+		e.coded[i] = zigzag(int32(r))
+	}
+	return chooseRice(e.coded, q.order), true
+}
+
+// riceCoding describes the partitioned Rice coding of a residual.
+type riceCoding struct {
+	bits   uint64
+	order  int
+	params [1 << maxPartitionOrder]uint8
+}
+
+// chooseRice chooses the partition order and Rice parameters for the residual u[order:].
+func chooseRice(u []uint32, order int) riceCoding {
+	n := len(u)
+	maxOrder := 0
+	for maxOrder < maxPartitionOrder && n%(2<<maxOrder) == 0 && n>>(maxOrder+1) > order {
+		maxOrder++
+	}
+	var sums [1 << maxPartitionOrder]uint64
+	partitions := 1 << maxOrder
+	for p := range partitions {
+		start, end := p*n>>maxOrder, (p+1)*n>>maxOrder
+		if p == 0 {
+			start = order
+		}
+		var sum uint64
+		for _, v := range u[start:end] {
+			sum += uint64(v)
+		}
+		sums[p] = sum
+	}
+	best := riceCoding{bits: math.MaxUint64}
+	for po := maxOrder; po >= 0; po-- {
+		c := riceCoding{order: po, bits: 6}
+		parts := 1 << po
+		for p := range parts {
+			count := uint64(n >> po)
+			if p == 0 {
+				count -= uint64(order)
+			}
+			k, cost := bestParam(sums[p], count)
+			c.params[p] = k
+			c.bits += cost
+		}
+		var maxParam uint8
+		for _, k := range c.params[:parts] {
+			maxParam = max(maxParam, k)
+		}
+		c.bits += 4 * uint64(parts)
+		if maxParam > 14 {
+			c.bits += uint64(parts) // 5 bit parameters
+		}
+		if c.bits < best.bits {
+			best = c
+		}
+		if po > 0 {
+			for p := range parts / 2 {
+				sums[p] = sums[2*p] + sums[2*p+1]
+			}
+		}
+	}
+	return best
+}
+
+// bestParam returns the Rice parameter minimizing the estimated size of count values summing to sum.
+func bestParam(sum, count uint64) (uint8, uint64) {
+	bestK, bestCost := uint8(0), uint64(math.MaxUint64)
+	for k := uint8(0); k <= 30; k++ {
+		cost := count*(uint64(k)+1) + sum>>k
+		if cost < bestCost {
+			bestK, bestCost = k, cost
+		}
+		if sum>>k == 0 {
+			break
+		}
+	}
+	return bestK, bestCost
+}
+
+// writeResidual writes the residual held in coded.
+func (e *Encoder) writeResidual(c riceCoding, order, n int) {
+	w := &e.bits
+	parts := 1 << c.order
+	paramBits := uint(4)
+	for _, k := range c.params[:parts] {
+		if k > 14 {
+			paramBits = 5
+		}
+	}
+	w.write(uint64(paramBits-4), 2)
+	w.write(uint64(c.order), 4)
+	i := order
+	for p := range parts {
+		end := (p + 1) * n >> c.order
+		k := uint(c.params[p])
+		w.write(uint64(k), paramBits)
+		for ; i < end; i++ {
+			v := e.coded[i]
+			w.writeUnary(uint64(v >> k))
+			w.write(uint64(v), k)
+		}
+	}
+}
+
+// resize returns buf with the given length, reallocating it only if its capacity is too small.
+func resize[T any](buf []T, size int) []T {
+	if cap(buf) < size {
+		return make([]T, size)
+	}
+	return buf[:size]
+}
@@ -0,0 +1,329 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// file is an in-memory io.WriteSeeker.
+type file struct {
+	data []byte
+	pos  int
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if end := f.pos + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	f.pos += copy(f.data[f.pos:], p)
+	return len(p), nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(f.pos)
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	f.pos = int(offset)
+	return offset, nil
+}
+
+// testSignal returns interleaved samples of bps bits: a sine on the first channel,
+// its scaled copy on the second, noise on the others, followed by silence and full-scale steps.
+func testSignal(frames, channels, bps int) []int32 {
+	rng := rand.New(rand.NewPCG(1, 2))
+	peak := math.Ldexp(1, bps-1) - 1
+	x := make([]int32, frames*channels)
+	for f := range frames {
+		for c := range channels {
+			var v float64
+			switch {
+			case f >= frames*3/4:
+				// Full-scale square wave.
+				v = peak
+				if f/8%2 == 0 {
+					v = -peak - 1
+				}
+			case f >= frames/2:
+				v = 0
+			case c == 0:
+				v = 0.8 * peak * math.Sin(2*math.Pi*440*float64(f)/48000)
+			case c == 1:
+				v = 0.5 * peak * math.Sin(2*math.Pi*440*float64(f)/48000)
+			default:
+				v = 0.1 * peak * (2*rng.Float64() - 1)
+			}
+			x[f*channels+c] = int32(v)
+		}
+	}
+	return x
+}
+
+func encode(t *testing.T, w io.Writer, samples []int32, options EncoderOptions) {
+	t.Helper()
+	e, err := NewEncoder(w, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in pieces that do not line up with the blocks.
+	for len(samples) > 0 {
+		n := min(len(samples), 1000*options.Channels)
+		if err = e.Write(samples[:n]); err != nil {
+			t.Fatal(err)
+		}
+		samples = samples[n:]
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func decodeAll(t *testing.T, d *Decoder) []int32 {
+	t.Helper()
+	var out []int32
+	buf := make([]int32, 777*d.Info().Channels)
+	for {
+		n, err := d.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// md5Of computes the MD5 signature of samples as defined for STREAMINFO.
+func md5Of(samples []int32, bps int) [16]byte {
+	var buf []byte
+	for _, v := range samples {
+		for b := range (bps + 7) / 8 {
+			buf = append(buf, byte(v>>(8*b)))
+		}
+	}
+	return md5.Sum(buf)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options EncoderOptions
+		frames  int
+	}{
+		{"16-bit stereo", EncoderOptions{SampleRate: 48000, Channels: 2}, 20000},
+		{"24-bit stereo", EncoderOptions{SampleRate: 96000, Channels: 2, BitsPerSample: 24}, 20000},
+		{"24-bit mono LPC 32", EncoderOptions{SampleRate: 44100, Channels: 1, BitsPerSample: 24, MaxLPCOrder: 32}, 10000},
+		{"16-bit fixed", EncoderOptions{SampleRate: 48000, Channels: 2, MaxLPCOrder: -1}, 10000},
+		{"8-bit 6 channels", EncoderOptions{SampleRate: 22050, Channels: 6, BitsPerSample: 8, BlockSize: 1152}, 5000},
+		{"20-bit odd rate", EncoderOptions{SampleRate: 12345, Channels: 3, BitsPerSample: 20, BlockSize: 100}, 1234},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			o := test.options
+			bps := o.BitsPerSample
+			if bps == 0 {
+				bps = 16
+			}
+			samples := testSignal(test.frames, o.Channels, bps)
+			var f file
+			encode(t, &f, samples, o)
+			d, err := NewDecoder(bytes.NewReader(f.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := d.Info()
+			if info.SampleRate != o.SampleRate || info.Channels != o.Channels || info.BitsPerSample != bps {
+				t.Errorf("info %+v does not match the options %+v", info, o)
+			}
+			if info.TotalSamples != uint64(test.frames) {
+				t.Errorf("total samples %d, want %d", info.TotalSamples, test.frames)
+			}
+			if info.MD5 != md5Of(samples, bps) {
+				t.Error("MD5 signature mismatch")
+			}
+			if len(f.data) >= len(samples)*bps/8 {
+				t.Errorf("encoded to %d bytes, not smaller than the %d bytes of PCM", len(f.data), len(samples)*bps/8)
+			}
+			got := decodeAll(t, d)
+			if i := mismatch(got, samples); i >= 0 {
+				t.Fatalf("sample %d differs", i)
+			}
+		})
+	}
+}
+
+// mismatch returns the index of the first differing sample, or -1.
+func mismatch(got, want []int32) int {
+	for i := range min(len(got), len(want)) {
+		if got[i] != want[i] {
+			return i
+		}
+	}
+	if len(got) != len(want) {
+		return min(len(got), len(want))
+	}
+	return -1
+}
+
+func TestSource(t *testing.T) {
+	var buf bytes.Buffer
+	e, err := NewEncoder(&buf, EncoderOptions{SampleRate: 48000, Channels: 2, BitsPerSample: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{0, 0.5, -0.25, -1, 0.75, 0.125}
+	if err = WriteSamples(e, want); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]float32, 10)
+	n, err := NewSource[float32](d).ReadSamples(got)
+	if n != len(want) || err != nil && err != io.EOF {
+		t.Fatalf("read %d samples, %v", n, err)
+	}
+	if !slices.Equal(got[:n], want) {
+		t.Errorf("got %v, want %v", got[:n], want)
+	}
+}
+
+func TestNotSeekableOutput(t *testing.T) {
+	samples := testSignal(10000, 2, 16)
+	var buf bytes.Buffer
+	encode(t, &buf, samples, EncoderOptions{SampleRate: 48000, Channels: 2})
+	d, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Info().TotalSamples; n != 0 {
+		t.Errorf("total samples %d, want 0 for an output that is not seekable", n)
+	}
+	if i := mismatch(decodeAll(t, d), samples); i >= 0 {
+		t.Fatalf("sample %d differs", i)
+	}
+	if err = d.Seek(0); !errors.Is(err, ErrNotSeekable) {
+		t.Errorf("Seek: got %v, want %v", err, ErrNotSeekable)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	samples := testSignal(50000, 2, 16)
+	var f file
+	encode(t, &f, samples, EncoderOptions{SampleRate: 48000, Channels: 2, BlockSize: 1024})
+	d, err := NewDecoder(bytes.NewReader(f.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]int32, 20)
+	for _, pos := range []uint64{40000, 0, 1023, 1024, 1025, 30000, 30005, 49990} {
+		if err = d.Seek(pos); err != nil {
+			t.Fatalf("Seek(%d): %v", pos, err)
+		}
+		if d.Position() != pos {
+			t.Errorf("position %d after Seek(%d)", d.Position(), pos)
+		}
+		n, err := d.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := samples[2*pos : 2*pos+uint64(n)]; !slices.Equal(buf[:n], want) {
+			t.Errorf("Seek(%d): read %v, want %v", pos, buf[:n], want)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	comments := &Comments{}
+	comments.Add("title", "Take 1")
+	comments.Add("artist", "A")
+	comments.Add("artist", "B")
+	comments.Set("Title", "Take 2")
+	var buf bytes.Buffer
+	encode(t, &buf, nil, EncoderOptions{SampleRate: 8000, Channels: 1, Comments: comments})
+	d, err := NewDecoder(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := d.Comments()
+	if got == nil || got.Vendor != vendor {
+		t.Fatalf("comments %+v, want vendor %q", got, vendor)
+	}
+	if want := []string{"ARTIST=A", "ARTIST=B", "TITLE=Take 2"}; !slices.Equal(got.Fields, want) {
+		t.Errorf("fields %q, want %q", got.Fields, want)
+	}
+	if v := got.Get("artist"); v != "A" {
+		t.Errorf("Get(artist) = %q, want A", v)
+	}
+	if n, err := d.Read(make([]int32, 10)); n != 0 || err != io.EOF {
+		t.Errorf("Read of an empty stream = %d, %v", n, err)
+	}
+}
+
+func TestCorruptStream(t *testing.T) {
+	var buf bytes.Buffer
+	encode(t, &buf, testSignal(5000, 1, 16), EncoderOptions{SampleRate: 48000, Channels: 1})
+	data := buf.Bytes()
+	data[len(data)-100] ^= 0x10
+	d, err := NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(readerOf(d)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v, want %v", err, ErrCorrupt)
+	}
+	if _, err = NewDecoder(bytes.NewReader([]byte("RIFF...."))); !errors.Is(err, ErrNoFLAC) {
+		t.Errorf("got %v, want %v", err, ErrNoFLAC)
+	}
+}
+
+// readerOf drains a decoder through io.Reader to reach its first error.
+func readerOf(d *Decoder) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		n, err := d.Read(make([]int32, len(p)))
+		return min(n, len(p)), err
+	})
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestCRC(t *testing.T) {
+	// Check values of CRC-8 (polynomial 0x07) and CRC-16/BUYPASS (polynomial 0x8005).
+	var c8 byte
+	var c16 uint16
+	for _, b := range []byte("123456789") {
+		c8, c16 = crc8(c8, b), crc16(c16, b)
+	}
+	if c8 != 0xf4 || c16 != 0xfee8 {
+		t.Errorf("got CRC-8 %#x and CRC-16 %#x, want 0xf4 and 0xfee8", c8, c16)
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	for _, o := range []EncoderOptions{
+		{SampleRate: 48000, Channels: 0},
+		{SampleRate: 48000, Channels: 9},
+		{SampleRate: 48000, Channels: 1, BitsPerSample: 33},
+		{SampleRate: 0, Channels: 1},
+		{SampleRate: 48000, Channels: 1, BlockSize: 8},
+		{SampleRate: 48000, Channels: 1, MaxLPCOrder: 33},
+	} {
+		if _, err := NewEncoder(io.Discard, o); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%+v: got %v, want %v", o, err, ErrUnsupported)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package flac
+
+import (
+	"errors"
+	"math"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Source reads a FLAC stream as a portaudio.Source of any sample type,
+// e.g. to play it from an output stream callback.
+type Source[T pa.Sample] struct {
+	d     *Decoder
+	buf   []int32
+	scale float64
+}
+
+// NewSource creates a source decoding d.
+func NewSource[T pa.Sample](d *Decoder) *Source[T] {
+	return &Source[T]{d: d, scale: math.Ldexp(1, 1-d.info.BitsPerSample)}
+}
+
+// ReadSamples decodes interleaved samples into buf. It returns io.EOF at the end of the stream.
+func (s *Source[T]) ReadSamples(buf []T) (int, error) {
+	s.buf = resize(s.buf, len(buf))
+	n, err := s.d.Read(s.buf)
+	for i, v := range s.buf[:n] {
+		buf[i] = pa.FromFloat64[T](float64(v) * s.scale)
+	}
+	return n, err
+}
+
+// StreamOptions returns encoder options matching the input of a stream.
+func StreamOptions(params *pa.StreamParameters, bitsPerSample int) EncoderOptions {
+	return EncoderOptions{
+		SampleRate:    int(params.SampleRate),
+		Channels:      params.Input.ChannelCount,
+		BitsPerSample: bitsPerSample,
+	}
+}
+
+// WriteSamples encodes interleaved samples of any sample type,
+// converting them to the bit depth of the encoder.
+func WriteSamples[T pa.Sample](e *Encoder, buf []T) error {
+	bps := e.options.BitsPerSample
+	scale := math.Ldexp(1, bps-1)
+	e.converted = resize(e.converted, len(buf))
+	for i, v := range buf {
+		e.converted[i] = int32(max(-scale, min(scale-1, math.Round(pa.ToFloat64(v)*scale))))
+	}
+	return e.Write(e.converted)
+}
+
+// RecordStream reads frames frames from a blocking input stream and encodes them.
+// Transient errors such as input overflows are ignored, so an overflow leaves a gap in the recording.
+func RecordStream[T pa.Sample](s *pa.Stream[T], e *Encoder, frames int) error {
+	channels := e.options.Channels
+	for frames > 0 {
+		buf, err := s.Read()
+		if err != nil {
+			if pa.IsTransient(err) {
+				continue
+			}
+			return err
+		}
+		if len(buf) == 0 {
+			return errors.New("flac: stream returned no samples")
+		}
+		n := min(len(buf), frames*channels)
+		if err = WriteSamples(e, buf[:n]); err != nil {
+			return err
+		}
+		frames -= n / channels
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package g711 converts between 16-bit linear PCM and the G.711 µ-law and A-law
+// companding used by telephony, e.g. as the PCMU and PCMA RTP payloads.
+//
+// Law converts buffers and single samples, Encoder and Decoder wrap io.Writer and
+// io.Reader, and Parameters describes the 8 kHz mono Int16 streams telephony expects.
+package g711
+
+import (
+	"fmt"
+	"math/bits"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// SampleRate is the sample rate of G.711.
+const SampleRate = 8000
+
+// FrameSize is the number of samples of a 20 ms packet, the default RTP packetization.
+const FrameSize = 160
+
+// Law is a G.711 companding law.
+type Law int
+
+const (
+	ULaw Law = iota // µ-law, used in North America and Japan
+	ALaw            // A-law, used elsewhere
+)
+
+func (l Law) String() string {
+	switch l {
+	case ULaw:
+		return "µ-law"
+	case ALaw:
+		return "A-law"
+	}
+	return fmt.Sprintf("Law(%d)", int(l))
+}
+
+// PayloadType returns the static RTP payload type, 0 (PCMU) or 8 (PCMA).
+func (l Law) PayloadType() uint8 {
+	if l == ALaw {
+		return 8
+	}
+	return 0
+}
+
+var ulawTable, alawTable [256]int16
+
+func init() {
+	for i := range 256 {
+		ulawTable[i] = decodeULaw(byte(i))
+		alawTable[i] = decodeALaw(byte(i))
+	}
+}
+
+// EncodeSample compands a linear sample.
+func (l Law) EncodeSample(s int16) byte {
+	if l == ALaw {
+		return encodeALaw(s)
+	}
+	return encodeULaw(s)
+}
+
+// DecodeSample expands a companded sample.
+func (l Law) DecodeSample(b byte) int16 {
+	if l == ALaw {
+		return alawTable[b]
+	}
+	return ulawTable[b]
+}
+
+// Encode compands linear samples from src into dst and returns the number of
+// converted samples, the smaller of both lengths.
+func (l Law) Encode(dst []byte, src []int16) int {
+	n := min(len(dst), len(src))
+	if l == ALaw {
+		for i, s := range src[:n] {
+			dst[i] = encodeALaw(s)
+		}
+	} else {
+		for i, s := range src[:n] {
+			dst[i] = encodeULaw(s)
+		}
+	}
+	return n
+}
+
+// Decode expands companded samples from src into dst and returns the number of
+// converted samples, the smaller of both lengths.
+func (l Law) Decode(dst []int16, src []byte) int {
+	n := min(len(dst), len(src))
+	table := &ulawTable
+	if l == ALaw {
+		table = &alawTable
+	}
+	for i, b := range src[:n] {
+		dst[i] = table[b]
+	}
+	return n
+}
+
+// segment returns the companding segment of a magnitude whose first segment ends below 1<<shift.
+func segment(v int, shift int) int {
+	return bits.Len(uint(v) >> shift)
+}
+
+func encodeULaw(s int16) byte {
+	const clip, bias = 8159, 0x21
+	v := int(s) >> 2 // 14-bit magnitude
+	mask := byte(0xff)
+	if v < 0 {
+		v = -v
+		mask = 0x7f
+	}
+	v = min(v, clip) + bias
+	seg := segment(v, 6)
+	if seg >= 8 {
+		return 0x7f ^ mask
+	}
+	return (byte(seg<<4) | byte(v>>(seg+1))&0x0f) ^ mask
+}
+
+func decodeULaw(b byte) int16 {
+	b = ^b
+	t := (int(b&0x0f)<<3 + 0x84) << ((b & 0x70) >> 4)
+	if b&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+func encodeALaw(s int16) byte {
+	v := int(s) >> 3 // 13-bit magnitude
+	mask := byte(0xd5)
+	if v < 0 {
+		v = -v - 1
+		mask = 0x55
+	}
+	seg := segment(v, 5)
+	if seg >= 8 {
+		return 0x7f ^ mask
+	}
+	shift := max(seg, 1)
+	return (byte(seg<<4) | byte(v>>shift)&0x0f) ^ mask
+}
+
+func decodeALaw(b byte) int16 {
+	b ^= 0x55
+	t := int(b&0x0f) << 4
+	switch seg := (b & 0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if b&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
+
+// Parameters returns parameters for 8 kHz mono Int16 streams with 20 ms buffers
+// on the given devices, one of which may be nil. Devices that cannot run at 8 kHz
+// need a host API that resamples, such as an ALSA plug device.
+func Parameters(in, out *pa.DeviceInfo) *pa.StreamParameters {
+	params := pa.LowLatencyParameters(in, out)
+	if in != nil {
+		params.Input.ChannelCount = 1
+	}
+	if out != nil {
+		params.Output.ChannelCount = 1
+	}
+	params.SampleRate = SampleRate
+	params.FramesPerBuffer = FrameSize
+	params.SampleFormat = pa.Int16
+	return params
+}
+
+// DefaultParameters returns Parameters for the default devices.
+func DefaultParameters() *pa.StreamParameters {
+	return Parameters(pa.DefaultInputDevice(), pa.DefaultOutputDevice())
+}
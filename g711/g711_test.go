@@ -0,0 +1,247 @@
+package g711
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+// The reference tables and vectors were generated with the audioop module of
+// CPython, which implements the G.711 reference algorithms of Sun Microsystems.
+
+var ulawReference = [256]int16{
+	-32124, -31100, -30076, -29052, -28028, -27004, -25980, -24956, -23932, -22908, -21884, -20860, -19836, -18812, -17788, -16764,
+	-15996, -15484, -14972, -14460, -13948, -13436, -12924, -12412, -11900, -11388, -10876, -10364, -9852, -9340, -8828, -8316,
+	-7932, -7676, -7420, -7164, -6908, -6652, -6396, -6140, -5884, -5628, -5372, -5116, -4860, -4604, -4348, -4092,
+	-3900, -3772, -3644, -3516, -3388, -3260, -3132, -3004, -2876, -2748, -2620, -2492, -2364, -2236, -2108, -1980,
+	-1884, -1820, -1756, -1692, -1628, -1564, -1500, -1436, -1372, -1308, -1244, -1180, -1116, -1052, -988, -924,
+	-876, -844, -812, -780, -748, -716, -684, -652, -620, -588, -556, -524, -492, -460, -428, -396,
+	-372, -356, -340, -324, -308, -292, -276, -260, -244, -228, -212, -196, -180, -164, -148, -132,
+	-120, -112, -104, -96, -88, -80, -72, -64, -56, -48, -40, -32, -24, -16, -8, 0,
+	32124, 31100, 30076, 29052, 28028, 27004, 25980, 24956, 23932, 22908, 21884, 20860, 19836, 18812, 17788, 16764,
+	15996, 15484, 14972, 14460, 13948, 13436, 12924, 12412, 11900, 11388, 10876, 10364, 9852, 9340, 8828, 8316,
+	7932, 7676, 7420, 7164, 6908, 6652, 6396, 6140, 5884, 5628, 5372, 5116, 4860, 4604, 4348, 4092,
+	3900, 3772, 3644, 3516, 3388, 3260, 3132, 3004, 2876, 2748, 2620, 2492, 2364, 2236, 2108, 1980,
+	1884, 1820, 1756, 1692, 1628, 1564, 1500, 1436, 1372, 1308, 1244, 1180, 1116, 1052, 988, 924,
+	876, 844, 812, 780, 748, 716, 684, 652, 620, 588, 556, 524, 492, 460, 428, 396,
+	372, 356, 340, 324, 308, 292, 276, 260, 244, 228, 212, 196, 180, 164, 148, 132,
+	120, 112, 104, 96, 88, 80, 72, 64, 56, 48, 40, 32, 24, 16, 8, 0,
+}
+
+var alawReference = [256]int16{
+	-5504, -5248, -6016, -5760, -4480, -4224, -4992, -4736, -7552, -7296, -8064, -7808, -6528, -6272, -7040, -6784,
+	-2752, -2624, -3008, -2880, -2240, -2112, -2496, -2368, -3776, -3648, -4032, -3904, -3264, -3136, -3520, -3392,
+	-22016, -20992, -24064, -23040, -17920, -16896, -19968, -18944, -30208, -29184, -32256, -31232, -26112, -25088, -28160, -27136,
+	-11008, -10496, -12032, -11520, -8960, -8448, -9984, -9472, -15104, -14592, -16128, -15616, -13056, -12544, -14080, -13568,
+	-344, -328, -376, -360, -280, -264, -312, -296, -472, -456, -504, -488, -408, -392, -440, -424,
+	-88, -72, -120, -104, -24, -8, -56, -40, -216, -200, -248, -232, -152, -136, -184, -168,
+	-1376, -1312, -1504, -1440, -1120, -1056, -1248, -1184, -1888, -1824, -2016, -1952, -1632, -1568, -1760, -1696,
+	-688, -656, -752, -720, -560, -528, -624, -592, -944, -912, -1008, -976, -816, -784, -880, -848,
+	5504, 5248, 6016, 5760, 4480, 4224, 4992, 4736, 7552, 7296, 8064, 7808, 6528, 6272, 7040, 6784,
+	2752, 2624, 3008, 2880, 2240, 2112, 2496, 2368, 3776, 3648, 4032, 3904, 3264, 3136, 3520, 3392,
+	22016, 20992, 24064, 23040, 17920, 16896, 19968, 18944, 30208, 29184, 32256, 31232, 26112, 25088, 28160, 27136,
+	11008, 10496, 12032, 11520, 8960, 8448, 9984, 9472, 15104, 14592, 16128, 15616, 13056, 12544, 14080, 13568,
+	344, 328, 376, 360, 280, 264, 312, 296, 472, 456, 504, 488, 408, 392, 440, 424,
+	88, 72, 120, 104, 24, 8, 56, 40, 216, 200, 248, 232, 152, 136, 184, 168,
+	1376, 1312, 1504, 1440, 1120, 1056, 1248, 1184, 1888, 1824, 2016, 1952, 1632, 1568, 1760, 1696,
+	688, 656, 752, 720, 560, 528, 624, 592, 944, 912, 1008, 976, 816, 784, 880, 848,
+}
+
+// encodeReference are linear samples around the segment boundaries and their µ-law and A-law codes.
+var encodeReference = []struct {
+	s          int16
+	ulaw, alaw byte
+}{
+	{-32768, 0x00, 0x2a},
+	{-32767, 0x00, 0x2a},
+	{-32124, 0x00, 0x2a},
+	{-32636, 0x00, 0x2a},
+	{-1000, 0x4e, 0x7a},
+	{-256, 0x67, 0x5a},
+	{-33, 0x7a, 0x57},
+	{-32, 0x7b, 0x54},
+	{-9, 0x7d, 0x55},
+	{-8, 0x7e, 0x55},
+	{-7, 0x7e, 0x55},
+	{-1, 0x7e, 0x55},
+	{0, 0xff, 0xd5},
+	{1, 0xff, 0xd5},
+	{7, 0xfe, 0xd5},
+	{8, 0xfe, 0xd5},
+	{9, 0xfe, 0xd5},
+	{31, 0xfb, 0xd4},
+	{32, 0xfb, 0xd7},
+	{33, 0xfb, 0xd7},
+	{100, 0xf2, 0xd3},
+	{255, 0xe7, 0xda},
+	{256, 0xe7, 0xc5},
+	{1000, 0xce, 0xfa},
+	{4000, 0xaf, 0x9a},
+	{32124, 0x80, 0xaa},
+	{32123, 0x80, 0xaa},
+	{32767, 0x80, 0xaa},
+}
+
+func TestDecodeAllCodes(t *testing.T) {
+	for _, test := range []struct {
+		law  Law
+		want *[256]int16
+	}{{ULaw, &ulawReference}, {ALaw, &alawReference}} {
+		for c := range 256 {
+			if got := test.law.DecodeSample(byte(c)); got != test.want[c] {
+				t.Errorf("%v: code %#02x decodes as %d, want %d", test.law, c, got, test.want[c])
+			}
+		}
+	}
+}
+
+func TestEncodeReference(t *testing.T) {
+	for _, test := range encodeReference {
+		if got := ULaw.EncodeSample(test.s); got != test.ulaw {
+			t.Errorf("µ-law: %d encodes as %#02x, want %#02x", test.s, got, test.ulaw)
+		}
+		if got := ALaw.EncodeSample(test.s); got != test.alaw {
+			t.Errorf("A-law: %d encodes as %#02x, want %#02x", test.s, got, test.alaw)
+		}
+	}
+}
+
+func TestEncodeAllCodes(t *testing.T) {
+	for _, law := range []Law{ULaw, ALaw} {
+		// Every code but µ-law's negative zero survives decoding and encoding.
+		for c := range 256 {
+			if law == ULaw && c == 0x7f {
+				continue
+			}
+			if got := law.EncodeSample(law.DecodeSample(byte(c))); got != byte(c) {
+				t.Errorf("%v: code %#02x encodes back as %#02x", law, c, got)
+			}
+		}
+		// Encoding is monotonic and the quantization error stays within one step.
+		prev := law.DecodeSample(law.EncodeSample(-32768))
+		for s := -32768; s <= 32767; s++ {
+			v := law.DecodeSample(law.EncodeSample(int16(s)))
+			if v < prev {
+				t.Fatalf("%v: %d decodes as %d, below %d of the previous sample", law, s, v, prev)
+			}
+			prev = v
+			if err, limit := abs(int(v)-s), max(16, abs(s)/16); err > limit {
+				t.Fatalf("%v: %d decodes as %d", law, s, v)
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestConvertBuffers(t *testing.T) {
+	src := []int16{-32768, -1000, 0, 1000, 32767}
+	for _, law := range []Law{ULaw, ALaw} {
+		codes := make([]byte, 3)
+		if n := law.Encode(codes, src); n != 3 {
+			t.Errorf("%v: encoded %d samples into 3 bytes", law, n)
+		}
+		codes = make([]byte, len(src))
+		law.Encode(codes, src)
+		pcm := make([]int16, len(src)+2)
+		if n := law.Decode(pcm, codes); n != len(src) {
+			t.Errorf("%v: decoded %d samples, want %d", law, n, len(src))
+		}
+		for i, c := range codes {
+			if c != law.EncodeSample(src[i]) || pcm[i] != law.DecodeSample(c) {
+				t.Errorf("%v: sample %d converted differently from EncodeSample and DecodeSample", law, i)
+			}
+		}
+	}
+	if ULaw.PayloadType() != 0 || ALaw.PayloadType() != 8 {
+		t.Error("wrong RTP payload types")
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	pcm := make([]int16, 1000)
+	for i := range pcm {
+		pcm[i] = int16(i*131 - 32768)
+	}
+	raw := make([]byte, 2*len(pcm))
+	for i, s := range pcm {
+		binary.LittleEndian.PutUint16(raw[2*i:], uint16(s))
+	}
+	for _, law := range []Law{ULaw, ALaw} {
+		want := make([]byte, len(pcm))
+		law.Encode(want, pcm)
+
+		// Writes split samples across calls.
+		var codes bytes.Buffer
+		e := NewEncoder(&codes, law)
+		for rest := raw; len(rest) > 0; {
+			n := min(len(rest), 7)
+			if k, err := e.Write(rest[:n]); k != n || err != nil {
+				t.Fatalf("%v: Write = %d, %v", law, k, err)
+			}
+			rest = rest[n:]
+		}
+		if !bytes.Equal(codes.Bytes(), want) {
+			t.Fatalf("%v: Encoder output differs from Encode", law)
+		}
+
+		// Reads of odd sizes from a reader returning one byte at a time.
+		expanded := make([]int16, len(pcm))
+		law.Decode(expanded, want)
+		wantRaw := make([]byte, 2*len(pcm))
+		for i, s := range expanded {
+			binary.LittleEndian.PutUint16(wantRaw[2*i:], uint16(s))
+		}
+		d := NewDecoder(iotest.OneByteReader(bytes.NewReader(want)), law)
+		var got []byte
+		buf := make([]byte, 3)
+		for {
+			n, err := d.Read(buf)
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Equal(got, wantRaw) {
+			t.Fatalf("%v: Decoder output differs from Decode", law)
+		}
+		if err := iotest.TestReader(NewDecoder(bytes.NewReader(want), law), wantRaw); err != nil {
+			t.Errorf("%v: %v", law, err)
+		}
+
+		samples := make([]int16, 300)
+		d = NewDecoder(iotest.OneByteReader(bytes.NewReader(want)), law)
+		var all []int16
+		for {
+			n, err := d.ReadSamples(samples)
+			all = append(all, samples[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil || n != len(samples) {
+				t.Fatalf("%v: ReadSamples = %d, %v", law, n, err)
+			}
+		}
+		if !slices.Equal(all, expanded) {
+			t.Errorf("%v: ReadSamples output differs from Decode", law)
+		}
+	}
+}
+
+func TestParameters(t *testing.T) {
+	p := Parameters(nil, nil)
+	if p.SampleRate != SampleRate || p.FramesPerBuffer != FrameSize || p.Input.ChannelCount != 0 {
+		t.Errorf("got %+v", p)
+	}
+}
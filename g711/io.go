@@ -0,0 +1,131 @@
+package g711
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Encoder is an io.Writer compressing 16-bit little-endian PCM into G.711 written to
+// an underlying writer, e.g. an RTP payload packetizer.
+type Encoder struct {
+	w   io.Writer
+	law Law
+	odd []byte // a trailing byte of an incomplete sample
+	buf []byte
+	pcm []int16
+}
+
+// NewEncoder creates an encoder writing to w.
+func NewEncoder(w io.Writer, law Law) *Encoder {
+	return &Encoder{w: w, law: law}
+}
+
+// Write compresses little-endian PCM. A trailing odd byte is kept until the next call.
+func (e *Encoder) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(e.odd) > 0 {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		s := int16(binary.LittleEndian.Uint16([]byte{e.odd[0], p[0]}))
+		e.odd = e.odd[:0]
+		p = p[1:]
+		if err := e.write([]byte{e.law.EncodeSample(s)}); err != nil {
+			return 0, err
+		}
+	}
+	samples := len(p) / 2
+	e.pcm = resize(e.pcm, samples)
+	for i := range e.pcm {
+		e.pcm[i] = int16(binary.LittleEndian.Uint16(p[2*i:]))
+	}
+	if err := e.WriteSamples(e.pcm); err != nil {
+		return 0, err
+	}
+	if len(p)%2 != 0 {
+		e.odd = append(e.odd, p[len(p)-1])
+	}
+	return n, nil
+}
+
+// WriteSamples compresses linear samples, e.g. the buffers read from a Stream[int16].
+func (e *Encoder) WriteSamples(samples []int16) error {
+	e.buf = resize(e.buf, len(samples))
+	e.law.Encode(e.buf, samples)
+	return e.write(e.buf)
+}
+
+func (e *Encoder) write(p []byte) error {
+	_, err := e.w.Write(p)
+	return err
+}
+
+// Decoder is an io.Reader expanding G.711 read from an underlying reader
+// into 16-bit little-endian PCM. It is also a portaudio.Source[int16].
+type Decoder struct {
+	r   io.Reader
+	law Law
+	buf []byte
+	pcm []int16
+	odd []byte // the second byte of a sample that did not fit the last Read
+}
+
+// NewDecoder creates a decoder reading from r.
+func NewDecoder(r io.Reader, law Law) *Decoder {
+	return &Decoder{r: r, law: law}
+}
+
+// Read expands companded samples into little-endian PCM.
+func (d *Decoder) Read(p []byte) (int, error) {
+	n := copy(p, d.odd)
+	d.odd = d.odd[n:]
+	if n == len(p) {
+		return n, nil
+	}
+	samples := (len(p) - n + 1) / 2
+	d.pcm = resize(d.pcm, samples)
+	k, err := d.read(d.pcm, false)
+	for _, s := range d.pcm[:k] {
+		var b [2]byte
+		binary.LittleEndian.PutUint16(b[:], uint16(s))
+		m := copy(p[n:], b[:])
+		n += m
+		if m < 2 {
+			d.odd = append(d.odd[:0], b[1])
+		}
+	}
+	if n > 0 && err == io.EOF && len(d.odd) > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadSamples expands companded samples into buf, e.g. the output buffer of a Stream[int16].
+// It returns io.EOF once the underlying reader is exhausted.
+func (d *Decoder) ReadSamples(buf []int16) (int, error) {
+	return d.read(buf, true)
+}
+
+// read expands samples from a single read of the underlying reader, or until buf is full.
+func (d *Decoder) read(buf []int16, full bool) (int, error) {
+	d.buf = resize(d.buf, len(buf))
+	var n int
+	var err error
+	if full {
+		n, err = io.ReadFull(d.r, d.buf)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+	} else {
+		n, err = d.r.Read(d.buf)
+	}
+	return d.law.Decode(buf, d.buf[:n]), err
+}
+
+// resize returns buf with the given length, reallocating it only if its capacity is too small.
+func resize[T any](buf []T, size int) []T {
+	if cap(buf) < size {
+		return make([]T, size)
+	}
+	return buf[:size]
+}
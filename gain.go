@@ -0,0 +1,208 @@
+package portaudio
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// RampShape is the curve a gain follows when it changes.
+type RampShape int32
+
+const (
+	// LinearRamp changes the gain by equal steps and reaches the target after the ramp time.
+	LinearRamp RampShape = iota
+	// ExponentialRamp approaches the target exponentially and is within -60dB of it after the ramp time.
+	ExponentialRamp
+)
+
+// DefaultRampTime is the ramp time of new gains.
+const DefaultRampTime = 10 * time.Millisecond
+
+// DBToLinear converts a gain in decibels to a linear factor.
+func DBToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// LinearToDB converts a linear factor to a gain in decibels.
+func LinearToDB(gain float64) float64 {
+	return 20 * math.Log10(gain)
+}
+
+// gainControl holds a gain set from any goroutine and ramps towards it on the audio thread.
+type gainControl struct {
+	target   atomic.Uint64 // math.Float64bits of the linear gain
+	muted    atomic.Bool
+	rampTime atomic.Int64
+	shape    atomic.Int32
+
+	// State owned by the audio thread.
+	current     float64
+	goal        float64
+	step        float64
+	exponential bool
+	started     bool
+}
+
+func (c *gainControl) init(gain float64) {
+	c.target.Store(math.Float64bits(gain))
+	c.rampTime.Store(int64(DefaultRampTime))
+}
+
+func (c *gainControl) set(gain float64) {
+	c.target.Store(math.Float64bits(gain))
+}
+
+func (c *gainControl) get() float64 {
+	return math.Float64frombits(c.target.Load())
+}
+
+// begin prepares the ramp for a buffer. It must be called on the audio thread before advance.
+func (c *gainControl) begin(sampleRate float64) {
+	goal := c.get()
+	if c.muted.Load() {
+		goal = 0
+	}
+	if !c.started {
+		c.current, c.goal, c.started = goal, goal, true
+		return
+	}
+	if goal == c.goal {
+		return
+	}
+	c.goal = goal
+	c.exponential = RampShape(c.shape.Load()) == ExponentialRamp
+	frames := time.Duration(c.rampTime.Load()).Seconds() * sampleRate
+	switch {
+	case frames < 1:
+		c.current, c.step = goal, 0
+	case c.exponential:
+		c.step = -math.Expm1(math.Log(1e-3) / frames)
+	default:
+		c.step = (goal - c.current) / frames
+	}
+}
+
+// advance returns the gain of the next frame.
+func (c *gainControl) advance() float64 {
+	g := c.current
+	if c.current == c.goal {
+		return g
+	}
+	if c.exponential {
+		c.current += (c.goal - c.current) * c.step
+		if math.Abs(c.goal-c.current) < 1e-6 {
+			c.current = c.goal
+		}
+		return g
+	}
+	c.current += c.step
+	if c.step > 0 && c.current > c.goal || c.step < 0 && c.current < c.goal || c.step == 0 {
+		c.current = c.goal
+	}
+	return g
+}
+
+// Gain is a volume control with mute that changes the gain with click-free ramps.
+// The setters may be called from any goroutine while the processing methods run on the audio thread.
+// Processing does not allocate.
+type Gain[T Sample] struct {
+	control    gainControl
+	sampleRate float64
+}
+
+// NewGain creates a gain of the given linear factor for audio of the given sample rate.
+func NewGain[T Sample](gain, sampleRate float64) *Gain[T] {
+	g := &Gain[T]{sampleRate: sampleRate}
+	g.control.init(gain)
+	return g
+}
+
+// SetLinear sets the gain as a linear factor.
+func (g *Gain[T]) SetLinear(gain float64) {
+	g.control.set(gain)
+}
+
+// Linear returns the gain as a linear factor.
+func (g *Gain[T]) Linear() float64 {
+	return g.control.get()
+}
+
+// SetDB sets the gain in decibels.
+func (g *Gain[T]) SetDB(db float64) {
+	g.control.set(DBToLinear(db))
+}
+
+// DB returns the gain in decibels.
+func (g *Gain[T]) DB() float64 {
+	return LinearToDB(g.control.get())
+}
+
+// SetMute mutes or unmutes the audio. The gain is kept.
+func (g *Gain[T]) SetMute(mute bool) {
+	g.control.muted.Store(mute)
+}
+
+// Muted reports whether the audio is muted.
+func (g *Gain[T]) Muted() bool {
+	return g.control.muted.Load()
+}
+
+// SetRamp sets the duration and the shape of the transitions to new gains.
+func (g *Gain[T]) SetRamp(d time.Duration, shape RampShape) {
+	g.control.rampTime.Store(int64(d))
+	g.control.shape.Store(int32(shape))
+}
+
+// Process applies the gain to interleaved frames with the given channel count.
+func (g *Gain[T]) Process(buf []T, channels int) {
+	c := &g.control
+	c.begin(g.sampleRate)
+	for f := 0; f+channels <= len(buf); f += channels {
+		gain := c.advance()
+		if gain == 1 {
+			continue
+		}
+		for i := f; i < f+channels; i++ {
+			buf[i] = FromFloat64[T](gain * ToFloat64(buf[i]))
+		}
+	}
+}
+
+// ProcessPlanar applies the gain to one buffer per channel.
+func (g *Gain[T]) ProcessPlanar(bufs [][]T) {
+	c := &g.control
+	c.begin(g.sampleRate)
+	if len(bufs) == 0 {
+		return
+	}
+	for f := range bufs[0] {
+		gain := c.advance()
+		if gain == 1 {
+			continue
+		}
+		for _, buf := range bufs {
+			buf[f] = FromFloat64[T](gain * ToFloat64(buf[f]))
+		}
+	}
+}
+
+// ProcessInput applies the gain to the input of s. It is meant to be called from the stream callback
+// or after a blocking Read.
+func (g *Gain[T]) ProcessInput(s *Stream[T]) {
+	if s.params.SampleFormat.IsNonInterleaved() {
+		g.ProcessPlanar(s.inS)
+		return
+	}
+	g.Process(s.in, s.params.Input.ChannelCount)
+}
+
+// ProcessOutput applies the gain to the output of s. It is meant to be called from the stream callback
+// after the output has been written.
+func (g *Gain[T]) ProcessOutput(s *Stream[T]) {
+	if s.params.SampleFormat.IsNonInterleaved() {
+		g.ProcessPlanar(s.outS)
+		return
+	}
+	g.Process(s.out, s.params.Output.ChannelCount)
+}
@@ -0,0 +1,162 @@
+package portaudio
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gains returns the gain applied to each of frames mono frames of ones, processed in buffers of at most size frames.
+func gains(g *Gain[float32], frames, size int) []float64 {
+	var out []float64
+	for len(out) < frames {
+		buf := ones(min(size, frames-len(out)))
+		g.Process(buf, 1)
+		for _, v := range buf {
+			out = append(out, float64(v))
+		}
+	}
+	return out
+}
+
+func ones(n int) []float32 {
+	buf := make([]float32, n)
+	for i := range buf {
+		buf[i] = 1
+	}
+	return buf
+}
+
+func TestDecibels(t *testing.T) {
+	for _, test := range []struct{ db, linear float64 }{{0, 1}, {-20, 0.1}, {20, 10}, {-6.020599913279624, 0.5}} {
+		if got := DBToLinear(test.db); math.Abs(got-test.linear) > 1e-12 {
+			t.Errorf("DBToLinear(%v) = %v, want %v", test.db, got, test.linear)
+		}
+		if got := LinearToDB(test.linear); math.Abs(got-test.db) > 1e-12 {
+			t.Errorf("LinearToDB(%v) = %v, want %v", test.linear, got, test.db)
+		}
+	}
+	if !math.IsInf(LinearToDB(0), -1) {
+		t.Errorf("LinearToDB(0) = %v", LinearToDB(0))
+	}
+	g := NewGain[float32](1, 48000)
+	g.SetDB(-20)
+	if math.Abs(g.Linear()-0.1) > 1e-12 || math.Abs(g.DB()+20) > 1e-12 {
+		t.Errorf("SetDB(-20): Linear = %v, DB = %v", g.Linear(), g.DB())
+	}
+}
+
+func TestGainLinearRamp(t *testing.T) {
+	// At 1000Hz the default ramp of 10ms lasts 10 frames.
+	g := NewGain[float32](1, 1000)
+	if got := gains(g, 5, 5); got[0] != 1 || got[4] != 1 {
+		t.Fatalf("initial gain %v", got)
+	}
+	g.SetLinear(0)
+	for _, size := range []int{20, 3} {
+		got := gains(g, 20, size)
+		for f, v := range got {
+			want := max(0, 1-0.1*float64(f))
+			if math.Abs(v-want) > 1e-6 {
+				t.Fatalf("buffers of %d: frame %d = %v, want %v", size, f, v, want)
+			}
+		}
+		g.SetLinear(1)
+		gains(g, 20, 20)
+		g.SetLinear(0)
+	}
+}
+
+func TestGainFirstBuffer(t *testing.T) {
+	// The first buffer starts at the gain without a ramp.
+	g := NewGain[float32](1, 1000)
+	g.SetLinear(0.5)
+	if got := gains(g, 3, 3); got[0] != 0.5 || got[2] != 0.5 {
+		t.Errorf("got %v, want 0.5", got)
+	}
+}
+
+func TestGainExponentialRamp(t *testing.T) {
+	g := NewGain[float32](1, 1000)
+	g.SetRamp(100*time.Millisecond, ExponentialRamp)
+	gains(g, 1, 1)
+	g.SetLinear(0)
+	got := gains(g, 300, 64)
+	for f := 1; f < len(got); f++ {
+		if got[f] > got[f-1] {
+			t.Fatalf("frame %d = %v rises from %v", f, got[f], got[f-1])
+		}
+	}
+	// Within -60dB after the ramp time.
+	if got[100] > 1e-3+1e-6 || got[90] < 1e-3 {
+		t.Errorf("frame 90 = %v, frame 100 = %v, want -60dB at frame 100", got[90], got[100])
+	}
+	if got[299] != 0 {
+		t.Errorf("frame 299 = %v, want 0", got[299])
+	}
+}
+
+func TestGainMute(t *testing.T) {
+	g := NewGain[float32](0.5, 1000)
+	g.SetRamp(0, LinearRamp)
+	gains(g, 1, 1)
+	g.SetMute(true)
+	if got := gains(g, 2, 2); got[0] != 0 || !g.Muted() || g.Linear() != 0.5 {
+		t.Errorf("muted: gain %v, Muted = %v, Linear = %v", got, g.Muted(), g.Linear())
+	}
+	g.SetMute(false)
+	if got := gains(g, 2, 2); got[0] != 0.5 {
+		t.Errorf("unmuted: gain %v", got)
+	}
+}
+
+func TestGainPlanar(t *testing.T) {
+	a := NewGain[int16](1, 1000)
+	b := NewGain[int16](1, 1000)
+	frames := make([]int16, 2*20)
+	planar := [][]int16{make([]int16, 20), make([]int16, 20)}
+	for f := range 20 {
+		frames[2*f], frames[2*f+1] = 10000, -20000
+		planar[0][f], planar[1][f] = 10000, -20000
+	}
+	a.Process(frames[:2], 2)
+	b.ProcessPlanar([][]int16{planar[0][:1], planar[1][:1]})
+	a.SetLinear(0.25)
+	b.SetLinear(0.25)
+	a.Process(frames[2:], 2)
+	b.ProcessPlanar([][]int16{planar[0][1:], planar[1][1:]})
+	for f := range 20 {
+		if frames[2*f] != planar[0][f] || frames[2*f+1] != planar[1][f] {
+			t.Fatalf("frame %d = %v, planar %v, %v", f, frames[2*f:2*f+2], planar[0][f], planar[1][f])
+		}
+	}
+	if frames[2*19] != 2500 || frames[2*19+1] != -5000 {
+		t.Errorf("last frame = %v", frames[2*19:])
+	}
+}
+
+func TestGainStream(t *testing.T) {
+	for _, format := range []SampleFormat{Float32, Float32 | NonInterleaved} {
+		t.Run(format.String(), func(t *testing.T) {
+			out := NewGain[float32](0.25, 48000)
+			in := NewGain[float32](2, 48000)
+			var last atomic.Uint32
+			openTestStream(t, loopbackParams(t, format, 2), func(s *Stream[float32]) StreamCallbackResult {
+				in.ProcessInput(s)
+				if format.IsNonInterleaved() {
+					last.Store(math.Float32bits(s.InS()[1][0]))
+					for _, buf := range s.OutS() {
+						copy(buf, ones(len(buf)))
+					}
+				} else {
+					last.Store(math.Float32bits(s.In()[1]))
+					copy(s.Out(), ones(len(s.Out())))
+				}
+				out.ProcessOutput(s)
+				return Continue
+			})
+			waitFor(t, "the input", func() bool { return math.Float32frombits(last.Load()) == 0.5 })
+		})
+	}
+}
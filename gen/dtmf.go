@@ -0,0 +1,84 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	dtmfKeys = [4]string{"123A", "456B", "789C", "*0#D"}
+	dtmfRows = [4]float64{697, 770, 852, 941}
+	dtmfCols = [4]float64{1209, 1336, 1477, 1633}
+)
+
+// DTMF is a sequence of dual-tone multi-frequency digits separated by pauses.
+type DTMF struct {
+	digits     string
+	tone, gap  int
+	sampleRate float64
+	index, pos int
+	low, high  Oscillator
+}
+
+// NewDTMF creates a signal dialing the given digits (0-9, A-D, * and #),
+// each sounding for tone and followed by gap.
+func NewDTMF(digits string, tone, gap time.Duration, sampleRate float64) (*DTMF, error) {
+	digits = strings.ToUpper(digits)
+	for _, d := range digits {
+		if _, _, ok := dtmfFrequencies(d); !ok {
+			return nil, fmt.Errorf("gen: invalid DTMF digit %q", d)
+		}
+	}
+	g := &DTMF{
+		digits:     digits,
+		tone:       int(tone.Seconds() * sampleRate),
+		gap:        int(gap.Seconds() * sampleRate),
+		sampleRate: sampleRate,
+	}
+	g.start()
+	return g, nil
+}
+
+func dtmfFrequencies(digit rune) (low, high float64, ok bool) {
+	for r, keys := range dtmfKeys {
+		if c := strings.IndexRune(keys, digit); c >= 0 {
+			return dtmfRows[r], dtmfCols[c], true
+		}
+	}
+	return 0, 0, false
+}
+
+// start prepares the oscillators for the current digit.
+func (g *DTMF) start() {
+	if g.index >= len(g.digits) {
+		return
+	}
+	low, high, _ := dtmfFrequencies(rune(g.digits[g.index]))
+	g.low = *NewOscillator(Sine, low, g.sampleRate)
+	g.high = *NewOscillator(Sine, high, g.sampleRate)
+}
+
+func (g *DTMF) Next() float64 {
+	if g.index >= len(g.digits) {
+		return 0
+	}
+	var v float64
+	if g.pos < g.tone {
+		v = 0.5 * (g.low.Next() + g.high.Next())
+	}
+	if g.pos++; g.pos == g.tone+g.gap {
+		g.pos = 0
+		g.index++
+		g.start()
+	}
+	return v
+}
+
+func (g *DTMF) Remaining() int {
+	left := len(g.digits) - g.index
+	if left <= 0 {
+		return 0
+	}
+	return left*(g.tone+g.gap) - g.pos
+}
@@ -0,0 +1,147 @@
+// Package gen provides signal generators for testing and calibration.
+//
+// A generator is a Signal producing one value per frame. NewSource renders it
+// into interleaved or planar buffers of any sample type, so it can be read from
+// an output stream callback or passed wherever a portaudio.Source is expected.
+// Generators keep their state between calls, so the output is continuous
+// regardless of how it is split into buffers.
+package gen
+
+import (
+	"io"
+	"math"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Signal is a mono signal generator.
+type Signal interface {
+	// Next returns the value of the next frame, within [-1, 1].
+	Next() float64
+}
+
+// Finite is implemented by signals of limited length.
+type Finite interface {
+	Signal
+	// Remaining returns the number of frames left.
+	Remaining() int
+}
+
+// Source renders a Signal to all channels of interleaved or planar buffers.
+type Source[T pa.Sample] struct {
+	signal   Signal
+	channels int
+	gain     float64
+}
+
+// NewSource creates a source playing signal on the given number of channels
+// with its peak at level dBFS.
+func NewSource[T pa.Sample](signal Signal, channels int, level float64) *Source[T] {
+	return &Source[T]{signal: signal, channels: channels, gain: pa.DBToLinear(level)}
+}
+
+// SetLevel changes the peak level in dBFS. It must not be called concurrently with reads.
+func (s *Source[T]) SetLevel(level float64) {
+	s.gain = pa.DBToLinear(level)
+}
+
+// frames returns how many of the wanted frames the signal can deliver.
+func (s *Source[T]) frames(wanted int) (int, error) {
+	if f, ok := s.signal.(Finite); ok {
+		if left := f.Remaining(); left <= wanted {
+			return left, io.EOF
+		}
+	}
+	return wanted, nil
+}
+
+// ReadSamples fills buf with whole interleaved frames.
+// It returns io.EOF once a Finite signal has ended.
+func (s *Source[T]) ReadSamples(buf []T) (int, error) {
+	n, err := s.frames(len(buf) / s.channels)
+	for i := range n {
+		v := pa.FromFloat64[T](s.gain * s.signal.Next())
+		frame := buf[i*s.channels : (i+1)*s.channels]
+		for c := range frame {
+			frame[c] = v
+		}
+	}
+	return n * s.channels, err
+}
+
+// ReadPlanar fills one buffer per channel and returns the number of frames.
+// It returns io.EOF once a Finite signal has ended.
+func (s *Source[T]) ReadPlanar(bufs [][]T) (int, error) {
+	if len(bufs) == 0 {
+		return 0, nil
+	}
+	n, err := s.frames(len(bufs[0]))
+	for i := range n {
+		v := pa.FromFloat64[T](s.gain * s.signal.Next())
+		for _, buf := range bufs {
+			buf[i] = v
+		}
+	}
+	return n, err
+}
+
+// Silence is a signal of zeros.
+type Silence struct{}
+
+func (Silence) Next() float64 {
+	return 0
+}
+
+// Impulse is a signal of unit impulses.
+type Impulse struct {
+	period, pos int
+}
+
+// NewImpulse creates a signal with a unit impulse every period frames,
+// or a single impulse followed by silence if period is zero.
+func NewImpulse(period int) *Impulse {
+	return &Impulse{period: period}
+}
+
+func (g *Impulse) Next() float64 {
+	pos := g.pos
+	g.pos++
+	if g.period > 0 {
+		g.pos %= g.period
+	}
+	if pos == 0 {
+		return 1
+	}
+	return 0
+}
+
+// MultiTone is a sum of sines of equal amplitude.
+type MultiTone struct {
+	tones []*Oscillator
+}
+
+// NewMultiTone creates a signal summing sines of the given frequencies,
+// scaled so that its peak cannot exceed 1.
+func NewMultiTone(sampleRate float64, frequencies ...float64) *MultiTone {
+	g := &MultiTone{}
+	for _, f := range frequencies {
+		g.tones = append(g.tones, NewOscillator(Sine, f, sampleRate))
+	}
+	return g
+}
+
+func (g *MultiTone) Next() float64 {
+	if len(g.tones) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, t := range g.tones {
+		sum += t.Next()
+	}
+	return sum / float64(len(g.tones))
+}
+
+// clamp limits v to [-1, 1].
+func clamp(v float64) float64 {
+	return math.Max(-1, math.Min(1, v))
+}
@@ -0,0 +1,241 @@
+package gen
+
+import (
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// take returns the next n values of g.
+func take(g Signal, n int) []float64 {
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = g.Next()
+	}
+	return v
+}
+
+func near(a, b []float64) bool {
+	return slices.EqualFunc(a, b, func(x, y float64) bool { return math.Abs(x-y) < 1e-9 })
+}
+
+// power returns the power of x at frequency f with the Goertzel algorithm.
+func power(x []float64, f, sampleRate float64) float64 {
+	c := 2 * math.Cos(2*math.Pi*f/sampleRate)
+	var s1, s2 float64
+	for _, v := range x {
+		s1, s2 = v+c*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - c*s1*s2
+}
+
+func TestOscillator(t *testing.T) {
+	r := math.Sqrt2 / 2
+	for _, test := range []struct {
+		waveform Waveform
+		want     []float64
+	}{
+		{Sine, []float64{0, r, 1, r, 0, -r, -1, -r, 0}},
+		{Square, []float64{1, 1, 1, 1, -1, -1, -1, -1, 1}},
+		{Saw, []float64{-1, -0.75, -0.5, -0.25, 0, 0.25, 0.5, 0.75, -1}},
+		{Triangle, []float64{-1, -0.5, 0, 0.5, 1, 0.5, 0, -0.5, -1}},
+	} {
+		// 1000Hz at 8000Hz takes 8 frames per cycle.
+		if got := take(NewOscillator(test.waveform, 1000, 8000), 9); !near(got, test.want) {
+			t.Errorf("waveform %d: got %v, want %v", test.waveform, got, test.want)
+		}
+	}
+
+	g := NewOscillator(Saw, 1000, 8000)
+	take(g, 2)
+	g.SetFrequency(2000)
+	if got := take(g, 3); !near(got, []float64{-0.5, 0, 0.5}) || g.Frequency() != 2000 {
+		t.Errorf("after SetFrequency: got %v at %vHz", got, g.Frequency())
+	}
+	g.SetPhase(-1.25)
+	if got := g.Next(); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("after SetPhase(-1.25): got %v, want 0.5", got)
+	}
+}
+
+func TestImpulse(t *testing.T) {
+	if got := take(NewImpulse(3), 7); !slices.Equal(got, []float64{1, 0, 0, 1, 0, 0, 1}) {
+		t.Errorf("period 3: got %v", got)
+	}
+	if got := take(NewImpulse(0), 5); !slices.Equal(got, []float64{1, 0, 0, 0, 0}) {
+		t.Errorf("single impulse: got %v", got)
+	}
+}
+
+func TestMultiTone(t *testing.T) {
+	x := take(NewMultiTone(48000, 440, 1000, 3000), 48000)
+	for i, v := range x {
+		if math.Abs(v) > 1 {
+			t.Fatalf("frame %d = %v exceeds full scale", i, v)
+		}
+	}
+	for _, f := range []float64{440, 1000, 3000} {
+		if on, off := power(x, f, 48000), power(x, f+200, 48000); on < 1000*off {
+			t.Errorf("%vHz: power %v, next to it %v", f, on, off)
+		}
+	}
+	if v := NewMultiTone(48000).Next(); v != 0 {
+		t.Errorf("no tones: got %v", v)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	// Redder noise is more correlated from one frame to the next.
+	var prev float64
+	for _, color := range []Color{White, Pink, Brown} {
+		x := take(NewNoise(color, 1), 100000)
+		if !slices.Equal(x[:100], take(NewNoise(color, 1), 100)) {
+			t.Errorf("color %d: equal seeds produce different noise", color)
+		}
+		var sum, sq, lag float64
+		for i, v := range x {
+			if math.Abs(v) > 1 {
+				t.Fatalf("color %d: frame %d = %v exceeds full scale", color, i, v)
+			}
+			sum += v
+			sq += v * v
+			if i > 0 {
+				lag += v * x[i-1]
+			}
+		}
+		if mean := sum / float64(len(x)); math.Abs(mean) > 0.05 {
+			t.Errorf("color %d: mean %v", color, mean)
+		}
+		correlation := lag / sq
+		if color == White && math.Abs(correlation) > 0.01 || color != White && correlation <= prev {
+			t.Errorf("color %d: correlation %v after %v", color, correlation, prev)
+		}
+		prev = correlation
+	}
+}
+
+func TestSweep(t *testing.T) {
+	const rate = 48000
+	g := NewSweep(100, 10000, time.Second, rate)
+	if g.Remaining() != rate {
+		t.Fatalf("Remaining = %d, want %d", g.Remaining(), rate)
+	}
+	x := take(g, rate)
+	if g.Remaining() != 0 || g.Next() != 0 {
+		t.Errorf("Remaining = %d after the end", g.Remaining())
+	}
+	// The frequency rises from 100Hz to 10kHz exponentially, e.g. 1kHz is reached halfway.
+	for _, at := range []float64{0.1, 0.5, 0.9} {
+		window := x[int(at*rate)-1024 : int(at*rate)+1024]
+		f := zeroCrossings(window) / 2 / (2048.0 / rate)
+		if want := 100 * math.Pow(100, at); math.Abs(f-want) > 0.1*want {
+			t.Errorf("at %vs: %vHz, want %vHz", at, f, want)
+		}
+	}
+	g.Rewind()
+	if !slices.Equal(take(g, 100), x[:100]) {
+		t.Error("Rewind does not restart the sweep")
+	}
+}
+
+func zeroCrossings(x []float64) float64 {
+	var n float64
+	for i := 1; i < len(x); i++ {
+		if x[i-1] < 0 != (x[i] < 0) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDTMF(t *testing.T) {
+	const rate = 8000
+	if _, err := NewDTMF("12E", 50*time.Millisecond, 50*time.Millisecond, rate); err == nil {
+		t.Error("invalid digit accepted")
+	}
+	digits := "159#0a*d"
+	g, err := NewDTMF(digits, 50*time.Millisecond, 25*time.Millisecond, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Remaining() != len(digits)*600 {
+		t.Fatalf("Remaining = %d, want %d", g.Remaining(), len(digits)*600)
+	}
+	for _, d := range "159#0A*D" {
+		tone, gap := take(g, 400), take(g, 200)
+		low, high, _ := dtmfFrequencies(d)
+		for _, f := range append(dtmfRows[:], dtmfCols[:]...) {
+			p := power(tone, f, rate)
+			if f == low || f == high {
+				if p < 1000 {
+					t.Errorf("%c: power %v at %vHz", d, p, f)
+				}
+			} else if p > 100 {
+				t.Errorf("%c: power %v at %vHz", d, p, f)
+			}
+		}
+		if slices.ContainsFunc(gap, func(v float64) bool { return v != 0 }) {
+			t.Errorf("%c: pause is not silent", d)
+		}
+	}
+	if g.Remaining() != 0 || g.Next() != 0 {
+		t.Errorf("Remaining = %d after the end", g.Remaining())
+	}
+}
+
+func TestSource(t *testing.T) {
+	// Reading in buffers of any size continues the signal.
+	whole := make([]int16, 3*1000)
+	if n, err := NewSource[int16](NewOscillator(Sine, 440, 48000), 3, -6).ReadSamples(whole); n != len(whole) || err != nil {
+		t.Fatalf("ReadSamples = %d, %v", n, err)
+	}
+	s := NewSource[int16](NewOscillator(Sine, 440, 48000), 3, -6)
+	var pieces []int16
+	for len(pieces) < len(whole) {
+		buf := make([]int16, min(3*77+2, len(whole)-len(pieces)))
+		n, _ := s.ReadSamples(buf)
+		pieces = append(pieces, buf[:n]...)
+	}
+	if !slices.Equal(pieces, whole) {
+		t.Error("output depends on the buffer size")
+	}
+	peak := slices.Max(whole)
+	if level := float64(peak) / 32768; math.Abs(level-math.Pow(10, -6.0/20)) > 1e-3 {
+		t.Errorf("peak %v at -6dBFS", level)
+	}
+	for i := 0; i < len(whole); i += 3 {
+		if whole[i] != whole[i+1] || whole[i] != whole[i+2] {
+			t.Fatalf("frame %d differs between channels: %v", i/3, whole[i:i+3])
+		}
+	}
+}
+
+func TestSourceEnd(t *testing.T) {
+	s := NewSource[float32](NewSweep(100, 1000, 10*time.Millisecond, 1000), 2, 0)
+	buf := make([]float32, 2*4)
+	for _, want := range []int{4, 4} {
+		if n, err := s.ReadSamples(buf); n != 2*want || err != nil {
+			t.Fatalf("ReadSamples = %d, %v, want %d frames", n, err, want)
+		}
+	}
+	if n, err := s.ReadSamples(buf); n != 2*2 || err != io.EOF {
+		t.Fatalf("ReadSamples at the end = %d, %v", n, err)
+	}
+
+	s = NewSource[float32](NewSweep(100, 1000, 10*time.Millisecond, 1000), 2, 0)
+	bufs := [][]float32{make([]float32, 6), make([]float32, 6)}
+	if n, err := s.ReadPlanar(bufs); n != 6 || err != nil {
+		t.Fatalf("ReadPlanar = %d, %v", n, err)
+	}
+	if !slices.Equal(bufs[0], bufs[1]) {
+		t.Errorf("channels differ: %v", bufs)
+	}
+	if n, err := s.ReadPlanar(bufs); n != 4 || err != io.EOF {
+		t.Fatalf("ReadPlanar at the end = %d, %v", n, err)
+	}
+	if n, err := s.ReadPlanar(bufs); n != 0 || err != io.EOF {
+		t.Fatalf("ReadPlanar after the end = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,68 @@
+package gen
+
+import "math"
+
+// Waveform is the shape of an Oscillator.
+type Waveform int
+
+const (
+	Sine Waveform = iota
+	Square
+	Saw
+	Triangle
+)
+
+// Oscillator is a periodic signal. Square, saw and triangle waves are
+// not band-limited, so high frequencies alias.
+type Oscillator struct {
+	waveform   Waveform
+	sampleRate float64
+	step       float64
+	phase      float64 // in cycles, within [0, 1)
+}
+
+// NewOscillator creates an oscillator of the given waveform starting at phase zero.
+func NewOscillator(waveform Waveform, frequency, sampleRate float64) *Oscillator {
+	g := &Oscillator{waveform: waveform, sampleRate: sampleRate}
+	g.SetFrequency(frequency)
+	return g
+}
+
+// SetFrequency changes the frequency without a phase discontinuity.
+func (g *Oscillator) SetFrequency(frequency float64) {
+	g.step = frequency / g.sampleRate
+}
+
+// Frequency returns the frequency in Hz.
+func (g *Oscillator) Frequency() float64 {
+	return g.step * g.sampleRate
+}
+
+// SetPhase sets the phase in cycles.
+func (g *Oscillator) SetPhase(phase float64) {
+	_, g.phase = math.Modf(phase)
+	if g.phase < 0 {
+		g.phase++
+	}
+}
+
+func (g *Oscillator) Next() float64 {
+	p := g.phase
+	_, g.phase = math.Modf(g.phase + g.step)
+	switch g.waveform {
+	case Square:
+		if p < 0.5 {
+			return 1
+		}
+		return -1
+	case Saw:
+		return 2*p - 1
+	case Triangle:
+		if p < 0.5 {
+			return 4*p - 1
+		}
+		return 3 - 4*p
+	default:
+		return math.Sin(2 * math.Pi * p)
+	}
+}
@@ -0,0 +1,45 @@
+package gen
+
+import (
+	"math"
+	"time"
+)
+
+// Sweep is a logarithmic sine sweep of a fixed duration.
+type Sweep struct {
+	length int
+	pos    int
+	rate   float64
+	k, l   float64
+}
+
+// NewSweep creates a sweep from one frequency to another over the given duration,
+// following the exponential sine sweep of Farina.
+func NewSweep(from, to float64, duration time.Duration, sampleRate float64) *Sweep {
+	t := duration.Seconds()
+	l := t / math.Log(to/from)
+	return &Sweep{
+		length: int(t * sampleRate),
+		rate:   sampleRate,
+		k:      2 * math.Pi * from * l,
+		l:      l,
+	}
+}
+
+func (g *Sweep) Next() float64 {
+	if g.pos >= g.length {
+		return 0
+	}
+	t := float64(g.pos) / g.rate
+	g.pos++
+	return math.Sin(g.k * (math.Exp(t/g.l) - 1))
+}
+
+func (g *Sweep) Remaining() int {
+	return max(0, g.length-g.pos)
+}
+
+// Rewind restarts the sweep.
+func (g *Sweep) Rewind() {
+	g.pos = 0
+}
@@ -0,0 +1,159 @@
+// Package graph runs audio processing graphs from a single stream callback.
+//
+// Nodes are connected into a directed acyclic graph which is scheduled in
+// topological order. All buffers are allocated by Build, so processing a graph
+// does not allocate.
+package graph
+
+import (
+	"errors"
+	"fmt"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Node processes audio. in holds one buffer per input channel and out one buffer
+// per output channel; the first frames samples of each buffer are valid.
+type Node[T pa.Sample] interface {
+	// Channels returns the number of input and output channels of the node.
+	Channels() (in, out int)
+	Process(in, out [][]T, frames int)
+}
+
+// streamNode is implemented by the nodes exchanging audio with the stream driving the graph.
+type streamNode[T pa.Sample] interface {
+	bind(s *pa.Stream[T], offset int)
+}
+
+var (
+	ErrCycle        = errors.New("graph: cycle detected")
+	ErrChannelCount = errors.New("graph: channel count mismatch")
+	ErrAlreadyBuilt = errors.New("graph: already built")
+)
+
+type vertex[T pa.Sample] struct {
+	node    Node[T]
+	sources []*vertex[T]
+	in, out [][]T
+}
+
+// Graph is a set of connected nodes.
+type Graph[T pa.Sample] struct {
+	maxFrames   int
+	vertices    []*vertex[T]
+	index       map[Node[T]]*vertex[T]
+	order       []*vertex[T]
+	streamNodes []streamNode[T]
+}
+
+// New creates an empty graph that processes at most maxFrames frames at once.
+// Longer buffers are processed in several passes.
+func New[T pa.Sample](maxFrames int) *Graph[T] {
+	return &Graph[T]{
+		maxFrames: maxFrames,
+		index:     make(map[Node[T]]*vertex[T]),
+	}
+}
+
+// Add adds a node without connections, e.g. a source whose output is not used yet.
+func (g *Graph[T]) Add(n Node[T]) error {
+	if g.order != nil {
+		return ErrAlreadyBuilt
+	}
+	g.vertex(n)
+	return nil
+}
+
+// Connect feeds the output channels of src into the next free input channels of dst.
+func (g *Graph[T]) Connect(src, dst Node[T]) error {
+	if g.order != nil {
+		return ErrAlreadyBuilt
+	}
+	d := g.vertex(dst)
+	d.sources = append(d.sources, g.vertex(src))
+	return nil
+}
+
+func (g *Graph[T]) vertex(n Node[T]) *vertex[T] {
+	v, ok := g.index[n]
+	if !ok {
+		v = &vertex[T]{node: n}
+		g.index[n] = v
+		g.vertices = append(g.vertices, v)
+	}
+	return v
+}
+
+// Build validates the channel counts, sorts the nodes topologically and allocates the buffers.
+// The graph cannot be changed afterwards.
+func (g *Graph[T]) Build() error {
+	if g.order != nil {
+		return ErrAlreadyBuilt
+	}
+	pending := make(map[*vertex[T]]int, len(g.vertices))
+	consumers := make(map[*vertex[T]][]*vertex[T], len(g.vertices))
+	for _, v := range g.vertices {
+		pending[v] = len(v.sources)
+		for _, src := range v.sources {
+			consumers[src] = append(consumers[src], v)
+		}
+	}
+	order := make([]*vertex[T], 0, len(g.vertices))
+	for _, v := range g.vertices {
+		if pending[v] == 0 {
+			order = append(order, v)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, c := range consumers[order[i]] {
+			if pending[c]--; pending[c] == 0 {
+				order = append(order, c)
+			}
+		}
+	}
+	if len(order) != len(g.vertices) {
+		return ErrCycle
+	}
+	for _, v := range order {
+		inChannels, outChannels := v.node.Channels()
+		v.out = make([][]T, outChannels)
+		for ch := range v.out {
+			v.out[ch] = make([]T, g.maxFrames)
+		}
+		for _, src := range v.sources {
+			v.in = append(v.in, src.out...)
+		}
+		if len(v.in) != inChannels {
+			return fmt.Errorf("%w: %T expects %d input channels, %d connected", ErrChannelCount, v.node, inChannels, len(v.in))
+		}
+		if s, ok := v.node.(streamNode[T]); ok {
+			g.streamNodes = append(g.streamNodes, s)
+		}
+	}
+	g.order = order
+	return nil
+}
+
+// Process runs every node once in topological order on frames frames,
+// which must not exceed the maximum frame count of the graph.
+func (g *Graph[T]) Process(frames int) {
+	for _, v := range g.order {
+		v.node.Process(v.in, v.out, frames)
+	}
+}
+
+// Callback processes the graph for the current buffer of s. Input and Output nodes
+// exchange audio with s. It can be passed to portaudio.OpenStream directly.
+func (g *Graph[T]) Callback(s *pa.Stream[T]) pa.StreamCallbackResult {
+	if g.order == nil {
+		return pa.Abort
+	}
+	frames := s.FrameCount()
+	for offset := 0; offset < frames; offset += g.maxFrames {
+		for _, n := range g.streamNodes {
+			n.bind(s, offset)
+		}
+		g.Process(min(g.maxFrames, frames-offset))
+	}
+	return pa.Continue
+}
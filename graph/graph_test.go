@@ -0,0 +1,257 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+func TestMain(m *testing.M) {
+	if err := pa.Initialize(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = pa.Terminate()
+	os.Exit(code)
+}
+
+// sliceReader reads the samples of a slice in pieces of up to 7 samples
+// and returns err, or io.EOF if it is nil, with the last ones.
+type sliceReader[T pa.Sample] struct {
+	data []T
+	err  error
+}
+
+func (r *sliceReader[T]) ReadSamples(buf []T) (int, error) {
+	n := copy(buf[:min(len(buf), 7)], r.data)
+	r.data = r.data[n:]
+	if len(r.data) > 0 {
+		return n, nil
+	}
+	if r.err != nil {
+		return n, r.err
+	}
+	return n, io.EOF
+}
+
+// recorder is a sink node keeping the interleaved frames of its input.
+type recorder struct {
+	channels int
+	mu       sync.Mutex
+	frames   []float32
+}
+
+func (r *recorder) Channels() (in, out int) {
+	return r.channels, 0
+}
+
+func (r *recorder) Process(in, _ [][]float32, frames int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for f := range frames {
+		for ch := range in {
+			r.frames = append(r.frames, in[ch][f])
+		}
+	}
+}
+
+func (r *recorder) samples() []float32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.frames)
+}
+
+// ramp returns stereo frames with i+1 on the left and -(i+1) on the right channel of frame i.
+func ramp(frames int) []float32 {
+	s := make([]float32, 2*frames)
+	for i := range frames {
+		s[2*i], s[2*i+1] = float32(i+1), -float32(i+1)
+	}
+	return s
+}
+
+func TestBuildErrors(t *testing.T) {
+	a := NewFunc[float32](1, 1, func(in, out [][]float32, frames int) {})
+	b := NewFunc[float32](1, 1, func(in, out [][]float32, frames int) {})
+	g := New[float32](64)
+	_ = g.Connect(a, b)
+	_ = g.Connect(b, a)
+	if err := g.Build(); !errors.Is(err, ErrCycle) {
+		t.Errorf("cycle: got %v, want %v", err, ErrCycle)
+	}
+
+	g = New[float32](64)
+	_ = g.Connect(NewPlayer[float32](&sliceReader[float32]{data: ramp(10)}, 2, 64), a)
+	if err := g.Build(); !errors.Is(err, ErrChannelCount) {
+		t.Errorf("stereo into mono: got %v, want %v", err, ErrChannelCount)
+	}
+
+	g = New[float32](64)
+	if err := g.Add(&recorder{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add(a); err != ErrAlreadyBuilt {
+		t.Errorf("Add after Build: got %v", err)
+	}
+	if err := g.Connect(a, b); err != ErrAlreadyBuilt {
+		t.Errorf("Connect after Build: got %v", err)
+	}
+	if err := g.Build(); err != ErrAlreadyBuilt {
+		t.Errorf("Build after Build: got %v", err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	dry := NewPlayer[float32](&sliceReader[float32]{data: ramp(100)}, 2, 64)
+	wet := NewPlayer[float32](&sliceReader[float32]{data: ramp(100)}, 2, 64)
+	double := NewFunc[float32](2, 2, func(in, out [][]float32, frames int) {
+		for ch := range in {
+			for f := range frames {
+				out[ch][f] = 2 * in[ch][f]
+			}
+		}
+	})
+	mixer := NewMixer[float32](2, 2)
+	mixer.SetGain(1, 0.5)
+	sink := &recorder{channels: 2}
+
+	// The nodes are added in reverse order of processing.
+	g := New[float32](64)
+	for _, c := range [][2]Node[float32]{{mixer, sink}, {dry, mixer}, {double, mixer}, {wet, double}} {
+		if err := g.Connect(c[0], c[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for _, frames := range []int{64, 30, 64} {
+		g.Process(frames)
+	}
+	// The mix is dry + 0.5 * 2 * wet; after the end of the players it is silent.
+	got := sink.samples()
+	if len(got) != 2*158 {
+		t.Fatalf("recorded %d frames, want 158", len(got)/2)
+	}
+	want := ramp(158)
+	for i := range want {
+		if i < 200 {
+			want[i] *= 2
+		} else {
+			want[i] = 0
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !dry.Done() || dry.Err() != nil {
+		t.Errorf("player: Done = %v, Err = %v at the end", dry.Done(), dry.Err())
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	errRead := errors.New("read failed")
+	p := NewPlayer[float32](&sliceReader[float32]{data: []float32{1, 2, 3, 4, 5}, err: errRead}, 2, 4)
+	out := [][]float32{make([]float32, 4), make([]float32, 4)}
+	p.Process(nil, out, 4)
+	// The incomplete last frame is dropped.
+	if !slices.Equal(out[0], []float32{1, 3, 0, 0}) || !slices.Equal(out[1], []float32{2, 4, 0, 0}) {
+		t.Errorf("got %v", out)
+	}
+	if !p.Done() || p.Err() != errRead {
+		t.Errorf("Done = %v, Err = %v", p.Done(), p.Err())
+	}
+}
+
+func TestPlayerNoProgress(t *testing.T) {
+	data := []float32{1, 2, 3}
+	p := NewPlayer[float32](pa.FuncSource[float32](func(buf []float32) (int, error) {
+		n := copy(buf, data)
+		data = data[n:]
+		return n, nil
+	}), 2, 4)
+	out := [][]float32{make([]float32, 4), make([]float32, 4)}
+	p.Process(nil, out, 4)
+	if !slices.Equal(out[0], []float32{1, 0, 0, 0}) || !slices.Equal(out[1], []float32{2, 0, 0, 0}) {
+		t.Errorf("got %v", out)
+	}
+	if !p.Done() || p.Err() != nil {
+		t.Errorf("Done = %v, Err = %v", p.Done(), p.Err())
+	}
+}
+
+func TestRawFilePlayer(t *testing.T) {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.NativeEndian, []int16{1, -1, 2, -2, 3})
+	p := NewRawFilePlayer[int16](&b, 2, 3)
+	out := [][]int16{make([]int16, 3), make([]int16, 3)}
+	p.Process(nil, out, 3)
+	if !slices.Equal(out[0], []int16{1, 2, 0}) || !slices.Equal(out[1], []int16{-1, -2, 0}) {
+		t.Errorf("got %v", out)
+	}
+	if !p.Done() || p.Err() != nil {
+		t.Errorf("Done = %v, Err = %v", p.Done(), p.Err())
+	}
+}
+
+func TestCallbackNotBuilt(t *testing.T) {
+	if r := New[float32](64).Callback(nil); r != pa.Abort {
+		t.Errorf("got %v, want %v", r, pa.Abort)
+	}
+}
+
+func TestCallback(t *testing.T) {
+	for _, format := range []pa.SampleFormat{pa.Float32, pa.Float32 | pa.NonInterleaved} {
+		t.Run(format.String(), func(t *testing.T) {
+			l := pa.NewLoopback(pa.LoopbackOptions{Name: t.Name()})
+			t.Cleanup(l.Close)
+			params := &pa.StreamParameters{
+				Input:           pa.StreamDeviceParameters{Device: l.Input(), ChannelCount: 2},
+				Output:          pa.StreamDeviceParameters{Device: l.Output(), ChannelCount: 2},
+				SampleRate:      48000,
+				SampleFormat:    format,
+				FramesPerBuffer: 256,
+			}
+			// Buffers of 256 frames are processed in passes of 100 frames.
+			g := New[float32](100)
+			sink := &recorder{channels: 2}
+			_ = g.Connect(NewPlayer[float32](&sliceReader[float32]{data: ramp(4800)}, 2, 100), NewOutput[float32](2))
+			_ = g.Connect(NewInput[float32](2), sink)
+			if err := g.Build(); err != nil {
+				t.Fatal(err)
+			}
+			s, err := pa.OpenStream(params, g.Callback, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if err = s.Start(); err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for len(sink.samples()) < 2*2400 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			got := sink.samples()
+			if len(got) < 2*2400 {
+				t.Fatalf("recorded %d frames", len(got)/2)
+			}
+			// The loopback delays the output by 10ms.
+			const delay = 480
+			if want := ramp(2400 - delay); !slices.Equal(got[2*delay:2*2400], want) {
+				t.Error("recorded input differs from the played output")
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package graph
+
+import (
+	"io"
+	"math"
+	"sync/atomic"
+	"unsafe"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Input is a source node delivering the input of the stream driving the graph.
+type Input[T pa.Sample] struct {
+	channels int
+	stream   *pa.Stream[T]
+	offset   int
+}
+
+// NewInput creates an input node with the channel count of the stream's input.
+func NewInput[T pa.Sample](channels int) *Input[T] {
+	return &Input[T]{channels: channels}
+}
+
+func (n *Input[T]) Channels() (in, out int) {
+	return 0, n.channels
+}
+
+func (n *Input[T]) bind(s *pa.Stream[T], offset int) {
+	n.stream, n.offset = s, offset
+}
+
+func (n *Input[T]) Process(_, out [][]T, frames int) {
+	if inS := n.stream.InS(); inS != nil {
+		for ch := range out {
+			copy(out[ch][:frames], inS[ch][n.offset:])
+		}
+		return
+	}
+	in := n.stream.In()[n.offset*n.channels:]
+	for f := range frames {
+		for ch := range out {
+			out[ch][f] = in[f*n.channels+ch]
+		}
+	}
+}
+
+// Output is a sink node writing its input to the output of the stream driving the graph.
+type Output[T pa.Sample] struct {
+	channels int
+	stream   *pa.Stream[T]
+	offset   int
+}
+
+// NewOutput creates an output node with the channel count of the stream's output.
+func NewOutput[T pa.Sample](channels int) *Output[T] {
+	return &Output[T]{channels: channels}
+}
+
+func (n *Output[T]) Channels() (in, out int) {
+	return n.channels, 0
+}
+
+func (n *Output[T]) bind(s *pa.Stream[T], offset int) {
+	n.stream, n.offset = s, offset
+}
+
+func (n *Output[T]) Process(in, _ [][]T, frames int) {
+	if outS := n.stream.OutS(); outS != nil {
+		for ch := range in {
+			copy(outS[ch][n.offset:n.offset+frames], in[ch])
+		}
+		return
+	}
+	out := n.stream.Out()[n.offset*n.channels:]
+	for f := range frames {
+		for ch := range in {
+			out[f*n.channels+ch] = in[ch][f]
+		}
+	}
+}
+
+// SampleReader reads interleaved samples, e.g. decoded from a file.
+type SampleReader[T pa.Sample] interface {
+	ReadSamples(buf []T) (int, error)
+}
+
+// Player is a source node playing interleaved samples from a SampleReader.
+// It outputs silence once the reader is exhausted.
+type Player[T pa.Sample] struct {
+	channels int
+	reader   SampleReader[T]
+	buf      []T
+	done     atomic.Bool
+	err      error
+}
+
+// NewPlayer creates a player node reading frames with the given channel count.
+// maxFrames must be at least the maximum frame count of the graph.
+func NewPlayer[T pa.Sample](r SampleReader[T], channels, maxFrames int) *Player[T] {
+	return &Player[T]{channels: channels, reader: r, buf: make([]T, channels*maxFrames)}
+}
+
+// NewRawFilePlayer creates a player node reading headerless interleaved samples of type T
+// in native byte order from r.
+func NewRawFilePlayer[T pa.Sample](r io.Reader, channels, maxFrames int) *Player[T] {
+	return NewPlayer[T](&rawReader[T]{r}, channels, maxFrames)
+}
+
+func (n *Player[T]) Channels() (in, out int) {
+	return 0, n.channels
+}
+
+// Done reports whether the reader is exhausted: it returned an error,
+// or no samples and no error, which is taken as the end of the data.
+func (n *Player[T]) Done() bool {
+	return n.done.Load()
+}
+
+// Err returns the error that stopped the reader, if it was not io.EOF.
+// It must not be called before Done reports true.
+func (n *Player[T]) Err() error {
+	return n.err
+}
+
+func (n *Player[T]) Process(_, out [][]T, frames int) {
+	buf := n.buf[:frames*n.channels]
+	read := 0
+	for !n.done.Load() && read < len(buf) {
+		r, err := n.reader.ReadSamples(buf[read:])
+		read += r
+		if err != nil || r == 0 {
+			if err != io.EOF {
+				n.err = err
+			}
+			n.done.Store(true)
+		}
+	}
+	read -= read % n.channels
+	clear(buf[read:])
+	for f := range frames {
+		for ch := range out {
+			out[ch][f] = buf[f*n.channels+ch]
+		}
+	}
+}
+
+type rawReader[T pa.Sample] struct {
+	r io.Reader
+}
+
+func (r *rawReader[T]) ReadSamples(buf []T) (int, error) {
+	size := int(unsafe.Sizeof(buf[0]))
+	bytes := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(buf))), len(buf)*size)
+	n, err := io.ReadFull(r.r, bytes)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n / size, err
+}
+
+// Mixer is a node summing several inputs of the same channel count with a gain per input.
+type Mixer[T pa.Sample] struct {
+	inputs, channels int
+	gains            []atomic.Uint64
+}
+
+// NewMixer creates a mixer of inputs inputs with channels channels each.
+// All gains are initially 1.
+func NewMixer[T pa.Sample](inputs, channels int) *Mixer[T] {
+	m := &Mixer[T]{inputs: inputs, channels: channels, gains: make([]atomic.Uint64, inputs)}
+	for i := range m.gains {
+		m.SetGain(i, 1)
+	}
+	return m
+}
+
+// SetGain sets the linear gain of an input. It may be called while the graph is running.
+func (m *Mixer[T]) SetGain(input int, gain float64) {
+	m.gains[input].Store(math.Float64bits(gain))
+}
+
+// Gain returns the linear gain of an input.
+func (m *Mixer[T]) Gain(input int) float64 {
+	return math.Float64frombits(m.gains[input].Load())
+}
+
+func (m *Mixer[T]) Channels() (in, out int) {
+	return m.inputs * m.channels, m.channels
+}
+
+func (m *Mixer[T]) Process(in, out [][]T, frames int) {
+	for ch := range out {
+		for f := range frames {
+			var sum float64
+			for i := range m.inputs {
+				sum += m.Gain(i) * pa.ToFloat64(in[i*m.channels+ch][f])
+			}
+			out[ch][f] = pa.FromFloat64[T](sum)
+		}
+	}
+}
+
+// Func is a node applying a function, e.g. an effect.
+type Func[T pa.Sample] struct {
+	in, out int
+	fn      func(in, out [][]T, frames int)
+}
+
+// NewFunc creates a node with the given channel counts which processes audio with fn.
+func NewFunc[T pa.Sample](in, out int, fn func(in, out [][]T, frames int)) *Func[T] {
+	return &Func[T]{in, out, fn}
+}
+
+func (n *Func[T]) Channels() (in, out int) {
+	return n.in, n.out
+}
+
+func (n *Func[T]) Process(in, out [][]T, frames int) {
+	n.fn(in, out, frames)
+}
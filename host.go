@@ -5,7 +5,11 @@ package portaudio
 #include <portaudio.h>
 */
 import "C"
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type HostErrorInfo struct {
 	HostApiType HostApiType
@@ -33,12 +37,52 @@ const (
 	JACK            HostApiType = C.paJACK
 	WASAPI          HostApiType = C.paWASAPI
 	AudioScienceHPI HostApiType = C.paAudioScienceHPI
+	// Host APIs added in PortAudio v19.8.
+	AudioIO    HostApiType = 15
+	PulseAudio HostApiType = 16
+	Sndio      HostApiType = 17
 )
 
 type HostApiType int
 
 func (t HostApiType) String() string {
-	return hostApiStrings[t]
+	if t >= 0 && int(t) < len(hostApiStrings) && hostApiStrings[t] != "" {
+		return hostApiStrings[t]
+	}
+	return "HostApiType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ParseHostApiType returns the host API type with the given name, ignoring case.
+// It accepts the names returned by HostApiType.String, including the "HostApiType(n)" form.
+func ParseHostApiType(s string) (HostApiType, error) {
+	for t, name := range hostApiStrings {
+		if name != "" && strings.EqualFold(name, s) {
+			return HostApiType(t), nil
+		}
+	}
+	if n, ok := strings.CutPrefix(s, "HostApiType("); ok {
+		if n, ok = strings.CutSuffix(n, ")"); ok {
+			if t, err := strconv.Atoi(n); err == nil {
+				return HostApiType(t), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("portaudio: unknown host API type %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t HostApiType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *HostApiType) UnmarshalText(text []byte) error {
+	v, err := ParseHostApiType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
 }
 
 var hostApiStrings = [...]string{
@@ -56,6 +100,9 @@ var hostApiStrings = [...]string{
 	JACK:            "JACK",
 	WASAPI:          "WASAPI",
 	AudioScienceHPI: "AudioScienceHPI",
+	AudioIO:         "AudioIO",
+	PulseAudio:      "PulseAudio",
+	Sndio:           "Sndio",
 }
 
 type HostApiInfo struct {
@@ -70,6 +117,9 @@ type HostApiInfo struct {
 // The returning value is a non-negative value indicating the number of available host APIs.
 func HostApi(index int) *HostApiInfo {
 	info := C.Pa_GetHostApiInfo(C.PaHostApiIndex(index))
+	if info == nil {
+		return nil
+	}
 	return &HostApiInfo{
 		Type: HostApiType(info._type),
 		Name: C.GoString(info.name),
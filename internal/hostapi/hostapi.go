@@ -0,0 +1,17 @@
+// Package hostapi gives the host API subpackages access to the PortAudio
+// internals of package portaudio without exporting them from it.
+// Its functions are set by package portaudio when it is initialized.
+package hostapi
+
+import "unsafe"
+
+var (
+	// Stream returns the PortAudio stream of a *portaudio.Stream,
+	// or nil if the stream is on a virtual device.
+	Stream func(stream any) unsafe.Pointer
+	// Error converts an error code returned by PortAudio into an error.
+	Error func(code int) error
+	// Params returns the *portaudio.StreamParameters a *portaudio.Stream was opened with,
+	// or nil if the stream is on a virtual device.
+	Params func(stream any) any
+)
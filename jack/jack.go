@@ -0,0 +1,194 @@
+//go:build jack
+
+// Package jack exposes the JACK specific extensions of PortAudio
+// and lets applications inspect and connect the ports of their streams.
+// It requires the JACK development files and is built with the jack build tag.
+package jack
+
+/*
+#cgo pkg-config: portaudio-2.0 jack
+#include <errno.h>
+#include <stdlib.h>
+#include <portaudio.h>
+#include <pa_jack.h>
+#include <jack/jack.h>
+
+static jack_client_t *openClient(const char *name, jack_status_t *status) {
+	return jack_client_open(name, JackNoStartServer, status);
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"unsafe"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+	"github.com/URALINNOVATSIYA/portaudio/internal/hostapi"
+)
+
+// PortFlags are the JACK port flags used to filter ports.
+type PortFlags C.ulong
+
+const (
+	PortIsInput    PortFlags = C.JackPortIsInput
+	PortIsOutput   PortFlags = C.JackPortIsOutput
+	PortIsPhysical PortFlags = C.JackPortIsPhysical
+)
+
+var (
+	clientNameMu sync.Mutex
+	clientName   *C.char
+)
+
+// SetClientName sets the name of the JACK client PortAudio registers.
+// It must be called before portaudio.Initialize.
+func SetClientName(name string) error {
+	clientNameMu.Lock()
+	defer clientNameMu.Unlock()
+	// PortAudio keeps the pointer, so the string must stay allocated until it is replaced.
+	cname := C.CString(name)
+	if err := hostapi.Error(int(C.PaJack_SetClientName(cname))); err != nil {
+		C.free(unsafe.Pointer(cname))
+		return err
+	}
+	if clientName != nil {
+		C.free(unsafe.Pointer(clientName))
+	}
+	clientName = cname
+	return nil
+}
+
+// ClientName returns the name of the JACK client PortAudio has registered.
+// JACK may have made the requested name unique, so it can differ from the one passed to SetClientName.
+func ClientName() (string, error) {
+	var name *C.char
+	if err := hostapi.Error(int(C.PaJack_GetClientName(&name))); err != nil {
+		return "", err
+	}
+	return C.GoString(name), nil
+}
+
+// Client is a JACK client used to inspect and connect ports.
+// It is independent from the client PortAudio uses for audio processing.
+type Client struct {
+	client *C.jack_client_t
+}
+
+// Open connects to the running JACK server as a client with the given name.
+// It never starts a server.
+func Open(name string) (*Client, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var status C.jack_status_t
+	client := C.openClient(cname, &status)
+	if client == nil {
+		return nil, fmt.Errorf("jack: cannot open client %q (status 0x%x)", name, int(status))
+	}
+	return &Client{client}, nil
+}
+
+// Close disconnects the client from the JACK server.
+func (c *Client) Close() error {
+	if C.jack_client_close(c.client) != 0 {
+		return errors.New("jack: cannot close client")
+	}
+	return nil
+}
+
+// Ports returns the names of the ports matching the regular expression and the flags.
+// An empty pattern matches every port.
+func (c *Client) Ports(pattern string, flags PortFlags) []string {
+	var cpattern *C.char
+	if pattern != "" {
+		cpattern = C.CString(pattern)
+		defer C.free(unsafe.Pointer(cpattern))
+	}
+	return goStrings(C.jack_get_ports(c.client, cpattern, nil, C.ulong(flags)))
+}
+
+// StreamPorts looks up with the client c the ports PortAudio's JACK client has registered for its streams.
+// The input ports receive the audio of the stream inputs and the output ports carry the audio
+// of the stream outputs, one port per channel in the order of registration. PortAudio registers
+// the ports of all its JACK streams on the same client, so they are the ports of s
+// while s is the only open JACK stream. Only the directions used by s are looked up.
+// It returns IncompatibleStreamHostApi if the stream is not on the JACK host API.
+func StreamPorts[T any](c *Client, s *pa.Stream[T]) (in, out []string, err error) {
+	params, _ := hostapi.Params(s).(*pa.StreamParameters)
+	if params == nil || !isJack(params.Input) && !isJack(params.Output) {
+		return nil, nil, pa.IncompatibleStreamHostApi
+	}
+	name, err := ClientName()
+	if err != nil {
+		return nil, nil, err
+	}
+	pattern := "^" + regexp.QuoteMeta(name) + ":"
+	if params.Input.Exists() {
+		in = c.Ports(pattern, PortIsInput)
+	}
+	if params.Output.Exists() {
+		out = c.Ports(pattern, PortIsOutput)
+	}
+	return in, out, nil
+}
+
+func isJack(p pa.StreamDeviceParameters) bool {
+	return p.Exists() && p.Device.HostApi != nil && p.Device.HostApi.Type == pa.JACK
+}
+
+// Connections returns the ports the given port is connected to.
+func (c *Client) Connections(port string) ([]string, error) {
+	p, err := c.port(port)
+	if err != nil {
+		return nil, err
+	}
+	return goStrings(C.jack_port_get_all_connections(c.client, p)), nil
+}
+
+// Connect connects the source (output) port to the destination (input) port.
+func (c *Client) Connect(source, destination string) error {
+	csrc, cdst := C.CString(source), C.CString(destination)
+	defer C.free(unsafe.Pointer(csrc))
+	defer C.free(unsafe.Pointer(cdst))
+	// EEXIST means the ports are already connected.
+	if r := C.jack_connect(c.client, csrc, cdst); r != 0 && r != C.EEXIST {
+		return fmt.Errorf("jack: cannot connect %q to %q (%d)", source, destination, int(r))
+	}
+	return nil
+}
+
+// Disconnect removes the connection between the source and the destination port.
+func (c *Client) Disconnect(source, destination string) error {
+	csrc, cdst := C.CString(source), C.CString(destination)
+	defer C.free(unsafe.Pointer(csrc))
+	defer C.free(unsafe.Pointer(cdst))
+	if r := C.jack_disconnect(c.client, csrc, cdst); r != 0 {
+		return fmt.Errorf("jack: cannot disconnect %q from %q (%d)", source, destination, int(r))
+	}
+	return nil
+}
+
+func (c *Client) port(name string) (*C.jack_port_t, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	p := C.jack_port_by_name(c.client, cname)
+	if p == nil {
+		return nil, fmt.Errorf("jack: no port %q", name)
+	}
+	return p, nil
+}
+
+// goStrings converts a NULL terminated array of strings allocated by JACK and frees it.
+func goStrings(list **C.char) []string {
+	if list == nil {
+		return nil
+	}
+	defer C.jack_free(unsafe.Pointer(list))
+	var names []string
+	for p := list; *p != nil; p = (**C.char)(unsafe.Add(unsafe.Pointer(p), unsafe.Sizeof(*p))) {
+		names = append(names, C.GoString(*p))
+	}
+	return names
+}
@@ -0,0 +1,148 @@
+//go:build jack
+
+package jack
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+func TestMain(m *testing.M) {
+	if err := pa.Initialize(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = pa.Terminate()
+	os.Exit(code)
+}
+
+func TestStreamPortsVirtual(t *testing.T) {
+	l := pa.NewLoopback(pa.LoopbackOptions{})
+	defer l.Close()
+	s, err := pa.OpenStream[float32](&pa.StreamParameters{
+		Output:          pa.StreamDeviceParameters{Device: l.Output(), ChannelCount: 2},
+		SampleRate:      48000,
+		SampleFormat:    pa.Float32,
+		FramesPerBuffer: 256,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, _, err := StreamPorts(nil, s); !errors.Is(err, pa.IncompatibleStreamHostApi) {
+		t.Errorf("StreamPorts: %v, want IncompatibleStreamHostApi", err)
+	}
+}
+
+// startDummyServer runs jackd with the dummy driver for the duration of the test.
+func startDummyServer(t *testing.T) {
+	path, err := exec.LookPath("jackd")
+	if err != nil {
+		t.Skip("jackd is not installed")
+	}
+	cmd := exec.Command(path, "--no-realtime", "-d", "dummy", "-r", "48000", "-p", "256")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if c, err := Open("probe"); err == nil {
+			_ = c.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("jackd did not start")
+		}
+	}
+}
+
+func jackDevice(t *testing.T) *pa.DeviceInfo {
+	for i := range pa.DeviceCount() {
+		if d := pa.Device(i); d.HostApi != nil && d.HostApi.Type == pa.JACK && d.MaxInputChannels >= 2 && d.MaxOutputChannels >= 2 {
+			return d
+		}
+	}
+	t.Skip("PortAudio has no JACK device with 2 channels")
+	return nil
+}
+
+func TestStreamPortsDummyServer(t *testing.T) {
+	startDummyServer(t)
+	if err := SetClientName("portaudio-test"); err != nil {
+		t.Fatal(err)
+	}
+	// The JACK host API connects to the server when PortAudio is initialized.
+	if err := pa.RescanDevices(); err != nil {
+		t.Fatal(err)
+	}
+	device := jackDevice(t)
+	open := func(in, out int) *pa.Stream[float32] {
+		params := &pa.StreamParameters{
+			SampleRate:      48000,
+			SampleFormat:    pa.Float32,
+			FramesPerBuffer: 256,
+		}
+		if in > 0 {
+			params.Input = pa.StreamDeviceParameters{Device: device, ChannelCount: in}
+		}
+		if out > 0 {
+			params.Output = pa.StreamDeviceParameters{Device: device, ChannelCount: out}
+		}
+		s, err := pa.OpenStream[float32](params, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	c, err := Open("portaudio-test-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	name, err := ClientName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ports of a closed stream are unregistered and the next stream continues the numbering.
+	first := open(1, 2)
+	in, out, err := StreamPorts(c, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{name + ":in_0"}; !slices.Equal(in, want) {
+		t.Errorf("input ports = %q, want %q", in, want)
+	}
+	if want := []string{name + ":out_0", name + ":out_1"}; !slices.Equal(out, want) {
+		t.Errorf("output ports = %q, want %q", out, want)
+	}
+	if err := c.Connect(out[0], in[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Connections(out[0]); err != nil || !slices.Equal(got, in[:1]) {
+		t.Errorf("Connections = %q, %v, want %q", got, err, in[:1])
+	}
+	if err := c.Disconnect(out[0], in[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	second := open(2, 0)
+	defer second.Close()
+	in, out, err = StreamPorts(c, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{name + ":in_1", name + ":in_2"}; !slices.Equal(in, want) || out != nil {
+		t.Errorf("ports = %q, %q, want %q and no output ports", in, out, want)
+	}
+}
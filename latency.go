@@ -0,0 +1,218 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrNoLoopback is returned by MeasureLatency if the test signal
+// could not be found in the captured audio.
+var ErrNoLoopback = errors.New("portaudio: test signal not detected on input")
+
+// LatencySignal is the test signal played by MeasureLatency.
+type LatencySignal int
+
+const (
+	// MLS is a maximum length sequence of 32767 frames. It is robust against noise.
+	MLS LatencySignal = iota
+	// Chirp is a logarithmic sine sweep. It tolerates loudspeakers with a limited bandwidth better.
+	Chirp
+)
+
+// LatencyOptions configures MeasureLatency.
+type LatencyOptions struct {
+	Signal LatencySignal
+	// Runs is the number of measurements. Zero selects 5.
+	Runs int
+	// Attenuation is how far the peak of the test signal lies below full scale in dB.
+	// Zero plays the test signal at 0dBFS.
+	Attenuation float64
+	// MaxLatency is the largest round-trip latency detected. Zero selects 1s.
+	MaxLatency time.Duration
+}
+
+// LatencyReport holds the results of MeasureLatency.
+type LatencyReport struct {
+	// Runs are the latencies of the successful measurements.
+	Runs []time.Duration
+	// Measured is the mean and Jitter the standard deviation of Runs.
+	Measured, Jitter time.Duration
+	Min, Max         time.Duration
+	// ReportedInput and ReportedOutput are the latencies reported by PortAudio for the stream.
+	ReportedInput, ReportedOutput time.Duration
+}
+
+// Reported returns the round-trip latency reported by PortAudio.
+func (r *LatencyReport) Reported() time.Duration {
+	return r.ReportedInput + r.ReportedOutput
+}
+
+// MeasureLatency measures the round-trip latency of a full-duplex stream.
+// It plays a test signal on all output channels, captures the first input channel
+// and finds the delay by cross-correlation. The output must be connected to the input,
+// acoustically or by a cable. The sample format of params is ignored, Float32 is used.
+func MeasureLatency(params *StreamParameters, options LatencyOptions) (*LatencyReport, error) {
+	if !params.Input.Exists() || !params.Output.Exists() {
+		return nil, streamError("measure latency", params.device(), params, InvalidDevice)
+	}
+	if options.Runs <= 0 {
+		options.Runs = 5
+	}
+	if options.MaxLatency <= 0 {
+		options.MaxLatency = time.Second
+	}
+	var signal []float64
+	switch options.Signal {
+	case Chirp:
+		signal = chirp(params.SampleRate)
+	default:
+		signal = mls()
+	}
+	gain := DBToLinear(-options.Attenuation)
+	gap := int(options.MaxLatency.Seconds() * params.SampleRate)
+	period := len(signal) + gap
+	// The first period is silent, giving the devices time to settle.
+	play := make([]float32, (options.Runs+1)*period+gap)
+	for run := 1; run <= options.Runs; run++ {
+		for i, v := range signal {
+			play[run*period+i] = float32(gain * v)
+		}
+	}
+	record := make([]float64, len(play))
+
+	p := *params
+	p.SampleFormat = Float32
+	inChannels, outChannels := p.Input.ChannelCount, p.Output.ChannelCount
+	pos := 0
+	done := make(chan struct{})
+	stream, err := OpenStream(&p, func(s *Stream[float32]) StreamCallbackResult {
+		in, out := s.In(), s.Out()
+		for f := range s.FrameCount() {
+			var v float32
+			if pos < len(play) {
+				v = play[pos]
+				record[pos] = float64(in[f*inChannels])
+				pos++
+			}
+			for c := range outChannels {
+				out[f*outChannels+c] = v
+			}
+		}
+		if pos == len(play) {
+			return Complete
+		}
+		return Continue
+	}, func(*Stream[float32]) {
+		close(done)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	info := stream.Info()
+	if err = stream.Start(); err != nil {
+		return nil, err
+	}
+	duration := time.Duration(float64(len(play)) / p.SampleRate * float64(time.Second))
+	select {
+	case <-done:
+	case <-time.After(2*duration + time.Second):
+		_ = stream.Abort()
+		return nil, streamError("measure latency", p.device(), &p, TimedOut)
+	}
+	if err = stream.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &LatencyReport{}
+	if info != nil {
+		report.ReportedInput, report.ReportedOutput = info.InputLatency, info.OutputLatency
+	}
+	c := crossCorrelate(record, signal)
+	for run := 1; run <= options.Runs; run++ {
+		if lag, ok := findPeak(c[run*period : run*period+gap]); ok {
+			report.Runs = append(report.Runs, time.Duration(lag/p.SampleRate*float64(time.Second)))
+		}
+	}
+	if len(report.Runs) == 0 {
+		return nil, streamError("measure latency", p.device(), &p, ErrNoLoopback)
+	}
+	report.summarize()
+	return report, nil
+}
+
+// findPeak returns the position of the correlation peak with sub-sample precision,
+// or false if the peak does not stand out of the noise.
+func findPeak(c []float64) (float64, bool) {
+	best := 0
+	var energy float64
+	for i, v := range c {
+		energy += v * v
+		if math.Abs(v) > math.Abs(c[best]) {
+			best = i
+		}
+	}
+	rms := math.Sqrt(energy / float64(len(c)))
+	if rms == 0 || math.Abs(c[best]) < 10*rms {
+		return 0, false
+	}
+	lag := float64(best)
+	if best > 0 && best < len(c)-1 {
+		// Parabolic interpolation around the peak.
+		a, b, d := math.Abs(c[best-1]), math.Abs(c[best]), math.Abs(c[best+1])
+		if denom := a - 2*b + d; denom != 0 {
+			lag += 0.5 * (a - d) / denom
+		}
+	}
+	return lag, true
+}
+
+func (r *LatencyReport) summarize() {
+	r.Min, r.Max = r.Runs[0], r.Runs[0]
+	var sum float64
+	for _, d := range r.Runs {
+		r.Min, r.Max = min(r.Min, d), max(r.Max, d)
+		sum += float64(d)
+	}
+	mean := sum / float64(len(r.Runs))
+	var variance float64
+	for _, d := range r.Runs {
+		variance += (float64(d) - mean) * (float64(d) - mean)
+	}
+	r.Measured = time.Duration(mean)
+	r.Jitter = time.Duration(math.Sqrt(variance / float64(len(r.Runs))))
+}
+
+// mls returns a maximum length sequence of 32767 values ±1
+// generated by a Fibonacci LFSR with the feedback polynomial x^15 + x^14 + 1.
+func mls() []float64 {
+	seq := make([]float64, 1<<15-1)
+	state := uint32(1)
+	for i := range seq {
+		bit := (state ^ state>>1) & 1
+		state = state>>1 | bit<<14
+		seq[i] = float64(int(state&1)*2 - 1)
+	}
+	return seq
+}
+
+// chirp returns a logarithmic sine sweep from 100Hz to 0.9 of the Nyquist frequency
+// lasting one second, faded in and out to limit the bandwidth.
+func chirp(sampleRate float64) []float64 {
+	n := int(sampleRate)
+	from, to := 100.0, 0.45*sampleRate
+	l := 1 / math.Log(to/from)
+	k := 2 * math.Pi * from * l
+	fade := n / 50
+	sweep := make([]float64, n)
+	for i := range sweep {
+		t := float64(i) / sampleRate
+		v := math.Sin(k * (math.Exp(t/l) - 1))
+		if edge := min(i, n-1-i); edge < fade {
+			v *= 0.5 - 0.5*math.Cos(math.Pi*float64(edge)/float64(fade))
+		}
+		sweep[i] = v
+	}
+	return sweep
+}
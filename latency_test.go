@@ -0,0 +1,100 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// latencyParams returns duplex parameters for a loopback delaying by frames frames at 48000Hz.
+func latencyParams(t *testing.T, frames int, noise float64) *StreamParameters {
+	t.Helper()
+	l := NewLoopback(LoopbackOptions{
+		Name:     t.Name(),
+		Channels: 1,
+		Latency:  time.Duration(frames) * time.Second / 48000,
+		Noise:    noise,
+		Seed:     1,
+	})
+	t.Cleanup(l.Close)
+	return &StreamParameters{
+		Input:           StreamDeviceParameters{Device: l.Input(), ChannelCount: 1},
+		Output:          StreamDeviceParameters{Device: l.Output(), ChannelCount: 1},
+		SampleRate:      48000,
+		FramesPerBuffer: 256,
+	}
+}
+
+func TestMeasureLatency(t *testing.T) {
+	const delay = 600
+	for _, test := range []struct {
+		name    string
+		options LatencyOptions
+	}{
+		{"MLS", LatencyOptions{Signal: MLS, Runs: 2, MaxLatency: 100 * time.Millisecond}},
+		{"Chirp", LatencyOptions{Signal: Chirp, Runs: 1, Attenuation: 12, MaxLatency: 100 * time.Millisecond}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			report, err := MeasureLatency(latencyParams(t, delay, 0.01), test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Runs) != test.options.Runs {
+				t.Fatalf("got %d runs, want %d", len(report.Runs), test.options.Runs)
+			}
+			for _, d := range report.Runs {
+				if frames := math.Round(d.Seconds() * 48000); frames != delay {
+					t.Errorf("measured %v = %v frames, want %d", d, frames, delay)
+				}
+			}
+			if frames := math.Round(report.Reported().Seconds() * 48000); frames != delay {
+				t.Errorf("reported %v = %v frames, want %d", report.Reported(), frames, delay)
+			}
+		})
+	}
+}
+
+func TestMeasureLatencyAttenuation(t *testing.T) {
+	// At -120dBFS the test signal drowns in the noise of the loopback.
+	options := LatencyOptions{Runs: 1, Attenuation: 120, MaxLatency: 100 * time.Millisecond}
+	_, err := MeasureLatency(latencyParams(t, 600, 0.5), options)
+	if !errors.Is(err, ErrNoLoopback) {
+		t.Fatalf("got %v, want %v", err, ErrNoLoopback)
+	}
+}
+
+func TestMLS(t *testing.T) {
+	seq := mls()
+	// The circular autocorrelation of a maximum length sequence is N at lag 0 and -1 elsewhere.
+	for _, lag := range []int{0, 1, 2, 100, len(seq) - 1} {
+		var sum float64
+		for i := range seq {
+			sum += seq[i] * seq[(i+lag)%len(seq)]
+		}
+		want := -1.0
+		if lag == 0 {
+			want = float64(len(seq))
+		}
+		if sum != want {
+			t.Errorf("autocorrelation at lag %d = %v, want %v", lag, sum, want)
+		}
+	}
+}
+
+func TestFindPeak(t *testing.T) {
+	c := make([]float64, 1000)
+	c[40], c[41], c[42] = 2, 4, 3
+	lag, ok := findPeak(c)
+	if !ok {
+		t.Fatal("peak not found")
+	}
+	// The parabola through (40,2), (41,4), (42,3) peaks at 41 + 1/6.
+	if want := 41 + 1.0/6; math.Abs(lag-want) > 1e-9 {
+		t.Errorf("got lag %v, want %v", lag, want)
+	}
+	if _, ok = findPeak(make([]float64, 100)); ok {
+		t.Error("peak found in silence")
+	}
+}
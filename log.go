@@ -0,0 +1,122 @@
+package portaudio
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	logger        atomic.Pointer[slog.Logger]
+	discardLogger = slog.New(slog.DiscardHandler)
+)
+
+// xrunLogInterval is the minimum interval between two xrun events logged for the same stream.
+const xrunLogInterval = time.Second
+
+// SetLogger sets the logger used for package level events (initialization, termination)
+// and for streams opened without their own StreamParameters.Logger.
+// A nil logger disables logging.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// Logger returns the logger set by SetLogger or a logger that discards everything.
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+// logError logs a failed operation. Host errors are logged with their host API details.
+func logError(l *slog.Logger, msg string, err error, attrs ...any) {
+	var info HostErrorInfo
+	if errors.As(err, &info) {
+		attrs = append(attrs,
+			slog.String("hostApi", info.HostApiType.String()),
+			slog.Int("hostCode", info.Code),
+			slog.String("hostText", info.Text),
+		)
+	}
+	l.Error(msg, append(attrs, slog.Any("error", err))...)
+}
+
+// rateLimiter lets through at most one event per interval
+// and counts the events suppressed in between. It is safe to use from the callback thread.
+type rateLimiter struct {
+	interval   time.Duration
+	last       atomic.Int64
+	suppressed atomic.Uint64
+}
+
+// allow reports whether an event may be emitted now and
+// how many events were suppressed since the last emitted one.
+func (l *rateLimiter) allow() (suppressed uint64, ok bool) {
+	now := time.Now().UnixNano()
+	last := l.last.Load()
+	if last != 0 && now-last < int64(l.interval) || !l.last.CompareAndSwap(last, now) {
+		l.suppressed.Add(1)
+		return 0, false
+	}
+	return l.suppressed.Swap(0), true
+}
+
+// LogValue implements slog.LogValuer.
+func (p StreamDeviceParameters) LogValue() slog.Value {
+	if !p.Exists() {
+		return slog.GroupValue()
+	}
+	return slog.GroupValue(
+		slog.Int("device", p.Device.Index),
+		slog.String("name", p.Device.Name),
+		slog.Int("channels", p.ChannelCount),
+		slog.Duration("latency", p.SuggestedLatency),
+	)
+}
+
+// LogValue implements slog.LogValuer.
+func (p *StreamParameters) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("input", p.Input),
+		slog.Any("output", p.Output),
+		slog.Float64("sampleRate", p.SampleRate),
+		slog.Any("sampleFormat", p.SampleFormat),
+		slog.Uint64("framesPerBuffer", p.FramesPerBuffer),
+		slog.Any("flags", p.Flags),
+	)
+}
+
+func (s *Stream[T]) logger() *slog.Logger {
+	if s.params.Logger != nil {
+		return s.params.Logger
+	}
+	return Logger()
+}
+
+// logOp wraps the error of a stream operation into a StreamError and logs the result
+// with the stream parameters, like the opening of the stream.
+func (s *Stream[T]) logOp(op string, device *DeviceInfo, err error) error {
+	l := s.logger()
+	if err != nil {
+		err = streamError(op, device, s.params, err)
+		logError(l, "stream "+op+" failed", err, slog.Any("params", s.params))
+		return err
+	}
+	l.Info("stream "+op, slog.Any("params", s.params))
+	return nil
+}
+
+// logXrun logs an underflow or overflow. Events are rate limited
+// so that a storm of xruns does not flood the log from the real-time path.
+func (s *Stream[T]) logXrun(flags StreamCallbackFlags) {
+	l := s.logger()
+	if !l.Enabled(context.Background(), slog.LevelWarn) {
+		return
+	}
+	if suppressed, ok := s.xrunLimiter.allow(); ok {
+		l.Warn("stream xrun", slog.Any("flags", flags), slog.Uint64("suppressed", suppressed))
+	}
+}
@@ -0,0 +1,253 @@
+package portaudio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordHandler collects the records logged at debug level and above.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+func (h *recordHandler) messages() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var msgs []string
+	for _, r := range h.records {
+		msgs = append(msgs, r.Message)
+	}
+	return msgs
+}
+
+// attrs returns the attributes of the first record with the message msg.
+func (h *recordHandler) attrs(msg string) map[string]slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.records {
+		if r.Message == msg {
+			m := map[string]slog.Value{}
+			r.Attrs(func(a slog.Attr) bool {
+				m[a.Key] = a.Value.Resolve()
+				return true
+			})
+			return m
+		}
+	}
+	return nil
+}
+
+// level returns the level of the first record with the message msg.
+func (h *recordHandler) level(msg string) slog.Level {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.records {
+		if r.Message == msg {
+			return r.Level
+		}
+	}
+	return slog.LevelDebug - 1
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := rateLimiter{interval: 50 * time.Millisecond}
+	if _, ok := l.allow(); !ok {
+		t.Fatal("first event suppressed")
+	}
+	for range 3 {
+		if _, ok := l.allow(); ok {
+			t.Fatal("event allowed within the interval")
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if suppressed, ok := l.allow(); !ok || suppressed != 3 {
+		t.Errorf("allow = %d, %v after the interval, want 3, true", suppressed, ok)
+	}
+}
+
+func TestLogHostError(t *testing.T) {
+	h := &recordHandler{}
+	err := fmt.Errorf("start: %w", HostErrorInfo{HostApiType: ALSA, Code: -19, Text: "No such device"})
+	logError(slog.New(h), "failed", err)
+	attrs := h.attrs("failed")
+	if attrs["hostApi"].String() != ALSA.String() || attrs["hostCode"].Int64() != -19 || attrs["hostText"].String() != "No such device" {
+		t.Errorf("logged %v", attrs)
+	}
+}
+
+func TestParametersLogValue(t *testing.T) {
+	device := &DeviceInfo{Index: 3, Name: "Speakers"}
+	params := &StreamParameters{
+		Output:          StreamDeviceParameters{Device: device, ChannelCount: 2, SuggestedLatency: 20 * time.Millisecond},
+		SampleRate:      48000,
+		SampleFormat:    Int16,
+		FramesPerBuffer: 256,
+	}
+	v := params.LogValue()
+	attrs := map[string]slog.Value{}
+	for _, a := range v.Group() {
+		attrs[a.Key] = a.Value.Resolve()
+	}
+	if len(attrs["input"].Group()) != 0 {
+		t.Errorf("absent input logged as %v", attrs["input"])
+	}
+	output := map[string]slog.Value{}
+	for _, a := range attrs["output"].Group() {
+		output[a.Key] = a.Value
+	}
+	if output["device"].Int64() != 3 || output["name"].String() != "Speakers" ||
+		output["channels"].Int64() != 2 || output["latency"].Duration() != 20*time.Millisecond {
+		t.Errorf("output logged as %v", attrs["output"])
+	}
+	if attrs["sampleRate"].Float64() != 48000 || attrs["framesPerBuffer"].Uint64() != 256 {
+		t.Errorf("logged %v", v)
+	}
+}
+
+func TestStreamLogging(t *testing.T) {
+	h := &recordHandler{}
+	params := loopbackParams(t, Float32, 1)
+	params.Logger = slog.New(h)
+	s, err := OpenStream[float32](params, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"stream opened", "stream start", "stream stop", "stream close"}
+	if msgs := h.messages(); !slices.Equal(msgs, want) {
+		t.Errorf("logged %q, want %q", msgs, want)
+	}
+	attrs := h.attrs("stream opened")
+	if attrs["callback"].Bool() {
+		t.Error("blocking stream logged with a callback")
+	}
+	if p := attrs["params"]; p.Kind() != slog.KindGroup || len(p.Group()) != 6 {
+		t.Errorf("params logged as %v", p)
+	}
+	// The life cycle is logged at the level of the opening with the same parameters.
+	for _, msg := range want {
+		if level := h.level(msg); level != slog.LevelInfo {
+			t.Errorf("%q logged at %v", msg, level)
+		}
+		if p := h.attrs(msg)["params"]; !p.Equal(attrs["params"]) {
+			t.Errorf("%q logged params %v, want %v", msg, p, attrs["params"])
+		}
+	}
+}
+
+func TestStreamLoggingFailure(t *testing.T) {
+	h := &recordHandler{}
+	params := loopbackParams(t, Float32, 1)
+	params.Logger = slog.New(h)
+	s, err := OpenStream[float32](params, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Stop(); !errors.Is(err, StreamIsStopped) {
+		t.Fatalf("Stop of a stopped stream: %v", err)
+	}
+	attrs := h.attrs("stream stop failed")
+	if h.level("stream stop failed") != slog.LevelError || !attrs["params"].Equal(h.attrs("stream opened")["params"]) {
+		t.Errorf("logged %v", attrs)
+	}
+}
+
+func TestStreamLoggingOpenFailure(t *testing.T) {
+	h := &recordHandler{}
+	params := loopbackParams(t, Float32, 1)
+	params.SampleRate = 44100
+	params.Logger = slog.New(h)
+	if _, err := OpenStream[float32](params, nil, nil); err == nil {
+		t.Fatal("stream opened at an unsupported rate")
+	}
+	attrs := h.attrs("stream open failed")
+	if attrs == nil {
+		t.Fatalf("logged %q", h.messages())
+	}
+	var se *StreamError
+	if err, _ := attrs["error"].Any().(error); !errors.As(err, &se) || se.Op != "open" {
+		t.Errorf("logged error %v", attrs["error"])
+	}
+}
+
+func TestPackageLogger(t *testing.T) {
+	if Logger() != discardLogger {
+		t.Fatal("logging enabled by default")
+	}
+	h := &recordHandler{}
+	SetLogger(slog.New(h))
+	t.Cleanup(func() { SetLogger(nil) })
+	// Streams without their own logger use the package logger.
+	s, err := OpenStream[float32](loopbackParams(t, Float32, 1), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	if msgs := h.messages(); len(msgs) == 0 || msgs[0] != "stream opened" {
+		t.Errorf("logged %q", msgs)
+	}
+}
+
+func TestXrunLogging(t *testing.T) {
+	h := &recordHandler{}
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 1, Xruns: 1})
+	t.Cleanup(l.Close)
+	params := &StreamParameters{
+		Output:          StreamDeviceParameters{Device: l.Output(), ChannelCount: 1},
+		SampleRate:      48000,
+		SampleFormat:    Float32,
+		FramesPerBuffer: 256,
+		Logger:          slog.New(h),
+	}
+	var mu sync.Mutex
+	calls := 0
+	openTestStream(t, params, func(*Stream[float32]) StreamCallbackResult {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return Continue
+	})
+	waitFor(t, "callbacks", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls >= 10
+	})
+	// Every buffer is an xrun, but only the first one within a second is logged.
+	var xruns int
+	for _, msg := range h.messages() {
+		if msg == "stream xrun" {
+			xruns++
+		}
+	}
+	if xruns != 1 {
+		t.Errorf("logged %d xruns, want 1", xruns)
+	}
+}
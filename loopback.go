@@ -0,0 +1,502 @@
+package portaudio
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// LoopbackOptions configures a Loopback.
+type LoopbackOptions struct {
+	// Name is the prefix of the device names. Empty selects "Loopback".
+	Name string
+	// Channels is the channel count of both devices. Zero selects 2.
+	Channels int
+	// SampleRate is the only sample rate supported by the devices. Zero selects 48000.
+	SampleRate float64
+	// FramesPerBuffer is used by streams opened with FramesPerBufferUnspecified. Zero selects 256.
+	FramesPerBuffer int
+	// Latency is the delay between writing to the output and reading from the input.
+	// It is at least one buffer. Zero selects 10ms.
+	Latency time.Duration
+	// Drift is the deviation of the input clock from the output clock in parts per million.
+	Drift float64
+	// Noise is the amplitude of the white noise added to the input.
+	Noise float64
+	// Dropouts is the probability that an input buffer is replaced by silence.
+	Dropouts float64
+	// Xruns is the probability that a buffer is flagged with InputOverflow or OutputUnderflow.
+	Xruns float64
+	// Seed seeds the noise, dropouts and xruns.
+	Seed uint64
+}
+
+// Loopback is a virtual device pair for tests: everything written to a stream
+// on its output device appears on streams of its input device after a latency.
+// The devices are listed by Device and DeviceCount after the PortAudio devices
+// and are opened with OpenStream like any other device, so code under test
+// can use them without changes, e.g. by selecting them with DeviceEnv.
+// Output streams are mixed. A duplex stream may use both devices.
+type Loopback struct {
+	options LoopbackOptions
+	epoch   time.Time
+	delay   int64
+
+	mu     sync.Mutex
+	ring   []float32 // the written frames
+	stamps []int64   // the frame position held by each ring slot
+	rand   *rand.Rand
+}
+
+// NewLoopback creates a loopback device pair and makes it available through Device.
+func NewLoopback(options LoopbackOptions) *Loopback {
+	if options.Name == "" {
+		options.Name = "Loopback"
+	}
+	if options.Channels <= 0 {
+		options.Channels = 2
+	}
+	if options.SampleRate <= 0 {
+		options.SampleRate = 48000
+	}
+	if options.FramesPerBuffer <= 0 {
+		options.FramesPerBuffer = 256
+	}
+	if options.Latency <= 0 {
+		options.Latency = 10 * time.Millisecond
+	}
+	l := &Loopback{
+		options: options,
+		epoch:   time.Now(),
+		delay:   framesAt(options.Latency, options.SampleRate),
+		rand:    rand.New(rand.NewPCG(options.Seed, options.Seed)),
+	}
+	size := int(l.delay) + int(options.SampleRate)
+	l.ring = make([]float32, size*options.Channels)
+	l.stamps = make([]int64, size)
+	for i := range l.stamps {
+		l.stamps[i] = -1
+	}
+	api := &HostApiInfo{Type: InDevelopment, Name: options.Name}
+	latency := time.Duration(options.FramesPerBuffer) * time.Second / time.Duration(options.SampleRate)
+	device := func(name string, in, out int) *DeviceInfo {
+		return &DeviceInfo{
+			Name:                     name,
+			MaxInputChannels:         in,
+			MaxOutputChannels:        out,
+			DefaultLowInputLatency:   latency,
+			DefaultLowOutputLatency:  latency,
+			DefaultHighInputLatency:  4 * latency,
+			DefaultHighOutputLatency: 4 * latency,
+			DefaultSampleRate:        options.SampleRate,
+			HostApi:                  api,
+			virtual:                  l,
+		}
+	}
+	registerVirtual(
+		device(options.Name+" Input", options.Channels, 0),
+		device(options.Name+" Output", 0, options.Channels),
+	)
+	return l
+}
+
+// Input returns the input device. It returns nil after Close.
+func (l *Loopback) Input() *DeviceInfo {
+	return l.find(true)
+}
+
+// Output returns the output device. It returns nil after Close.
+func (l *Loopback) Output() *DeviceInfo {
+	return l.find(false)
+}
+
+func (l *Loopback) find(input bool) *DeviceInfo {
+	for i, n := 0, DeviceCount(); i < n; i++ {
+		if d := Device(i); d != nil && d.virtual == l && (d.MaxInputChannels > 0) == input {
+			return d
+		}
+	}
+	return nil
+}
+
+// Close removes the devices. Open streams keep working.
+func (l *Loopback) Close() {
+	unregisterVirtual(l)
+}
+
+func (l *Loopback) checkFormat(params *StreamParameters) error {
+	if params.Input.Exists() && params.Input.Device.MaxInputChannels == 0 ||
+		params.Output.Exists() && params.Output.Device.MaxOutputChannels == 0 {
+		return InvalidChannelCount
+	}
+	for _, p := range []StreamDeviceParameters{params.Input, params.Output} {
+		if p.Exists() && (p.ChannelCount <= 0 || p.ChannelCount > l.options.Channels) {
+			return InvalidChannelCount
+		}
+	}
+	if params.SampleRate != l.options.SampleRate {
+		return InvalidSampleRate
+	}
+	if sampleBytes(params.SampleFormat) == 0 {
+		return SampleFormatNotSupported
+	}
+	return nil
+}
+
+func (l *Loopback) open(params *StreamParameters, target callbackTarget, callback bool) (backend, error) {
+	if err := l.checkFormat(params); err != nil {
+		return nil, err
+	}
+	frames := int(params.FramesPerBuffer)
+	if frames == FramesPerBufferUnspecified {
+		frames = l.options.FramesPerBuffer
+	}
+	s := &loopbackStream{
+		l:        l,
+		target:   target,
+		callback: callback,
+		format:   params.SampleFormat,
+		frames:   frames,
+		inRate:   l.options.SampleRate * (1 + l.options.Drift*1e-6),
+		outRate:  l.options.SampleRate,
+		delay:    max(l.delay, int64(frames)),
+		isStop:   true,
+		inFrame:  make([]float32, frames*l.options.Channels),
+		outFrame: make([]float32, frames*l.options.Channels),
+	}
+	if params.Input.Exists() {
+		s.inChannels = params.Input.ChannelCount
+		s.in = allocDeviceBuffer(params.SampleFormat, s.inChannels, frames)
+	}
+	if params.Output.Exists() {
+		s.outChannels = params.Output.ChannelCount
+		s.out = allocDeviceBuffer(params.SampleFormat, s.outChannels, frames)
+		if s.inChannels > 0 {
+			// A duplex stream runs on a single clock.
+			s.inRate = s.outRate
+		}
+	}
+	return s, nil
+}
+
+// write mixes frames of interleaved samples into the ring at the given position.
+func (l *Loopback) write(pos int64, samples []float32, channels int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := l.options.Channels
+	for f := range len(samples) / channels {
+		p := pos + int64(f)
+		slot := int(p % int64(len(l.stamps)))
+		frame := l.ring[slot*ch : (slot+1)*ch]
+		if l.stamps[slot] != p {
+			l.stamps[slot] = p
+			clear(frame)
+		}
+		for c := range channels {
+			frame[c] += samples[f*channels+c]
+		}
+	}
+}
+
+// read reads frames of interleaved samples from the ring at the given position,
+// adding noise and dropouts. It returns whether an xrun is simulated.
+func (l *Loopback) read(pos int64, samples []float32, channels int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := l.options.Channels
+	dropout := l.options.Dropouts > 0 && l.rand.Float64() < l.options.Dropouts
+	for f := range len(samples) / channels {
+		p := pos + int64(f)
+		slot := int(p % int64(len(l.stamps)))
+		frame := samples[f*channels : (f+1)*channels]
+		if p < 0 || l.stamps[slot] != p || dropout {
+			clear(frame)
+		} else {
+			copy(frame, l.ring[slot*ch:slot*ch+channels])
+		}
+		if l.options.Noise > 0 {
+			for c := range frame {
+				frame[c] += float32(l.options.Noise * (2*l.rand.Float64() - 1))
+			}
+		}
+	}
+	return l.xrun()
+}
+
+// xrun returns whether an xrun is simulated.
+func (l *Loopback) xrun() bool {
+	return l.options.Xruns > 0 && l.rand.Float64() < l.options.Xruns
+}
+
+// loopbackStream is a stream on the devices of a Loopback.
+// Input and output positions are frames on the clocks of the devices since the epoch of the loopback.
+type loopbackStream struct {
+	l                *Loopback
+	target           callbackTarget
+	callback         bool
+	format           SampleFormat
+	frames           int
+	inChannels       int
+	outChannels      int
+	in, out          deviceBuffer
+	inRate, outRate  float64
+	delay            int64
+	inFrame          []float32 // scratch buffers, separate so a duplex stream can read and write concurrently
+	outFrame         []float32
+	mu               sync.Mutex
+	isActive, isStop bool
+	quit, done       chan struct{}
+	inPos, outPos    int64       // guarded by mu
+	inCallback       atomic.Bool // set by run while it calls the callback or the finished callback
+}
+
+func (s *loopbackStream) elapsed() time.Duration {
+	return time.Since(s.l.epoch)
+}
+
+func (s *loopbackStream) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStop {
+		return StreamIsNotStopped
+	}
+	if done := s.done; done != nil && !s.inCallback.Load() {
+		// A callback that stopped the stream may still be returning.
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+		if !s.isStop {
+			return StreamIsNotStopped
+		}
+	}
+	now := s.elapsed()
+	s.inPos, s.outPos = framesAt(now, s.inRate), framesAt(now, s.outRate)
+	s.isActive, s.isStop = true, false
+	if s.callback {
+		s.quit, s.done = make(chan struct{}), make(chan struct{})
+		go s.run(s.quit, s.done)
+	}
+	return nil
+}
+
+// run calls the stream callback whenever the device clock completes a buffer.
+func (s *loopbackStream) run(quit, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		s.inCallback.Store(true)
+		defer s.inCallback.Store(false)
+		s.target.finished()
+	}()
+	defer func() {
+		s.mu.Lock()
+		s.isActive = false
+		s.mu.Unlock()
+	}()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		inPos, outPos := s.positions()
+		var wake time.Duration
+		if s.inChannels > 0 {
+			wake = timeAt(inPos+int64(s.frames), s.inRate)
+		} else {
+			wake = timeAt(outPos+int64(s.frames), s.outRate)
+		}
+		timer.Reset(max(0, wake-s.elapsed()))
+		select {
+		case <-quit:
+			return
+		case <-timer.C:
+		}
+		// The callback may have stopped the stream while the timer was running.
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		if s.process(inPos, outPos) != Continue {
+			return
+		}
+	}
+}
+
+// positions returns the frame positions of the input and output clocks.
+func (s *loopbackStream) positions() (in, out int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inPos, s.outPos
+}
+
+// process runs the callback for the buffer at the given positions.
+func (s *loopbackStream) process(inPos, outPos int64) StreamCallbackResult {
+	var flags StreamCallbackFlags
+	var in, out unsafe.Pointer
+	if s.inChannels > 0 {
+		samples := s.inFrame[:s.frames*s.inChannels]
+		if s.l.read(inPos-s.delay, samples, s.inChannels) {
+			flags |= InputOverflow
+		}
+		s.in.store(samples, s.frames)
+		in = s.in.ptr
+	}
+	if s.outChannels > 0 {
+		out = s.out.ptr
+		if s.l.xrun() {
+			flags |= OutputUnderflow
+		}
+	}
+	timeInfo := callbackTimeInfo(timeAt(inPos, s.inRate), s.elapsed(), timeAt(outPos, s.outRate))
+	s.inCallback.Store(true)
+	result := s.target.Callback(in, out, C.ulong(s.frames), &timeInfo, C.PaStreamCallbackFlags(flags))
+	s.inCallback.Store(false)
+	if s.outChannels > 0 {
+		samples := s.outFrame[:s.frames*s.outChannels]
+		s.out.load(samples, s.frames)
+		s.l.write(outPos, samples, s.outChannels)
+	}
+	s.mu.Lock()
+	s.inPos, s.outPos = inPos+int64(s.frames), outPos+int64(s.frames)
+	s.mu.Unlock()
+	return result
+}
+
+func (s *loopbackStream) stop() error {
+	return s.halt()
+}
+
+func (s *loopbackStream) abort() error {
+	return s.halt()
+}
+
+func (s *loopbackStream) halt() error {
+	s.mu.Lock()
+	if s.isStop {
+		s.mu.Unlock()
+		return StreamIsStopped
+	}
+	s.isStop = true
+	s.isActive = false
+	quit, done := s.quit, s.done
+	s.mu.Unlock()
+	if quit != nil {
+		close(quit)
+		// Called from the callback, the stream ends when the callback returns.
+		if !s.inCallback.Load() {
+			<-done
+		}
+	}
+	return nil
+}
+
+func (s *loopbackStream) close() error {
+	if !s.stopped() {
+		return s.halt()
+	}
+	return nil
+}
+
+func (s *loopbackStream) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isActive
+}
+
+func (s *loopbackStream) stopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isStop
+}
+
+func (s *loopbackStream) time() time.Duration {
+	return s.elapsed()
+}
+
+// info splits the delay of the loopback into one buffer of output latency
+// and the rest as input latency, so their sum is the round-trip latency.
+func (s *loopbackStream) info() *StreamInfo {
+	info := &StreamInfo{SampleRate: s.outRate}
+	if s.inChannels > 0 {
+		info.InputLatency = timeAt(s.delay-int64(s.frames), s.inRate)
+		if s.outChannels == 0 {
+			info.SampleRate = s.inRate
+		}
+	}
+	if s.outChannels > 0 {
+		info.OutputLatency = timeAt(int64(s.frames), s.outRate)
+	}
+	return info
+}
+
+// hostBuffer is the number of frames a blocking stream may fall behind before an xrun.
+func (s *loopbackStream) hostBuffer() int64 {
+	return 4 * int64(s.frames)
+}
+
+func (s *loopbackStream) read(buf unsafe.Pointer, frames int) error {
+	if s.inChannels == 0 {
+		return CanNotReadFromAnOutputOnlyStream
+	}
+	if s.stopped() {
+		return StreamIsStopped
+	}
+	var err error
+	s.mu.Lock()
+	pos := s.inPos
+	if lag := framesAt(s.elapsed(), s.inRate) - pos - int64(frames); lag > s.hostBuffer() {
+		pos += lag
+		err = InputOverflowed
+	}
+	end := pos + int64(frames)
+	s.inPos = end
+	s.mu.Unlock()
+	time.Sleep(timeAt(end, s.inRate) - s.elapsed())
+	samples := resize(s.inFrame, frames*s.inChannels)
+	if s.l.read(pos-s.delay, samples, s.inChannels) && err == nil {
+		err = InputOverflowed
+	}
+	deviceBuffer{buf, s.format, s.inChannels}.store(samples, frames)
+	return err
+}
+
+func (s *loopbackStream) write(buf unsafe.Pointer, frames int) error {
+	if s.outChannels == 0 {
+		return CanNotWriteToAnInputOnlyStream
+	}
+	if s.stopped() {
+		return StreamIsStopped
+	}
+	var err error
+	s.mu.Lock()
+	pos := s.outPos
+	if lag := framesAt(s.elapsed(), s.outRate) - pos; lag > s.hostBuffer() {
+		pos += lag
+		err = OutputUnderflowed
+	} else if s.l.xrun() {
+		err = OutputUnderflowed
+	}
+	s.outPos = pos + int64(frames)
+	s.mu.Unlock()
+	// The buffer is written when the clock reaches its first frame.
+	time.Sleep(timeAt(pos, s.outRate) - s.elapsed())
+	samples := resize(s.outFrame, frames*s.outChannels)
+	deviceBuffer{buf, s.format, s.outChannels}.load(samples, frames)
+	s.l.write(pos, samples, s.outChannels)
+	return err
+}
+
+func (s *loopbackStream) readAvailable() int {
+	inPos, _ := s.positions()
+	return int(max(0, framesAt(s.elapsed(), s.inRate)-inPos))
+}
+
+func (s *loopbackStream) writeAvailable() int {
+	_, outPos := s.positions()
+	return int(max(0, s.hostBuffer()-(outPos-framesAt(s.elapsed(), s.outRate))))
+}
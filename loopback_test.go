@@ -0,0 +1,216 @@
+package portaudio
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoopbackPlayback(t *testing.T) {
+	params := loopbackParams(t, Int16, 1)
+	s := openTestStream[int16](t, params, nil)
+	delay := int(framesAt(10*time.Millisecond, 48000))
+	var played, recorded []int16
+	out := make([]int16, 256)
+	for range 20 {
+		for i := range out {
+			out[i] = int16(len(played) + i + 1)
+		}
+		played = append(played, out...)
+		if err := s.Write(out); err != nil {
+			t.Fatal(err)
+		}
+		in, err := s.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, in...)
+	}
+	for i, v := range recorded {
+		var want int16
+		if i >= delay {
+			want = played[i-delay]
+		}
+		if v != want {
+			t.Fatalf("frame %d = %d, want %d", i, v, want)
+		}
+	}
+}
+
+func TestLoopbackSeparateStreams(t *testing.T) {
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 1})
+	t.Cleanup(l.Close)
+	var next atomic.Int32
+	openTestStream(t, &StreamParameters{
+		Output:          StreamDeviceParameters{Device: l.Output(), ChannelCount: 1},
+		SampleRate:      48000,
+		SampleFormat:    Int16,
+		FramesPerBuffer: 256,
+	}, func(s *Stream[int16]) StreamCallbackResult {
+		for i := range s.Out() {
+			s.Out()[i] = int16(next.Add(1)%30000 + 1)
+		}
+		return Continue
+	})
+	in := openTestStream[int16](t, &StreamParameters{
+		Input:           StreamDeviceParameters{Device: l.Input(), ChannelCount: 1},
+		SampleRate:      48000,
+		SampleFormat:    Int16,
+		FramesPerBuffer: 256,
+	}, nil)
+	var recorded []int16
+	for range 10 {
+		buf, err := in.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorded = append(recorded, buf...)
+	}
+	// The streams start at different times, but the ramp arrives without gaps.
+	last := recorded[len(recorded)-1024:]
+	for i := 1; i < len(last); i++ {
+		if last[i] != last[i-1]%30000+1 {
+			t.Fatalf("frame %d = %d follows %d", i, last[i], last[i-1])
+		}
+	}
+}
+
+func TestLoopbackStopFromCallback(t *testing.T) {
+	for _, op := range []string{"stop", "abort"} {
+		t.Run(op, func(t *testing.T) {
+			var calls atomic.Int32
+			result := make(chan error, 1)
+			s := openTestStream(t, loopbackParams(t, Float32, 1), func(s *Stream[float32]) StreamCallbackResult {
+				if calls.Add(1) == 3 {
+					if op == "stop" {
+						result <- s.Stop()
+					} else {
+						result <- s.Abort()
+					}
+				}
+				return Continue
+			})
+			select {
+			case err := <-result:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s from the callback did not return", op)
+			}
+			waitFor(t, "the stream to become inactive", func() bool { return !s.IsActive() })
+			time.Sleep(20 * time.Millisecond)
+			if n := calls.Load(); n != 3 {
+				t.Errorf("callback called %d times, want 3", n)
+			}
+			if !s.IsStopped() {
+				t.Error("stream not stopped")
+			}
+			if err := s.Start(); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "the callback after restarting", func() bool { return calls.Load() > 3 })
+		})
+	}
+}
+
+func TestLoopbackStopFromFinishedCallback(t *testing.T) {
+	result := make(chan error, 1)
+	s, err := OpenStream(loopbackParams(t, Float32, 1), func(s *Stream[float32]) StreamCallbackResult {
+		return Complete
+	}, func(s *Stream[float32]) { result <- s.Stop() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop from the finished callback did not return")
+	}
+	if !s.IsStopped() {
+		t.Error("stream not stopped")
+	}
+}
+
+func TestLoopbackConcurrentAccess(t *testing.T) {
+	s := openTestStream[float32](t, loopbackParams(t, Float32, 1), nil)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			if _, err := s.Read(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		out := make([]float32, 256)
+		for range 10 {
+			if err := s.Write(out); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for range 100 {
+		if _, err := s.ReadAvailable(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.WriteAvailable(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+}
+
+func TestLoopbackInfo(t *testing.T) {
+	s := openTestStream[float32](t, loopbackParams(t, Float32, 1), nil)
+	info := s.Info()
+	if info.InputLatency <= 0 || info.OutputLatency <= 0 {
+		t.Fatalf("latencies %v and %v, want both positive", info.InputLatency, info.OutputLatency)
+	}
+	if sum := info.InputLatency + info.OutputLatency; sum.Round(time.Microsecond) != 10*time.Millisecond {
+		t.Errorf("round-trip latency %v, want 10ms", sum)
+	}
+}
+
+func TestVirtualDeviceIndex(t *testing.T) {
+	a := NewLoopback(LoopbackOptions{Name: t.Name() + " A"})
+	b := NewLoopback(LoopbackOptions{Name: t.Name() + " B"})
+	t.Cleanup(b.Close)
+	index := b.Input().Index
+	a.Close()
+	if got := b.Input().Index; got != index {
+		t.Errorf("index changed from %d to %d when another device was removed", index, got)
+	}
+	if d := Device(index); d == nil || d.Name != b.Input().Name {
+		t.Errorf("Device(%d) = %v, want %q", index, d, b.Input().Name)
+	}
+	found := false
+	for i, n := 0, DeviceCount(); i < n; i++ {
+		if d := Device(i); d != nil && d.Index == index {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("device not listed")
+	}
+	if err := RescanDevices(); err != nil {
+		t.Fatal(err)
+	}
+	if d := Device(index); d == nil || d.Name != b.Input().Name {
+		t.Errorf("after rescanning Device(%d) = %v, want %q", index, d, b.Input().Name)
+	}
+}
@@ -0,0 +1,123 @@
+package meter
+
+import "math"
+
+// biquad is a second order IIR filter in transposed direct form II.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+type biquadState struct {
+	z1, z2 float64
+}
+
+func (f *biquad) process(s *biquadState, x float64) float64 {
+	y := f.b0*x + s.z1
+	s.z1 = f.b1*x - f.a1*y + s.z2
+	s.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter of ITU-R BS.1770:
+// a high shelf modeling the acoustic effect of the head and a high pass (RLB weighting).
+// The coefficients are derived for the given sample rate and match the ones
+// published for 48kHz.
+func kWeighting(sampleRate float64) (shelf, highPass biquad) {
+	const (
+		shelfFreq = 1681.974450955533
+		shelfGain = 3.999843853973347
+		shelfQ    = 0.7071752369554196
+		hpFreq    = 38.13547087602444
+		hpQ       = 0.5003270373238773
+	)
+	k := math.Tan(math.Pi * shelfFreq / sampleRate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf = biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+	k = math.Tan(math.Pi * hpFreq / sampleRate)
+	a0 = 1 + k/hpQ + k*k
+	highPass = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/hpQ + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// Oversampling of the true peak measurement.
+const (
+	oversampling = 4
+	tapsPerPhase = 12
+)
+
+// interpolator is a polyphase FIR filter upsampling by the oversampling factor
+// to estimate the peaks between samples.
+type interpolator struct {
+	phases [oversampling][tapsPerPhase]float64
+}
+
+// newInterpolator designs a Kaiser windowed sinc low pass with the cutoff at the original Nyquist frequency.
+func newInterpolator() *interpolator {
+	const n = oversampling * tapsPerPhase
+	const beta = 6.0
+	ip := &interpolator{}
+	center := float64(n-1) / 2
+	for i := range n {
+		x := (float64(i) - center) / oversampling
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		r := (float64(i) - center) / center
+		w := besselI0(beta*math.Sqrt(max(0, 1-r*r))) / besselI0(beta)
+		ip.phases[i%oversampling][i/oversampling] = sinc * w
+	}
+	// Normalize the phases to unity gain at DC.
+	for p := range ip.phases {
+		var sum float64
+		for _, c := range ip.phases[p] {
+			sum += c
+		}
+		for i := range ip.phases[p] {
+			ip.phases[p][i] /= sum
+		}
+	}
+	return ip
+}
+
+// peak pushes x into the history and returns the largest absolute value of the interpolated samples.
+func (ip *interpolator) peak(history *[tapsPerPhase]float64, x float64) float64 {
+	copy(history[1:], history[:tapsPerPhase-1])
+	history[0] = x
+	p := 0.0
+	for _, phase := range ip.phases {
+		var y float64
+		for i, c := range phase {
+			y += c * history[i]
+		}
+		p = max(p, math.Abs(y))
+	}
+	return p
+}
+
+// besselI0 is the modified Bessel function of the first kind of order zero.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / 2) / float64(k)
+		sum += term * term
+		if term*term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
@@ -0,0 +1,39 @@
+package meter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKWeighting(t *testing.T) {
+	// The coefficients published in ITU-R BS.1770 for 48kHz.
+	shelf, hp := kWeighting(48000)
+	for _, tt := range []struct {
+		got, want biquad
+	}{
+		{shelf, biquad{1.53512485958697, -2.69169618940638, 1.19839281085285, -1.69065929318241, 0.73248077421585}},
+		{hp, biquad{1, -2, 1, -1.99004745483398, 0.99007225036621}},
+	} {
+		got := []float64{tt.got.b0, tt.got.b1, tt.got.b2, tt.got.a1, tt.got.a2}
+		want := []float64{tt.want.b0, tt.want.b1, tt.want.b2, tt.want.a1, tt.want.a2}
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > 1e-8 {
+				t.Errorf("coefficients %v, want %v", got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestInterpolator(t *testing.T) {
+	ip := newInterpolator()
+	var history [tapsPerPhase]float64
+	// A constant signal is passed unchanged.
+	var p float64
+	for range 2 * tapsPerPhase {
+		p = ip.peak(&history, 0.5)
+	}
+	if math.Abs(p-0.5) > 1e-12 {
+		t.Errorf("peak of a constant signal = %v, want 0.5", p)
+	}
+}
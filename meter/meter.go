@@ -0,0 +1,425 @@
+// Package meter measures the level of audio: sample peak, RMS, true peak
+// and the loudness and loudness range defined by ITU-R BS.1770 and EBU R128.
+//
+// A Meter is fed from the audio thread, typically from a stream callback,
+// and can be read from any goroutine through lock-free snapshots.
+package meter
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Loudness gating of EBU R128.
+const (
+	absoluteGate     = -70.0 // LUFS
+	relativeGate     = -10.0 // LU
+	rangeGate        = -20.0 // LU, relative gate of the loudness range
+	rangeLow         = 0.10  // percentiles of the loudness range
+	rangeHigh        = 0.95
+	blockStep        = 100 * time.Millisecond
+	momentaryBlocks  = 4  // 400ms
+	shortTermBlocks  = 30 // 3s
+	histogramMin     = absoluteGate
+	histogramMax     = 10.0
+	histogramBinSize = 0.01
+	histogramBins    = int((histogramMax - histogramMin) / histogramBinSize)
+)
+
+// Options configures a Meter.
+type Options struct {
+	// Window is the integration time of the peak and RMS levels. Zero selects 300ms.
+	Window time.Duration
+	// Weights are the loudness weights of the channels. If nil, the weights
+	// of ITU-R BS.1770 are used for 5.0 and 5.1 layouts and 1 otherwise.
+	Weights []float64
+}
+
+// Snapshot holds the levels measured by a Meter.
+// Levels are linear, loudness values are in LUFS and -Inf if not measured yet.
+type Snapshot struct {
+	// Peak is the largest absolute sample of each channel in the last window.
+	Peak []float64
+	// RMS is the root mean square of each channel in the last window.
+	RMS []float64
+	// TruePeak is the largest absolute value of each channel in the last window,
+	// including the peaks between the samples estimated by 4x oversampling.
+	TruePeak []float64
+	// MaxPeak and MaxTruePeak are the largest values since the meter was created or reset.
+	MaxPeak     []float64
+	MaxTruePeak []float64
+	// Momentary is the loudness of the last 400ms.
+	Momentary float64
+	// ShortTerm is the loudness of the last 3s.
+	ShortTerm float64
+	// Integrated is the gated loudness since the meter was created or reset.
+	Integrated float64
+	// LoudnessRange is the loudness range of EBU Tech 3342 in LU since the meter
+	// was created or reset, i.e. the spread of the gated short-term loudness values.
+	// It is zero if not measured yet.
+	LoudnessRange float64
+}
+
+type channel struct {
+	shelfState, highPassState biquadState
+	history                   [tapsPerPhase]float64
+	peak, truePeak, squares   float64
+	maxPeak, maxTruePeak      float64
+	energy                    float64 // K-weighted energy of the current block
+}
+
+// published holds the values readable from other goroutines.
+type published struct {
+	seq        atomic.Uint64
+	peak       []atomic.Uint64
+	rms        []atomic.Uint64
+	truePeak   []atomic.Uint64
+	maxPeak    []atomic.Uint64
+	maxTrue    []atomic.Uint64
+	momentary  atomic.Uint64
+	shortTerm  atomic.Uint64
+	integrated atomic.Uint64
+	lra        atomic.Uint64
+}
+
+// Meter measures levels and loudness of interleaved or planar audio.
+// Process and its variants must be called from one goroutine at a time,
+// Snapshot may be called from any goroutine. Processing does not allocate.
+type Meter struct {
+	channels     int
+	weights      []float64
+	shelf, hp    biquad
+	interpolator *interpolator
+	state        []channel
+	window       int
+	windowFrames int
+	blockSize    int
+	blockFrames  int
+	blocks       [shortTermBlocks]float64
+	blockCount   int
+	blockIndex   int
+	binCount     [histogramBins]uint64 // histogram of the momentary loudness for the integrated loudness
+	binEnergy    [histogramBins]float64
+	rangeCount   [histogramBins]uint64 // histogram of the short-term loudness for the loudness range
+	rangeEnergy  [histogramBins]float64
+	reset        atomic.Bool
+	out          published
+	frame        []float64
+}
+
+// New creates a meter for audio with the given channel count and sample rate.
+func New(channels int, sampleRate float64, options Options) *Meter {
+	if options.Window <= 0 {
+		options.Window = 300 * time.Millisecond
+	}
+	m := &Meter{
+		channels:     channels,
+		weights:      options.Weights,
+		interpolator: newInterpolator(),
+		state:        make([]channel, channels),
+		window:       max(1, int(options.Window.Seconds()*sampleRate)),
+		blockSize:    max(1, int(blockStep.Seconds()*sampleRate)),
+		frame:        make([]float64, channels),
+	}
+	if m.weights == nil {
+		m.weights = defaultWeights(channels)
+	}
+	m.shelf, m.hp = kWeighting(sampleRate)
+	m.out.peak = make([]atomic.Uint64, channels)
+	m.out.rms = make([]atomic.Uint64, channels)
+	m.out.truePeak = make([]atomic.Uint64, channels)
+	m.out.maxPeak = make([]atomic.Uint64, channels)
+	m.out.maxTrue = make([]atomic.Uint64, channels)
+	m.clear()
+	return m
+}
+
+func defaultWeights(channels int) []float64 {
+	weights := make([]float64, channels)
+	for i := range weights {
+		weights[i] = 1
+	}
+	switch channels {
+	case 5: // L, R, C, Ls, Rs
+		weights[3], weights[4] = 1.41, 1.41
+	case 6: // L, R, C, LFE, Ls, Rs
+		weights[3], weights[4], weights[5] = 0, 1.41, 1.41
+	}
+	return weights
+}
+
+// Reset restarts the maximum levels and the integrated loudness.
+// It may be called from any goroutine and takes effect with the next processed buffer.
+func (m *Meter) Reset() {
+	m.reset.Store(true)
+}
+
+func (m *Meter) clear() {
+	for i := range m.state {
+		m.state[i].maxPeak, m.state[i].maxTruePeak = 0, 0
+		m.state[i].energy = 0
+	}
+	m.binCount = [histogramBins]uint64{}
+	m.binEnergy = [histogramBins]float64{}
+	m.rangeCount = [histogramBins]uint64{}
+	m.rangeEnergy = [histogramBins]float64{}
+	m.blockCount, m.blockIndex, m.blockFrames = 0, 0, 0
+	m.blocks = [shortTermBlocks]float64{}
+	inf := math.Float64bits(math.Inf(-1))
+	m.out.seq.Add(1)
+	for i := range m.channels {
+		m.out.maxPeak[i].Store(0)
+		m.out.maxTrue[i].Store(0)
+	}
+	m.out.momentary.Store(inf)
+	m.out.shortTerm.Store(inf)
+	m.out.integrated.Store(inf)
+	m.out.lra.Store(0)
+	m.out.seq.Add(1)
+}
+
+// Process measures interleaved samples.
+func Process[T pa.Sample](m *Meter, buf []T) {
+	m.begin()
+	for f := 0; f+m.channels <= len(buf); f += m.channels {
+		for ch := range m.frame {
+			m.frame[ch] = pa.ToFloat64(buf[f+ch])
+		}
+		m.processFrame()
+	}
+}
+
+// ProcessPlanar measures one buffer per channel.
+func ProcessPlanar[T pa.Sample](m *Meter, bufs [][]T) {
+	m.begin()
+	if len(bufs) == 0 {
+		return
+	}
+	for f := range bufs[0] {
+		for ch := range m.frame {
+			m.frame[ch] = pa.ToFloat64(bufs[ch][f])
+		}
+		m.processFrame()
+	}
+}
+
+// Input returns a stream callback that measures the input of the stream and then calls callback.
+func Input[T pa.Sample](m *Meter, callback func(*pa.Stream[T]) pa.StreamCallbackResult) func(*pa.Stream[T]) pa.StreamCallbackResult {
+	return func(s *pa.Stream[T]) pa.StreamCallbackResult {
+		if inS := s.InS(); inS != nil {
+			ProcessPlanar(m, inS)
+		} else {
+			Process(m, s.In())
+		}
+		return callback(s)
+	}
+}
+
+// Output returns a stream callback that calls callback and then measures the output it produced.
+func Output[T pa.Sample](m *Meter, callback func(*pa.Stream[T]) pa.StreamCallbackResult) func(*pa.Stream[T]) pa.StreamCallbackResult {
+	return func(s *pa.Stream[T]) pa.StreamCallbackResult {
+		result := callback(s)
+		if outS := s.OutS(); outS != nil {
+			ProcessPlanar(m, outS)
+		} else {
+			Process(m, s.Out())
+		}
+		return result
+	}
+}
+
+func (m *Meter) begin() {
+	if m.reset.Swap(false) {
+		m.clear()
+	}
+}
+
+func (m *Meter) processFrame() {
+	for ch, x := range m.frame {
+		c := &m.state[ch]
+		a := math.Abs(x)
+		c.peak = max(c.peak, a)
+		c.squares += x * x
+		c.truePeak = max(c.truePeak, a, m.interpolator.peak(&c.history, x))
+		y := m.hp.process(&c.highPassState, m.shelf.process(&c.shelfState, x))
+		c.energy += y * y
+	}
+	if m.windowFrames++; m.windowFrames == m.window {
+		m.publishLevels()
+	}
+	if m.blockFrames++; m.blockFrames == m.blockSize {
+		m.finishBlock()
+	}
+}
+
+func (m *Meter) publishLevels() {
+	m.out.seq.Add(1)
+	for ch := range m.state {
+		c := &m.state[ch]
+		c.maxPeak = max(c.maxPeak, c.peak)
+		c.maxTruePeak = max(c.maxTruePeak, c.truePeak)
+		m.out.peak[ch].Store(math.Float64bits(c.peak))
+		m.out.rms[ch].Store(math.Float64bits(math.Sqrt(c.squares / float64(m.windowFrames))))
+		m.out.truePeak[ch].Store(math.Float64bits(c.truePeak))
+		m.out.maxPeak[ch].Store(math.Float64bits(c.maxPeak))
+		m.out.maxTrue[ch].Store(math.Float64bits(c.maxTruePeak))
+		c.peak, c.truePeak, c.squares = 0, 0, 0
+	}
+	m.out.seq.Add(1)
+	m.windowFrames = 0
+}
+
+// finishBlock completes a 100ms block and updates the loudness values.
+func (m *Meter) finishBlock() {
+	var energy float64
+	for ch := range m.state {
+		energy += m.weights[ch] * m.state[ch].energy / float64(m.blockSize)
+		m.state[ch].energy = 0
+	}
+	m.blockFrames = 0
+	m.blocks[m.blockIndex] = energy
+	m.blockIndex = (m.blockIndex + 1) % shortTermBlocks
+	m.blockCount++
+	momentary, shortTerm := math.Inf(-1), math.Inf(-1)
+	if m.blockCount >= momentaryBlocks {
+		e := m.meanEnergy(momentaryBlocks)
+		momentary = loudness(e)
+		addToHistogram(&m.binCount, &m.binEnergy, momentary, e)
+	}
+	if m.blockCount >= shortTermBlocks {
+		e := m.meanEnergy(shortTermBlocks)
+		shortTerm = loudness(e)
+		addToHistogram(&m.rangeCount, &m.rangeEnergy, shortTerm, e)
+	}
+	integrated := m.integrated()
+	lra := m.loudnessRange()
+	m.out.seq.Add(1)
+	m.out.momentary.Store(math.Float64bits(momentary))
+	m.out.shortTerm.Store(math.Float64bits(shortTerm))
+	m.out.integrated.Store(math.Float64bits(integrated))
+	m.out.lra.Store(math.Float64bits(lra))
+	m.out.seq.Add(1)
+}
+
+// meanEnergy returns the mean energy of the last n blocks.
+func (m *Meter) meanEnergy(n int) float64 {
+	var sum float64
+	for i := 1; i <= n; i++ {
+		sum += m.blocks[(m.blockIndex-i+shortTermBlocks)%shortTermBlocks]
+	}
+	return sum / float64(n)
+}
+
+// addToHistogram registers a block with loudness l above the absolute gate.
+func addToHistogram(count *[histogramBins]uint64, energy *[histogramBins]float64, l, e float64) {
+	if l <= absoluteGate {
+		return
+	}
+	bin := min(histogramBins-1, int((l-histogramMin)/histogramBinSize))
+	count[bin]++
+	energy[bin] += e
+}
+
+// relativeGateBin returns the first bin of a histogram above the gate relative
+// to the mean loudness of all its blocks, or -1 if the histogram is empty.
+func relativeGateBin(count *[histogramBins]uint64, energy *[histogramBins]float64, gate float64) int {
+	var n uint64
+	var e float64
+	for i := range count {
+		n += count[i]
+		e += energy[i]
+	}
+	if n == 0 {
+		return -1
+	}
+	return max(0, int(math.Ceil((loudness(e/float64(n))+gate-histogramMin)/histogramBinSize)))
+}
+
+// integrated applies the relative gate to the blocks above the absolute gate.
+func (m *Meter) integrated() float64 {
+	first := relativeGateBin(&m.binCount, &m.binEnergy, relativeGate)
+	if first < 0 {
+		return math.Inf(-1)
+	}
+	var count uint64
+	var energy float64
+	for i := first; i < histogramBins; i++ {
+		count += m.binCount[i]
+		energy += m.binEnergy[i]
+	}
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(energy / float64(count))
+}
+
+// loudnessRange returns the difference between the 95th and the 10th percentile
+// of the short-term loudness values that pass the absolute and the relative gate.
+func (m *Meter) loudnessRange() float64 {
+	first := relativeGateBin(&m.rangeCount, &m.rangeEnergy, rangeGate)
+	if first < 0 {
+		return 0
+	}
+	var count uint64
+	for i := first; i < histogramBins; i++ {
+		count += m.rangeCount[i]
+	}
+	if count == 0 {
+		return 0
+	}
+	return m.rangePercentile(first, count, rangeHigh) - m.rangePercentile(first, count, rangeLow)
+}
+
+// rangePercentile returns the loudness of the short-term histogram at the given percentile
+// of the count values starting at bin first.
+func (m *Meter) rangePercentile(first int, count uint64, p float64) float64 {
+	index := uint64(math.Round(float64(count-1) * p))
+	var seen uint64
+	for i := first; i < histogramBins; i++ {
+		if seen += m.rangeCount[i]; seen > index {
+			return histogramMin + (float64(i)+0.5)*histogramBinSize
+		}
+	}
+	return histogramMax
+}
+
+func loudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// Snapshot returns the current levels. It may be called from any goroutine.
+func (m *Meter) Snapshot() Snapshot {
+	s := Snapshot{
+		Peak:        make([]float64, m.channels),
+		RMS:         make([]float64, m.channels),
+		TruePeak:    make([]float64, m.channels),
+		MaxPeak:     make([]float64, m.channels),
+		MaxTruePeak: make([]float64, m.channels),
+	}
+	load := func(v *atomic.Uint64) float64 {
+		return math.Float64frombits(v.Load())
+	}
+	for {
+		seq := m.out.seq.Load()
+		if seq%2 != 0 {
+			continue
+		}
+		for ch := range m.channels {
+			s.Peak[ch] = load(&m.out.peak[ch])
+			s.RMS[ch] = load(&m.out.rms[ch])
+			s.TruePeak[ch] = load(&m.out.truePeak[ch])
+			s.MaxPeak[ch] = load(&m.out.maxPeak[ch])
+			s.MaxTruePeak[ch] = load(&m.out.maxTrue[ch])
+		}
+		s.Momentary = load(&m.out.momentary)
+		s.ShortTerm = load(&m.out.shortTerm)
+		s.Integrated = load(&m.out.integrated)
+		s.LoudnessRange = load(&m.out.lra)
+		if m.out.seq.Load() == seq {
+			return s
+		}
+	}
+}
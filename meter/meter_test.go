@@ -0,0 +1,170 @@
+package meter
+
+import (
+	"math"
+	"testing"
+)
+
+const rate = 48000
+
+// segment is a part of an EBU test signal: a 1kHz sine with the given level in dBFS on each channel.
+type segment struct {
+	levels  []float64
+	seconds float64
+}
+
+func stereo(dBFS, seconds float64) segment {
+	return segment{[]float64{dBFS, dBFS}, seconds}
+}
+
+// signal generates the interleaved samples of the segments with a continuous phase.
+func signal(segments ...segment) []float64 {
+	var buf []float64
+	n := 0
+	for _, s := range segments {
+		gains := make([]float64, len(s.levels))
+		for i, l := range s.levels {
+			gains[i] = math.Pow(10, l/20)
+		}
+		for range int(math.Round(s.seconds * rate)) {
+			x := math.Sin(2 * math.Pi * 1000 * float64(n) / rate)
+			for _, g := range gains {
+				buf = append(buf, g*x)
+			}
+			n++
+		}
+	}
+	return buf
+}
+
+// feed processes the signal in buffers of 100ms and calls check after each buffer with the elapsed time.
+func feed(m *Meter, buf []float64, check func(seconds float64)) {
+	size := rate / 10 * m.channels
+	for i := 0; i < len(buf); i += size {
+		Process(m, buf[i:min(len(buf), i+size)])
+		if check != nil {
+			check(float64(min(len(buf), i+size)/m.channels) / rate)
+		}
+	}
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// TestEBU3341 runs the minimum requirements test signals of EBU Tech 3341 for the loudness meter.
+func TestEBU3341(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		signal   []float64
+		channels int
+		// The expected loudness at the end of the signal in LUFS.
+		momentary, shortTerm, integrated float64
+	}{
+		{"case 1", signal(stereo(-23, 20)), 2, -23, -23, -23},
+		{"case 2", signal(stereo(-33, 20)), 2, -33, -33, -33},
+		{"case 3", signal(stereo(-36, 10), stereo(-23, 60), stereo(-36, 10)), 2, -36, -36, -23},
+		{"case 4", signal(stereo(-72, 10), stereo(-36, 10), stereo(-23, 60), stereo(-36, 10), stereo(-72, 10)), 2, -72, -72, -23},
+		{"case 5", signal(stereo(-26, 20), stereo(-20, 20.1), stereo(-26, 20)), 2, -26, -26, -23},
+		{"case 6", signal(segment{[]float64{-28, -28, -24, -30, -30}, 20}), 5, -23, -23, -23},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.channels, rate, Options{})
+			feed(m, tt.signal, nil)
+			s := m.Snapshot()
+			if !near(s.Momentary, tt.momentary, 0.1) || !near(s.ShortTerm, tt.shortTerm, 0.1) || !near(s.Integrated, tt.integrated, 0.1) {
+				t.Errorf("M %.2f S %.2f I %.2f, want M %v S %v I %v ±0.1", s.Momentary, s.ShortTerm, s.Integrated, tt.momentary, tt.shortTerm, tt.integrated)
+			}
+		})
+	}
+}
+
+// TestEBU3341ShortTerm runs case 9 of EBU Tech 3341: the short-term loudness of
+// alternating 1.34s at -20 dBFS and 1.66s at -30 dBFS is -23 LUFS at all times.
+func TestEBU3341ShortTerm(t *testing.T) {
+	var segments []segment
+	for range 20 {
+		segments = append(segments, stereo(-20, 1.34), stereo(-30, 1.66))
+	}
+	m := New(2, rate, Options{})
+	feed(m, signal(segments...), func(seconds float64) {
+		if s := m.Snapshot(); seconds >= 3 && !near(s.ShortTerm, -23, 0.1) {
+			t.Fatalf("short-term loudness %.2f at %.1fs, want -23 ±0.1", s.ShortTerm, seconds)
+		}
+	})
+}
+
+// TestEBU3342 runs the loudness range test signals 1 to 4 of EBU Tech 3342.
+func TestEBU3342(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		signal []float64
+		lra    float64
+	}{
+		{"case 1", signal(stereo(-20, 20), stereo(-30, 20)), 10},
+		{"case 2", signal(stereo(-20, 20), stereo(-15, 20)), 5},
+		{"case 3", signal(stereo(-40, 20), stereo(-20, 20)), 20},
+		{"case 4", signal(stereo(-50, 20), stereo(-35, 20), stereo(-20, 20), stereo(-35, 20), stereo(-50, 20)), 15},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(2, rate, Options{})
+			feed(m, tt.signal, nil)
+			if s := m.Snapshot(); !near(s.LoudnessRange, tt.lra, 1) {
+				t.Errorf("LRA %.2f, want %v ±1", s.LoudnessRange, tt.lra)
+			}
+		})
+	}
+}
+
+func TestLevels(t *testing.T) {
+	m := New(1, rate, Options{})
+	// A sine at a quarter of the sample rate sampled 45° off its peaks.
+	buf := make([]float64, rate)
+	for i := range buf {
+		buf[i] = 0.5 * math.Sin(math.Pi/2*float64(i)+math.Pi/4)
+	}
+	feed(m, buf, nil)
+	s := m.Snapshot()
+	if !near(s.Peak[0], 0.5*math.Sqrt2/2, 1e-9) || !near(s.RMS[0], 0.5*math.Sqrt2/2, 1e-9) {
+		t.Errorf("Peak %v RMS %v, want %v", s.Peak[0], s.RMS[0], 0.5*math.Sqrt2/2)
+	}
+	// EBU Tech 3341 allows true peak readings between -0.4 dB and +0.2 dB of the true value.
+	if db := 20 * math.Log10(s.TruePeak[0]/0.5); db < -0.4 || db > 0.2 {
+		t.Errorf("TruePeak %v is %.2f dB off", s.TruePeak[0], db)
+	}
+	if s.MaxPeak[0] != s.Peak[0] || s.MaxTruePeak[0] < s.TruePeak[0] {
+		t.Errorf("MaxPeak %v MaxTruePeak %v", s.MaxPeak[0], s.MaxTruePeak[0])
+	}
+}
+
+func TestReset(t *testing.T) {
+	m := New(2, rate, Options{})
+	feed(m, signal(stereo(-23, 20)), nil)
+	// Half a block of a full scale signal must not leak into the measurement after Reset.
+	Process(m, signal(stereo(0, 0.05)))
+	m.Reset()
+	Process(m, []float64{})
+	s := m.Snapshot()
+	if !math.IsInf(s.Integrated, -1) || !math.IsInf(s.Momentary, -1) || s.LoudnessRange != 0 || s.MaxPeak[0] != 0 {
+		t.Fatalf("snapshot after Reset = %+v", s)
+	}
+	feed(m, signal(stereo(-33, 20)), nil)
+	if s = m.Snapshot(); !near(s.Integrated, -33, 0.1) || !near(s.Momentary, -33, 0.1) {
+		t.Errorf("after Reset: M %.2f I %.2f, want -33", s.Momentary, s.Integrated)
+	}
+}
+
+func TestProcessPlanar(t *testing.T) {
+	interleaved, planar := New(2, rate, Options{}), New(2, rate, Options{})
+	buf := signal(segment{[]float64{-20, -30}, 5})
+	feed(interleaved, buf, nil)
+	bufs := [][]float32{make([]float32, len(buf)/2), make([]float32, len(buf)/2)}
+	for i, v := range buf {
+		bufs[i%2][i/2] = float32(v)
+	}
+	ProcessPlanar(planar, bufs)
+	a, b := interleaved.Snapshot(), planar.Snapshot()
+	if !near(a.Integrated, b.Integrated, 1e-3) || !near(a.Peak[1], b.Peak[1], 1e-6) {
+		t.Errorf("interleaved %+v, planar %+v", a, b)
+	}
+}
@@ -0,0 +1,287 @@
+package portaudio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyVoices is reported by a voice rejected because the mixer plays the maximum number of voices.
+var ErrTooManyVoices = errors.New("portaudio: too many voices")
+
+// ErrVoicePlayed is returned by Mixer.Play for a voice that has been played before.
+var ErrVoicePlayed = errors.New("portaudio: voice has already been played")
+
+// Voice is a source of audio played by a Mixer.
+type Voice[T Sample] struct {
+	source   Source[T]
+	channels int
+	gain     gainControl
+	pan      atomic.Uint64
+	fadeIn   time.Duration
+	stop     atomic.Int64 // fade out duration + 1, 0 if not stopping
+	played   atomic.Bool
+	done     chan struct{}
+	finished sync.Once
+	err      error
+
+	// State owned by the audio thread.
+	buf       []T
+	fade      float64
+	fadeStep  float64
+	stopping  bool
+	exhausted bool
+}
+
+// NewVoice creates a voice playing interleaved samples with the given channel count from source.
+func NewVoice[T Sample](source Source[T], channels int) *Voice[T] {
+	v := &Voice[T]{source: source, channels: channels, done: make(chan struct{})}
+	v.gain.init(1)
+	return v
+}
+
+// SetGain sets the linear gain of the voice. It may be called while the voice is playing,
+// in which case the gain ramps to the new value over DefaultRampTime.
+func (v *Voice[T]) SetGain(gain float64) {
+	v.gain.set(gain)
+}
+
+// SetPan sets the position of the voice between -1 (left) and 1 (right) on a stereo output.
+// Mono voices are panned with constant power, stereo voices are balanced.
+// It may be called while the voice is playing.
+func (v *Voice[T]) SetPan(pan float64) {
+	v.pan.Store(math.Float64bits(max(-1, min(1, pan))))
+}
+
+// SetFadeIn makes the voice fade in over the given duration. It must be called before the voice is played.
+func (v *Voice[T]) SetFadeIn(d time.Duration) {
+	v.fadeIn = d
+}
+
+// Stop fades the voice out over the given duration and removes it from the mixer.
+func (v *Voice[T]) Stop(fadeOut time.Duration) {
+	v.stop.Store(int64(fadeOut) + 1)
+}
+
+// Done returns a channel that is closed when the voice has finished playing.
+func (v *Voice[T]) Done() <-chan struct{} {
+	return v.done
+}
+
+// Err returns the error that ended the voice, if it did not end normally.
+// It must not be called before Done is closed.
+func (v *Voice[T]) Err() error {
+	return v.err
+}
+
+func (v *Voice[T]) finish(err error) {
+	v.finished.Do(func() {
+		v.err = err
+		close(v.done)
+	})
+}
+
+// MixerOptions configures a Mixer.
+type MixerOptions struct {
+	// MaxVoices limits the number of voices played at once. Zero selects 32.
+	MaxVoices int
+	// SoftClip saturates the mix smoothly instead of clipping it hard.
+	SoftClip bool
+}
+
+// Mixer plays any number of voices through one output stream.
+type Mixer[T Sample] struct {
+	stream   *Stream[T]
+	options  MixerOptions
+	channels int
+	rate     float64
+	add      chan *Voice[T]
+	voices   []*Voice[T]
+	mix      []float64
+	frames   int
+}
+
+// OpenMixer opens an output stream on params.Output that plays the mixed voices.
+// The output must use interleaved samples in the format of T, or Float32 if T is float64.
+func OpenMixer[T Sample](params *StreamParameters, options MixerOptions) (*Mixer[T], error) {
+	if !params.Output.Exists() {
+		return nil, streamError("open mixer", params.Output.Device, params, InvalidDevice)
+	}
+	if params.SampleFormat != sampleFormatOf[T]() {
+		return nil, streamError("open mixer", params.Output.Device, params, SampleFormatNotSupported)
+	}
+	if options.MaxVoices <= 0 {
+		options.MaxVoices = 32
+	}
+	outParams := *params
+	outParams.Input = StreamDeviceParameters{}
+	m := &Mixer[T]{
+		options:  options,
+		channels: params.Output.ChannelCount,
+		rate:     params.SampleRate,
+		add:      make(chan *Voice[T], options.MaxVoices),
+		voices:   make([]*Voice[T], 0, options.MaxVoices),
+		frames:   int(params.FramesPerBuffer),
+	}
+	if m.frames == FramesPerBufferUnspecified {
+		m.frames = 4096
+	}
+	m.mix = make([]float64, m.frames*m.channels)
+	var err error
+	if m.stream, err = OpenStream(&outParams, m.process, nil); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Stream returns the output stream.
+func (m *Mixer[T]) Stream() *Stream[T] {
+	return m.stream
+}
+
+// Start starts the output stream.
+func (m *Mixer[T]) Start() error {
+	return m.stream.Start()
+}
+
+// Stop stops the output stream.
+func (m *Mixer[T]) Stop() error {
+	return m.stream.Stop()
+}
+
+// Close closes the output stream.
+func (m *Mixer[T]) Close() error {
+	return m.stream.Close()
+}
+
+// Play starts playing the voice. Its completion is signaled by Voice.Done.
+// A voice can be played only once.
+func (m *Mixer[T]) Play(v *Voice[T]) error {
+	if v.played.Swap(true) {
+		return ErrVoicePlayed
+	}
+	v.buf = make([]T, m.frames*v.channels)
+	v.fade, v.fadeStep = 1, 0
+	if v.fadeIn > 0 {
+		v.fade, v.fadeStep = 0, 1/(v.fadeIn.Seconds()*m.rate)
+	}
+	select {
+	case m.add <- v:
+	default:
+		v.finish(ErrTooManyVoices)
+	}
+	return nil
+}
+
+func (m *Mixer[T]) process(s *Stream[T]) StreamCallbackResult {
+	m.accept()
+	out := s.Out()
+	frames := len(out) / m.channels
+	m.mix = resize(m.mix, len(out))
+	clear(m.mix)
+	for i := 0; i < len(m.voices); {
+		v := m.voices[i]
+		if !m.mixVoice(v, frames) {
+			i++
+			continue
+		}
+		last := len(m.voices) - 1
+		m.voices[i], m.voices[last] = m.voices[last], nil
+		m.voices = m.voices[:last]
+		v.finish(v.err)
+	}
+	for i, x := range m.mix {
+		if m.options.SoftClip {
+			x = softClip(x)
+		}
+		out[i] = FromFloat64[T](x)
+	}
+	return Continue
+}
+
+// accept takes the voices queued by Play.
+func (m *Mixer[T]) accept() {
+	for {
+		select {
+		case v := <-m.add:
+			if len(m.voices) == cap(m.voices) {
+				v.finish(ErrTooManyVoices)
+				continue
+			}
+			m.voices = append(m.voices, v)
+		default:
+			return
+		}
+	}
+}
+
+// mixVoice adds frames frames of the voice to the mix and reports whether the voice has finished.
+func (m *Mixer[T]) mixVoice(v *Voice[T], frames int) bool {
+	if stop := v.stop.Load(); stop != 0 && !v.stopping {
+		v.stopping = true
+		fadeOut := time.Duration(stop - 1)
+		if fadeOut <= 0 {
+			return true
+		}
+		v.fadeStep = -v.fade / (fadeOut.Seconds() * m.rate)
+	}
+	v.buf = resize(v.buf, frames*v.channels)
+	n := 0
+	for !v.exhausted && n < len(v.buf) {
+		r, err := v.source.ReadSamples(v.buf[n:])
+		n += r
+		if err != nil {
+			v.exhausted = true
+			if err != io.EOF {
+				v.err = err
+			}
+		}
+	}
+	n /= v.channels
+	v.gain.begin(m.rate)
+	left, right := 1.0, 1.0
+	if m.channels == 2 {
+		left, right = panGains(math.Float64frombits(v.pan.Load()), v.channels)
+	}
+	for f := 0; f < n; f++ {
+		g := v.gain.advance() * v.fade
+		v.fade = max(0, min(1, v.fade+v.fadeStep))
+		frame := v.buf[f*v.channels : (f+1)*v.channels]
+		mix := m.mix[f*m.channels : (f+1)*m.channels]
+		for ch := range mix {
+			x := g * ToFloat64(frame[ch%v.channels])
+			if m.channels == 2 {
+				if ch == 0 {
+					x *= left
+				} else {
+					x *= right
+				}
+			}
+			mix[ch] += x
+		}
+	}
+	return v.exhausted && n < frames || v.stopping && v.fade == 0
+}
+
+// panGains returns the gains of the left and right channel.
+// Mono sources are panned with constant power, so they are attenuated by 3 dB
+// in the centre and unchanged when panned hard. Other sources are balanced.
+func panGains(pan float64, channels int) (left, right float64) {
+	if channels == 1 {
+		angle := (pan + 1) * math.Pi / 4
+		return math.Cos(angle), math.Sin(angle)
+	}
+	return min(1, 1-pan), min(1, 1+pan)
+}
+
+// softClip passes signals below half of full scale unchanged and saturates louder signals smoothly towards full scale.
+func softClip(x float64) float64 {
+	const knee = 0.5
+	if a := math.Abs(x); a > knee {
+		return math.Copysign(knee+(1-knee)*math.Tanh((a-knee)/(1-knee)), x)
+	}
+	return x
+}
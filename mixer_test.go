@@ -0,0 +1,181 @@
+package portaudio
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPanGains(t *testing.T) {
+	for _, tt := range []struct {
+		pan         float64
+		channels    int
+		left, right float64
+	}{
+		{-1, 1, 1, 0},
+		{0, 1, math.Sqrt2 / 2, math.Sqrt2 / 2},
+		{1, 1, 0, 1},
+		{-1, 2, 1, 0},
+		{0, 2, 1, 1},
+		{0.5, 2, 0.5, 1},
+	} {
+		left, right := panGains(tt.pan, tt.channels)
+		if math.Abs(left-tt.left) > 1e-12 || math.Abs(right-tt.right) > 1e-12 {
+			t.Errorf("panGains(%v, %d) = %v, %v, want %v, %v", tt.pan, tt.channels, left, right, tt.left, tt.right)
+		}
+	}
+}
+
+func TestSoftClip(t *testing.T) {
+	for _, x := range []float64{0, 0.3, -0.5} {
+		if got := softClip(x); got != x {
+			t.Errorf("softClip(%v) = %v", x, got)
+		}
+	}
+	for _, x := range []float64{0.8, 2, -10} {
+		if got := softClip(x); math.Abs(got) > 1 || math.Abs(got) <= 0.5 || math.Signbit(got) != math.Signbit(x) {
+			t.Errorf("softClip(%v) = %v", x, got)
+		}
+	}
+}
+
+// openTestMixer opens a mixer on a stereo loopback and returns the last frame received on its input.
+func openTestMixer(t *testing.T, options MixerOptions) (*Mixer[float32], func() [2]float32) {
+	t.Helper()
+	params := loopbackParams(t, Float32, 2)
+	m, err := OpenMixer[float32](params, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	if err = m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	params.Output = StreamDeviceParameters{}
+	var last [2]atomic.Uint32
+	openTestStream(t, params, func(s *Stream[float32]) StreamCallbackResult {
+		in := s.In()
+		last[0].Store(math.Float32bits(in[len(in)-2]))
+		last[1].Store(math.Float32bits(in[len(in)-1]))
+		return Continue
+	})
+	return m, func() [2]float32 {
+		return [2]float32{math.Float32frombits(last[0].Load()), math.Float32frombits(last[1].Load())}
+	}
+}
+
+func constant(level float32, samples int) []float32 {
+	buf := make([]float32, samples)
+	for i := range buf {
+		buf[i] = level
+	}
+	return buf
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+	}
+}
+
+func TestMixer(t *testing.T) {
+	m, last := openTestMixer(t, MixerOptions{})
+	left := NewVoice[float32](NewBufferSource(constant(0.5, 48000)), 1)
+	left.SetPan(-1)
+	right := NewVoice[float32](NewBufferSource(constant(0.25, 2*48000)), 2)
+	right.SetPan(1)
+	for _, v := range []*Voice[float32]{left, right} {
+		if err := m.Play(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A hard panned mono voice keeps its level; a stereo voice is balanced.
+	waitFor(t, "the mix", func() bool { return last() == [2]float32{0.5, 0.25} })
+	if err := m.Play(left); !errors.Is(err, ErrVoicePlayed) {
+		t.Errorf("Play of a playing voice: %v, want ErrVoicePlayed", err)
+	}
+	left.Stop(0)
+	right.Stop(10 * time.Millisecond)
+	for _, v := range []*Voice[float32]{left, right} {
+		select {
+		case <-v.Done():
+			if v.Err() != nil {
+				t.Errorf("Err = %v", v.Err())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("stopped voice is not done")
+		}
+	}
+	waitFor(t, "silence", func() bool { return last() == [2]float32{} })
+}
+
+func TestMixerVoiceEnds(t *testing.T) {
+	m, last := openTestMixer(t, MixerOptions{})
+	v := NewVoice[float32](NewBufferSource(constant(0.5, 2*4800)), 2)
+	if err := m.Play(v); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-v.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("exhausted voice is not done")
+	}
+	waitFor(t, "silence", func() bool { return last() == [2]float32{} })
+}
+
+func TestMixerVoiceError(t *testing.T) {
+	m, _ := openTestMixer(t, MixerOptions{})
+	errRead := errors.New("read failed")
+	v := NewVoice(FuncSource[float32](func(buf []float32) (int, error) {
+		return copy(buf, constant(0.5, 100)), errRead
+	}), 1)
+	if err := m.Play(v); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-v.Done():
+		if v.Err() != errRead {
+			t.Errorf("Err = %v, want %v", v.Err(), errRead)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed voice is not done")
+	}
+}
+
+func TestMixerTooManyVoices(t *testing.T) {
+	m, _ := openTestMixer(t, MixerOptions{MaxVoices: 1})
+	voices := make([]*Voice[float32], 3)
+	for i := range voices {
+		voices[i] = NewVoice[float32](NewBufferSource(constant(0.1, 48000)), 1)
+		if err := m.Play(voices[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rejected := 0
+	for _, v := range voices[1:] {
+		select {
+		case <-v.Done():
+			if !errors.Is(v.Err(), ErrTooManyVoices) {
+				t.Errorf("Err = %v, want ErrTooManyVoices", v.Err())
+			}
+			rejected++
+		case <-time.After(time.Second):
+		}
+	}
+	if rejected != 2 {
+		t.Errorf("%d voices rejected, want 2", rejected)
+	}
+	voices[0].Stop(0)
+	<-voices[0].Done()
+}
+
+func TestOpenMixerSampleFormat(t *testing.T) {
+	if _, err := OpenMixer[int16](loopbackParams(t, Float32, 2), MixerOptions{}); !errors.Is(err, SampleFormatNotSupported) {
+		t.Errorf("int16 samples on a Float32 stream: %v, want SampleFormatNotSupported", err)
+	}
+}
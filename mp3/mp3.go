@@ -0,0 +1,247 @@
+// Package mp3 decodes MPEG audio layer I, II and III files to float32 samples with libmpg123.
+package mp3
+
+/*
+#cgo pkg-config: libmpg123
+#include <stdint.h>
+#include <stdlib.h>
+#include <mpg123.h>
+
+extern mpg123_ssize_t mp3Read(void *handle, void *buf, size_t size);
+extern off_t mp3Seek(void *handle, off_t offset, int whence);
+
+static int openHandle(mpg123_handle *mh, uintptr_t h) {
+	int err = mpg123_replace_reader_handle(mh, mp3Read, mp3Seek, NULL);
+	if (err != MPG123_OK) {
+		return err;
+	}
+	return mpg123_open_handle(mh, (void*)h);
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/cgo"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Error is an error code returned by libmpg123.
+type Error C.int
+
+func (err Error) Error() string {
+	return "mp3: " + C.GoString(C.mpg123_plain_strerror(C.int(err)))
+}
+
+// ErrNotSeekable is returned by Seek if the underlying reader is not an io.Seeker.
+var ErrNotSeekable = errors.New("mp3: reader is not seekable")
+
+var initOnce sync.Once
+
+// Decoder decodes an MPEG audio stream into interleaved float32 samples at its native rate.
+// It must not be used concurrently.
+type Decoder struct {
+	mh         *C.mpg123_handle
+	handle     cgo.Handle
+	src        *source
+	closer     io.Closer
+	sampleRate int
+	channels   int
+	length     int64
+}
+
+// source is the reader behind the callbacks of libmpg123.
+type source struct {
+	r   io.Reader
+	err error // the last error of r other than io.EOF
+}
+
+// NewDecoder creates a decoder reading from r. If r is an io.Seeker,
+// the stream is scanned for an exact length and Seek can be used.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	initOnce.Do(func() { C.mpg123_init() })
+	var code C.int
+	mh := C.mpg123_new(nil, &code)
+	if mh == nil {
+		return nil, Error(code)
+	}
+	d := &Decoder{mh: mh, src: &source{r: r}, length: -1}
+	d.handle = cgo.NewHandle(d.src)
+	if err := d.open(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Open opens and decodes a file. Close closes the file.
+func Open(name string) (*Decoder, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+func (d *Decoder) open() error {
+	if err := d.check(C.mpg123_param(d.mh, C.MPG123_ADD_FLAGS, C.MPG123_FORCE_FLOAT|C.MPG123_QUIET, 0)); err != nil {
+		return err
+	}
+	if err := d.check(C.openHandle(d.mh, C.uintptr_t(d.handle))); err != nil {
+		return err
+	}
+	var rate C.long
+	var channels, encoding C.int
+	if err := d.check(C.mpg123_getformat(d.mh, &rate, &channels, &encoding)); err != nil {
+		return err
+	}
+	// Fix the output format so that streams changing it midway are converted.
+	if err := d.check(C.mpg123_format_none(d.mh)); err != nil {
+		return err
+	}
+	if err := d.check(C.mpg123_format(d.mh, rate, channels, C.MPG123_ENC_FLOAT_32)); err != nil {
+		return err
+	}
+	d.sampleRate, d.channels = int(rate), int(channels)
+	if _, ok := d.src.r.(io.Seeker); ok && C.mpg123_scan(d.mh) == C.MPG123_OK {
+		if n := C.mpg123_length(d.mh); n >= 0 {
+			d.length = int64(n)
+		}
+	}
+	return nil
+}
+
+// check converts a libmpg123 result code, preferring the more detailed message of the handle.
+func (d *Decoder) check(code C.int) error {
+	if code == C.MPG123_OK {
+		return nil
+	}
+	if d.src.err != nil {
+		return d.src.err
+	}
+	if code == C.MPG123_ERR {
+		return fmt.Errorf("mp3: %s", C.GoString(C.mpg123_strerror(d.mh)))
+	}
+	return Error(code)
+}
+
+// SampleRate returns the native rate of the stream.
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// Channels returns the number of channels, 1 or 2.
+func (d *Decoder) Channels() int {
+	return d.channels
+}
+
+// Length returns the number of frames, or -1 if it is unknown.
+func (d *Decoder) Length() int64 {
+	return d.length
+}
+
+// Duration returns the length of the stream, or zero if it is unknown.
+func (d *Decoder) Duration() time.Duration {
+	if d.length < 0 {
+		return 0
+	}
+	return time.Duration(d.length) * time.Second / time.Duration(d.sampleRate)
+}
+
+// Position returns the index of the next frame.
+func (d *Decoder) Position() int64 {
+	return int64(C.mpg123_tell(d.mh))
+}
+
+// Read decodes interleaved samples into buf and returns their number,
+// a multiple of the channel count. It returns io.EOF at the end of the stream.
+func (d *Decoder) Read(buf []float32) (int, error) {
+	n := len(buf) - len(buf)%d.channels
+	if n == 0 {
+		return 0, nil
+	}
+	var done C.size_t
+	code := C.mpg123_read(d.mh, unsafe.Pointer(&buf[0]), C.size_t(4*n), &done)
+	samples := int(done) / 4
+	switch code {
+	case C.MPG123_OK, C.MPG123_NEW_FORMAT:
+		return samples, nil
+	case C.MPG123_DONE:
+		return samples, io.EOF
+	case C.MPG123_NEED_MORE:
+		// The reader ended in the middle of a frame.
+		return samples, io.EOF
+	}
+	return samples, d.check(code)
+}
+
+// ReadSamples is Read, making the decoder a portaudio.Source[float32].
+func (d *Decoder) ReadSamples(buf []float32) (int, error) {
+	return d.Read(buf)
+}
+
+// SeekFrame moves to the given frame.
+func (d *Decoder) SeekFrame(frame int64) error {
+	if _, ok := d.src.r.(io.Seeker); !ok {
+		return ErrNotSeekable
+	}
+	if n := C.mpg123_seek(d.mh, C.off_t(frame), C.int(io.SeekStart)); n < 0 {
+		return d.check(C.int(n))
+	}
+	return nil
+}
+
+// Seek moves to the given time.
+func (d *Decoder) Seek(t time.Duration) error {
+	return d.SeekFrame(int64(t * time.Duration(d.sampleRate) / time.Second))
+}
+
+// Close releases the decoder and closes the file opened by Open.
+func (d *Decoder) Close() error {
+	if d.mh == nil {
+		return nil
+	}
+	C.mpg123_close(d.mh)
+	C.mpg123_delete(d.mh)
+	d.mh = nil
+	d.handle.Delete()
+	if d.closer != nil {
+		return d.closer.Close()
+	}
+	return nil
+}
+
+//export mp3Read
+func mp3Read(handle, buf unsafe.Pointer, size C.size_t) C.mpg123_ssize_t {
+	s := cgo.Handle(uintptr(handle)).Value().(*source)
+	n, err := io.ReadFull(s.r, unsafe.Slice((*byte)(buf), int(size)))
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.err = err
+		return -1
+	}
+	return C.mpg123_ssize_t(n)
+}
+
+//export mp3Seek
+func mp3Seek(handle unsafe.Pointer, offset C.off_t, whence C.int) C.off_t {
+	s := cgo.Handle(uintptr(handle)).Value().(*source)
+	seeker, ok := s.r.(io.Seeker)
+	if !ok {
+		return -1
+	}
+	pos, err := seeker.Seek(int64(offset), int(whence))
+	if err != nil {
+		return -1
+	}
+	return C.off_t(pos)
+}
@@ -0,0 +1,124 @@
+package mp3
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// silence returns n silent MPEG-1 Layer III frames at 44100Hz and 128kbit/s
+// of 1152 samples per channel. The side information and main data are all zero.
+func silence(n, channels int) []byte {
+	header := []byte{0xff, 0xfb, 0x90, 0x00}
+	if channels == 1 {
+		header[3] = 0xc0
+	}
+	frame := make([]byte, 417)
+	copy(frame, header)
+	return bytes.Repeat(frame, n)
+}
+
+// stream hides the io.Seeker of a reader.
+type stream struct{ io.Reader }
+
+// readAll reads d in buffers of size samples and returns the number of frames
+// and the error ending the stream.
+func readAll(t *testing.T, d *Decoder, size int) (int64, error) {
+	t.Helper()
+	buf := make([]float32, size)
+	var samples int64
+	for {
+		n, err := d.Read(buf)
+		if n%d.Channels() != 0 {
+			t.Fatalf("Read returned %d samples of %d channels", n, d.Channels())
+		}
+		if slices.ContainsFunc(buf[:n], func(v float32) bool { return v != 0 }) {
+			t.Fatal("silence decodes to non-zero samples")
+		}
+		samples += int64(n)
+		if err != nil {
+			return samples / int64(d.Channels()), err
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, channels := range []int{1, 2} {
+		d, err := NewDecoder(bytes.NewReader(silence(10, channels)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		if d.SampleRate() != 44100 || d.Channels() != channels {
+			t.Errorf("format %dHz, %d channels, want 44100Hz, %d", d.SampleRate(), d.Channels(), channels)
+		}
+		if d.Length() != 11520 || d.Duration() != 11520*time.Second/44100 {
+			t.Errorf("Length = %d, Duration = %v, want 11520 frames", d.Length(), d.Duration())
+		}
+		if frames, err := readAll(t, d, 1001); frames != 11520 || err != io.EOF {
+			t.Errorf("read %d frames, %v, want 11520, %v", frames, err, io.EOF)
+		}
+		if d.Position() != 11520 {
+			t.Errorf("Position = %d at the end", d.Position())
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	d, err := NewDecoder(bytes.NewReader(silence(10, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err = d.SeekFrame(5000); err != nil {
+		t.Fatal(err)
+	}
+	if d.Position() != 5000 {
+		t.Errorf("Position = %d after SeekFrame(5000)", d.Position())
+	}
+	if frames, err := readAll(t, d, 2*512); frames != 11520-5000 || err != io.EOF {
+		t.Errorf("read %d frames, %v after SeekFrame(5000)", frames, err)
+	}
+	if err = d.Seek(100 * time.Millisecond); err != nil || d.Position() != 4410 {
+		t.Errorf("Seek(100ms) = %v, Position = %d, want 4410", err, d.Position())
+	}
+}
+
+func TestNotSeekable(t *testing.T) {
+	d, err := NewDecoder(stream{bytes.NewReader(silence(10, 2))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Length() != -1 || d.Duration() != 0 {
+		t.Errorf("Length = %d, Duration = %v of a stream", d.Length(), d.Duration())
+	}
+	if err = d.SeekFrame(100); !errors.Is(err, ErrNotSeekable) {
+		t.Errorf("SeekFrame = %v, want %v", err, ErrNotSeekable)
+	}
+	if frames, err := readAll(t, d, 4096); frames != 11520 || err != io.EOF {
+		t.Errorf("read %d frames, %v, want 11520, %v", frames, err, io.EOF)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	data := silence(10, 2)
+	d, err := NewDecoder(bytes.NewReader(data[:len(data)-200]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if frames, err := readAll(t, d, 4096); frames >= 11520 || frames < 9*1152 || err != io.EOF {
+		t.Errorf("read %d frames, %v, want the 9 complete MPEG frames and %v", frames, err, io.EOF)
+	}
+}
+
+func TestNotMP3(t *testing.T) {
+	if d, err := NewDecoder(bytes.NewReader(make([]byte, 1000))); err == nil {
+		d.Close()
+		t.Error("NewDecoder accepts zeros")
+	}
+}
@@ -0,0 +1,526 @@
+package opus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// ErrNoOpus is returned by NewReader for Ogg files that do not hold an Opus stream.
+var ErrNoOpus = errors.New("opus: not an Ogg Opus stream")
+
+// Ogg Opus always counts granule positions at 48 kHz.
+const granuleRate = 48000
+
+// WriterOptions configure an Ogg Opus writer.
+type WriterOptions struct {
+	SampleRate  int         // 8000, 12000, 16000, 24000 or 48000, 48000 if zero
+	Channels    int         // 1 or 2, 1 if zero
+	Application Application // VoIP if zero
+	Bitrate     int         // bits per second, zero to let the encoder choose
+	Complexity  int         // 1 to 10, zero for the encoder default
+	FEC         bool        // in-band forward error correction
+	PacketLoss  int         // expected packet loss in percent, tunes FEC
+	DTX         bool        // discontinuous transmission during silence
+	// FrameDuration is 2.5, 5, 10, 20, 40 or 60 ms, 20 ms if zero.
+	FrameDuration time.Duration
+	// Comments are written to the OpusTags header, e.g. "TITLE=Take 1".
+	Comments []string
+}
+
+// Writer encodes interleaved samples into an Ogg Opus stream.
+type Writer struct {
+	pw      *PacketWriter
+	ogg     oggWriter
+	options WriterOptions
+	preSkip int64  // encoder delay at 48 kHz
+	pending []byte // the last packet, written once the next one or Close decides its page flags
+	input   int64  // samples per channel written by the caller
+	encoded int64  // samples per channel encoded, including padding
+	closed  bool
+}
+
+// NewWriter writes the Ogg Opus headers to w and returns a writer encoding into it.
+func NewWriter(w io.Writer, options WriterOptions) (*Writer, error) {
+	if options.SampleRate == 0 {
+		options.SampleRate = granuleRate
+	}
+	if options.Channels == 0 {
+		options.Channels = 1
+	}
+	if options.Application == 0 {
+		options.Application = VoIP
+	}
+	if options.FrameDuration == 0 {
+		options.FrameDuration = 20 * time.Millisecond
+	}
+	if !ValidSampleRate(options.SampleRate) {
+		return nil, fmt.Errorf("opus: unsupported sample rate %d", options.SampleRate)
+	}
+	if options.Channels > 2 {
+		return nil, fmt.Errorf("opus: unsupported channel count %d", options.Channels)
+	}
+	enc, err := NewEncoder(options.SampleRate, options.Channels, options.Application)
+	if err != nil {
+		return nil, err
+	}
+	ow := &Writer{
+		ogg:     oggWriter{w: w, serial: rand.Uint32()},
+		options: options,
+	}
+	if ow.pw, err = NewPacketWriter(enc, options.FrameDuration, ow.writePacket); err != nil {
+		enc.Close()
+		return nil, err
+	}
+	if err = ow.configure(); err != nil {
+		enc.Close()
+		return nil, err
+	}
+	if err = ow.writeHeaders(); err != nil {
+		enc.Close()
+		return nil, err
+	}
+	return ow, nil
+}
+
+func (w *Writer) configure() error {
+	o, enc := w.options, w.pw.enc
+	if err := enc.SetBitrate(o.Bitrate); err != nil {
+		return err
+	}
+	if o.Complexity != 0 {
+		if err := enc.SetComplexity(o.Complexity); err != nil {
+			return err
+		}
+	}
+	if err := enc.SetInBandFEC(o.FEC); err != nil {
+		return err
+	}
+	if err := enc.SetPacketLoss(o.PacketLoss); err != nil {
+		return err
+	}
+	if err := enc.SetDTX(o.DTX); err != nil {
+		return err
+	}
+	lookahead, err := enc.Lookahead()
+	if err != nil {
+		return err
+	}
+	w.preSkip = int64(lookahead) * granuleRate / int64(o.SampleRate)
+	return nil
+}
+
+func (w *Writer) writeHeaders() error {
+	head := append([]byte("OpusHead"), 1, byte(w.options.Channels))
+	head = binary.LittleEndian.AppendUint16(head, uint16(w.preSkip))
+	head = binary.LittleEndian.AppendUint32(head, uint32(w.options.SampleRate))
+	head = append(head, 0, 0, 0) // output gain and channel mapping family
+	if err := w.ogg.writePacket(head, 0, pageBOS); err != nil {
+		return err
+	}
+	vendor := Version()
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(w.options.Comments)))
+	for _, c := range w.options.Comments {
+		tags = binary.LittleEndian.AppendUint32(tags, uint32(len(c)))
+		tags = append(tags, c...)
+	}
+	return w.ogg.writePacket(tags, 0, 0)
+}
+
+// Encoder returns the underlying encoder, e.g. to change the bitrate while writing.
+func (w *Writer) Encoder() *Encoder {
+	return w.pw.enc
+}
+
+// Channels returns the number of encoded channels.
+func (w *Writer) Channels() int {
+	return w.options.Channels
+}
+
+// Write encodes interleaved samples in [-1, 1], buffering them up to a whole frame.
+func (w *Writer) Write(pcm []float32) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.pw.Write(pcm); err != nil {
+		return err
+	}
+	w.input += int64(len(pcm) / w.options.Channels)
+	return nil
+}
+
+// writePacket receives the packets of the packet writer.
+func (w *Writer) writePacket(packet []byte) error {
+	if err := w.flush(false); err != nil {
+		return err
+	}
+	w.pending = append(w.pending[:0], packet...)
+	w.encoded += int64(w.pw.frameSize)
+	return nil
+}
+
+// flush writes the pending packet.
+func (w *Writer) flush(last bool) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	granule := w.encoded * granuleRate / int64(w.options.SampleRate)
+	var flags byte
+	if last {
+		// The final granule position trims the padding of the last frame.
+		granule = min(granule, w.preSkip+w.input*granuleRate/int64(w.options.SampleRate))
+		flags = pageEOS
+	}
+	packet := w.pending
+	w.pending = w.pending[:0]
+	return w.ogg.writePacket(packet, granule, flags)
+}
+
+// Close encodes the buffered samples and the encoder delay, writes the last page
+// and releases the encoder. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.pw.enc.Close()
+	channels := int64(w.options.Channels)
+	pad := w.input + w.preSkip*int64(w.options.SampleRate)/granuleRate - w.encoded - int64(w.pw.filled)/channels
+	if pad > 0 {
+		if err := w.pw.Write(make([]float32, pad*channels)); err != nil {
+			return err
+		}
+	}
+	if err := w.pw.Flush(); err != nil {
+		return err
+	}
+	return w.flush(true)
+}
+
+// PacketWriter buffers interleaved samples into frames and passes each encoded
+// packet to a function, e.g. to send it as an RTP payload.
+type PacketWriter struct {
+	enc       *Encoder
+	write     func(packet []byte) error
+	frameSize int // samples per channel of a packet at the encoder rate
+	frame     []float32
+	filled    int
+	packet    []byte
+}
+
+// NewPacketWriter creates a packet writer encoding frames of 2.5, 5, 10, 20, 40 or 60 ms with enc.
+// The packet passed to write is only valid during the call.
+func NewPacketWriter(enc *Encoder, frameDuration time.Duration, write func(packet []byte) error) (*PacketWriter, error) {
+	switch frameDuration {
+	case 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+		20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond:
+	default:
+		return nil, fmt.Errorf("opus: unsupported frame duration %v", frameDuration)
+	}
+	frameSize := int(time.Duration(enc.sampleRate) * frameDuration / time.Second)
+	return &PacketWriter{
+		enc:       enc,
+		write:     write,
+		frameSize: frameSize,
+		frame:     make([]float32, frameSize*enc.channels),
+		packet:    make([]byte, 4*MaxPacketSize),
+	}, nil
+}
+
+// FrameSize returns the number of samples per channel of a packet.
+func (w *PacketWriter) FrameSize() int {
+	return w.frameSize
+}
+
+// Channels returns the number of encoded channels.
+func (w *PacketWriter) Channels() int {
+	return w.enc.channels
+}
+
+// Write encodes interleaved samples in [-1, 1], buffering them up to a whole frame.
+func (w *PacketWriter) Write(pcm []float32) error {
+	if len(pcm)%w.enc.channels != 0 {
+		return errors.New("opus: samples are not a multiple of the channel count")
+	}
+	for len(pcm) > 0 {
+		n := copy(w.frame[w.filled:], pcm)
+		w.filled += n
+		pcm = pcm[n:]
+		if w.filled == len(w.frame) {
+			if err := w.encode(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush pads buffered samples with silence to a whole frame and encodes them.
+func (w *PacketWriter) Flush() error {
+	if w.filled == 0 {
+		return nil
+	}
+	return w.encode()
+}
+
+func (w *PacketWriter) encode() error {
+	clear(w.frame[w.filled:])
+	w.filled = 0
+	n, err := w.enc.EncodeFloat32(w.frame, w.packet)
+	if err != nil {
+		return err
+	}
+	return w.write(w.packet[:n])
+}
+
+// Reader decodes an Ogg Opus stream into interleaved samples, concealing lost pages.
+type Reader struct {
+	dec       *Decoder
+	ogg       oggReader
+	inputRate int
+	gain      float32
+	vendor    string
+	comments  []string
+	maxFrame  int       // samples per channel of the longest packet at the output rate
+	packet    oggPacket // the packet to decode once lost samples are concealed
+	pending   bool      // packet has not been decoded yet
+	conceal   int       // samples per channel still to conceal
+	fecSize   int       // samples per channel to recover from the FEC data of packet
+	skip      int       // samples per channel of pre-skip still to drop
+	decoded   int64     // samples per channel decoded at 48 kHz
+	pcm       []float32
+	pos, size int
+	eof       bool
+}
+
+// NewReader reads the Ogg Opus headers from r and returns a reader decoding at the given rate,
+// 48000 if zero. Only mono and stereo streams are supported.
+func NewReader(r io.Reader, sampleRate int) (*Reader, error) {
+	if sampleRate == 0 {
+		sampleRate = granuleRate
+	}
+	or := &Reader{ogg: oggReader{r: r}, gain: 1}
+	head, err := or.ogg.next()
+	if err != nil {
+		if errors.Is(err, errCorrupt) {
+			return nil, ErrNoOpus
+		}
+		return nil, err
+	}
+	if len(head.data) < 19 || string(head.data[:8]) != "OpusHead" || head.data[8]&0xf0 != 0 {
+		return nil, ErrNoOpus
+	}
+	channels := int(head.data[9])
+	preSkip := int(binary.LittleEndian.Uint16(head.data[10:]))
+	or.inputRate = int(binary.LittleEndian.Uint32(head.data[12:]))
+	if gain := int16(binary.LittleEndian.Uint16(head.data[16:])); gain != 0 {
+		or.gain = float32(math.Pow(10, float64(gain)/(20*256)))
+	}
+	if head.data[18] != 0 || channels > 2 {
+		return nil, fmt.Errorf("opus: unsupported channel mapping %d with %d channels", head.data[18], channels)
+	}
+	tags, err := or.ogg.next()
+	if err != nil {
+		return nil, err
+	}
+	if err = or.parseTags(tags.data); err != nil {
+		return nil, err
+	}
+	if or.dec, err = NewDecoder(sampleRate, channels); err != nil {
+		return nil, err
+	}
+	or.maxFrame = MaxFrameSize * sampleRate / granuleRate
+	or.skip = preSkip * sampleRate / granuleRate
+	or.pcm = make([]float32, or.maxFrame*channels)
+	return or, nil
+}
+
+func (r *Reader) parseTags(data []byte) error {
+	if len(data) < 16 || string(data[:8]) != "OpusTags" {
+		return ErrNoOpus
+	}
+	data = data[8:]
+	field := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(data)
+		if uint64(n) > uint64(len(data)-4) {
+			return "", false
+		}
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		return s, true
+	}
+	var ok bool
+	if r.vendor, ok = field(); !ok || len(data) < 4 {
+		return fmt.Errorf("%w: bad OpusTags header", errCorrupt)
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for range count {
+		c, ok := field()
+		if !ok {
+			return fmt.Errorf("%w: bad OpusTags header", errCorrupt)
+		}
+		r.comments = append(r.comments, c)
+	}
+	return nil
+}
+
+// Decoder returns the underlying decoder.
+func (r *Reader) Decoder() *Decoder {
+	return r.dec
+}
+
+// Channels returns the number of decoded channels.
+func (r *Reader) Channels() int {
+	return r.dec.channels
+}
+
+// SampleRate returns the rate of the decoded audio.
+func (r *Reader) SampleRate() int {
+	return r.dec.sampleRate
+}
+
+// InputSampleRate returns the rate of the audio before encoding, zero if it is unknown.
+func (r *Reader) InputSampleRate() int {
+	return r.inputRate
+}
+
+// Vendor returns the vendor string of the encoder.
+func (r *Reader) Vendor() string {
+	return r.vendor
+}
+
+// Comments returns the user comments, e.g. "TITLE=Take 1".
+func (r *Reader) Comments() []string {
+	return r.comments
+}
+
+// Comment returns the value of the first comment with the given name, ignoring case.
+func (r *Reader) Comment(name string) string {
+	for _, c := range r.comments {
+		if k, v, ok := strings.Cut(c, "="); ok && strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Close releases the decoder. It does not close the underlying reader.
+func (r *Reader) Close() error {
+	return r.dec.Close()
+}
+
+// Read decodes interleaved samples in [-1, 1] into buf and returns their number.
+// It returns io.EOF at the end of the stream.
+func (r *Reader) Read(buf []float32) (int, error) {
+	n := 0
+	for n < len(buf) {
+		if r.pos == r.size {
+			if err := r.decodeNext(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		k := copy(buf[n:], r.pcm[r.pos:r.size])
+		r.pos += k
+		n += k
+	}
+	return n, nil
+}
+
+// decodeNext decodes the next packet or conceals lost ones.
+func (r *Reader) decodeNext() error {
+	if r.eof {
+		return io.EOF
+	}
+	channels := r.dec.channels
+	if !r.pending {
+		p, err := r.ogg.next()
+		if err == io.EOF {
+			r.eof = true
+		}
+		if err != nil {
+			return err
+		}
+		r.packet, r.pending = p, true
+		if p.lost {
+			r.lost(p)
+		}
+	}
+	var n int
+	var err error
+	normal := false
+	switch {
+	case r.conceal > r.fecSize:
+		n, err = r.dec.DecodeFloat32(nil, r.pcm[:min(r.conceal-r.fecSize, r.maxFrame)*channels], false)
+		r.conceal -= n
+	case r.conceal > 0:
+		n, err = r.dec.DecodeFloat32(r.packet.data, r.pcm[:r.conceal*channels], true)
+		r.conceal = 0
+	default:
+		n, err = r.dec.DecodeFloat32(r.packet.data, r.pcm, false)
+		normal, r.pending = true, false
+	}
+	if err != nil {
+		return err
+	}
+	start, end := 0, n
+	decoded := r.decoded + int64(n)*granuleRate/int64(r.dec.sampleRate)
+	if normal && r.packet.eos {
+		if r.packet.granule >= 0 && decoded > r.packet.granule {
+			// The last page trims the padding of the final frame.
+			end -= int((decoded - r.packet.granule) * int64(r.dec.sampleRate) / granuleRate)
+		}
+		r.eof = true
+	}
+	r.decoded = decoded
+	if r.skip > 0 {
+		start = min(r.skip, n)
+		r.skip -= start
+	}
+	end = max(start, end)
+	if r.gain != 1 {
+		for i := start * channels; i < end*channels; i++ {
+			r.pcm[i] *= r.gain
+		}
+	}
+	r.pos, r.size = start*channels, end*channels
+	return nil
+}
+
+// lost prepares the concealment of the samples lost before p, derived from the granule
+// position of its page. The last lost packet is recovered from the FEC data of p if present.
+func (r *Reader) lost(p oggPacket) {
+	rate := r.dec.sampleRate
+	granule, samples := p.granule, 0
+	size, err := PacketSamples(p.data, granuleRate)
+	if err != nil {
+		return
+	}
+	samples += size
+	for _, q := range r.ogg.packets {
+		if n, err := PacketSamples(q.data, granuleRate); err == nil {
+			samples += n
+		}
+		granule = q.granule
+	}
+	missing := granule - int64(samples) - r.decoded
+	if granule < 0 || missing <= 0 {
+		return
+	}
+	// Concealment works in multiples of 2.5 ms.
+	quantum := int64(rate) / 400
+	r.conceal = int(missing * int64(rate) / granuleRate / quantum * quantum)
+	r.fecSize = min(r.conceal, size*rate/granuleRate)
+}
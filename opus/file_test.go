@@ -0,0 +1,170 @@
+package opus
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func sine(frames, channels, rate int) []float32 {
+	pcm := make([]float32, frames*channels)
+	for i := range pcm {
+		pcm[i] = float32(0.5 * math.Sin(2*math.Pi*440*float64(i/channels)/float64(rate)))
+	}
+	return pcm
+}
+
+func writeOpus(t *testing.T, pcm []float32, options WriterOptions) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in pieces that do not line up with the frames.
+	for len(pcm) > 0 {
+		n := min(len(pcm), 1000*w.Channels())
+		if err = w.Write(pcm[:n]); err != nil {
+			t.Fatal(err)
+		}
+		pcm = pcm[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readOpus(t *testing.T, r io.Reader, rate int) (*Reader, []float32) {
+	t.Helper()
+	or, err := NewReader(r, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { or.Close() })
+	var pcm []float32
+	buf := make([]float32, 777)
+	for {
+		n, err := or.Read(buf)
+		pcm = append(pcm, buf[:n]...)
+		if err == io.EOF {
+			return or, pcm
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options WriterOptions
+		frames  int
+		rate    int // of the reader
+	}{
+		{"48kHz mono", WriterOptions{}, 48123, 48000},
+		{"16kHz stereo", WriterOptions{SampleRate: 16000, Channels: 2}, 16001, 16000},
+		{"decoded at 48kHz", WriterOptions{SampleRate: 16000, Channels: 2, FrameDuration: 60 * time.Millisecond}, 16000, 48000},
+		{"2.5ms frames", WriterOptions{SampleRate: 8000, FrameDuration: 2500 * time.Microsecond}, 799, 8000},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			o := test.options
+			channels := max(o.Channels, 1)
+			rate := o.SampleRate
+			if rate == 0 {
+				rate = 48000
+			}
+			o.Comments = []string{"TITLE=Take 1"}
+			data := writeOpus(t, sine(test.frames, channels, rate), o)
+			r, pcm := readOpus(t, bytes.NewReader(data), test.rate)
+			if r.Channels() != channels || r.InputSampleRate() != rate {
+				t.Errorf("%d channels at %dHz, want %d at %dHz", r.Channels(), r.InputSampleRate(), channels, rate)
+			}
+			if r.Comment("title") != "Take 1" || r.Vendor() != Version() {
+				t.Errorf("comments %q, vendor %q", r.Comments(), r.Vendor())
+			}
+			// The pre-skip and the final granule position trim the stream to its input length.
+			if want := test.frames * test.rate / rate * channels; len(pcm) != want {
+				t.Errorf("decoded %d samples, want %d", len(pcm), want)
+			}
+		})
+	}
+}
+
+func TestReaderPacketLoss(t *testing.T) {
+	const frames = 48000
+	for _, fec := range []bool{false, true} {
+		data := writeOpus(t, sine(frames, 1, 48000), WriterOptions{FEC: fec, PacketLoss: 10})
+		pages := oggPages(t, data)
+		// Drop one page, then three consecutive ones, after the two header pages.
+		var lossy []byte
+		for i, page := range pages {
+			if i != 5 && (i < 20 || i > 22) {
+				lossy = append(lossy, page...)
+			}
+		}
+		_, pcm := readOpus(t, bytes.NewReader(lossy), 48000)
+		if len(pcm) != frames {
+			t.Errorf("FEC %v: decoded %d samples, want %d with the lost ones concealed", fec, len(pcm), frames)
+		}
+		for i, v := range pcm {
+			if math.IsNaN(float64(v)) || math.Abs(float64(v)) > 2 {
+				t.Fatalf("FEC %v: sample %d = %v", fec, i, v)
+			}
+		}
+	}
+}
+
+func TestReaderNotOpus(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("RIFF....WAVE")), 0); !errors.Is(err, ErrNoOpus) {
+		t.Errorf("got %v, want %v", err, ErrNoOpus)
+	}
+	var buf bytes.Buffer
+	w := oggWriter{w: &buf}
+	if err := w.writePacket([]byte("OpusHeadX"), 0, pageBOS); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(&buf, 0); !errors.Is(err, ErrNoOpus) {
+		t.Errorf("short header: got %v, want %v", err, ErrNoOpus)
+	}
+}
+
+func TestPacketWriter(t *testing.T) {
+	enc, err := NewEncoder(16000, 2, Audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	var sizes []int
+	w, err := NewPacketWriter(enc, 10*time.Millisecond, func(packet []byte) error {
+		n, err := PacketSamples(packet, 16000)
+		sizes = append(sizes, n)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.FrameSize() != 160 {
+		t.Errorf("frame size %d, want 160", w.FrameSize())
+	}
+	if err = w.Write(sine(500, 2, 16000)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{160, 160, 160, 160}; !slices.Equal(sizes, want) {
+		t.Errorf("packet sizes %v, want %v", sizes, want)
+	}
+	if err = w.Write(make([]float32, 3)); err == nil {
+		t.Error("wrote an odd number of stereo samples")
+	}
+	if _, err = NewPacketWriter(enc, 15*time.Millisecond, nil); err == nil {
+		t.Error("accepted a frame duration of 15ms")
+	}
+}
@@ -0,0 +1,212 @@
+package opus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Ogg page header flags.
+const (
+	pageContinued = 1
+	pageBOS       = 2
+	pageEOS       = 4
+)
+
+const pageHeaderSize = 27
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+func oggCRC(crc uint32, b []byte) uint32 {
+	for _, v := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^v]
+	}
+	return crc
+}
+
+// oggWriter writes a single logical Ogg stream, one page per packet.
+type oggWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+	buf    []byte
+}
+
+// writePacket writes a packet on pages of its own, the last of which has the given granule position.
+func (o *oggWriter) writePacket(packet []byte, granule int64, flags byte) error {
+	for first := true; ; first = false {
+		segments := min(len(packet)/255+1, 255)
+		size := min(len(packet), 255*segments)
+		last := size == len(packet) && (segments < 255 || size < 255*255)
+		pageGranule := int64(-1)
+		pageFlags := flags &^ pageEOS
+		if last {
+			pageGranule = granule
+			pageFlags = flags
+		}
+		if !first {
+			pageFlags = pageFlags&^pageBOS | pageContinued
+		}
+		b := append(o.buf[:0], "OggS\x00"...)
+		b = append(b, pageFlags)
+		b = binary.LittleEndian.AppendUint64(b, uint64(pageGranule))
+		b = binary.LittleEndian.AppendUint32(b, o.serial)
+		b = binary.LittleEndian.AppendUint32(b, o.seq)
+		b = append(b, 0, 0, 0, 0, byte(segments))
+		for i := range segments {
+			b = append(b, byte(min(255, size-255*i)))
+		}
+		b = append(b, packet[:size]...)
+		binary.LittleEndian.PutUint32(b[22:], oggCRC(0, b))
+		o.buf = b
+		if _, err := o.w.Write(b); err != nil {
+			return err
+		}
+		o.seq++
+		packet = packet[size:]
+		if last {
+			break
+		}
+	}
+	return nil
+}
+
+// oggPacket is a packet read from an Ogg stream.
+type oggPacket struct {
+	data    []byte
+	granule int64 // granule position of the page the packet ends on, -1 if the packet does not end a page
+	eos     bool  // the packet is the last of the stream
+	lost    bool  // pages were lost before the packet
+}
+
+// oggReader reads the packets of the first logical stream of an Ogg file.
+type oggReader struct {
+	r       io.Reader
+	serial  uint32
+	started bool
+	seq     uint32
+	eos     bool
+	lost    bool
+	header  [pageHeaderSize + 255]byte
+	page    []byte
+	partial []byte
+	packets []oggPacket
+}
+
+var errCorrupt = errors.New("opus: corrupt Ogg stream")
+
+// next returns the next complete packet.
+func (o *oggReader) next() (oggPacket, error) {
+	for len(o.packets) == 0 {
+		if o.eos {
+			return oggPacket{}, io.EOF
+		}
+		if err := o.readPage(); err != nil {
+			return oggPacket{}, err
+		}
+	}
+	p := o.packets[0]
+	o.packets = o.packets[1:]
+	return p, nil
+}
+
+func (o *oggReader) readPage() error {
+	h := o.header[:pageHeaderSize]
+	if _, err := io.ReadFull(o.r, h); err != nil {
+		if err == io.EOF && o.started {
+			// A truncated file ends the stream without an EOS page.
+			o.eos = true
+			return nil
+		}
+		if !o.started && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: no pages", errCorrupt)
+		}
+		return err
+	}
+	if string(h[:4]) != "OggS" || h[4] != 0 {
+		return fmt.Errorf("%w: bad page header", errCorrupt)
+	}
+	flags := h[5]
+	granule := int64(binary.LittleEndian.Uint64(h[6:]))
+	serial := binary.LittleEndian.Uint32(h[14:])
+	seq := binary.LittleEndian.Uint32(h[18:])
+	crc := binary.LittleEndian.Uint32(h[22:])
+	segments := int(h[26])
+	lacing := o.header[pageHeaderSize : pageHeaderSize+segments]
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return err
+	}
+	size := 0
+	for _, v := range lacing {
+		size += int(v)
+	}
+	if cap(o.page) < size {
+		o.page = make([]byte, size)
+	}
+	o.page = o.page[:size]
+	if _, err := io.ReadFull(o.r, o.page); err != nil {
+		return err
+	}
+	if !o.started {
+		if flags&pageBOS == 0 {
+			return fmt.Errorf("%w: missing BOS page", errCorrupt)
+		}
+		o.started, o.serial, o.seq = true, serial, seq
+	} else if serial != o.serial {
+		return nil
+	}
+	clear(o.header[22:26])
+	if oggCRC(oggCRC(0, o.header[:pageHeaderSize+segments]), o.page) != crc {
+		// Treat a damaged page like a lost one.
+		o.lost = true
+		o.partial = o.partial[:0]
+		return nil
+	}
+	if seq != o.seq {
+		o.lost = true
+		o.partial = o.partial[:0]
+	}
+	o.seq = seq + 1
+	continued := flags&pageContinued != 0 && len(o.partial) > 0
+	skip := flags&pageContinued != 0 && !continued
+	o.packets = o.packets[:0]
+	data := o.page
+	for _, v := range lacing {
+		if !skip {
+			o.partial = append(o.partial, data[:v]...)
+		}
+		data = data[v:]
+		if v < 255 {
+			if !skip {
+				o.packets = append(o.packets, oggPacket{data: o.partial, granule: -1, lost: o.lost})
+				o.lost = false
+				o.partial = nil
+			}
+			skip = false
+		}
+	}
+	if n := len(o.packets); n > 0 {
+		o.packets[n-1].granule = granule
+		if flags&pageEOS != 0 && len(o.partial) == 0 {
+			o.packets[n-1].eos = true
+		}
+	}
+	if flags&pageEOS != 0 {
+		o.eos = true
+	}
+	return nil
+}
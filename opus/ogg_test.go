@@ -0,0 +1,186 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// oggPages splits an Ogg stream into pages.
+func oggPages(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var pages [][]byte
+	for len(data) > 0 {
+		if len(data) < pageHeaderSize || string(data[:4]) != "OggS" {
+			t.Fatalf("bad page at %d bytes from the end", len(data))
+		}
+		segments := int(data[26])
+		size := pageHeaderSize + segments
+		for _, v := range data[pageHeaderSize:size] {
+			size += int(v)
+		}
+		pages = append(pages, data[:size])
+		data = data[size:]
+	}
+	return pages
+}
+
+func testPacket(size int) []byte {
+	p := make([]byte, size)
+	for i := range p {
+		p[i] = byte(i*7 + size)
+	}
+	return p
+}
+
+func writePackets(t *testing.T, sizes []int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := oggWriter{w: &buf, serial: 0x1234}
+	for i, size := range sizes {
+		var flags byte
+		switch i {
+		case 0:
+			flags = pageBOS
+		case len(sizes) - 1:
+			flags = pageEOS
+		}
+		if err := w.writePacket(testPacket(size), int64(1000*(i+1)), flags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func readPackets(t *testing.T, r io.Reader) []oggPacket {
+	t.Helper()
+	o := oggReader{r: r}
+	var packets []oggPacket
+	for {
+		p, err := o.next()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.data = bytes.Clone(p.data)
+		packets = append(packets, p)
+	}
+}
+
+func TestOggCRC(t *testing.T) {
+	// CRC-32/POSIX without the final inversion.
+	if crc := oggCRC(0, []byte("123456789")); crc != ^uint32(0x765e7680) {
+		t.Errorf("got %#x, want %#x", crc, ^uint32(0x765e7680))
+	}
+}
+
+func TestOggPages(t *testing.T) {
+	sizes := []int{19, 0, 1, 254, 255, 256, 510, 255 * 255, 100000, 3}
+	data := writePackets(t, sizes)
+	pages := oggPages(t, data)
+	// Packets of 255×255 bytes or more span two pages.
+	if len(pages) != len(sizes)+2 {
+		t.Fatalf("%d pages, want %d", len(pages), len(sizes)+2)
+	}
+	packet := 0
+	for i, page := range pages {
+		flags := page[5]
+		granule := int64(binary.LittleEndian.Uint64(page[6:]))
+		if serial := binary.LittleEndian.Uint32(page[14:]); serial != 0x1234 {
+			t.Errorf("page %d: serial %#x", i, serial)
+		}
+		if seq := binary.LittleEndian.Uint32(page[18:]); seq != uint32(i) {
+			t.Errorf("page %d: sequence number %d", i, seq)
+		}
+		if (flags&pageBOS != 0) != (i == 0) || (flags&pageEOS != 0) != (i == len(pages)-1) {
+			t.Errorf("page %d: flags %#x", i, flags)
+		}
+		continued := i > 0 && pages[i-1][pageHeaderSize+int(pages[i-1][26])-1] == 255
+		if (flags&pageContinued != 0) != continued {
+			t.Errorf("page %d: continued flag %v, want %v", i, flags&pageContinued != 0, continued)
+		}
+		last := page[pageHeaderSize+int(page[26])-1] < 255
+		if !last && granule != -1 {
+			t.Errorf("page %d without a packet end has granule position %d", i, granule)
+		}
+		if last {
+			packet++
+			if granule != int64(1000*packet) {
+				t.Errorf("page %d: granule position %d, want %d", i, granule, 1000*packet)
+			}
+		}
+	}
+
+	packets := readPackets(t, bytes.NewReader(data))
+	if len(packets) != len(sizes) {
+		t.Fatalf("read %d packets, want %d", len(packets), len(sizes))
+	}
+	for i, p := range packets {
+		if !bytes.Equal(p.data, testPacket(sizes[i])) {
+			t.Errorf("packet %d differs", i)
+		}
+		if p.granule != int64(1000*(i+1)) || p.lost || p.eos != (i == len(sizes)-1) {
+			t.Errorf("packet %d: granule %d, lost %v, eos %v", i, p.granule, p.lost, p.eos)
+		}
+	}
+}
+
+func TestOggPageLoss(t *testing.T) {
+	sizes := []int{19, 10, 20, 100000, 30, 40, 50}
+	pages := oggPages(t, writePackets(t, sizes))
+	join := func(pages ...[]byte) io.Reader {
+		return bytes.NewReader(bytes.Join(pages, nil))
+	}
+	// Dropping the page of packet 2 marks packet 3 as following a loss.
+	packets := readPackets(t, join(append(pages[:2:2], pages[3:]...)...))
+	if len(packets) != 6 || !packets[2].lost || !bytes.Equal(packets[2].data, testPacket(100000)) {
+		t.Fatalf("after losing a page: %d packets, packet 2 lost %v", len(packets), len(packets) > 2 && packets[2].lost)
+	}
+	// Dropping the first page of a packet spanning two pages drops the whole packet.
+	packets = readPackets(t, join(append(pages[:3:3], pages[4:]...)...))
+	if len(packets) != 6 || !packets[3].lost || !bytes.Equal(packets[3].data, testPacket(30)) {
+		t.Fatalf("after losing a continued packet: %d packets", len(packets))
+	}
+	// A damaged page counts as lost.
+	damaged := bytes.Clone(pages[5])
+	damaged[len(damaged)-1] ^= 1
+	packets = readPackets(t, join(pages[0], pages[1], pages[2], pages[3], pages[4], damaged, pages[6], pages[7]))
+	if len(packets) != 6 || !packets[4].lost || packets[5].lost || !bytes.Equal(packets[4].data, testPacket(40)) {
+		t.Fatalf("after damaging a page: %d packets", len(packets))
+	}
+	// Pages of other logical streams are skipped.
+	var other bytes.Buffer
+	ow := oggWriter{w: &other, serial: 0x5678}
+	if err := ow.writePacket(testPacket(5), 0, pageBOS|pageEOS); err != nil {
+		t.Fatal(err)
+	}
+	packets = readPackets(t, join(pages[0], other.Bytes(), pages[1], pages[2], pages[3], pages[4], pages[5], pages[6], pages[7]))
+	if len(packets) != len(sizes) {
+		t.Fatalf("with another stream: %d packets, want %d", len(packets), len(sizes))
+	}
+	// A truncated stream ends without an EOS page.
+	packets = readPackets(t, join(pages[:3]...))
+	if len(packets) != 3 || packets[2].eos {
+		t.Fatalf("truncated: %d packets", len(packets))
+	}
+}
+
+func TestOggErrors(t *testing.T) {
+	o := oggReader{r: bytes.NewReader(nil)}
+	if _, err := o.next(); !errors.Is(err, errCorrupt) {
+		t.Errorf("empty: got %v, want %v", err, errCorrupt)
+	}
+	o = oggReader{r: bytes.NewReader([]byte("RIFF" + string(make([]byte, 40))))}
+	if _, err := o.next(); !errors.Is(err, errCorrupt) {
+		t.Errorf("not Ogg: got %v, want %v", err, errCorrupt)
+	}
+	pages := oggPages(t, writePackets(t, []int{1, 2, 3}))
+	o = oggReader{r: bytes.NewReader(pages[1])}
+	if _, err := o.next(); !errors.Is(err, errCorrupt) {
+		t.Errorf("no BOS page: got %v, want %v", err, errCorrupt)
+	}
+}
@@ -0,0 +1,335 @@
+// Package opus encodes and decodes Opus with libopus and reads and writes
+// the Ogg Opus file format in pure Go.
+//
+// Encoder and Decoder work on raw Opus packets, e.g. for RTP payloads.
+// Writer and Reader wrap them in an Ogg container, and RecordStream and Source
+// connect them to portaudio streams. Decoders conceal lost packets, using the
+// in-band forward error correction of the following packet when it is present.
+package opus
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+// The ctl functions are variadic, which cgo cannot call directly.
+static int encoderSet(OpusEncoder *st, int request, opus_int32 value) {
+	return opus_encoder_ctl(st, request, value);
+}
+
+static int encoderGet(OpusEncoder *st, int request, opus_int32 *value) {
+	return opus_encoder_ctl(st, request, value);
+}
+
+static int decoderGet(OpusDecoder *st, int request, opus_int32 *value) {
+	return opus_decoder_ctl(st, request, value);
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+// Error is an error code returned by libopus.
+type Error C.int
+
+func (err Error) Error() string {
+	return "opus: " + C.GoString(C.opus_strerror(C.int(err)))
+}
+
+// libopus errors.
+const (
+	BadArg         Error = C.OPUS_BAD_ARG
+	BufferTooSmall Error = C.OPUS_BUFFER_TOO_SMALL
+	InternalError  Error = C.OPUS_INTERNAL_ERROR
+	InvalidPacket  Error = C.OPUS_INVALID_PACKET
+	Unimplemented  Error = C.OPUS_UNIMPLEMENTED
+	InvalidState   Error = C.OPUS_INVALID_STATE
+	AllocFail      Error = C.OPUS_ALLOC_FAIL
+)
+
+// ErrClosed is returned when an encoder or decoder is used after Close.
+var ErrClosed = errors.New("opus: codec is closed")
+
+// Application selects the encoder tuning.
+type Application int
+
+const (
+	VoIP               Application = C.OPUS_APPLICATION_VOIP
+	Audio              Application = C.OPUS_APPLICATION_AUDIO
+	RestrictedLowDelay Application = C.OPUS_APPLICATION_RESTRICTED_LOWDELAY
+)
+
+// BitrateMax requests the maximum bitrate from SetBitrate.
+const BitrateMax = C.OPUS_BITRATE_MAX
+
+// MaxPacketSize is the largest size of a single Opus packet.
+const MaxPacketSize = 1275
+
+// MaxFrameSize is the number of samples per channel of the longest packet, 120 ms at 48 kHz.
+const MaxFrameSize = 5760
+
+// Version returns the libopus version string.
+func Version() string {
+	return C.GoString(C.opus_get_version_string())
+}
+
+func errorFromCode(code C.int) error {
+	if code < 0 {
+		return Error(code)
+	}
+	return nil
+}
+
+// ValidSampleRate reports whether libopus can encode and decode at the given rate.
+func ValidSampleRate(rate int) bool {
+	switch rate {
+	case 8000, 12000, 16000, 24000, 48000:
+		return true
+	}
+	return false
+}
+
+// PacketSamples returns the number of samples per channel of a packet at the given rate.
+func PacketSamples(packet []byte, rate int) (int, error) {
+	if len(packet) == 0 {
+		return 0, InvalidPacket
+	}
+	n := C.opus_packet_get_nb_samples((*C.uchar)(&packet[0]), C.opus_int32(len(packet)), C.opus_int32(rate))
+	if err := errorFromCode(n); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// Encoder encodes raw Opus packets. It must not be used concurrently.
+type Encoder struct {
+	st         *C.OpusEncoder
+	sampleRate int
+	channels   int
+}
+
+// NewEncoder creates an encoder for 8, 12, 16, 24 or 48 kHz audio with one or two channels.
+// It must be released with Close.
+func NewEncoder(sampleRate, channels int, application Application) (*Encoder, error) {
+	var code C.int
+	st := C.opus_encoder_create(C.opus_int32(sampleRate), C.int(channels), C.int(application), &code)
+	if err := errorFromCode(code); err != nil {
+		return nil, err
+	}
+	return &Encoder{st: st, sampleRate: sampleRate, channels: channels}, nil
+}
+
+func (e *Encoder) set(request C.int, value int) error {
+	if e.st == nil {
+		return ErrClosed
+	}
+	return errorFromCode(C.encoderSet(e.st, request, C.opus_int32(value)))
+}
+
+func (e *Encoder) get(request C.int) (int, error) {
+	if e.st == nil {
+		return 0, ErrClosed
+	}
+	var v C.opus_int32
+	if err := errorFromCode(C.encoderGet(e.st, request, &v)); err != nil {
+		return 0, err
+	}
+	return int(v), nil
+}
+
+// SampleRate returns the rate of the encoded audio.
+func (e *Encoder) SampleRate() int {
+	return e.sampleRate
+}
+
+// Channels returns the number of encoded channels.
+func (e *Encoder) Channels() int {
+	return e.channels
+}
+
+// SetBitrate sets the target bitrate in bits per second, BitrateMax, or zero to let the encoder choose.
+func (e *Encoder) SetBitrate(bitrate int) error {
+	if bitrate == 0 {
+		bitrate = C.OPUS_AUTO
+	}
+	return e.set(C.OPUS_SET_BITRATE_REQUEST, bitrate)
+}
+
+// Bitrate returns the target bitrate in bits per second.
+func (e *Encoder) Bitrate() (int, error) {
+	return e.get(C.OPUS_GET_BITRATE_REQUEST)
+}
+
+// SetComplexity sets the computational complexity from 0 to 10.
+func (e *Encoder) SetComplexity(complexity int) error {
+	return e.set(C.OPUS_SET_COMPLEXITY_REQUEST, complexity)
+}
+
+// SetInBandFEC enables forward error correction, which lets a decoder recover a lost packet
+// from the following one. It only takes effect with a non-zero expected packet loss.
+func (e *Encoder) SetInBandFEC(enabled bool) error {
+	return e.set(C.OPUS_SET_INBAND_FEC_REQUEST, b2i(enabled))
+}
+
+// SetPacketLoss sets the expected packet loss in percent.
+func (e *Encoder) SetPacketLoss(percent int) error {
+	return e.set(C.OPUS_SET_PACKET_LOSS_PERC_REQUEST, percent)
+}
+
+// SetDTX enables discontinuous transmission, which reduces silent frames to
+// packets of one or two bytes that need not be transmitted.
+func (e *Encoder) SetDTX(enabled bool) error {
+	return e.set(C.OPUS_SET_DTX_REQUEST, b2i(enabled))
+}
+
+// InDTX reports whether the last packet was encoded in discontinuous transmission.
+func (e *Encoder) InDTX() (bool, error) {
+	v, err := e.get(C.OPUS_GET_IN_DTX_REQUEST)
+	return v != 0, err
+}
+
+// Lookahead returns the delay of the encoder in samples per channel at its rate.
+func (e *Encoder) Lookahead() (int, error) {
+	return e.get(C.OPUS_GET_LOOKAHEAD_REQUEST)
+}
+
+// Encode encodes one frame of interleaved samples into data and returns the packet size.
+// The frame must be 2.5, 5, 10, 20, 40 or 60 ms long.
+func (e *Encoder) Encode(pcm []int16, data []byte) (int, error) {
+	if e.st == nil {
+		return 0, ErrClosed
+	}
+	if len(pcm) == 0 || len(data) == 0 {
+		return 0, BadArg
+	}
+	n := C.opus_encode(e.st, (*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)/e.channels),
+		(*C.uchar)(&data[0]), C.opus_int32(len(data)))
+	if err := errorFromCode(C.int(n)); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// EncodeFloat32 is like Encode for samples in [-1, 1].
+func (e *Encoder) EncodeFloat32(pcm []float32, data []byte) (int, error) {
+	if e.st == nil {
+		return 0, ErrClosed
+	}
+	if len(pcm) == 0 || len(data) == 0 {
+		return 0, BadArg
+	}
+	n := C.opus_encode_float(e.st, (*C.float)(&pcm[0]), C.int(len(pcm)/e.channels),
+		(*C.uchar)(&data[0]), C.opus_int32(len(data)))
+	if err := errorFromCode(C.int(n)); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// Close releases the encoder.
+func (e *Encoder) Close() error {
+	if e.st != nil {
+		C.opus_encoder_destroy(e.st)
+		e.st = nil
+	}
+	return nil
+}
+
+// Decoder decodes raw Opus packets. It must not be used concurrently.
+type Decoder struct {
+	st         *C.OpusDecoder
+	sampleRate int
+	channels   int
+}
+
+// NewDecoder creates a decoder producing 8, 12, 16, 24 or 48 kHz audio with one or two channels.
+// It must be released with Close.
+func NewDecoder(sampleRate, channels int) (*Decoder, error) {
+	var code C.int
+	st := C.opus_decoder_create(C.opus_int32(sampleRate), C.int(channels), &code)
+	if err := errorFromCode(code); err != nil {
+		return nil, err
+	}
+	return &Decoder{st: st, sampleRate: sampleRate, channels: channels}, nil
+}
+
+// SampleRate returns the rate of the decoded audio.
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// Channels returns the number of decoded channels.
+func (d *Decoder) Channels() int {
+	return d.channels
+}
+
+// Decode decodes a packet into pcm, which must hold the whole packet, and returns the number
+// of samples per channel. A nil packet conceals a lost one and produces len(pcm) samples,
+// which must be a multiple of 2.5 ms. With fec set, the packet following a lost one
+// is used to recover the lost packet from its forward error correction data.
+func (d *Decoder) Decode(packet []byte, pcm []int16, fec bool) (int, error) {
+	if d.st == nil {
+		return 0, ErrClosed
+	}
+	if len(pcm) < d.channels {
+		return 0, BufferTooSmall
+	}
+	data, size := packetData(packet)
+	n := C.opus_decode(d.st, data, size, (*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)/d.channels), C.int(b2i(fec)))
+	if err := errorFromCode(n); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// DecodeFloat32 is like Decode producing samples in [-1, 1].
+func (d *Decoder) DecodeFloat32(packet []byte, pcm []float32, fec bool) (int, error) {
+	if d.st == nil {
+		return 0, ErrClosed
+	}
+	if len(pcm) < d.channels {
+		return 0, BufferTooSmall
+	}
+	data, size := packetData(packet)
+	n := C.opus_decode_float(d.st, data, size, (*C.float)(&pcm[0]), C.int(len(pcm)/d.channels), C.int(b2i(fec)))
+	if err := errorFromCode(n); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// LastPacketDuration returns the number of samples per channel of the last decoded or concealed packet.
+func (d *Decoder) LastPacketDuration() (int, error) {
+	if d.st == nil {
+		return 0, ErrClosed
+	}
+	var v C.opus_int32
+	if err := errorFromCode(C.decoderGet(d.st, C.OPUS_GET_LAST_PACKET_DURATION_REQUEST, &v)); err != nil {
+		return 0, err
+	}
+	return int(v), nil
+}
+
+// Close releases the decoder.
+func (d *Decoder) Close() error {
+	if d.st != nil {
+		C.opus_decoder_destroy(d.st)
+		d.st = nil
+	}
+	return nil
+}
+
+func packetData(packet []byte) (*C.uchar, C.opus_int32) {
+	if len(packet) == 0 {
+		return nil, 0
+	}
+	return (*C.uchar)(&packet[0]), C.opus_int32(len(packet))
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
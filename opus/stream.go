@@ -0,0 +1,88 @@
+package opus
+
+import (
+	"errors"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Source reads an Ogg Opus stream as a portaudio.Source of any sample type,
+// e.g. to play it from an output stream callback.
+type Source[T pa.Sample] struct {
+	r   *Reader
+	buf []float32
+}
+
+// NewSource creates a source decoding r.
+func NewSource[T pa.Sample](r *Reader) *Source[T] {
+	return &Source[T]{r: r}
+}
+
+// ReadSamples decodes interleaved samples into buf. It returns io.EOF at the end of the stream.
+func (s *Source[T]) ReadSamples(buf []T) (int, error) {
+	if len(s.buf) < len(buf) {
+		s.buf = make([]float32, len(buf))
+	}
+	n, err := s.r.Read(s.buf[:len(buf)])
+	for i, v := range s.buf[:n] {
+		buf[i] = pa.FromFloat64[T](float64(v))
+	}
+	return n, err
+}
+
+// SampleWriter is implemented by Writer and PacketWriter.
+type SampleWriter interface {
+	Write(pcm []float32) error
+	Channels() int
+}
+
+// StreamOptions returns writer options matching the input of a stream,
+// which must use one of the rates Opus supports.
+func StreamOptions(params *pa.StreamParameters) WriterOptions {
+	return WriterOptions{
+		SampleRate: int(params.SampleRate),
+		Channels:   params.Input.ChannelCount,
+	}
+}
+
+// WriteSamples encodes interleaved samples of any sample type.
+func WriteSamples[T pa.Sample](w SampleWriter, buf []T) error {
+	pcm := make([]float32, len(buf))
+	for i, v := range buf {
+		pcm[i] = float32(pa.ToFloat64(v))
+	}
+	return w.Write(pcm)
+}
+
+// RecordStream reads frames frames from a blocking input stream, typically a Stream[float32]
+// or Stream[int16], and encodes them. Transient errors such as input overflows are ignored,
+// so an overflow leaves a gap in the recording.
+func RecordStream[T pa.Sample](s *pa.Stream[T], w SampleWriter, frames int) error {
+	channels := w.Channels()
+	var pcm []float32
+	for frames > 0 {
+		buf, err := s.Read()
+		if err != nil {
+			if pa.IsTransient(err) {
+				continue
+			}
+			return err
+		}
+		if len(buf) == 0 {
+			return errors.New("opus: stream returned no samples")
+		}
+		n := min(len(buf), frames*channels)
+		if cap(pcm) < n {
+			pcm = make([]float32, n)
+		}
+		pcm = pcm[:n]
+		for i, v := range buf[:n] {
+			pcm[i] = float32(pa.ToFloat64(v))
+		}
+		if err = w.Write(pcm); err != nil {
+			return err
+		}
+		frames -= n / channels
+	}
+	return nil
+}
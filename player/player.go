@@ -0,0 +1,188 @@
+// Package player plays decoded files, e.g. from the mp3 and vorbis packages,
+// through an output stream, resampling them if the device runs at a different rate.
+package player
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+// Decoder produces interleaved float32 samples at the native rate of a file.
+// It is implemented by mp3.Decoder and vorbis.Decoder.
+type Decoder interface {
+	SampleRate() int
+	Channels() int
+	// Duration returns the length of the file, or zero if it is unknown.
+	Duration() time.Duration
+	// Read returns io.EOF at the end of the file.
+	Read(buf []float32) (int, error)
+	Seek(t time.Duration) error
+}
+
+// Player plays a decoder through an output Stream[float32].
+type Player struct {
+	d        Decoder
+	stream   *pa.Stream[float32]
+	source   *pa.ResampleSource[float32]
+	mu       sync.Mutex // guards the decoder against Seek while the callback reads it
+	channels int        // output channels
+	rate     float64    // output rate
+	buf      []float32
+	start    time.Duration // position of the first frame after the last seek
+	frames   atomic.Int64  // output frames played since the last seek
+	err      error
+	ended    atomic.Bool // the callback has completed the stream
+	doneOnce sync.Once
+	done     chan struct{}
+}
+
+// New opens the default output device for playing d.
+func New(d Decoder) (*Player, error) {
+	device := pa.DefaultOutputDevice()
+	if device == nil {
+		return nil, errors.New("player: no default output device")
+	}
+	return NewWithDevice(d, device)
+}
+
+// NewWithDevice opens an output stream on the device with HighLatencyParameters.
+// Mono files are played on all output channels, other files on as many channels as the device has.
+func NewWithDevice(d Decoder, device *pa.DeviceInfo) (*Player, error) {
+	params := pa.HighLatencyParameters(nil, device)
+	p := &Player{
+		d:        d,
+		channels: params.Output.ChannelCount,
+		rate:     params.SampleRate,
+		done:     make(chan struct{}),
+	}
+	p.source = pa.NewResampleSource[float32](pa.FuncSource[float32](p.read), p.channels, float64(d.SampleRate()), p.rate)
+	stream, err := pa.OpenStream(params, p.process, p.finished)
+	if err != nil {
+		return nil, err
+	}
+	p.stream = stream
+	return p, nil
+}
+
+// Stream returns the output stream.
+func (p *Player) Stream() *pa.Stream[float32] {
+	return p.stream
+}
+
+// Play starts or resumes playback.
+func (p *Player) Play() error {
+	return p.stream.Start()
+}
+
+// Pause stops playback after the buffered samples have been played. Play resumes it.
+func (p *Player) Pause() error {
+	return p.stream.Stop()
+}
+
+// Seek moves playback to the given time.
+func (p *Player) Seek(t time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.d.Seek(t); err != nil {
+		return err
+	}
+	p.source.Reset()
+	p.start = t
+	p.frames.Store(0)
+	return nil
+}
+
+// Position returns the playback position, ahead of the audible one by the output latency.
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.start + time.Duration(float64(p.frames.Load())/p.rate*float64(time.Second))
+}
+
+// Duration returns the length of the file, or zero if it is unknown.
+func (p *Player) Duration() time.Duration {
+	return p.d.Duration()
+}
+
+// Done returns a channel that is closed when playback has finished.
+// Pausing does not close it.
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the error that ended playback early. It must not be called before Done is closed.
+func (p *Player) Err() error {
+	return p.err
+}
+
+// Close closes the output stream. It does not close the decoder.
+func (p *Player) Close() error {
+	return p.stream.Close()
+}
+
+// process fills the output buffer. It outputs silence while Seek holds the decoder.
+func (p *Player) process(s *pa.Stream[float32]) pa.StreamCallbackResult {
+	out := s.Out()
+	if !p.mu.TryLock() {
+		clear(out)
+		return pa.Continue
+	}
+	defer p.mu.Unlock()
+	n, err := p.source.ReadSamples(out)
+	p.frames.Add(int64(n / p.channels))
+	if err != nil {
+		clear(out[n:])
+		if err != io.EOF {
+			p.err = err
+		}
+		p.ended.Store(true)
+		return pa.Complete
+	}
+	return pa.Continue
+}
+
+// finished signals Done once the stream has completed, but not when it was paused.
+func (p *Player) finished(*pa.Stream[float32]) {
+	if p.ended.Load() {
+		p.doneOnce.Do(func() { close(p.done) })
+	}
+}
+
+// read reads frames from the decoder and maps their channels to the output channels.
+func (p *Player) read(buf []float32) (int, error) {
+	dc := p.d.Channels()
+	if dc == p.channels {
+		return p.d.Read(buf)
+	}
+	frames := len(buf) / p.channels
+	if cap(p.buf) < frames*dc {
+		p.buf = make([]float32, frames*dc)
+	}
+	n, err := p.d.Read(p.buf[:frames*dc])
+	n /= dc
+	for i := range n {
+		for c := range p.channels {
+			buf[i*p.channels+c] = p.buf[i*dc+min(c, dc-1)]
+		}
+	}
+	return n * p.channels, err
+}
+
+// Play plays d to the end through the default output device.
+func Play(d Decoder) error {
+	p, err := New(d)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	if err = p.Play(); err != nil {
+		return err
+	}
+	<-p.done
+	return p.err
+}
@@ -0,0 +1,160 @@
+package player
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	pa "github.com/URALINNOVATSIYA/portaudio"
+)
+
+func TestMain(m *testing.M) {
+	if err := pa.Initialize(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = pa.Terminate()
+	os.Exit(code)
+}
+
+// testDecoder decodes frames frames of a constant value per channel.
+type testDecoder struct {
+	rate, channels int
+	frames         int
+	pos            int
+	err            error // returned instead of io.EOF
+}
+
+func (d *testDecoder) SampleRate() int { return d.rate }
+func (d *testDecoder) Channels() int   { return d.channels }
+
+func (d *testDecoder) Duration() time.Duration {
+	return time.Duration(d.frames) * time.Second / time.Duration(d.rate)
+}
+
+func (d *testDecoder) Read(buf []float32) (int, error) {
+	n := min(len(buf)/d.channels, d.frames-d.pos)
+	for i := range n * d.channels {
+		buf[i] = float32(i%d.channels+1) / 4
+	}
+	d.pos += n
+	if d.pos == d.frames {
+		if d.err != nil {
+			return n * d.channels, d.err
+		}
+		return n * d.channels, io.EOF
+	}
+	return n * d.channels, nil
+}
+
+func (d *testDecoder) Seek(t time.Duration) error {
+	d.pos = min(d.frames, int(t*time.Duration(d.rate)/time.Second))
+	return nil
+}
+
+func newTestPlayer(t *testing.T, d Decoder) *Player {
+	t.Helper()
+	l := pa.NewLoopback(pa.LoopbackOptions{Name: t.Name()})
+	t.Cleanup(l.Close)
+	p, err := NewWithDevice(d, l.Output())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func wait(t *testing.T, p *Player) {
+	t.Helper()
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("playback does not finish")
+	}
+}
+
+func TestPlay(t *testing.T) {
+	d := &testDecoder{rate: 24000, channels: 1, frames: 4800}
+	p := newTestPlayer(t, d)
+	if p.Duration() != 200*time.Millisecond {
+		t.Errorf("Duration = %v", p.Duration())
+	}
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, p)
+	if err := p.Err(); err != nil {
+		t.Errorf("Err = %v", err)
+	}
+	// The 4800 frames at 24000Hz are resampled to 9599 frames at 48000Hz.
+	if pos := p.Position().Round(time.Millisecond); pos != 200*time.Millisecond {
+		t.Errorf("Position = %v at the end", p.Position())
+	}
+}
+
+func TestPlayError(t *testing.T) {
+	errDecode := errors.New("decoding failed")
+	p := newTestPlayer(t, &testDecoder{rate: 48000, channels: 2, frames: 1000, err: errDecode})
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, p)
+	if err := p.Err(); err != errDecode {
+		t.Errorf("Err = %v, want %v", err, errDecode)
+	}
+}
+
+func TestPauseSeek(t *testing.T) {
+	d := &testDecoder{rate: 48000, channels: 2, frames: 48000 * 60}
+	p := newTestPlayer(t, d)
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-p.Done():
+		t.Fatal("Done closed by Pause")
+	default:
+	}
+	if err := p.Seek(59990 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if pos := p.Position(); pos != 59990*time.Millisecond {
+		t.Errorf("Position = %v after Seek", pos)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, p)
+	if pos := p.Position().Round(time.Millisecond); pos != time.Minute {
+		t.Errorf("Position = %v at the end", p.Position())
+	}
+}
+
+func TestChannelMapping(t *testing.T) {
+	for _, test := range []struct {
+		channels, outputs int
+		want              []float32
+	}{
+		{1, 2, []float32{0.25, 0.25}},
+		{2, 2, []float32{0.25, 0.5}},
+		{3, 2, []float32{0.25, 0.5}},
+		{2, 4, []float32{0.25, 0.5, 0.5, 0.5}},
+	} {
+		p := &Player{d: &testDecoder{rate: 48000, channels: test.channels, frames: 10}, channels: test.outputs}
+		buf := make([]float32, 4*test.outputs)
+		n, err := p.read(buf)
+		if n != len(buf) || err != nil {
+			t.Fatalf("read = %d, %v", n, err)
+		}
+		for i, v := range buf {
+			if want := test.want[i%test.outputs]; v != want {
+				t.Errorf("%d to %d channels: sample %d = %v, want %v", test.channels, test.outputs, i, v, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package portaudio
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variables that let operators redirect the default devices without code changes.
+const (
+	// HostApiEnv holds a comma separated list of host APIs, most preferred first.
+	// It overrides the preference set by PreferHostApis.
+	HostApiEnv = "HOSTAPI"
+	// DeviceEnv holds the index or the name of the device used as the default input and output device.
+	DeviceEnv = "DEVICE"
+)
+
+var (
+	preferenceMu       sync.RWMutex
+	hostApiPreferences []HostApiType
+)
+
+// PreferHostApis sets the host APIs DefaultInputDevice, DefaultOutputDevice and
+// the parameter builders take the default devices from, most preferred first.
+// Host APIs that are not available or have no default device are skipped.
+// If none of them qualifies, the defaults of PortAudio's default host API are used.
+// Calling PreferHostApis without arguments restores PortAudio's defaults.
+func PreferHostApis(types ...HostApiType) {
+	preferenceMu.Lock()
+	defer preferenceMu.Unlock()
+	hostApiPreferences = append([]HostApiType(nil), types...)
+}
+
+// PreferredHostApis returns the host API preference currently in effect,
+// i.e. the content of HostApiEnv if it is set or the list passed to PreferHostApis.
+func PreferredHostApis() []HostApiType {
+	if env := os.Getenv(HostApiEnv); env != "" {
+		var types []HostApiType
+		for _, name := range strings.Split(env, ",") {
+			if t, ok := lookupHostApiType(strings.TrimSpace(name)); ok {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	preferenceMu.RLock()
+	defer preferenceMu.RUnlock()
+	return append([]HostApiType(nil), hostApiPreferences...)
+}
+
+// PreferredHostApi returns the most preferred available host API
+// or the default host API if none of the preferred ones is available.
+func PreferredHostApi() *HostApiInfo {
+	for _, t := range PreferredHostApis() {
+		if index := C.Pa_HostApiTypeIdToHostApiIndex(C.PaHostApiTypeId(t)); index >= 0 {
+			return HostApi(int(index))
+		}
+	}
+	return DefaultHostApi()
+}
+
+func defaultDeviceIndex(input bool) int {
+	if env := os.Getenv(DeviceEnv); env != "" {
+		if index, ok := lookupDevice(env, input); ok {
+			return index
+		}
+	}
+	for _, t := range PreferredHostApis() {
+		index := C.Pa_HostApiTypeIdToHostApiIndex(C.PaHostApiTypeId(t))
+		if index < 0 {
+			continue
+		}
+		info := C.Pa_GetHostApiInfo(index)
+		if info == nil {
+			continue
+		}
+		device := info.defaultOutputDevice
+		if input {
+			device = info.defaultInputDevice
+		}
+		if device != C.paNoDevice {
+			return int(device)
+		}
+	}
+	if input {
+		return int(C.Pa_GetDefaultInputDevice())
+	}
+	return int(C.Pa_GetDefaultOutputDevice())
+}
+
+// lookupDevice finds a device by index or name that supports the given direction.
+// Exact names win over case-insensitive substrings, and devices of
+// more preferred host APIs win over the others.
+func lookupDevice(spec string, input bool) (int, bool) {
+	hasChannels := func(info *DeviceInfo) bool {
+		if input {
+			return info.MaxInputChannels > 0
+		}
+		return info.MaxOutputChannels > 0
+	}
+	if index, err := strconv.Atoi(spec); err == nil {
+		if info := Device(index); info != nil && hasChannels(info) {
+			return info.Index, true
+		}
+		return index, false
+	}
+	preferences := PreferredHostApis()
+	rank := func(info *DeviceInfo) int {
+		r := 2 * len(preferences)
+		if info.HostApi != nil {
+			for i, t := range preferences {
+				if info.HostApi.Type == t {
+					r = 2 * i
+					break
+				}
+			}
+		}
+		if info.Name != spec {
+			r++
+		}
+		return r
+	}
+	found, best := -1, 0
+	lower := strings.ToLower(spec)
+	for i, n := 0, DeviceCount(); i < n; i++ {
+		info := Device(i)
+		if info == nil || !hasChannels(info) || !strings.Contains(strings.ToLower(info.Name), lower) {
+			continue
+		}
+		if r := rank(info); found < 0 || r < best {
+			found, best = info.Index, r
+		}
+	}
+	return found, found >= 0
+}
+
+// lookupHostApiType finds a host API type by its type name (e.g. "ALSA")
+// or by the name of an available host API (e.g. "Windows WASAPI"), ignoring case.
+func lookupHostApiType(name string) (HostApiType, bool) {
+	if t, err := ParseHostApiType(name); err == nil {
+		return t, true
+	}
+	for i, n := 0, HostApiCount(); i < n; i++ {
+		if info := C.Pa_GetHostApiInfo(C.PaHostApiIndex(i)); info != nil && strings.EqualFold(C.GoString(info.name), name) {
+			return HostApiType(info._type), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,43 @@
+package portaudio
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestPreferredHostApis(t *testing.T) {
+	PreferHostApis(JACK, ALSA)
+	defer PreferHostApis()
+	if got, want := PreferredHostApis(), []HostApiType{JACK, ALSA}; !slices.Equal(got, want) {
+		t.Errorf("PreferredHostApis = %v, want %v", got, want)
+	}
+	t.Setenv(HostApiEnv, "alsa, bogus ,OSS")
+	if got, want := PreferredHostApis(), []HostApiType{ALSA, OSS}; !slices.Equal(got, want) {
+		t.Errorf("PreferredHostApis with %s = %v, want %v", HostApiEnv, got, want)
+	}
+}
+
+func TestDeviceEnv(t *testing.T) {
+	if HostApiEnv != "HOSTAPI" || DeviceEnv != "DEVICE" {
+		t.Fatalf("environment variables are %s and %s", HostApiEnv, DeviceEnv)
+	}
+	l := NewLoopback(LoopbackOptions{Name: "PolicyTest"})
+	defer l.Close()
+	other := NewLoopback(LoopbackOptions{Name: "PolicyTest Output Monitor"})
+	defer other.Close()
+
+	t.Setenv(DeviceEnv, "policytest")
+	if got := DefaultInputDevice(); got == nil || got.Name != "PolicyTest Input" {
+		t.Errorf("DefaultInputDevice = %v, want PolicyTest Input", got)
+	}
+	// An exact name wins over a longer name containing it.
+	t.Setenv(DeviceEnv, "PolicyTest Output")
+	if got := DefaultOutputDevice(); got == nil || got.Name != "PolicyTest Output" {
+		t.Errorf("DefaultOutputDevice = %v, want PolicyTest Output", got)
+	}
+	t.Setenv(DeviceEnv, strconv.Itoa(other.Output().Index))
+	if got := DefaultOutputDeviceIndex(); got != other.Output().Index {
+		t.Errorf("DefaultOutputDeviceIndex = %d, want %d", got, other.Output().Index)
+	}
+}
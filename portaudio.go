@@ -6,7 +6,14 @@ package portaudio
 */
 import "C"
 import (
+	"errors"
+	"log/slog"
+	"math"
+	"sync/atomic"
 	"time"
+	"unsafe"
+
+	"github.com/URALINNOVATSIYA/portaudio/internal/hostapi"
 )
 
 // See https://portaudio.com/docs/v19-doxydocs-dev/ for more info about PortAudio
@@ -52,12 +59,21 @@ const (
 
 var initialized = 0
 
+// openStreams counts the open PortAudio streams, which RescanDevices would invalidate.
+var openStreams atomic.Int64
+
+// ErrStreamsOpen is returned by RescanDevices while PortAudio streams are open.
+var ErrStreamsOpen = errors.New("portaudio: cannot rescan devices while streams are open")
+
 // Initialize initializes internal data structures and prepares underlying host APIs for use.
 func Initialize() error {
 	if initialized <= 0 {
 		if err := C.Pa_Initialize(); err != C.paNoError {
-			return goError(err)
+			e := goError(err)
+			logError(Logger(), "portaudio initialization failed", e)
+			return e
 		}
+		Logger().Info("portaudio initialized", slog.String("version", VersionText()))
 	}
 	initialized++
 	return nil
@@ -69,15 +85,68 @@ func Terminate() error {
 		initialized--
 		if initialized == 0 {
 			if err := C.Pa_Terminate(); err != C.paNoError {
-				return goError(err)
+				e := goError(err)
+				logError(Logger(), "portaudio termination failed", e)
+				return e
 			}
+			Logger().Info("portaudio terminated")
 		}
 	}
 	return nil
 }
 
+// RescanDevices re-initializes PortAudio so that devices plugged in or removed
+// since initialization are reflected by Device, DeviceCount and HostApi.
+// PortAudio is initialized once per process, so re-initializing it would invalidate
+// every open PortAudio stream: RescanDevices returns ErrStreamsOpen until all
+// of them are closed. Streams on virtual devices are not affected. The indices
+// of PortAudio devices may change. It does nothing if PortAudio is not initialized.
+func RescanDevices() error {
+	if initialized <= 0 {
+		return nil
+	}
+	if openStreams.Load() > 0 {
+		return ErrStreamsOpen
+	}
+	if err := C.Pa_Terminate(); err != C.paNoError {
+		e := goError(err)
+		logError(Logger(), "portaudio termination failed", e)
+		return e
+	}
+	if err := C.Pa_Initialize(); err != C.paNoError {
+		initialized = 0
+		e := goError(err)
+		logError(Logger(), "portaudio initialization failed", e)
+		return e
+	}
+	Logger().Info("portaudio devices rescanned", slog.Int("devices", DeviceCount()))
+	return nil
+}
+
+// duration converts seconds to a Duration, rounding to the nearest nanosecond
+// so that durations converted to PaTime convert back unchanged.
 func duration(paTime C.PaTime) time.Duration {
-	return time.Duration(paTime * C.PaTime(time.Second))
+	return time.Duration(math.Round(float64(paTime) * float64(time.Second)))
+}
+
+func init() {
+	hostapi.Error = func(code int) error {
+		return goError(C.PaError(code))
+	}
+	hostapi.Stream = func(stream any) unsafe.Pointer {
+		if s, ok := stream.(interface{ paStreamPtr() unsafe.Pointer }); ok {
+			return s.paStreamPtr()
+		}
+		return nil
+	}
+	hostapi.Params = func(stream any) any {
+		if s, ok := stream.(interface{ paParams() *StreamParameters }); ok {
+			if params := s.paParams(); params != nil {
+				return params
+			}
+		}
+		return nil
+	}
 }
 
 func goError(err C.PaError) error {
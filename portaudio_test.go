@@ -0,0 +1,15 @@
+package portaudio
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if err := Initialize(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = Terminate()
+	os.Exit(code)
+}
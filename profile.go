@@ -0,0 +1,193 @@
+package portaudio
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+// Latency values of StreamDeviceProfile besides durations.
+const (
+	LowLatency  = "low"
+	HighLatency = "high"
+)
+
+// StreamDeviceProfile references a device by its name and host API
+// instead of its index, so that it can be stored and restored.
+type StreamDeviceProfile struct {
+	// Device is the device name. An empty name selects the default device.
+	Device string `json:"device,omitempty"`
+	// HostApi restricts the device lookup to a host API.
+	HostApi *HostApiType `json:"hostApi,omitempty"`
+	// Channels is the channel count. Zero selects mono input or stereo output (if supported).
+	Channels int `json:"channels,omitempty"`
+	// Latency is LowLatency, HighLatency or a duration such as "20ms". Empty means HighLatency.
+	Latency string `json:"latency,omitempty"`
+}
+
+// StreamProfile is a serializable description of StreamParameters.
+// It round-trips through encoding/json and any encoder
+// that respects encoding.TextMarshaler.
+type StreamProfile struct {
+	Input  *StreamDeviceProfile `json:"input,omitempty"`
+	Output *StreamDeviceProfile `json:"output,omitempty"`
+	// SampleRate of zero selects the default sample rate of the devices.
+	SampleRate float64 `json:"sampleRate,omitempty"`
+	// SampleFormat of zero selects Float32.
+	SampleFormat    SampleFormat `json:"sampleFormat,omitempty"`
+	FramesPerBuffer uint64       `json:"framesPerBuffer,omitempty"`
+	Flags           StreamFlags  `json:"flags,omitempty"`
+}
+
+// NewStreamProfile creates a profile describing the given parameters.
+func NewStreamProfile(params *StreamParameters) *StreamProfile {
+	return &StreamProfile{
+		Input:           newStreamDeviceProfile(params.Input),
+		Output:          newStreamDeviceProfile(params.Output),
+		SampleRate:      params.SampleRate,
+		SampleFormat:    params.SampleFormat,
+		FramesPerBuffer: params.FramesPerBuffer,
+		Flags:           params.Flags,
+	}
+}
+
+func newStreamDeviceProfile(p StreamDeviceParameters) *StreamDeviceProfile {
+	if !p.Exists() {
+		return nil
+	}
+	profile := &StreamDeviceProfile{
+		Device:   p.Device.Name,
+		Channels: p.ChannelCount,
+		Latency:  p.SuggestedLatency.String(),
+	}
+	if p.Device.HostApi != nil {
+		t := p.Device.HostApi.Type
+		profile.HostApi = &t
+	}
+	return profile
+}
+
+// Resolve looks up the devices of the profile and returns the concrete stream parameters.
+// It fails if a device cannot be found, the channel count or latency is invalid,
+// or PortAudio reports that the format is not supported.
+func (p *StreamProfile) Resolve() (*StreamParameters, error) {
+	if p.Input == nil && p.Output == nil {
+		return nil, fmt.Errorf("portaudio: profile has neither input nor output")
+	}
+	params := &StreamParameters{
+		SampleRate:      p.SampleRate,
+		SampleFormat:    p.SampleFormat,
+		FramesPerBuffer: p.FramesPerBuffer,
+		Flags:           p.Flags,
+	}
+	var err error
+	if p.Input != nil {
+		if params.Input, err = p.Input.resolve(true); err != nil {
+			return nil, err
+		}
+	}
+	if p.Output != nil {
+		if params.Output, err = p.Output.resolve(false); err != nil {
+			return nil, err
+		}
+	}
+	if params.SampleFormat == 0 {
+		params.SampleFormat = Float32
+	}
+	if params.SampleRate == 0 {
+		if params.Output.Exists() {
+			params.SampleRate = params.Output.Device.DefaultSampleRate
+		} else {
+			params.SampleRate = params.Input.Device.DefaultSampleRate
+		}
+	}
+	if params.SampleRate < 0 {
+		return nil, fmt.Errorf("portaudio: profile sample rate %g: %w", params.SampleRate, InvalidSampleRate)
+	}
+	if err = CheckFormatSupported(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func (p *StreamDeviceProfile) resolve(input bool) (StreamDeviceParameters, error) {
+	direction := "output"
+	if input {
+		direction = "input"
+	}
+	device := p.device(input)
+	if device == nil {
+		return StreamDeviceParameters{}, fmt.Errorf("portaudio: profile %s device %q: %w", direction, p.Device, InvalidDevice)
+	}
+	maxChannels, defaultChannels := device.MaxOutputChannels, 2
+	lowLatency, highLatency := device.DefaultLowOutputLatency, device.DefaultHighOutputLatency
+	if input {
+		maxChannels, defaultChannels = device.MaxInputChannels, 1
+		lowLatency, highLatency = device.DefaultLowInputLatency, device.DefaultHighInputLatency
+	}
+	channels := p.Channels
+	if channels == 0 {
+		channels = min(maxChannels, defaultChannels)
+	}
+	if channels <= 0 || channels > maxChannels {
+		return StreamDeviceParameters{}, fmt.Errorf(
+			"portaudio: profile %s device %q: %d of %d channels: %w",
+			direction, device.Name, channels, maxChannels, InvalidChannelCount,
+		)
+	}
+	var latency time.Duration
+	switch p.Latency {
+	case "", HighLatency:
+		latency = highLatency
+	case LowLatency:
+		latency = lowLatency
+	default:
+		var err error
+		if latency, err = time.ParseDuration(p.Latency); err != nil || latency < 0 {
+			return StreamDeviceParameters{}, fmt.Errorf("portaudio: profile %s latency %q is invalid", direction, p.Latency)
+		}
+	}
+	return StreamDeviceParameters{
+		Device:           device,
+		ChannelCount:     channels,
+		SuggestedLatency: latency,
+	}, nil
+}
+
+// device finds the device of the profile or nil if there is none.
+func (p *StreamDeviceProfile) device(input bool) *DeviceInfo {
+	if p.Device == "" {
+		if p.HostApi == nil {
+			return Device(defaultDeviceIndex(input))
+		}
+		index := C.Pa_HostApiTypeIdToHostApiIndex(C.PaHostApiTypeId(*p.HostApi))
+		if index < 0 {
+			return nil
+		}
+		info := C.Pa_GetHostApiInfo(index)
+		if info == nil {
+			return nil
+		}
+		if input {
+			return Device(int(info.defaultInputDevice))
+		}
+		return Device(int(info.defaultOutputDevice))
+	}
+	for i, n := 0, DeviceCount(); i < n; i++ {
+		d := Device(i)
+		if d == nil || d.Name != p.Device {
+			continue
+		}
+		if p.HostApi != nil && (d.HostApi == nil || d.HostApi.Type != *p.HostApi) {
+			continue
+		}
+		if input && d.MaxInputChannels > 0 || !input && d.MaxOutputChannels > 0 {
+			return d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package portaudio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamProfileJSON(t *testing.T) {
+	api := &HostApiInfo{Type: ALSA, Name: "ALSA"}
+	params := &StreamParameters{
+		Input: StreamDeviceParameters{
+			Device:           &DeviceInfo{Index: 1, Name: "USB Mic", HostApi: api},
+			ChannelCount:     1,
+			SuggestedLatency: 20 * time.Millisecond,
+		},
+		Output: StreamDeviceParameters{
+			Device:           &DeviceInfo{Index: 2, Name: "Speakers"},
+			ChannelCount:     2,
+			SuggestedLatency: 100 * time.Millisecond,
+		},
+		SampleRate:      44100,
+		SampleFormat:    Int16,
+		FramesPerBuffer: 512,
+		Flags:           ClipOff,
+	}
+	profile := NewStreamProfile(params)
+	data, err := json.Marshal(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const text = `{"input":{"device":"USB Mic","hostApi":"ALSA","channels":1,"latency":"20ms"},` +
+		`"output":{"device":"Speakers","channels":2,"latency":"100ms"},` +
+		`"sampleRate":44100,"sampleFormat":"Int16","framesPerBuffer":512,"flags":"ClipOff"}`
+	if string(data) != text {
+		t.Errorf("got %s, want %s", data, text)
+	}
+	var got StreamProfile
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, profile) {
+		t.Errorf("unmarshaled %+v, want %+v", got, *profile)
+	}
+
+	// An output-only profile leaves out the input.
+	params.Input = StreamDeviceParameters{}
+	if data, _ = json.Marshal(NewStreamProfile(params)); bytes.Contains(data, []byte(`"input"`)) {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestStreamProfileWithoutDevices(t *testing.T) {
+	if params, err := (&StreamProfile{SampleRate: 48000}).Resolve(); err == nil {
+		t.Errorf("resolved to %+v", params)
+	}
+}
+
+func TestStreamProfileRoundTrip(t *testing.T) {
+	params := loopbackParams(t, Int16, 2)
+	params.Input.ChannelCount = 1
+	params.Input.SuggestedLatency = 20 * time.Millisecond
+	params.Output.SuggestedLatency = 40 * time.Millisecond
+	params.Flags = ClipOff | DitherOff
+
+	data, err := json.Marshal(NewStreamProfile(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile StreamProfile
+	if err = json.Unmarshal(data, &profile); err != nil {
+		t.Fatal(err)
+	}
+	got, err := profile.Resolve()
+	if err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	for _, p := range [][2]StreamDeviceParameters{{got.Input, params.Input}, {got.Output, params.Output}} {
+		if p[0].Device.Index != p[1].Device.Index || p[0].ChannelCount != p[1].ChannelCount ||
+			p[0].SuggestedLatency != p[1].SuggestedLatency {
+			t.Errorf("%s: resolved %v, want %v", data, p[0], p[1])
+		}
+	}
+	if got.SampleRate != params.SampleRate || got.SampleFormat != params.SampleFormat ||
+		got.FramesPerBuffer != params.FramesPerBuffer || got.Flags != params.Flags {
+		t.Errorf("%s: resolved %+v", data, got)
+	}
+}
+
+func TestStreamProfileDefaults(t *testing.T) {
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 4, SampleRate: 44100})
+	t.Cleanup(l.Close)
+	var profile StreamProfile
+	text := `{"input": {"device": "` + l.Input().Name + `", "latency": "low"}, "output": {"device": "` + l.Output().Name + `"}}`
+	if err := json.Unmarshal([]byte(text), &profile); err != nil {
+		t.Fatal(err)
+	}
+	params, err := profile.Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, out := params.Input, params.Output
+	if in.Device.Index != l.Input().Index || in.ChannelCount != 1 || in.SuggestedLatency != l.Input().DefaultLowInputLatency {
+		t.Errorf("input resolved to %v", in)
+	}
+	if out.Device.Index != l.Output().Index || out.ChannelCount != 2 || out.SuggestedLatency != l.Output().DefaultHighOutputLatency {
+		t.Errorf("output resolved to %v", out)
+	}
+	if params.SampleRate != 44100 || params.SampleFormat != Float32 {
+		t.Errorf("resolved to %v at %v", params.SampleFormat, params.SampleRate)
+	}
+}
+
+func TestStreamProfileErrors(t *testing.T) {
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 2})
+	t.Cleanup(l.Close)
+	api := l.Output().HostApi.Type
+	other := ALSA
+	for _, test := range []struct {
+		name    string
+		profile StreamProfile
+		want    error
+	}{
+		{"UnknownDevice", StreamProfile{Output: &StreamDeviceProfile{Device: t.Name() + " Speaker"}}, InvalidDevice},
+		{"OtherHostApi", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name, HostApi: &other}}, InvalidDevice},
+		{"OutputAsInput", StreamProfile{Input: &StreamDeviceProfile{Device: l.Output().Name, HostApi: &api}}, InvalidDevice},
+		{"Channels", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name, Channels: 3}}, InvalidChannelCount},
+		{"Latency", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name, Latency: "soon"}}, nil},
+		{"NegativeLatency", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name, Latency: "-1ms"}}, nil},
+		{"NegativeRate", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name}, SampleRate: -1}, InvalidSampleRate},
+		{"UnsupportedRate", StreamProfile{Output: &StreamDeviceProfile{Device: l.Output().Name}, SampleRate: 44100}, InvalidSampleRate},
+	} {
+		params, err := test.profile.Resolve()
+		if err == nil {
+			t.Errorf("%s: resolved to %+v", test.name, params)
+			continue
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
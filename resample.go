@@ -0,0 +1,214 @@
+package portaudio
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// resampler converts interleaved frames by an arbitrary, slowly varying ratio
+// using 4-point cubic Hermite interpolation. It keeps its state across buffers.
+type resampler struct {
+	channels int
+	hist     []float64 // the last 4 input frames, oldest first
+	pos      float64   // position between hist frames 1 and 2
+}
+
+func newResampler(channels int) *resampler {
+	r := &resampler{channels: channels, hist: make([]float64, 4*channels)}
+	r.reset()
+	return r
+}
+
+// reset clears the history. The first output frame is then the next input frame.
+func (r *resampler) reset() {
+	clear(r.hist)
+	r.pos = 3
+}
+
+// process fills out with interleaved frames. ratio is the number of input frames
+// consumed per output frame. next fills its argument with the next input frame
+// and reports false if no input is available, in which case the last frame is held.
+// It returns the number of input frames that were missing.
+func (r *resampler) process(out []float64, ratio float64, next func(frame []float64) bool) (missing int) {
+	c := r.channels
+	h := r.hist
+	for f := 0; f+c <= len(out); f += c {
+		for r.pos >= 1 {
+			copy(h, h[c:])
+			if !next(h[3*c:]) {
+				copy(h[3*c:], h[2*c:3*c])
+				missing++
+			}
+			r.pos--
+		}
+		x := r.pos
+		for ch := 0; ch < c; ch++ {
+			y0, y1, y2, y3 := h[ch], h[c+ch], h[2*c+ch], h[3*c+ch]
+			c1 := 0.5 * (y2 - y0)
+			c2 := y0 - 2.5*y1 + 2*y2 - 0.5*y3
+			c3 := 0.5*(y3-y0) + 1.5*(y1-y2)
+			out[f+ch] = ((c3*x+c2)*x+c1)*x + y1
+		}
+		r.pos += ratio
+	}
+	return missing
+}
+
+// rateEstimator measures the actual frame rate of a stream clock
+// from the callback timestamps over the whole lifetime of the stream.
+type rateEstimator struct {
+	start   time.Duration
+	frames  uint64
+	started bool
+	rate    atomic.Uint64 // math.Float64bits of frames per second, 0 if unknown
+}
+
+// minRateWindow is the time span after which the rate estimate is published.
+const minRateWindow = 2 * time.Second
+
+// update registers a buffer of frameCount frames beginning at time t.
+func (e *rateEstimator) update(t time.Duration, frameCount int) {
+	if t == 0 {
+		return
+	}
+	if !e.started {
+		e.start, e.started = t, true
+		e.frames = uint64(frameCount)
+		return
+	}
+	if elapsed := t - e.start; elapsed >= minRateWindow {
+		e.rate.Store(math.Float64bits(float64(e.frames) / elapsed.Seconds()))
+	}
+	e.frames += uint64(frameCount)
+}
+
+func (e *rateEstimator) value() float64 {
+	return math.Float64frombits(e.rate.Load())
+}
+
+// driftController derives the resampling ratio between two stream clocks
+// from their measured rates and holds the fill level of the buffer between them
+// at the target with a PI controller.
+type driftController struct {
+	target   float64 // frames
+	fill     float64 // smoothed fill level in frames
+	integral float64
+}
+
+const (
+	driftSmoothing     = 0.05
+	driftKp            = 2e-3
+	driftKi            = 1e-4
+	maxCorrection      = 2e-3
+	maxRatioDivergence = 0.02
+)
+
+// ratio returns the resampling ratio for the next buffer.
+// drift is the ratio of the producer to the consumer rate or 0 if unknown,
+// fill is the current fill level and dt the duration of the buffer.
+func (d *driftController) ratio(drift float64, fill int, dt float64) float64 {
+	if drift == 0 {
+		drift = 1
+	}
+	d.fill += driftSmoothing * (float64(fill) - d.fill)
+	e := (d.fill - d.target) / d.target
+	d.integral = max(-maxCorrection/driftKi, min(maxCorrection/driftKi, d.integral+e*dt))
+	correction := max(-maxCorrection, min(maxCorrection, driftKp*e+driftKi*d.integral))
+	return max(1-maxRatioDivergence, min(1+maxRatioDivergence, drift*(1+correction)))
+}
+
+// reset restarts the controller at the given fill level.
+func (d *driftController) reset(fill int) {
+	d.fill = float64(fill)
+	d.integral = 0
+}
+
+// ResampleSource is a Source converting the sample rate of another source,
+// e.g. to play a file on a device running at a different rate.
+type ResampleSource[T Sample] struct {
+	src      Source[T]
+	r        *resampler
+	ratio    float64
+	in       []T
+	pos      int
+	size     int
+	frames   int64 // input frames read from src
+	produced int64 // output frames returned
+	err      error // the error that ended src
+	out      []float64
+}
+
+// NewResampleSource creates a source converting the interleaved frames of src from one rate to another.
+func NewResampleSource[T Sample](src Source[T], channels int, fromRate, toRate float64) *ResampleSource[T] {
+	return &ResampleSource[T]{
+		src:   src,
+		r:     newResampler(channels),
+		ratio: fromRate / toRate,
+		in:    make([]T, 1024*channels),
+	}
+}
+
+// ReadSamples fills buf with resampled frames. Once src is exhausted,
+// it returns io.EOF or the error src returned after the last frame.
+func (s *ResampleSource[T]) ReadSamples(buf []T) (int, error) {
+	c := s.r.channels
+	frames := len(buf) / c
+	if s.err != nil {
+		frames = int(min(int64(frames), s.total()-s.produced))
+		if frames <= 0 {
+			return 0, s.err
+		}
+	}
+	s.out = resize(s.out, frames*c)
+	s.r.process(s.out, s.ratio, s.next)
+	if s.err != nil {
+		// Frames interpolated past the last input frame are dropped.
+		frames = int(max(0, min(int64(frames), s.total()-s.produced)))
+	}
+	for i, v := range s.out[:frames*c] {
+		buf[i] = FromFloat64[T](v)
+	}
+	s.produced += int64(frames)
+	if s.err != nil && s.produced == s.total() {
+		return frames * c, s.err
+	}
+	return frames * c, nil
+}
+
+// next supplies the resampler with the next input frame.
+func (s *ResampleSource[T]) next(frame []float64) bool {
+	c := s.r.channels
+	for s.pos+c > s.size {
+		if s.err != nil {
+			return false
+		}
+		n, err := s.src.ReadSamples(s.in)
+		s.pos, s.size = 0, n-n%c
+		s.frames += int64(n / c)
+		if err != nil {
+			s.err = err
+		}
+	}
+	for i := range frame {
+		frame[i] = ToFloat64(s.in[s.pos+i])
+	}
+	s.pos += c
+	return true
+}
+
+// total returns the number of output frames covering the input once src is exhausted.
+func (s *ResampleSource[T]) total() int64 {
+	if s.frames == 0 {
+		return 0
+	}
+	return int64(float64(s.frames-1)/s.ratio) + 1
+}
+
+// Reset discards buffered frames and the end of the input, e.g. after seeking src.
+func (s *ResampleSource[T]) Reset() {
+	s.r.reset()
+	s.pos, s.size = 0, 0
+	s.frames, s.produced = 0, 0
+	s.err = nil
+}
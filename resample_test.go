@@ -0,0 +1,152 @@
+package portaudio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// readAll reads s to the end with buffers of size samples.
+func readAll[T Sample](t *testing.T, s Source[T], size int) ([]T, error) {
+	t.Helper()
+	var all []T
+	buf := make([]T, size)
+	for range 100000 {
+		n, err := s.ReadSamples(buf)
+		all = append(all, buf[:n]...)
+		if err != nil {
+			return all, err
+		}
+	}
+	t.Fatal("source does not end")
+	return nil, nil
+}
+
+// ramp returns n frames of channels channels rising in proportion to the frame index and c+1 in channel c.
+func ramp(n, channels int) []float32 {
+	s := make([]float32, n*channels)
+	for i := range n {
+		for c := range channels {
+			s[i*channels+c] = float32((c+1)*i) / float32(n*channels)
+		}
+	}
+	return s
+}
+
+func TestResampleSourceLength(t *testing.T) {
+	for _, test := range []struct {
+		frames         int
+		fromRate, rate float64
+		want           int
+	}{
+		{1000, 48000, 48000, 1000},
+		{1000, 24000, 48000, 1999},
+		{1000, 48000, 24000, 500},
+		{1000, 44100, 48000, 1088},
+		{1000, 48000, 44100, 918},
+		{1, 44100, 48000, 1},
+		{0, 44100, 48000, 0},
+	} {
+		for _, size := range []int{2, 6, 512, 4096} {
+			s := NewResampleSource[float32](NewBufferSource(ramp(test.frames, 2)), 2, test.fromRate, test.rate)
+			out, err := readAll[float32](t, s, size)
+			if err != io.EOF {
+				t.Fatalf("%v->%v: got error %v, want %v", test.fromRate, test.rate, err, io.EOF)
+			}
+			if len(out) != 2*test.want {
+				t.Errorf("%d frames %v->%v in buffers of %d: got %d frames, want %d",
+					test.frames, test.fromRate, test.rate, size, len(out)/2, test.want)
+			}
+			if n, err := s.ReadSamples(make([]float32, size)); n != 0 || err != io.EOF {
+				t.Errorf("ReadSamples after the end = %d, %v", n, err)
+			}
+		}
+	}
+}
+
+func TestResampleSourceValues(t *testing.T) {
+	const frames = 1000
+	in := ramp(frames, 2)
+
+	// At equal rates the frames pass unchanged.
+	out, _ := readAll[float32](t, NewResampleSource[float32](NewBufferSource(in), 2, 44100, 44100), 300)
+	for i := range in {
+		if out[i] != in[i] {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], in[i])
+		}
+	}
+
+	// Cubic interpolation reproduces a ramp away from both ends.
+	out, _ = readAll[float32](t, NewResampleSource[float32](NewBufferSource(in), 2, 24000, 48000), 300)
+	for i := 4; i < 2*(frames-2); i++ {
+		for c := range 2 {
+			want := float64(c+1) * float64(i) / 2 / (2 * frames)
+			if v := float64(out[2*i+c]); math.Abs(v-want) > 1e-6 {
+				t.Fatalf("frame %d channel %d = %v, want %v", i, c, v, want)
+			}
+		}
+	}
+	if last := out[len(out)-2:]; last[0] != in[len(in)-2] || last[1] != in[len(in)-1] {
+		t.Errorf("last frame = %v, want %v", last, in[len(in)-2:])
+	}
+}
+
+func TestResampleSourceEnd(t *testing.T) {
+	errSource := errors.New("source failed")
+	for _, test := range []struct {
+		name string
+		err  error
+		// split returns the samples and the error in separate calls
+		split bool
+	}{
+		{"EOF", io.EOF, false},
+		{"SplitEOF", io.EOF, true},
+		{"Error", errSource, false},
+		{"SplitError", errSource, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			in := ramp(100, 1)
+			src := FuncSource[float32](func(buf []float32) (int, error) {
+				n := copy(buf, in)
+				in = in[n:]
+				if len(in) > 0 || test.split && n > 0 {
+					return n, nil
+				}
+				return n, test.err
+			})
+			s := NewResampleSource[float32](src, 1, 44100, 48000)
+			out, err := readAll[float32](t, s, 64)
+			if err != test.err {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+			if len(out) != 108 {
+				t.Errorf("got %d frames, want 108", len(out))
+			}
+		})
+	}
+}
+
+func TestResampleSourceReset(t *testing.T) {
+	src := NewBufferSource(ramp(500, 1))
+	s := NewResampleSource[float32](src, 1, 32000, 48000)
+	want, _ := readAll[float32](t, s, 100)
+
+	// A reset in the middle discards the buffered frames.
+	src.Rewind()
+	s.Reset()
+	if _, err := s.ReadSamples(make([]float32, 100)); err != nil {
+		t.Fatal(err)
+	}
+	src.Rewind()
+	s.Reset()
+	got, err := readAll[float32](t, s, 100)
+	if err != io.EOF || len(got) != len(want) {
+		t.Fatalf("got %d frames, %v after Reset, want %d", len(got), err, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d = %v after Reset, want %v", i, got[i], want[i])
+		}
+	}
+}
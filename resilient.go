@@ -0,0 +1,438 @@
+package portaudio
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamStalled is reported when the callback of a resilient stream
+// has not been invoked for longer than ResilienceOptions.StallTimeout.
+var ErrStreamStalled = errors.New("portaudio: stream callback stalled")
+
+// ErrStreamLost is returned by a resilient stream whose stream has been lost
+// and is being reopened by another goroutine.
+var ErrStreamLost = errors.New("portaudio: stream lost")
+
+// DefaultRetryInterval is the pause between reconnection attempts if ResilienceOptions.RetryInterval is zero.
+const DefaultRetryInterval = 500 * time.Millisecond
+
+// ResilienceOptions configures how a ResilientStream recovers from device loss.
+type ResilienceOptions struct {
+	// StallTimeout is the time without callback invocations after which
+	// a running callback stream is considered broken. Zero disables stall detection.
+	StallTimeout time.Duration
+	// RetryInterval is the pause between two reconnection attempts. Zero selects DefaultRetryInterval.
+	RetryInterval time.Duration
+	// MaxRetries limits the number of reconnection attempts. Zero means no limit.
+	MaxRetries int
+	// RescanDevices re-initializes PortAudio before each attempt so that
+	// re-plugged devices are found. The attempt fails with ErrStreamsOpen
+	// while other PortAudio streams are open, see RescanDevices.
+	RescanDevices bool
+	// FallbackToDefault reopens the stream on the default devices
+	// if the original devices cannot be found or have fewer channels than the stream.
+	// The channel counts of the stream are kept, so a device with fewer channels is never used.
+	FallbackToDefault bool
+}
+
+// ReconnectEvent describes a reconnection attempt of a ResilientStream.
+type ReconnectEvent struct {
+	Attempt int
+	// Cause is the error that triggered the reconnection.
+	Cause error
+	// Err is nil if the stream has been reopened successfully.
+	Err error
+	// Params are the parameters the stream has been reopened with.
+	Params *StreamParameters
+}
+
+// ResilientStream wraps a Stream and transparently reopens it
+// when its device is lost or its callback stalls.
+// While the stream is being reopened, the other methods do not block:
+// Stop, Abort and Close cancel the reconnection and Read and Write return ErrStreamLost.
+type ResilientStream[T any] struct {
+	mu               sync.Mutex
+	stream           *Stream[T] // nil while the stream is lost
+	params           *StreamParameters
+	callback         func(*Stream[T]) StreamCallbackResult
+	finishedCallback func(*Stream[T])
+	options          ResilienceOptions
+	events           chan ReconnectEvent
+	progress         atomic.Uint64
+	completed        atomic.Bool
+	running          bool
+	closed           bool
+	stop             chan struct{}
+	cancel           chan struct{} // closed to cancel the reconnection in progress
+	in               []T
+}
+
+// OpenResilientStream opens a stream like OpenStream and keeps it alive according to the options.
+func OpenResilientStream[T any](
+	params *StreamParameters,
+	callback func(*Stream[T]) StreamCallbackResult,
+	finishedCallback func(*Stream[T]),
+	options ResilienceOptions,
+) (*ResilientStream[T], error) {
+	r := &ResilientStream[T]{
+		params:           params,
+		callback:         callback,
+		finishedCallback: finishedCallback,
+		options:          options,
+		events:           make(chan ReconnectEvent, 16),
+	}
+	if r.options.RetryInterval <= 0 {
+		r.options.RetryInterval = DefaultRetryInterval
+	}
+	s, err := OpenStream(params, r.streamCallback(), finishedCallback)
+	if err != nil {
+		return nil, err
+	}
+	r.stream = s
+	return r, nil
+}
+
+// Stream returns the currently open stream, or nil if it is lost.
+func (r *ResilientStream[T]) Stream() *Stream[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stream
+}
+
+// Events returns the channel reconnection attempts are reported to.
+// Events are dropped if the channel is not drained.
+func (r *ResilientStream[T]) Events() <-chan ReconnectEvent {
+	return r.events
+}
+
+// Start commences audio processing and, if configured, the stall detection.
+func (r *ResilientStream[T]) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.current(); err != nil {
+		return err
+	}
+	r.completed.Store(false)
+	r.running = true
+	if err := r.stream.Start(); err != nil {
+		if !IsDeviceLost(err) {
+			r.running = false
+			return err
+		}
+		if err = r.reconnect(err); err != nil {
+			r.running = false
+			return err
+		}
+	}
+	if r.callback != nil && r.options.StallTimeout > 0 && r.stop == nil {
+		r.stop = make(chan struct{})
+		go r.watch(r.stop)
+	}
+	return nil
+}
+
+// Stop terminates audio processing and the stall detection.
+func (r *ResilientStream[T]) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.halt()
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.Stop()
+}
+
+// Abort terminates audio processing immediately and stops the stall detection.
+func (r *ResilientStream[T]) Abort() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.halt()
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.Abort()
+}
+
+// Close closes the underlying stream.
+func (r *ResilientStream[T]) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.halt()
+	if r.closed {
+		return BadStreamPtr
+	}
+	r.closed = true
+	s := r.stream
+	r.stream = nil
+	if s == nil {
+		return nil
+	}
+	return s.Close()
+}
+
+// Read reads samples from the input stream, reopening the stream if its device is lost.
+// The returned slice is owned by the ResilientStream and stays valid until the next Read.
+func (r *ResilientStream[T]) Read() ([]T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.current(); err != nil {
+		return nil, err
+	}
+	in, err := r.stream.Read()
+	if err != nil && IsDeviceLost(err) {
+		if err = r.reconnect(err); err != nil {
+			return nil, err
+		}
+		in, err = r.stream.Read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.in = append(r.in[:0], in...)
+	return r.in, nil
+}
+
+// Write writes samples to the output stream, reopening the stream if its device is lost.
+func (r *ResilientStream[T]) Write(data []T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.current(); err != nil {
+		return err
+	}
+	err := r.stream.Write(data)
+	if err == nil || !IsDeviceLost(err) {
+		return err
+	}
+	if err = r.reconnect(err); err != nil {
+		return err
+	}
+	return r.stream.Write(data)
+}
+
+// current makes sure that r.stream is open, reopening it if it has been lost.
+// It must be called with r.mu held.
+func (r *ResilientStream[T]) current() error {
+	switch {
+	case r.closed:
+		return BadStreamPtr
+	case r.stream != nil:
+		return nil
+	case r.cancel != nil:
+		return ErrStreamLost
+	}
+	return r.reconnect(ErrStreamLost)
+}
+
+func (r *ResilientStream[T]) streamCallback() func(*Stream[T]) StreamCallbackResult {
+	if r.callback == nil {
+		return nil
+	}
+	r.completed.Store(false)
+	return func(s *Stream[T]) StreamCallbackResult {
+		r.progress.Add(1)
+		result := r.callback(s)
+		if result != Continue {
+			r.completed.Store(true)
+		}
+		return result
+	}
+}
+
+// halt stops the stall detection and cancels the reconnection in progress.
+// It must be called with r.mu held.
+func (r *ResilientStream[T]) halt() {
+	r.running = false
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	if r.cancel != nil {
+		close(r.cancel)
+		r.cancel = nil
+	}
+}
+
+// watch reopens the stream when its callback has not been invoked for StallTimeout.
+func (r *ResilientStream[T]) watch(stop chan struct{}) {
+	ticker := time.NewTicker(r.options.StallTimeout / 2)
+	defer ticker.Stop()
+	last, since := r.progress.Load(), time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if p := r.progress.Load(); p != last {
+				last, since = p, now
+				continue
+			}
+			if now.Sub(since) < r.options.StallTimeout {
+				continue
+			}
+			r.mu.Lock()
+			if r.stop == stop && r.cancel == nil && !r.completed.Load() {
+				_ = r.reconnect(ErrStreamStalled)
+			}
+			r.mu.Unlock()
+			last, since = r.progress.Load(), time.Now()
+		}
+	}
+}
+
+// reconnect closes the broken stream and reopens it until it succeeds, the retries
+// are exhausted or it is cancelled by halt. It must be called with r.mu held,
+// which it releases while waiting between attempts.
+func (r *ResilientStream[T]) reconnect(cause error) error {
+	l := r.logger()
+	l.Warn("stream lost, reconnecting", slog.Any("cause", cause))
+	if r.stream != nil {
+		_ = r.stream.Abort()
+		_ = r.stream.Close()
+		r.stream = nil
+	}
+	cancel := make(chan struct{})
+	r.cancel = cancel
+	defer func() {
+		if r.cancel == cancel {
+			r.cancel = nil
+		}
+	}()
+	var err error
+	for attempt := 1; r.options.MaxRetries <= 0 || attempt <= r.options.MaxRetries; attempt++ {
+		if attempt > 1 && !r.wait(cancel) {
+			l.Info("stream reconnection cancelled", slog.Int("attempt", attempt))
+			return err
+		}
+		var params *StreamParameters
+		if params, err = r.reopen(); err == nil {
+			r.emit(ReconnectEvent{attempt, cause, nil, params})
+			l.Info("stream reconnected", slog.Int("attempt", attempt), slog.Any("params", params))
+			return nil
+		}
+		r.emit(ReconnectEvent{attempt, cause, err, params})
+		logError(l, "stream reconnection failed", err, slog.Int("attempt", attempt))
+	}
+	return err
+}
+
+// wait waits for RetryInterval with r.mu released.
+// It returns false if the reconnection has been cancelled meanwhile.
+func (r *ResilientStream[T]) wait(cancel chan struct{}) bool {
+	r.mu.Unlock()
+	timer := time.NewTimer(r.options.RetryInterval)
+	select {
+	case <-timer.C:
+	case <-cancel:
+		timer.Stop()
+	}
+	r.mu.Lock()
+	select {
+	case <-cancel:
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *ResilientStream[T]) reopen() (*StreamParameters, error) {
+	if r.options.RescanDevices {
+		if err := RescanDevices(); err != nil {
+			return nil, err
+		}
+	}
+	params, err := r.resolveParams()
+	if err != nil {
+		return nil, err
+	}
+	s, err := OpenStream(params, r.streamCallback(), r.finishedCallback)
+	if err != nil {
+		return params, err
+	}
+	if r.running {
+		if err = s.Start(); err != nil {
+			_ = s.Close()
+			return params, err
+		}
+	}
+	r.stream = s
+	return params, nil
+}
+
+// resolveParams finds the devices of the original parameters in the current device list.
+func (r *ResilientStream[T]) resolveParams() (*StreamParameters, error) {
+	params := *r.params
+	for _, p := range []struct {
+		params *StreamDeviceParameters
+		input  bool
+	}{{&params.Input, true}, {&params.Output, false}} {
+		if !p.params.Exists() {
+			continue
+		}
+		dev, err := r.resolveDevice(*p.params, p.input)
+		if err != nil {
+			return nil, streamError("reopen", p.params.Device, r.params, err)
+		}
+		p.params.Device = dev
+	}
+	return &params, nil
+}
+
+// resolveDevice returns the device to reopen p on: the device with the same name and host API,
+// or the default device if FallbackToDefault is set. Devices with fewer channels than p are skipped,
+// since the callback relies on the channel count. If no device is found, it returns DeviceUnavailable,
+// or InvalidChannelCount if the devices found have too few channels.
+func (r *ResilientStream[T]) resolveDevice(p StreamDeviceParameters, input bool) (*DeviceInfo, error) {
+	candidates := []*DeviceInfo{findDevice(p.Device, input)}
+	if r.options.FallbackToDefault {
+		if input {
+			candidates = append(candidates, DefaultInputDevice())
+		} else {
+			candidates = append(candidates, DefaultOutputDevice())
+		}
+	}
+	err := DeviceUnavailable
+	for _, dev := range candidates {
+		if dev == nil {
+			continue
+		}
+		if input && dev.MaxInputChannels >= p.ChannelCount || !input && dev.MaxOutputChannels >= p.ChannelCount {
+			return dev, nil
+		}
+		err = InvalidChannelCount
+	}
+	return nil, err
+}
+
+func (r *ResilientStream[T]) logger() *slog.Logger {
+	if r.params.Logger != nil {
+		return r.params.Logger
+	}
+	return Logger()
+}
+
+func (r *ResilientStream[T]) emit(e ReconnectEvent) {
+	select {
+	case r.events <- e:
+	default:
+	}
+}
+
+// findDevice looks up a device with the same name and host API as the given one.
+func findDevice(device *DeviceInfo, input bool) *DeviceInfo {
+	for i, n := 0, DeviceCount(); i < n; i++ {
+		d := Device(i)
+		if d == nil || d.Name != device.Name {
+			continue
+		}
+		if device.HostApi != nil && d.HostApi != nil && d.HostApi.Type != device.HostApi.Type {
+			continue
+		}
+		if input && d.MaxInputChannels > 0 || !input && d.MaxOutputChannels > 0 {
+			return d
+		}
+	}
+	return nil
+}
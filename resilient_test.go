@@ -0,0 +1,226 @@
+package portaudio
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// flakyDevice is the input device of a Loopback that can be unplugged:
+// streams opened before disconnect fail with DeviceUnavailable
+// and the next opens fail until failures is used up.
+type flakyDevice struct {
+	*Loopback
+	generation atomic.Int32
+	failures   atomic.Int32
+}
+
+func newFlakyDevice(t *testing.T) (*flakyDevice, *DeviceInfo) {
+	l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 1})
+	info := *l.Input()
+	l.Close()
+	d := &flakyDevice{Loopback: l}
+	info.virtual = d
+	registerVirtual(&info)
+	t.Cleanup(func() { unregisterVirtual(d) })
+	return d, &info
+}
+
+func (d *flakyDevice) disconnect(failures int) {
+	d.failures.Store(int32(failures))
+	d.generation.Add(1)
+}
+
+func (d *flakyDevice) open(params *StreamParameters, target callbackTarget, callback bool) (backend, error) {
+	if d.failures.Add(-1) >= 0 {
+		return nil, DeviceUnavailable
+	}
+	b, err := d.Loopback.open(params, target, callback)
+	if err != nil {
+		return nil, err
+	}
+	return &flakyBackend{b, d, d.generation.Load()}, nil
+}
+
+type flakyBackend struct {
+	backend
+	device     *flakyDevice
+	generation int32
+}
+
+func (b *flakyBackend) lost() bool {
+	return b.device.generation.Load() != b.generation
+}
+
+func (b *flakyBackend) start() error {
+	if b.lost() {
+		return DeviceUnavailable
+	}
+	return b.backend.start()
+}
+
+func (b *flakyBackend) read(buf unsafe.Pointer, frames int) error {
+	if b.lost() {
+		return DeviceUnavailable
+	}
+	return b.backend.read(buf, frames)
+}
+
+func openFlakyStream(t *testing.T, options ResilienceOptions) (*flakyDevice, *ResilientStream[float32]) {
+	t.Helper()
+	d, info := newFlakyDevice(t)
+	params := &StreamParameters{
+		Input:           StreamDeviceParameters{Device: info, ChannelCount: 1},
+		SampleRate:      48000,
+		SampleFormat:    Float32,
+		FramesPerBuffer: 64,
+	}
+	r, err := OpenResilientStream[float32](params, nil, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	if err = r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return d, r
+}
+
+func TestResilientStreamReconnect(t *testing.T) {
+	d, r := openFlakyStream(t, ResilienceOptions{RetryInterval: time.Millisecond})
+	first, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := r.Stream()
+	d.disconnect(2)
+	in, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read after device loss: %v", err)
+	}
+	if len(in) != 64 || &in[0] != &first[0] {
+		t.Error("Read does not return the buffer of the resilient stream")
+	}
+	if r.Stream() == old {
+		t.Error("stream has not been reopened")
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		e := <-r.Events()
+		if e.Attempt != attempt || !errors.Is(e.Cause, DeviceUnavailable) {
+			t.Errorf("event %d: %+v", attempt, e)
+		}
+		if (e.Err == nil) != (attempt == 3) {
+			t.Errorf("attempt %d: error %v", attempt, e.Err)
+		}
+	}
+}
+
+func TestResilientStreamRetriesExhausted(t *testing.T) {
+	d, r := openFlakyStream(t, ResilienceOptions{RetryInterval: time.Millisecond, MaxRetries: 2})
+	d.disconnect(4)
+	if _, err := r.Read(); !errors.Is(err, DeviceUnavailable) {
+		t.Fatalf("Read: %v, want DeviceUnavailable", err)
+	}
+	if r.Stream() != nil {
+		t.Fatal("lost stream is still referenced")
+	}
+	if err := r.Stop(); err != nil {
+		t.Errorf("Stop of a lost stream: %v", err)
+	}
+	// Each call reconnects again until the device is back.
+	if err := r.Start(); !errors.Is(err, DeviceUnavailable) {
+		t.Fatalf("Start: %v, want DeviceUnavailable", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+}
+
+func TestResilientStreamChannelCount(t *testing.T) {
+	mono := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 1})
+	t.Cleanup(mono.Close)
+	stereo := NewLoopback(LoopbackOptions{Name: t.Name() + " Stereo", Channels: 2})
+	t.Cleanup(stereo.Close)
+	t.Setenv(DeviceEnv, stereo.Input().Name)
+	for _, test := range []struct {
+		device  *DeviceInfo
+		options ResilienceOptions
+		want    error
+	}{
+		{stereo.Input(), ResilienceOptions{}, nil},
+		{mono.Input(), ResilienceOptions{}, InvalidChannelCount},
+		{mono.Input(), ResilienceOptions{FallbackToDefault: true}, nil},
+		{&DeviceInfo{Name: t.Name() + " Unplugged"}, ResilienceOptions{}, DeviceUnavailable},
+	} {
+		// The device has been replaced by one with the same name, which is found by the reconnection.
+		device := *test.device
+		r := &ResilientStream[float32]{
+			params:  &StreamParameters{Input: StreamDeviceParameters{Device: &device, ChannelCount: 2}},
+			options: test.options,
+		}
+		params, err := r.resolveParams()
+		if test.want != nil {
+			if !errors.Is(err, test.want) {
+				t.Errorf("%s: got %v, want %v", device.Name, err, test.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", device.Name, err)
+			continue
+		}
+		if in := params.Input; in.ChannelCount != 2 || in.Device.Index != stereo.Input().Index {
+			t.Errorf("%s: resolved to %s with %d channels", device.Name, in.Device.Name, in.ChannelCount)
+		}
+	}
+}
+
+func TestResilientStreamCloseCancelsReconnect(t *testing.T) {
+	d, r := openFlakyStream(t, ResilienceOptions{RetryInterval: time.Hour})
+	d.disconnect(1 << 30)
+	done := make(chan error)
+	go func() {
+		_, err := r.Read()
+		done <- err
+	}()
+	for len(r.Events()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrStreamLost) {
+		t.Errorf("concurrent Read: %v, want ErrStreamLost", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, DeviceUnavailable) {
+			t.Errorf("Read: %v, want DeviceUnavailable", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close does not cancel the reconnection")
+	}
+	if _, err := r.Read(); !errors.Is(err, BadStreamPtr) {
+		t.Errorf("Read after Close: %v, want BadStreamPtr", err)
+	}
+}
+
+func TestResilientStreamDefaultRetryInterval(t *testing.T) {
+	_, r := openFlakyStream(t, ResilienceOptions{})
+	if r.options.RetryInterval != DefaultRetryInterval {
+		t.Errorf("RetryInterval = %v, want %v", r.options.RetryInterval, DefaultRetryInterval)
+	}
+}
+
+func TestRescanDevicesWithOpenStreams(t *testing.T) {
+	openStreams.Add(1)
+	defer openStreams.Add(-1)
+	if err := RescanDevices(); !errors.Is(err, ErrStreamsOpen) {
+		t.Errorf("RescanDevices: %v, want ErrStreamsOpen", err)
+	}
+}
@@ -0,0 +1,59 @@
+package portaudio
+
+import "sync/atomic"
+
+// ringBuffer is a lock-free single producer, single consumer queue of samples.
+type ringBuffer[T any] struct {
+	buf  []T
+	mask uint64
+	r, w atomic.Uint64
+}
+
+// newRingBuffer creates a ring buffer holding at least size samples.
+func newRingBuffer[T any](size int) *ringBuffer[T] {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &ringBuffer[T]{buf: make([]T, n), mask: uint64(n - 1)}
+}
+
+// len returns the number of samples that can be read.
+func (b *ringBuffer[T]) len() int {
+	return int(b.w.Load() - b.r.Load())
+}
+
+// free returns the number of samples that can be written.
+func (b *ringBuffer[T]) free() int {
+	return len(b.buf) - b.len()
+}
+
+// write appends as many samples of src as fit and returns their number.
+func (b *ringBuffer[T]) write(src []T) int {
+	w := b.w.Load()
+	n := min(len(src), len(b.buf)-int(w-b.r.Load()))
+	i := int(w & b.mask)
+	c := copy(b.buf[i:], src[:n])
+	copy(b.buf, src[c:n])
+	b.w.Store(w + uint64(n))
+	return n
+}
+
+// read removes up to len(dst) samples into dst and returns their number.
+func (b *ringBuffer[T]) read(dst []T) int {
+	r := b.r.Load()
+	n := min(len(dst), int(b.w.Load()-r))
+	i := int(r & b.mask)
+	c := copy(dst[:n], b.buf[i:])
+	copy(dst[c:n], b.buf)
+	b.r.Store(r + uint64(n))
+	return n
+}
+
+// discard removes up to n samples without reading them and returns their number.
+func (b *ringBuffer[T]) discard(n int) int {
+	r := b.r.Load()
+	n = min(n, int(b.w.Load()-r))
+	b.r.Store(r + uint64(n))
+	return n
+}
@@ -0,0 +1,68 @@
+package portaudio
+
+import (
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// Sample is the set of sample types that can be processed numerically.
+// Integer samples are signed except uint8, which is offset binary like UInt8.
+type Sample interface {
+	~int8 | ~uint8 | ~int16 | ~int32 | ~float32 | ~float64
+}
+
+// sampleFormatOf returns the format of device samples holding samples of type T.
+// float64 samples are converted to and from Float32.
+func sampleFormatOf[T Sample]() SampleFormat {
+	switch reflect.TypeFor[T]().Kind() {
+	case reflect.Int8:
+		return Int8
+	case reflect.Uint8:
+		return UInt8
+	case reflect.Int16:
+		return Int16
+	case reflect.Int32:
+		return Int32
+	default:
+		return Float32
+	}
+}
+
+// sampleScale returns the full scale value of integer sample types, or zero for floating point types.
+func sampleScale[T Sample]() float64 {
+	var one T = 1
+	if one/2 != 0 {
+		return 0
+	}
+	var zero T
+	return math.Ldexp(1, 8*int(unsafe.Sizeof(zero))-1)
+}
+
+// ToFloat64 converts a sample to a float64 where the full scale of integer types maps to [-1, 1).
+func ToFloat64[T Sample](v T) float64 {
+	scale := sampleScale[T]()
+	if scale == 0 {
+		return float64(v)
+	}
+	var zero T
+	if zero-1 > 0 {
+		return (float64(v) - scale) / scale
+	}
+	return float64(v) / scale
+}
+
+// FromFloat64 converts a float64 to a sample. Values out of the range of integer types are clipped.
+func FromFloat64[T Sample](v float64) T {
+	scale := sampleScale[T]()
+	if scale == 0 {
+		return T(v)
+	}
+	v = math.Round(v * scale)
+	v = max(-scale, min(scale-1, v))
+	var zero T
+	if zero-1 > 0 {
+		return T(v + scale)
+	}
+	return T(v)
+}
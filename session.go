@@ -0,0 +1,602 @@
+package portaudio
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// A session file starts with sessionMagic and a header:
+//
+//	version            byte
+//	sample format      uvarint
+//	input channels     uvarint
+//	output channels    uvarint
+//	frames per buffer  uvarint
+//	sample rate        float64, little endian
+//
+// followed by one record per callback:
+//
+//	frame count        uvarint
+//	status flags       uvarint
+//	input ADC time     varint, nanoseconds relative to the previous record
+//	current time       varint, nanoseconds relative to the previous record
+//	output DAC time    varint, nanoseconds relative to the previous record
+//	input samples      frame count × input channels samples, interleaved, in the sample format
+const (
+	sessionMagic   = "PASESSION"
+	sessionVersion = 1
+	// maxRecordHeader is the maximum encoded size of the fields preceding the samples of a record.
+	maxRecordHeader = 5 * binary.MaxVarintLen64
+)
+
+// ErrInvalidSession is returned when a session file is malformed.
+var ErrInvalidSession = errors.New("portaudio: invalid session file")
+
+// errNoCallback is returned when recording or replaying a stream without a callback.
+var errNoCallback = errors.New("portaudio: stream has no callback")
+
+// sessionHeader describes the stream a session was recorded from.
+type sessionHeader struct {
+	format          SampleFormat
+	inChannels      int
+	outChannels     int
+	framesPerBuffer int
+	sampleRate      float64
+}
+
+func (h *sessionHeader) write(w io.Writer) error {
+	buf := append([]byte(sessionMagic), sessionVersion)
+	buf = binary.AppendUvarint(buf, uint64(h.format))
+	buf = binary.AppendUvarint(buf, uint64(h.inChannels))
+	buf = binary.AppendUvarint(buf, uint64(h.outChannels))
+	buf = binary.AppendUvarint(buf, uint64(h.framesPerBuffer))
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(h.sampleRate))
+	_, err := w.Write(buf)
+	return err
+}
+
+func (h *sessionHeader) read(r *bufio.Reader) error {
+	magic := make([]byte, len(sessionMagic)+1)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic[:len(sessionMagic)]) != sessionMagic {
+		return ErrInvalidSession
+	}
+	if magic[len(sessionMagic)] != sessionVersion {
+		return fmt.Errorf("%w: version %d", ErrInvalidSession, magic[len(sessionMagic)])
+	}
+	var fields [4]uint64
+	for i := range fields {
+		v, err := binary.ReadUvarint(r)
+		if err != nil || i > 0 && v > math.MaxInt32 {
+			return ErrInvalidSession
+		}
+		fields[i] = v
+	}
+	var rate [8]byte
+	if _, err := io.ReadFull(r, rate[:]); err != nil {
+		return ErrInvalidSession
+	}
+	h.format = SampleFormat(fields[0])
+	h.inChannels, h.outChannels, h.framesPerBuffer = int(fields[1]), int(fields[2]), int(fields[3])
+	h.sampleRate = math.Float64frombits(binary.LittleEndian.Uint64(rate[:]))
+	if sampleBytes(h.format) == 0 || h.sampleRate <= 0 {
+		return ErrInvalidSession
+	}
+	return nil
+}
+
+// SessionRecorder records the callbacks of a stream: the input samples, frame count,
+// time information and status flags of every callback. Recording does not block the
+// callback. The records are queued and written by a separate goroutine; if the queue
+// is full, records are dropped and counted.
+type SessionRecorder struct {
+	w       *bufio.Writer
+	header  sessionHeader
+	queue   *ringBuffer[byte]
+	scratch []byte
+	last    [3]time.Duration
+	dropped atomic.Uint64
+	wake    chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+// NewSessionRecorder creates a recorder writing to w. It queues up to size bytes
+// of records, where zero selects 4MB.
+func NewSessionRecorder(w io.Writer, size int) *SessionRecorder {
+	if size <= 0 {
+		size = 4 << 20
+	}
+	return &SessionRecorder{
+		w:     bufio.NewWriter(w),
+		queue: newRingBuffer[byte](size),
+		wake:  make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Record starts recording the callbacks of the stream with r. It must be called before
+// the stream is started and only once per recorder. Stream callbacks on virtual devices
+// are recorded as well. Record(nil) stops recording.
+func (s *Stream[T]) Record(r *SessionRecorder) error {
+	if r == nil {
+		s.recorder.Store(nil)
+		return nil
+	}
+	if s.callback == nil {
+		return streamError("record", s.params.device(), s.params, errNoCallback)
+	}
+	r.header = sessionHeader{
+		format:          s.params.SampleFormat,
+		framesPerBuffer: int(s.params.FramesPerBuffer),
+		sampleRate:      s.params.SampleRate,
+	}
+	if s.params.Input.Exists() {
+		r.header.inChannels = s.params.Input.ChannelCount
+	}
+	if s.params.Output.Exists() {
+		r.header.outChannels = s.params.Output.ChannelCount
+	}
+	if sampleBytes(r.header.format) == 0 {
+		return streamError("record", s.params.device(), s.params, SampleFormatNotSupported)
+	}
+	if err := r.header.write(r.w); err != nil {
+		return streamError("record", s.params.device(), s.params, err)
+	}
+	go r.run()
+	s.recorder.Store(r)
+	return nil
+}
+
+// record queues a callback. It is called by the stream callback before the user callback.
+func (r *SessionRecorder) record(in unsafe.Pointer, frames int, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	size := sampleBytes(r.header.format)
+	dataSize := 0
+	if in != nil {
+		dataSize = frames * r.header.inChannels * size
+	}
+	r.scratch = resize(r.scratch, maxRecordHeader+dataSize)
+	buf := binary.AppendUvarint(r.scratch[:0], uint64(frames))
+	buf = binary.AppendUvarint(buf, uint64(flags))
+	for i, t := range [3]time.Duration{timeInfo.InputBufferAdcTime, timeInfo.CurrentTime, timeInfo.OutputBufferDacTime} {
+		buf = binary.AppendVarint(buf, int64(t-r.last[i]))
+		r.last[i] = t
+	}
+	if r.queue.free() < len(buf)+dataSize {
+		r.dropped.Add(1)
+		return
+	}
+	if in != nil {
+		if r.header.format.IsNonInterleaved() {
+			// Interleave the channels.
+			channels := unsafe.Slice((*unsafe.Pointer)(in), r.header.inChannels)
+			data := buf[len(buf) : len(buf)+dataSize]
+			for f := range frames {
+				for c, p := range channels {
+					copy(data[(f*len(channels)+c)*size:], unsafe.Slice((*byte)(unsafe.Add(p, f*size)), size))
+				}
+			}
+			buf = buf[:len(buf)+dataSize]
+		} else {
+			buf = append(buf, unsafe.Slice((*byte)(in), dataSize)...)
+		}
+	}
+	r.queue.write(buf)
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queued records until the recorder is closed.
+func (r *SessionRecorder) run() {
+	defer close(r.done)
+	buf := make([]byte, 64<<10)
+	for {
+		select {
+		case <-r.wake:
+		case <-r.quit:
+			r.drain(buf)
+			return
+		}
+		r.drain(buf)
+	}
+}
+
+func (r *SessionRecorder) drain(buf []byte) {
+	for {
+		n := r.queue.read(buf)
+		if n == 0 {
+			return
+		}
+		if _, err := r.w.Write(buf[:n]); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+// Dropped returns the number of callbacks that were not recorded because the queue was full.
+func (r *SessionRecorder) Dropped() int {
+	return int(r.dropped.Load())
+}
+
+// Close writes the remaining records and flushes the output. The stream must be stopped.
+func (r *SessionRecorder) Close() error {
+	select {
+	case <-r.quit:
+	default:
+		close(r.quit)
+	}
+	<-r.done
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// ReplayOptions configures OpenReplayStream.
+type ReplayOptions struct {
+	// Realtime paces the callbacks like the recorded session instead of running them as fast as possible.
+	Realtime bool
+	// NoOutput opens the stream without output even if the recorded stream had one.
+	NoOutput bool
+}
+
+// OpenReplayStream opens a stream that replays a recorded session: the callback is called
+// with exactly the recorded input samples, frame counts, time information and status flags.
+// The stream has the sample format, sample rate and channel counts of the recorded stream and
+// runs on a virtual device that is not listed by Device. Output samples are discarded.
+// The stream finishes after the last record.
+func OpenReplayStream[T any](
+	r io.Reader,
+	options ReplayOptions,
+	callback func(*Stream[T]) StreamCallbackResult,
+	finishedCallback func(*Stream[T]),
+) (*Stream[T], error) {
+	replay := &replayDevice{r: bufio.NewReader(r), options: options}
+	if err := replay.header.read(replay.r); err != nil {
+		return nil, err
+	}
+	h := replay.header
+	if options.NoOutput {
+		h.outChannels = 0
+	}
+	device := &DeviceInfo{
+		Index:             -1,
+		Name:              "Replay",
+		MaxInputChannels:  h.inChannels,
+		MaxOutputChannels: h.outChannels,
+		DefaultSampleRate: h.sampleRate,
+		HostApi:           &HostApiInfo{Type: InDevelopment, Name: "Replay"},
+		virtual:           replay,
+	}
+	params := &StreamParameters{
+		SampleRate:      h.sampleRate,
+		SampleFormat:    h.format,
+		FramesPerBuffer: uint64(h.framesPerBuffer),
+	}
+	if h.inChannels > 0 {
+		params.Input = StreamDeviceParameters{Device: device, ChannelCount: h.inChannels}
+	}
+	if h.outChannels > 0 {
+		params.Output = StreamDeviceParameters{Device: device, ChannelCount: h.outChannels}
+	}
+	return OpenStream(params, callback, finishedCallback)
+}
+
+// ReplaySession replays a recorded session through callback, see OpenReplayStream,
+// and returns once all records have been replayed. It returns the error that made
+// the replay fail, including a *CallbackPanicError if the callback panicked.
+func ReplaySession[T any](r io.Reader, options ReplayOptions, callback func(*Stream[T]) StreamCallbackResult) error {
+	done := make(chan struct{})
+	s, err := OpenReplayStream(r, options, callback, func(*Stream[T]) { close(done) })
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err = s.Start(); err != nil {
+		return err
+	}
+	<-done
+	if err = s.Err(); err != nil {
+		return err
+	}
+	return s.backend.(*replayStream).err
+}
+
+// replayDevice is the virtual device of a replay stream.
+type replayDevice struct {
+	r       *bufio.Reader
+	header  sessionHeader
+	options ReplayOptions
+}
+
+func (d *replayDevice) checkFormat(params *StreamParameters) error {
+	if params.Input.Exists() && params.Input.ChannelCount != d.header.inChannels ||
+		params.Output.Exists() && params.Output.ChannelCount > d.header.outChannels {
+		return InvalidChannelCount
+	}
+	if params.SampleFormat != d.header.format {
+		return SampleFormatNotSupported
+	}
+	if params.SampleRate != d.header.sampleRate {
+		return InvalidSampleRate
+	}
+	return nil
+}
+
+func (d *replayDevice) open(params *StreamParameters, target callbackTarget, callback bool) (backend, error) {
+	if err := d.checkFormat(params); err != nil {
+		return nil, err
+	}
+	if !callback {
+		return nil, errNoCallback
+	}
+	s := &replayStream{device: d, target: target, isStop: true}
+	if params.Output.Exists() {
+		s.outChannels = params.Output.ChannelCount
+	}
+	return s, nil
+}
+
+// replayStream is the backend of a replay stream.
+type replayStream struct {
+	device           *replayDevice
+	target           callbackTarget
+	outChannels      int
+	in, out          deviceBuffer
+	inFrames         int // capacity of in
+	outFrames        int // capacity of out
+	data             []byte
+	times            [3]time.Duration // of the last record
+	records          int
+	first            time.Duration // current time of the first record
+	started          time.Time     // when the first record is due in Realtime mode
+	current          atomic.Int64
+	err              error
+	mu               sync.Mutex
+	isActive, isStop bool
+	finished         bool
+	quit, done       chan struct{}
+	inCallback       atomic.Bool // set by run while it calls the callback or the finished callback
+
+	// pending is a record read but not yet replayed because the stream was stopped.
+	pending bool
+	frames  int
+	flags   StreamCallbackFlags
+	inPtr   unsafe.Pointer
+	outPtr  unsafe.Pointer
+}
+
+func (s *replayStream) start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStop {
+		return StreamIsNotStopped
+	}
+	if done := s.done; done != nil && !s.inCallback.Load() {
+		// A callback that stopped the stream may still be returning.
+		s.mu.Unlock()
+		<-done
+		s.mu.Lock()
+		if !s.isStop {
+			return StreamIsNotStopped
+		}
+	}
+	s.isActive, s.isStop = true, false
+	// Realtime pacing resumes where the stream was stopped.
+	s.started = time.Now()
+	if s.records > 0 {
+		s.started = s.started.Add(-time.Duration(s.current.Load()) + s.first)
+	}
+	s.quit, s.done = make(chan struct{}), make(chan struct{})
+	go s.run(s.quit, s.done)
+	return nil
+}
+
+func (s *replayStream) run(quit, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		s.inCallback.Store(true)
+		defer s.inCallback.Store(false)
+		s.target.finished()
+	}()
+	defer func() {
+		s.mu.Lock()
+		s.isActive = false
+		s.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		if s.finished {
+			return
+		}
+		result, err := s.next(quit)
+		if err != nil {
+			s.finished = true
+			if err != io.EOF {
+				s.err = err
+			}
+			return
+		}
+		if result != Continue {
+			return
+		}
+	}
+}
+
+// next replays a record. In Realtime mode it waits until the record is due
+// and returns Continue without replaying it if quit is closed meanwhile.
+func (s *replayStream) next(quit chan struct{}) (StreamCallbackResult, error) {
+	if !s.pending {
+		if err := s.load(); err != nil {
+			return Abort, err
+		}
+		s.pending = true
+	}
+	if s.device.options.Realtime {
+		if wait := time.Until(s.started.Add(s.times[1] - s.first)); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-quit:
+				return Continue, nil
+			case <-timer.C:
+			}
+		}
+	}
+	s.pending = false
+	s.current.Store(int64(s.times[1]))
+	timeInfo := callbackTimeInfo(s.times[0], s.times[1], s.times[2])
+	s.inCallback.Store(true)
+	defer s.inCallback.Store(false)
+	return s.target.Callback(s.inPtr, s.outPtr, C.ulong(s.frames), &timeInfo, C.PaStreamCallbackFlags(s.flags)), nil
+}
+
+// load reads the next record.
+func (s *replayStream) load() error {
+	h := &s.device.header
+	r := s.device.r
+	frames, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return ErrInvalidSession
+	}
+	flags, err := binary.ReadUvarint(r)
+	if err != nil || frames > math.MaxInt32 {
+		return ErrInvalidSession
+	}
+	for i := range s.times {
+		delta, err := binary.ReadVarint(r)
+		if err != nil {
+			return ErrInvalidSession
+		}
+		s.times[i] += time.Duration(delta)
+	}
+	n := int(frames)
+	var in, out unsafe.Pointer
+	if h.inChannels > 0 {
+		size := sampleBytes(h.format)
+		s.data = resize(s.data, n*h.inChannels*size)
+		if _, err := io.ReadFull(r, s.data); err != nil {
+			return ErrInvalidSession
+		}
+		if h.format.IsNonInterleaved() {
+			if n > s.inFrames {
+				s.in, s.inFrames = allocDeviceBuffer(h.format, h.inChannels, n), n
+			}
+			// Deinterleave the channels.
+			for c := range h.inChannels {
+				p, _ := s.in.channel(c)
+				dst := unsafe.Slice((*byte)(p), n*size)
+				for f := range n {
+					copy(dst[f*size:(f+1)*size], s.data[(f*h.inChannels+c)*size:])
+				}
+			}
+			in = s.in.ptr
+		} else if n > 0 {
+			in = unsafe.Pointer(unsafe.SliceData(s.data))
+		}
+	}
+	if s.outChannels > 0 {
+		if n > s.outFrames {
+			s.out, s.outFrames = allocDeviceBuffer(h.format, s.outChannels, n), n
+		}
+		out = s.out.ptr
+	}
+	if s.records == 0 {
+		s.first = s.times[1]
+	}
+	s.records++
+	s.frames, s.flags, s.inPtr, s.outPtr = n, StreamCallbackFlags(flags), in, out
+	return nil
+}
+
+func (s *replayStream) stop() error {
+	return s.halt()
+}
+
+func (s *replayStream) abort() error {
+	return s.halt()
+}
+
+func (s *replayStream) halt() error {
+	s.mu.Lock()
+	if s.isStop {
+		s.mu.Unlock()
+		return StreamIsStopped
+	}
+	s.isStop, s.isActive = true, false
+	quit, done := s.quit, s.done
+	s.mu.Unlock()
+	close(quit)
+	// Called from the callback, the stream ends when the callback returns.
+	if !s.inCallback.Load() {
+		<-done
+	}
+	return nil
+}
+
+func (s *replayStream) close() error {
+	if !s.stopped() {
+		return s.halt()
+	}
+	return nil
+}
+
+func (s *replayStream) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isActive
+}
+
+func (s *replayStream) stopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isStop
+}
+
+// time returns the current time of the last replayed record.
+func (s *replayStream) time() time.Duration {
+	return time.Duration(s.current.Load())
+}
+
+func (s *replayStream) info() *StreamInfo {
+	return &StreamInfo{SampleRate: s.device.header.sampleRate}
+}
+
+func (s *replayStream) read(unsafe.Pointer, int) error {
+	return errNoCallback
+}
+
+func (s *replayStream) write(unsafe.Pointer, int) error {
+	return errNoCallback
+}
+
+func (s *replayStream) readAvailable() int {
+	return 0
+}
+
+func (s *replayStream) writeAvailable() int {
+	return 0
+}
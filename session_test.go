@@ -0,0 +1,248 @@
+package portaudio
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// callbackRecord is what a callback was called with.
+type callbackRecord struct {
+	frames   int
+	flags    StreamCallbackFlags
+	timeInfo StreamCallbackTimeInfo
+	in       []int16 // interleaved
+}
+
+func captureCallback(s *Stream[int16], records *[]callbackRecord) {
+	r := callbackRecord{frames: s.FrameCount(), flags: s.StatusFlags(), timeInfo: s.TimeInfo()}
+	if in := s.InS(); in != nil {
+		for f := range s.FrameCount() {
+			for c := range in {
+				r.in = append(r.in, in[c][f])
+			}
+		}
+	} else {
+		r.in = slices.Clone(s.In())
+	}
+	*records = append(*records, r)
+}
+
+func TestRecordAndReplaySession(t *testing.T) {
+	for _, format := range []SampleFormat{Int16, Int16 | NonInterleaved} {
+		t.Run(format.String(), func(t *testing.T) {
+			l := NewLoopback(LoopbackOptions{Name: t.Name(), Channels: 2, Noise: 0.01, Xruns: 0.3, Seed: 1})
+			t.Cleanup(l.Close)
+			params := &StreamParameters{
+				Input:           StreamDeviceParameters{Device: l.Input(), ChannelCount: 2},
+				Output:          StreamDeviceParameters{Device: l.Output(), ChannelCount: 2},
+				SampleRate:      48000,
+				SampleFormat:    format,
+				FramesPerBuffer: 256,
+			}
+			var recorded []callbackRecord
+			done := make(chan struct{})
+			s, err := OpenStream(params, func(s *Stream[int16]) StreamCallbackResult {
+				captureCallback(s, &recorded)
+				if out := s.OutS(); out != nil {
+					for c := range out {
+						for f := range out[c] {
+							out[c][f] = int16(1000*len(recorded) + f*(c+1))
+						}
+					}
+				} else {
+					for i := range s.Out() {
+						s.Out()[i] = int16(1000*len(recorded) + i)
+					}
+				}
+				if len(recorded) == 20 {
+					return Complete
+				}
+				return Continue
+			}, func(*Stream[int16]) { close(done) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			var session bytes.Buffer
+			recorder := NewSessionRecorder(&session, 0)
+			if err = s.Record(recorder); err != nil {
+				t.Fatal(err)
+			}
+			if err = s.Start(); err != nil {
+				t.Fatal(err)
+			}
+			<-done
+			if err = recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if n := recorder.Dropped(); n != 0 {
+				t.Fatalf("%d callbacks dropped", n)
+			}
+
+			var replayed []callbackRecord
+			err = ReplaySession(bytes.NewReader(session.Bytes()), ReplayOptions{}, func(s *Stream[int16]) StreamCallbackResult {
+				captureCallback(s, &replayed)
+				return Continue
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(replayed) != len(recorded) {
+				t.Fatalf("replayed %d callbacks, want %d", len(replayed), len(recorded))
+			}
+			flagged := false
+			for i, want := range recorded {
+				got := replayed[i]
+				if got.frames != want.frames || got.flags != want.flags || got.timeInfo != want.timeInfo {
+					t.Errorf("callback %d: got %d frames, flags %v, %+v; want %d frames, flags %v, %+v",
+						i, got.frames, got.flags, got.timeInfo, want.frames, want.flags, want.timeInfo)
+				}
+				if !slices.Equal(got.in, want.in) {
+					t.Errorf("callback %d: input differs", i)
+				}
+				flagged = flagged || want.flags != 0
+			}
+			if !flagged {
+				t.Error("no xrun recorded, the flags are not tested")
+			}
+		})
+	}
+}
+
+// syntheticSession returns a mono Int16 session of one-frame records with the given current times.
+func syntheticSession(t *testing.T, times ...time.Duration) []byte {
+	t.Helper()
+	var session bytes.Buffer
+	r := NewSessionRecorder(&session, 0)
+	r.header = sessionHeader{format: Int16, inChannels: 1, framesPerBuffer: 1, sampleRate: 48000}
+	if err := r.header.write(r.w); err != nil {
+		t.Fatal(err)
+	}
+	go r.run()
+	for i, at := range times {
+		sample := int16(i)
+		r.record(unsafe.Pointer(&sample), 1, StreamCallbackTimeInfo{CurrentTime: at}, 0)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return session.Bytes()
+}
+
+func TestReplayRealtimeStop(t *testing.T) {
+	session := syntheticSession(t, 0, time.Hour)
+	called := make(chan struct{}, 2)
+	s, err := OpenReplayStream(bytes.NewReader(session), ReplayOptions{Realtime: true}, func(s *Stream[int16]) StreamCallbackResult {
+		called <- struct{}{}
+		return Continue
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-called
+	// The second record is due in an hour.
+	stopped := make(chan error)
+	go func() { stopped <- s.Stop() }()
+	select {
+	case err = <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop waited for the next record")
+	}
+	if len(called) != 0 {
+		t.Error("the callback was called after stopping")
+	}
+}
+
+func TestReplayStopFromCallback(t *testing.T) {
+	session := syntheticSession(t, 0, time.Millisecond, 2*time.Millisecond)
+	var calls atomic.Int32
+	result := make(chan error, 1)
+	finished := make(chan struct{}, 2)
+	s, err := OpenReplayStream(bytes.NewReader(session), ReplayOptions{}, func(s *Stream[int16]) StreamCallbackResult {
+		if calls.Add(1) == 1 {
+			result <- s.Stop()
+		}
+		return Continue
+	}, func(*Stream[int16]) { finished <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop from the callback did not return")
+	}
+	<-finished
+	if n := calls.Load(); n != 1 {
+		t.Errorf("callback called %d times, want 1", n)
+	}
+	// Restarting replays the remaining records.
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-finished
+	if n := calls.Load(); n != 3 {
+		t.Errorf("callback called %d times, want 3", n)
+	}
+}
+
+func TestReplayStopFromFinishedCallback(t *testing.T) {
+	session := syntheticSession(t, 0, time.Millisecond)
+	result := make(chan error, 1)
+	s, err := OpenReplayStream(bytes.NewReader(session), ReplayOptions{}, func(s *Stream[int16]) StreamCallbackResult {
+		return Complete
+	}, func(s *Stream[int16]) { result <- s.Stop() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop from the finished callback did not return")
+	}
+	if !s.IsStopped() {
+		t.Error("stream not stopped")
+	}
+}
+
+func TestReplayInvalidSession(t *testing.T) {
+	session := syntheticSession(t, 0, time.Millisecond)
+	err := ReplaySession(bytes.NewReader(session[:len(session)-1]), ReplayOptions{}, func(*Stream[int16]) StreamCallbackResult {
+		return Continue
+	})
+	if !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("truncated session: got %v, want %v", err, ErrInvalidSession)
+	}
+	err = ReplaySession(bytes.NewReader([]byte("not a session")), ReplayOptions{}, func(*Stream[int16]) StreamCallbackResult {
+		return Continue
+	})
+	if !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("garbage: got %v, want %v", err, ErrInvalidSession)
+	}
+}
@@ -0,0 +1,92 @@
+package portaudio
+
+import (
+	"encoding/binary"
+	"io"
+	"unsafe"
+)
+
+// Source produces interleaved samples on demand, e.g. from a decoded file or a generator.
+type Source[T Sample] interface {
+	// ReadSamples fills buf with samples and returns their number.
+	// It returns io.EOF once the source is exhausted.
+	ReadSamples(buf []T) (int, error)
+}
+
+// BufferSource is a Source playing samples held in memory.
+type BufferSource[T Sample] struct {
+	samples []T
+	pos     int
+}
+
+// NewBufferSource creates a source playing the given interleaved samples once.
+func NewBufferSource[T Sample](samples []T) *BufferSource[T] {
+	return &BufferSource[T]{samples: samples}
+}
+
+func (s *BufferSource[T]) ReadSamples(buf []T) (int, error) {
+	n := copy(buf, s.samples[s.pos:])
+	s.pos += n
+	if s.pos == len(s.samples) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Rewind restarts the source from the beginning.
+func (s *BufferSource[T]) Rewind() {
+	s.pos = 0
+}
+
+// ReaderSource is a Source decoding headerless samples of type T from an io.Reader.
+type ReaderSource[T Sample] struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   []byte
+}
+
+// NewReaderSource creates a source reading samples in the given byte order from r.
+func NewReaderSource[T Sample](r io.Reader, order binary.ByteOrder) *ReaderSource[T] {
+	return &ReaderSource[T]{r: r, order: order}
+}
+
+func (s *ReaderSource[T]) ReadSamples(buf []T) (int, error) {
+	size := int(unsafe.Sizeof(buf[0]))
+	s.buf = resize(s.buf, len(buf)*size)
+	n, err := io.ReadFull(s.r, s.buf)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	n /= size
+	decodeSamples(buf[:n], s.buf, s.order)
+	return n, err
+}
+
+// decodeSamples decodes samples of type T from src in the given byte order.
+func decodeSamples[T Sample](dst []T, src []byte, order binary.ByteOrder) {
+	size := int(unsafe.Sizeof(*new(T)))
+	raw := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(dst))), len(dst)*size)
+	copy(raw, src)
+	if nativeOrder(order) {
+		return
+	}
+	for i := 0; i < len(raw); i += size {
+		for a, b := i, i+size-1; a < b; a, b = a+1, b-1 {
+			raw[a], raw[b] = raw[b], raw[a]
+		}
+	}
+}
+
+// nativeOrder reports whether order is the byte order of the host.
+func nativeOrder(order binary.ByteOrder) bool {
+	probe := [2]byte{}
+	binary.NativeEndian.PutUint16(probe[:], 1)
+	return order.Uint16(probe[:]) == 1
+}
+
+// FuncSource is a Source calling a function, e.g. a generator.
+type FuncSource[T Sample] func(buf []T) (int, error)
+
+func (f FuncSource[T]) ReadSamples(buf []T) (int, error) {
+	return f(buf)
+}
@@ -0,0 +1,52 @@
+package portaudio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestBufferSource(t *testing.T) {
+	s := NewBufferSource([]int16{1, 2, 3, 4, 5})
+	buf := make([]int16, 3)
+	if n, err := s.ReadSamples(buf); n != 3 || err != nil || !slices.Equal(buf, []int16{1, 2, 3}) {
+		t.Fatalf("ReadSamples = %d, %v, %v", n, err, buf)
+	}
+	if n, err := s.ReadSamples(buf); n != 2 || err != io.EOF || !slices.Equal(buf[:n], []int16{4, 5}) {
+		t.Fatalf("ReadSamples at the end = %d, %v, %v", n, err, buf[:n])
+	}
+	s.Rewind()
+	if n, _ := s.ReadSamples(buf); n != 3 || buf[0] != 1 {
+		t.Errorf("ReadSamples after Rewind = %d, %v", n, buf)
+	}
+}
+
+func TestReaderSource(t *testing.T) {
+	want := []int32{1, -2, 1 << 20}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var b bytes.Buffer
+		_ = binary.Write(&b, order, want)
+		b.WriteByte(0) // a partial sample is dropped
+		s := NewReaderSource[int32](&b, order)
+		buf := make([]int32, 4)
+		n, err := s.ReadSamples(buf)
+		if n != 3 || err != io.EOF || !slices.Equal(buf[:n], want) {
+			t.Errorf("%v: ReadSamples = %d, %v, %v", order, n, err, buf[:n])
+		}
+	}
+}
+
+func TestFuncSource(t *testing.T) {
+	var s Source[float32] = FuncSource[float32](func(buf []float32) (int, error) {
+		for i := range buf {
+			buf[i] = float32(i)
+		}
+		return len(buf), nil
+	})
+	buf := make([]float32, 3)
+	if n, err := s.ReadSamples(buf); n != 3 || err != nil || buf[2] != 2 {
+		t.Errorf("ReadSamples = %d, %v, %v", n, err, buf)
+	}
+}
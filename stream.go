@@ -2,13 +2,22 @@ package portaudio
 
 /*
 #cgo pkg-config: portaudio-2.0
+#include <stdint.h>
+#include <stdlib.h>
 #include <portaudio.h>
 extern PaStreamCallback* paStreamCallback;
 extern PaStreamFinishedCallback* paStreamFinishedCallback;
+
+static void* handleToUserData(uintptr_t h) {
+	return (void*)h;
+}
 */
 import "C"
 import (
+	"log/slog"
 	"runtime/cgo"
+	"runtime/debug"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
@@ -42,6 +51,7 @@ const (
 	Int16          SampleFormat = C.paInt16
 	Int8           SampleFormat = C.paInt8
 	UInt8          SampleFormat = C.paUInt8
+	CustomFormat   SampleFormat = C.paCustomFormat
 )
 
 const FramesPerBufferUnspecified = C.paFramesPerBufferUnspecified
@@ -109,8 +119,23 @@ type StreamDeviceParameters struct {
 	Device           *DeviceInfo
 	ChannelCount     int
 	SuggestedLatency time.Duration
+	// HostApiSpecificStreamInfo optionally carries host API specific parameters,
+	// see the host API subpackages (e.g. alsa).
+	HostApiSpecificStreamInfo HostApiSpecificStreamInfo
 }
 
+// HostApiSpecificStreamInfo is implemented by the host API specific stream parameters
+// of the host API subpackages.
+type HostApiSpecificStreamInfo interface {
+	// StreamInfo returns a pointer to the C structure passed to PortAudio.
+	// The structure must be allocated in C memory.
+	StreamInfo() unsafe.Pointer
+}
+
+// UseHostApiSpecificDeviceSpecification is a device index indicating that
+// the device is specified by the HostApiSpecificStreamInfo of the stream parameters.
+const UseHostApiSpecificDeviceSpecification = C.paUseHostApiSpecificDeviceSpecification
+
 func (p StreamDeviceParameters) Exists() bool {
 	return p.Device != nil
 }
@@ -124,6 +149,16 @@ type StreamParameters struct {
 	FramesPerBuffer uint64
 	Flags           StreamFlags
 	RawOutput       bool
+	// Logger receives the stream events. If it is nil, the package logger is used.
+	Logger *slog.Logger
+}
+
+// device returns the input device of the stream or the output device for output-only streams.
+func (p *StreamParameters) device() *DeviceInfo {
+	if p.Input.Exists() {
+		return p.Input.Device
+	}
+	return p.Output.Device
 }
 
 type StreamInfo struct {
@@ -132,21 +167,36 @@ type StreamInfo struct {
 }
 
 type Stream[T any] struct {
-	paStream         unsafe.Pointer
-	params           *StreamParameters
-	in, out          []T
-	inS, outS        [][]T
-	inSize, outSize  int
-	frameCount       int
-	timeInfo         StreamCallbackTimeInfo
-	statusFlags      StreamCallbackFlags
-	callback         func(*Stream[T]) StreamCallbackResult
-	finishedCallback func(*Stream[T])
+	paStream          unsafe.Pointer
+	handle            cgo.Handle
+	params            *StreamParameters
+	in, out           []T
+	inS, outS         [][]T
+	inSize, outSize   int
+	frameCount        int
+	timeInfo          StreamCallbackTimeInfo
+	statusFlags       StreamCallbackFlags
+	callback          func(*Stream[T]) StreamCallbackResult
+	finishedCallback  func(*Stream[T])
+	xrunLimiter       rateLimiter
+	convert           bool
+	devOut            unsafe.Pointer
+	blockIn, blockOut []float32
+	planarIn          unsafe.Pointer // device buffers of blocking NonInterleaved streams in C memory
+	planarOut         unsafe.Pointer
+	err               atomic.Pointer[CallbackPanicError]
+	watchdog          callbackWatchdog
+	backend           backend // runs streams on virtual devices
+	recorder          atomic.Pointer[SessionRecorder]
 }
 
 func newStream[T any](params *StreamParameters) *Stream[T] {
 	return &Stream[T]{
-		params: params,
+		params:      params,
+		xrunLimiter: rateLimiter{interval: xrunLogInterval},
+		watchdog: callbackWatchdog{
+			limiter: rateLimiter{interval: xrunLogInterval},
+		},
 	}
 }
 
@@ -157,7 +207,13 @@ func OpenStream[T any](
 	finishedCallback func(*Stream[T]),
 ) (*Stream[T], error) {
 	s := newStream[T](params)
-	return s, s.init(params, callback, finishedCallback)
+	if err := s.init(params, callback, finishedCallback); err != nil {
+		err = streamError("open", params.device(), params, err)
+		logError(s.logger(), "stream open failed", err, slog.Any("params", params))
+		return s, err
+	}
+	s.logger().Info("stream opened", slog.Any("params", params), slog.Bool("callback", callback != nil))
+	return s, nil
 }
 
 func (s *Stream[T]) init(
@@ -165,27 +221,21 @@ func (s *Stream[T]) init(
 	callback func(*Stream[T]) StreamCallbackResult,
 	finishedCallback func(*Stream[T]),
 ) error {
-	inParams := paStreamParameters(params.Input, params.SampleFormat)
-	outParams := paStreamParameters(params.Output, params.SampleFormat)
-	scb := C.paStreamCallback
-	if callback == nil {
-		scb = nil
-	} else {
+	if err := checkSampleType[T](params); err != nil {
+		return err
+	}
+	if callback != nil {
 		s.callback = callback
 	}
-	sid := cgo.NewHandle(s)
-	err := goError(
-		C.Pa_OpenStream(
-			&s.paStream,
-			inParams,
-			outParams,
-			C.double(params.SampleRate),
-			C.ulong(params.FramesPerBuffer),
-			C.PaStreamFlags(params.Flags),
-			scb,
-			unsafe.Pointer(sid),
-		),
-	)
+	device, err := params.virtualDevice()
+	if err != nil {
+		return err
+	}
+	if device != nil {
+		s.backend, err = device.open(params, s, callback != nil)
+	} else {
+		err = s.openPaStream(params)
+	}
 	if err != nil {
 		return err
 	}
@@ -198,6 +248,7 @@ func (s *Stream[T]) init(
 	if params.RawOutput {
 		sampleSize = SampleSize(params.SampleFormat)
 	}
+	s.convert = needsConversion[T](params)
 	if callback != nil {
 		if params.SampleFormat.IsNonInterleaved() {
 			if params.Input.Exists() {
@@ -219,21 +270,102 @@ func (s *Stream[T]) init(
 		return nil
 	}
 	size := sampleSize * int(params.FramesPerBuffer)
+	if params.SampleFormat.IsNonInterleaved() {
+		if params.Input.Exists() {
+			s.inS = makePlanar[T](params.Input.ChannelCount, size)
+			s.planarIn = allocPlanar(params.Input.ChannelCount, size*s.deviceSampleSize())
+		}
+		if params.Output.Exists() {
+			s.outS = makePlanar[T](params.Output.ChannelCount, size)
+			s.planarOut = allocPlanar(params.Output.ChannelCount, size*s.deviceSampleSize())
+		}
+		return nil
+	}
 	if params.Input.Exists() {
 		s.in = make([]T, size*params.Input.ChannelCount)
+		if s.convert {
+			s.blockIn = make([]float32, len(s.in))
+		}
 	}
 	if params.Output.Exists() {
 		s.out = make([]T, size*params.Output.ChannelCount)
+		if s.convert {
+			s.blockOut = make([]float32, len(s.out))
+		}
 	}
 	return nil
 }
 
+// openPaStream opens the PortAudio stream.
+func (s *Stream[T]) openPaStream(params *StreamParameters) error {
+	scb := C.paStreamCallback
+	if s.callback == nil {
+		scb = nil
+	}
+	s.handle = cgo.NewHandle(callbackTarget(s))
+	err := goError(
+		C.Pa_OpenStream(
+			&s.paStream,
+			paStreamParameters(params.Input, params.SampleFormat),
+			paStreamParameters(params.Output, params.SampleFormat),
+			C.double(params.SampleRate),
+			C.ulong(params.FramesPerBuffer),
+			C.PaStreamFlags(params.Flags),
+			scb,
+			C.handleToUserData(C.uintptr_t(s.handle)),
+		),
+	)
+	if err != nil {
+		s.handle.Delete()
+		s.handle = 0
+		return err
+	}
+	openStreams.Add(1)
+	return nil
+}
+
+// allocPlanar allocates in C memory an array of pointers to channels buffers
+// of the given size in bytes followed by the buffers, so that it can be passed to PortAudio.
+func allocPlanar(channels, size int) unsafe.Pointer {
+	ptrSize := int(unsafe.Sizeof(unsafe.Pointer(nil)))
+	p := C.calloc(C.size_t(channels), C.size_t(ptrSize+size))
+	for c, ptrs := 0, unsafe.Slice((*unsafe.Pointer)(p), channels); c < channels; c++ {
+		ptrs[c] = unsafe.Add(p, channels*ptrSize+c*size)
+	}
+	return p
+}
+
+// freeBuffers releases the device buffers of blocking NonInterleaved streams.
+func (s *Stream[T]) freeBuffers() {
+	C.free(s.planarIn)
+	C.free(s.planarOut)
+	s.planarIn, s.planarOut = nil, nil
+}
+
+// paStreamPtr returns the underlying PortAudio stream for the host API subpackages,
+// see package hostapi. It returns nil for streams on virtual devices.
+func (s *Stream[T]) paStreamPtr() unsafe.Pointer {
+	return s.paStream
+}
+
+// paParams returns the parameters of the PortAudio stream for the host API subpackages,
+// see package hostapi. It returns nil for streams on virtual devices.
+func (s *Stream[T]) paParams() *StreamParameters {
+	if s.paStream == nil {
+		return nil
+	}
+	return s.params
+}
+
 // IsActive determines whether the stream is active. A stream is active after
 // a successful call to Start(), until it becomes inactive either as
 // a result of a call to Stop() or Abort(), or as a result of a return value other
 // than Continue from the stream callback. In the latter case, the stream is considered
 // inactive after the last buffer has finished playing.
 func (s *Stream[T]) IsActive() bool {
+	if s.backend != nil {
+		return s.backend.active()
+	}
 	return int(C.Pa_IsStreamActive(s.paStream)) == 1
 }
 
@@ -241,6 +373,9 @@ func (s *Stream[T]) IsActive() bool {
 // prior to a successful call to Start() and after a successful call to Stop() or Abort().
 // If a stream callback returns a value other than Continue the stream is NOT considered to be stopped.
 func (s *Stream[T]) IsStopped() bool {
+	if s.backend != nil {
+		return s.backend.stopped()
+	}
 	return int(C.Pa_IsStreamStopped(s.paStream)) == 1
 }
 
@@ -250,17 +385,26 @@ func (s *Stream[T]) IsStopped() bool {
 // but not limited to the client supplied stream callback.
 // This function does not work with blocking read/write streams.
 func (s *Stream[T]) CpuLoad() float64 {
+	if s.backend != nil {
+		return 0
+	}
 	return float64(C.Pa_GetStreamCpuLoad(s.paStream))
 }
 
 // Time returns the current time in seconds for a lifespan of a stream.
 // Starting and stopping the stream does not affect the passage of time.
 func (s *Stream[T]) Time() time.Duration {
+	if s.backend != nil {
+		return s.backend.time()
+	}
 	return duration(C.Pa_GetStreamTime(s.paStream))
 }
 
 // Info returns information about the stream.
 func (s *Stream[T]) Info() *StreamInfo {
+	if s.backend != nil {
+		return s.backend.info()
+	}
 	info := C.Pa_GetStreamInfo(s.paStream)
 	if info == nil {
 		return nil
@@ -285,50 +429,83 @@ func (s *Stream[T]) TimeInfo() StreamCallbackTimeInfo {
 }
 
 func (s *Stream[T]) SetFinishedCallback(callback func(*Stream[T])) error {
+	if s.backend != nil {
+		s.finishedCallback = callback
+		return nil
+	}
 	cb := C.paStreamFinishedCallback
 	if callback == nil {
 		cb = nil
 	} else {
 		s.finishedCallback = callback
 	}
-	return goError(C.Pa_SetStreamFinishedCallback(s.paStream, cb))
+	return streamError("set finished callback", s.params.device(), s.params, goError(C.Pa_SetStreamFinishedCallback(s.paStream, cb)))
 }
 
 // Start commences audio processing.
 func (s *Stream[T]) Start() error {
-	return goError(C.Pa_StartStream(s.paStream))
+	if s.backend != nil {
+		return s.logOp("start", s.params.device(), s.backend.start())
+	}
+	return s.logOp("start", s.params.device(), goError(C.Pa_StartStream(s.paStream)))
 }
 
 // Stop terminates audio processing.
 // It waits until all pending audio buffers have been played before it returns.
 func (s *Stream[T]) Stop() error {
-	return goError(C.Pa_StopStream(s.paStream))
+	if s.backend != nil {
+		return s.logOp("stop", s.params.device(), s.backend.stop())
+	}
+	return s.logOp("stop", s.params.device(), goError(C.Pa_StopStream(s.paStream)))
 }
 
 // Close closes an audio stream. If the audio stream is active it discards any pending buffers.
 func (s *Stream[T]) Close() error {
-	return goError(C.Pa_CloseStream(s.paStream))
+	if s.backend != nil {
+		err := s.backend.close()
+		s.freeBuffers()
+		return s.logOp("close", s.params.device(), err)
+	}
+	err := goError(C.Pa_CloseStream(s.paStream))
+	if err == nil {
+		s.freeBuffers()
+	}
+	if err == nil && s.handle != 0 {
+		s.handle.Delete()
+		s.handle = 0
+		openStreams.Add(-1)
+	}
+	return s.logOp("close", s.params.device(), err)
 }
 
 // Abort terminates audio processing immediately without waiting for pending buffers to complete.
 func (s *Stream[T]) Abort() error {
-	return goError(C.Pa_AbortStream(s.paStream))
+	if s.backend != nil {
+		return s.logOp("abort", s.params.device(), s.backend.abort())
+	}
+	return s.logOp("abort", s.params.device(), goError(C.Pa_AbortStream(s.paStream)))
 }
 
 // ReadAvailable returns the number of frames that can be read from the stream without waiting.
 func (s *Stream[T]) ReadAvailable() (int, error) {
+	if s.backend != nil {
+		return s.backend.readAvailable(), nil
+	}
 	size := C.Pa_GetStreamReadAvailable(s.paStream)
 	if size < 0 {
-		return 0, goError(C.PaError(size))
+		return 0, streamError("read available", s.params.Input.Device, s.params, goError(C.PaError(size)))
 	}
 	return int(size), nil
 }
 
 // WriteAvailable returns the number of frames that can be written from the stream without waiting.
 func (s *Stream[T]) WriteAvailable() (int, error) {
+	if s.backend != nil {
+		return s.backend.writeAvailable(), nil
+	}
 	size := C.Pa_GetStreamWriteAvailable(s.paStream)
 	if size < 0 {
-		return 0, goError(C.PaError(size))
+		return 0, streamError("write available", s.params.Output.Device, s.params, goError(C.PaError(size)))
 	}
 	return int(size), nil
 }
@@ -355,24 +532,50 @@ func (s *Stream[T]) Read() ([]T, error) {
 	if s.callback != nil {
 		return s.in, nil
 	}
-	err := goError(C.Pa_ReadStream(s.paStream, unsafe.Pointer(&s.in[0]), C.ulong(s.params.FramesPerBuffer)))
+	if s.convert {
+		err := s.readStream(unsafe.Pointer(&s.blockIn[0]))
+		if err != nil {
+			return nil, s.logReadError(err)
+		}
+		s.in = convertIn(s.in, unsafe.Pointer(&s.blockIn[0]), len(s.blockIn))
+		return s.in, nil
+	}
+	err := s.readStream(unsafe.Pointer(&s.in[0]))
 	if err != nil {
-		return nil, err
+		return nil, s.logReadError(err)
 	}
 	return s.in, nil
 }
 
+// ReadS reads samples from a NonInterleaved input stream, one slice per channel.
 func (s *Stream[T]) ReadS() ([][]T, error) {
 	if s.callback != nil {
 		return s.inS, nil
 	}
-	err := goError(C.Pa_ReadStream(s.paStream, unsafe.Pointer(&s.inS[0]), C.ulong(s.params.FramesPerBuffer)))
+	err := s.readStream(s.planarIn)
 	if err != nil {
-		return nil, err
+		return nil, s.logReadError(err)
 	}
+	s.loadPlanar(s.inS, s.planarIn)
 	return s.inS, nil
 }
 
+// readStream reads a buffer of FramesPerBuffer frames from the stream.
+func (s *Stream[T]) readStream(buf unsafe.Pointer) error {
+	if s.backend != nil {
+		return s.backend.read(buf, int(s.params.FramesPerBuffer))
+	}
+	return goError(C.Pa_ReadStream(s.paStream, buf, C.ulong(s.params.FramesPerBuffer)))
+}
+
+// writeStream writes a buffer of FramesPerBuffer frames to the stream.
+func (s *Stream[T]) writeStream(buf unsafe.Pointer) error {
+	if s.backend != nil {
+		return s.backend.write(buf, int(s.params.FramesPerBuffer))
+	}
+	return goError(C.Pa_WriteStream(s.paStream, buf, C.ulong(s.params.FramesPerBuffer)))
+}
+
 // Write writes samples to an output stream. This function doesn't return until the entire buffer
 // has been written - this may involve waiting for the operating system to consume the data.
 func (s *Stream[T]) Write(data []T) error {
@@ -380,29 +583,45 @@ func (s *Stream[T]) Write(data []T) error {
 	if s.callback != nil {
 		return nil
 	}
-	err := goError(C.Pa_WriteStream(s.paStream, unsafe.Pointer(&s.out[0]), C.ulong(s.params.FramesPerBuffer)))
-	if err != nil {
-		if err == OutputUnderflowed {
-			return nil
-		}
-		return err
+	if s.convert {
+		convertOut(unsafe.Pointer(&s.blockOut[0]), s.out)
+		return s.logWriteError(s.writeStream(unsafe.Pointer(&s.blockOut[0])))
 	}
-	return nil
+	return s.logWriteError(s.writeStream(unsafe.Pointer(&s.out[0])))
 }
 
+// WriteS writes samples to a NonInterleaved output stream, one slice per channel.
 func (s *Stream[T]) WriteS(data [][]T) error {
-	copy(s.outS, data)
+	for c := range min(len(s.outS), len(data)) {
+		copy(s.outS[c], data[c])
+	}
 	if s.callback != nil {
 		return nil
 	}
-	err := goError(C.Pa_WriteStream(s.paStream, unsafe.Pointer(&s.outS[0]), C.ulong(s.params.FramesPerBuffer)))
-	if err != nil {
-		if err == OutputUnderflowed {
-			return nil
-		}
-		return err
+	s.storePlanar(s.planarOut, s.outS)
+	return s.logWriteError(s.writeStream(s.planarOut))
+}
+
+func (s *Stream[T]) logReadError(err error) error {
+	if err == nil {
+		return nil
 	}
-	return nil
+	if err == InputOverflowed {
+		s.logXrun(InputOverflow)
+		return streamError("read", s.params.Input.Device, s.params, err)
+	}
+	return s.logOp("read", s.params.Input.Device, err)
+}
+
+func (s *Stream[T]) logWriteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == OutputUnderflowed {
+		s.logXrun(OutputUnderflow)
+		return nil
+	}
+	return s.logOp("write", s.params.Output.Device, err)
 }
 
 func (s *Stream[T]) Callback(
@@ -410,17 +629,77 @@ func (s *Stream[T]) Callback(
 	frameCount C.ulong,
 	timeInfo *C.PaStreamCallbackTimeInfo,
 	statusFlags C.PaStreamCallbackFlags,
-) StreamCallbackResult {
+) (result StreamCallbackResult) {
 	s.statusFlags = StreamCallbackFlags(statusFlags)
+	if xrun := s.statusFlags &^ PrimingOutput; xrun != 0 {
+		s.logXrun(xrun)
+	}
 	s.timeInfo = StreamCallbackTimeInfo{
 		duration(timeInfo.inputBufferAdcTime),
 		duration(timeInfo.currentTime),
 		duration(timeInfo.outputBufferDacTime),
 	}
 	s.frameCount = int(frameCount)
-	s.setInBuffer(in)
-	s.setOutBuffer(out)
-	return s.callback(s)
+	if r := s.recorder.Load(); r != nil {
+		r.record(in, s.frameCount, s.timeInfo, s.statusFlags)
+	}
+	if s.convert {
+		if in != nil {
+			s.convertInBuffer(in)
+		}
+		if out != nil {
+			s.prepareOutBuffer(out)
+			defer s.flushOutBuffer()
+		}
+	} else {
+		s.setInBuffer(in)
+		s.setOutBuffer(out)
+	}
+	if s.err.Load() != nil {
+		return Abort
+	}
+	defer s.recoverCallback("callback", &result)
+	if !s.watchdog.enabled() {
+		return s.callback(s)
+	}
+	start := time.Now()
+	result = s.callback(s)
+	s.checkCallbackTime(time.Since(start))
+	return result
+}
+
+// Err returns the error that made the stream fail, i.e. a *CallbackPanicError
+// if the stream callback or the finished callback panicked, or nil otherwise.
+// It can be used from the finished callback to find out why the stream finished.
+func (s *Stream[T]) Err() error {
+	if err := s.err.Load(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// recoverCallback recovers a panic of a user callback,
+// marks the stream as failed and makes the stream abort.
+func (s *Stream[T]) recoverCallback(name string, result *StreamCallbackResult) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := &CallbackPanicError{name, v, debug.Stack()}
+	s.err.CompareAndSwap(nil, err)
+	s.logger().Error("stream "+name+" panicked", slog.Any("panic", v), slog.String("stack", string(err.Stack)))
+	if result != nil {
+		*result = Abort
+	}
+}
+
+func (s *Stream[T]) finished() {
+	s.logger().Debug("stream finished")
+	if s.finishedCallback == nil {
+		return
+	}
+	defer s.recoverCallback("finished callback", nil)
+	s.finishedCallback(s)
 }
 
 func (s *Stream[T]) setInBuffer(ptr unsafe.Pointer) {
@@ -524,25 +803,59 @@ func LowLatencyParameters(in, out *DeviceInfo) *StreamParameters {
 // output device must be nil for input-only streams respectively.
 // Returns true if the format is supported, and false otherwise.
 func IsFormatSupported(params *StreamParameters) bool {
-	return goError(
-		C.Pa_IsFormatSupported(
-			paStreamParameters(params.Input, params.SampleFormat),
-			paStreamParameters(params.Output, params.SampleFormat),
-			C.double(params.SampleRate),
+	return CheckFormatSupported(params) == nil
+}
+
+// CheckFormatSupported is like IsFormatSupported but returns
+// the reason why the format is not supported as a *StreamError.
+func CheckFormatSupported(params *StreamParameters) error {
+	device, err := params.virtualDevice()
+	if device != nil {
+		err = device.checkFormat(params)
+	}
+	if device != nil || err != nil {
+		return streamError("check format", params.device(), params, err)
+	}
+	return streamError(
+		"check format",
+		params.device(),
+		params,
+		goError(
+			C.Pa_IsFormatSupported(
+				paStreamParameters(params.Input, params.SampleFormat),
+				paStreamParameters(params.Output, params.SampleFormat),
+				C.double(params.SampleRate),
+			),
 		),
-	) == nil
+	)
 }
 
 func paStreamParameters(p StreamDeviceParameters, sampleFormat SampleFormat) *C.PaStreamParameters {
 	if !p.Exists() {
 		return nil
 	}
-	return &C.PaStreamParameters{
+	params := &C.PaStreamParameters{
 		device:           C.int(p.Device.Index),
 		channelCount:     C.int(p.ChannelCount),
 		sampleFormat:     C.PaSampleFormat(sampleFormat),
 		suggestedLatency: C.PaTime(p.SuggestedLatency.Seconds()),
 	}
+	if p.HostApiSpecificStreamInfo != nil {
+		params.hostApiSpecificStreamInfo = p.HostApiSpecificStreamInfo.StreamInfo()
+	}
+	return params
+}
+
+// callbackTarget is implemented by every instantiation of Stream.
+// The exported C callbacks dispatch through it, so streams of any sample type work.
+type callbackTarget interface {
+	Callback(
+		in, out unsafe.Pointer,
+		frameCount C.ulong,
+		timeInfo *C.PaStreamCallbackTimeInfo,
+		statusFlags C.PaStreamCallbackFlags,
+	) StreamCallbackResult
+	finished()
 }
 
 //export streamCallback
@@ -553,20 +866,7 @@ func streamCallback(
 	statusFlags C.PaStreamCallbackFlags,
 	userData unsafe.Pointer,
 ) C.PaStreamCallbackResult {
-	switch s := (cgo.Handle)(userData).Value().(type) {
-	case *Stream[byte]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[int8]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[int16]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[uint16]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[int32]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[uint32]:
-		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
-	case *Stream[float32]:
+	if s, ok := cgo.Handle(uintptr(userData)).Value().(callbackTarget); ok {
 		return s.Callback(in, out, frameCount, timeInfo, statusFlags)
 	}
 	return Abort
@@ -574,20 +874,7 @@ func streamCallback(
 
 //export streamFinishedCallback
 func streamFinishedCallback(userData unsafe.Pointer) {
-	switch s := (cgo.Handle)(userData).Value().(type) {
-	case *Stream[byte]:
-		s.finishedCallback(s)
-	case *Stream[int8]:
-		s.finishedCallback(s)
-	case *Stream[int16]:
-		s.finishedCallback(s)
-	case *Stream[uint16]:
-		s.finishedCallback(s)
-	case *Stream[int32]:
-		s.finishedCallback(s)
-	case *Stream[uint32]:
-		s.finishedCallback(s)
-	case *Stream[float32]:
-		s.finishedCallback(s)
+	if s, ok := cgo.Handle(uintptr(userData)).Value().(callbackTarget); ok {
+		s.finished()
 	}
 }
@@ -0,0 +1,144 @@
+package portaudio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type flagName struct {
+	flag uint64
+	name string
+}
+
+var sampleFormatNames = []flagName{
+	{uint64(Float32), "Float32"},
+	{uint64(Int32), "Int32"},
+	{uint64(Int24), "Int24"},
+	{uint64(Int16), "Int16"},
+	{uint64(Int8), "Int8"},
+	{uint64(UInt8), "UInt8"},
+	{uint64(CustomFormat), "CustomFormat"},
+	{uint64(NonInterleaved), "NonInterleaved"},
+}
+
+var streamFlagNames = []flagName{
+	{uint64(ClipOff), "ClipOff"},
+	{uint64(DitherOff), "DitherOff"},
+	{uint64(NeverDropInput), "NeverDropInput"},
+	{uint64(PrimeOutputBuffersUsingStreamCallback), "PrimeOutputBuffersUsingStreamCallback"},
+}
+
+var streamCallbackFlagNames = []flagName{
+	{uint64(InputUnderflow), "InputUnderflow"},
+	{uint64(InputOverflow), "InputOverflow"},
+	{uint64(OutputUnderflow), "OutputUnderflow"},
+	{uint64(OutputOverflow), "OutputOverflow"},
+	{uint64(PrimingOutput), "PrimingOutput"},
+}
+
+// formatFlags joins the names of the set flags with "|".
+// Bits without a name are appended as a hexadecimal number.
+func formatFlags(v uint64, names []flagName, zero string) string {
+	if v == 0 {
+		return zero
+	}
+	var parts []string
+	for _, f := range names {
+		if v&f.flag != 0 {
+			parts = append(parts, f.name)
+			v &^= f.flag
+		}
+	}
+	if v != 0 {
+		parts = append(parts, "0x"+strconv.FormatUint(v, 16))
+	}
+	return strings.Join(parts, "|")
+}
+
+// parseFlags parses the output of formatFlags, ignoring case and spaces around the names.
+func parseFlags(text, kind string, names []flagName, zero string) (uint64, error) {
+	var v uint64
+	for _, part := range strings.Split(text, "|") {
+		part = strings.TrimSpace(part)
+		if part == "" || strings.EqualFold(part, zero) {
+			continue
+		}
+		if n, err := strconv.ParseUint(part, 0, 64); err == nil {
+			v |= n
+			continue
+		}
+		found := false
+		for _, f := range names {
+			if strings.EqualFold(f.name, part) {
+				v |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("portaudio: unknown %s %q", kind, part)
+		}
+	}
+	return v, nil
+}
+
+func (f SampleFormat) String() string {
+	return formatFlags(uint64(f), sampleFormatNames, "0")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f SampleFormat) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts names joined with "|", e.g. "Float32|NonInterleaved".
+func (f *SampleFormat) UnmarshalText(text []byte) error {
+	v, err := parseFlags(string(text), "sample format", sampleFormatNames, "0")
+	if err != nil {
+		return err
+	}
+	*f = SampleFormat(v)
+	return nil
+}
+
+func (f StreamFlags) String() string {
+	return formatFlags(uint64(f), streamFlagNames, "NoFlag")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f StreamFlags) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts names joined with "|", e.g. "ClipOff|DitherOff".
+func (f *StreamFlags) UnmarshalText(text []byte) error {
+	v, err := parseFlags(string(text), "stream flag", streamFlagNames, "NoFlag")
+	if err != nil {
+		return err
+	}
+	*f = StreamFlags(v)
+	return nil
+}
+
+func (f StreamCallbackFlags) String() string {
+	return formatFlags(uint64(f), streamCallbackFlagNames, "0")
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f StreamCallbackFlags) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It accepts names joined with "|", e.g. "InputOverflow|OutputUnderflow".
+func (f *StreamCallbackFlags) UnmarshalText(text []byte) error {
+	v, err := parseFlags(string(text), "stream callback flag", streamCallbackFlagNames, "0")
+	if err != nil {
+		return err
+	}
+	*f = StreamCallbackFlags(v)
+	return nil
+}
@@ -0,0 +1,99 @@
+package portaudio
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHostApiTypeString(t *testing.T) {
+	for _, test := range []struct {
+		t    HostApiType
+		want string
+	}{
+		{ALSA, "ALSA"},
+		{Sndio, "Sndio"},
+		{6, "HostApiType(6)"},
+		{-1, "HostApiType(-1)"},
+		{100, "HostApiType(100)"},
+	} {
+		if got := test.t.String(); got != test.want {
+			t.Errorf("String(%d) = %q, want %q", int(test.t), got, test.want)
+		}
+		if got, err := ParseHostApiType(test.want); got != test.t || err != nil {
+			t.Errorf("ParseHostApiType(%q) = %v, %v", test.want, got, err)
+		}
+	}
+	for i, name := range hostApiStrings {
+		if name == "" {
+			continue
+		}
+		if got, err := ParseHostApiType(strings.ToLower(name)); int(got) != i || err != nil {
+			t.Errorf("ParseHostApiType(%q) = %v, %v", strings.ToLower(name), got, err)
+		}
+	}
+	for _, s := range []string{"", "Pulse", "HostApiType(x)", "HostApiType(1"} {
+		if _, err := ParseHostApiType(s); err == nil {
+			t.Errorf("ParseHostApiType(%q) succeeded", s)
+		}
+	}
+}
+
+func TestFlagsText(t *testing.T) {
+	for _, test := range []struct {
+		v    interface{ String() string }
+		want string
+	}{
+		{Float32 | NonInterleaved, "Float32|NonInterleaved"},
+		{SampleFormat(0), "0"},
+		{StreamFlags(0), "NoFlag"},
+		{ClipOff | DitherOff, "ClipOff|DitherOff"},
+		{ClipOff | 0x100000, "ClipOff|0x100000"},
+		{InputOverflow | OutputUnderflow, "InputOverflow|OutputUnderflow"},
+		{StreamCallbackFlags(0), "0"},
+	} {
+		if got := test.v.String(); got != test.want {
+			t.Errorf("String = %q, want %q", got, test.want)
+		}
+	}
+
+	var f StreamFlags
+	if err := f.UnmarshalText([]byte(" clipoff | DITHEROFF|0x100000 ")); err != nil || f != ClipOff|DitherOff|0x100000 {
+		t.Errorf("UnmarshalText = %v, %v", f, err)
+	}
+	if err := f.UnmarshalText([]byte("NoFlag")); err != nil || f != 0 {
+		t.Errorf("UnmarshalText(NoFlag) = %v, %v", f, err)
+	}
+	if err := f.UnmarshalText([]byte("ClipOff|Loud")); err == nil {
+		t.Error("unknown flag accepted")
+	}
+	var c StreamCallbackFlags
+	if err := c.UnmarshalText([]byte("PrimingOutput")); err != nil || c != PrimingOutput {
+		t.Errorf("UnmarshalText = %v, %v", c, err)
+	}
+}
+
+func TestTextJSON(t *testing.T) {
+	type config struct {
+		Api    HostApiType
+		Format SampleFormat
+		Flags  StreamFlags
+		Status StreamCallbackFlags
+	}
+	want := config{WASAPI, Int24 | NonInterleaved, NeverDropInput, OutputUnderflow | 0x40}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const text = `{"Api":"WASAPI","Format":"Int24|NonInterleaved","Flags":"NeverDropInput","Status":"OutputUnderflow|0x40"}`
+	if string(data) != text {
+		t.Errorf("got %s, want %s", data, text)
+	}
+	var got config
+	if err = json.Unmarshal(data, &got); err != nil || got != want {
+		t.Errorf("Unmarshal = %+v, %v", got, err)
+	}
+	if err = json.Unmarshal([]byte(`{"Format":"Float64"}`), &got); err == nil {
+		t.Error("unknown sample format accepted")
+	}
+}
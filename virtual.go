@@ -0,0 +1,250 @@
+package portaudio
+
+/*
+#cgo pkg-config: portaudio-2.0
+#include <portaudio.h>
+*/
+import "C"
+import (
+	"math"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// virtualDevice is a device implemented in Go instead of by a PortAudio host API.
+// Its DeviceInfo values are listed after the PortAudio devices.
+// Their indices start at virtualDeviceBase, so they stay valid when the PortAudio devices change.
+type virtualDevice interface {
+	// checkFormat reports whether a stream with the given parameters can be opened.
+	checkFormat(params *StreamParameters) error
+	// open opens a stream. The backend calls target for callback streams.
+	open(params *StreamParameters, target callbackTarget, callback bool) (backend, error)
+}
+
+// backend runs a stream on a virtual device in place of PortAudio.
+// Buffers are in the layout PortAudio uses for the sample format of the stream.
+type backend interface {
+	start() error
+	stop() error
+	abort() error
+	close() error
+	active() bool
+	stopped() bool
+	time() time.Duration
+	info() *StreamInfo
+	read(buf unsafe.Pointer, frames int) error
+	write(buf unsafe.Pointer, frames int) error
+	readAvailable() int
+	writeAvailable() int
+}
+
+// virtualDeviceBase is the index of the first virtual device. It is above any PortAudio device index.
+const virtualDeviceBase = 1 << 20
+
+var virtualDevices struct {
+	sync.Mutex
+	devices []*DeviceInfo
+	next    int
+}
+
+// registerVirtual lists devices and assigns their indices. Indices are not reused.
+func registerVirtual(devices ...*DeviceInfo) {
+	virtualDevices.Lock()
+	defer virtualDevices.Unlock()
+	for _, d := range devices {
+		d.Index = virtualDeviceBase + virtualDevices.next
+		virtualDevices.next++
+	}
+	virtualDevices.devices = append(virtualDevices.devices, devices...)
+}
+
+func unregisterVirtual(device virtualDevice) {
+	virtualDevices.Lock()
+	defer virtualDevices.Unlock()
+	devices := virtualDevices.devices[:0]
+	for _, d := range virtualDevices.devices {
+		if d.virtual != device {
+			devices = append(devices, d)
+		}
+	}
+	virtualDevices.devices = devices
+}
+
+// virtualDeviceCount returns the number of virtual devices.
+func virtualDeviceCount() int {
+	virtualDevices.Lock()
+	defer virtualDevices.Unlock()
+	return len(virtualDevices.devices)
+}
+
+// virtualDeviceInfo returns the virtual device with the given index, or nil if there is none.
+func virtualDeviceInfo(index int) *DeviceInfo {
+	virtualDevices.Lock()
+	defer virtualDevices.Unlock()
+	for _, d := range virtualDevices.devices {
+		if d.Index == index {
+			info := *d
+			return &info
+		}
+	}
+	return nil
+}
+
+// virtualDeviceAt returns the virtual device at the given position in the device list,
+// where the first virtual device follows the last PortAudio device, or nil if there is none.
+func virtualDeviceAt(position int) *DeviceInfo {
+	first := max(0, int(C.Pa_GetDeviceCount()))
+	virtualDevices.Lock()
+	defer virtualDevices.Unlock()
+	if position < first || position >= first+len(virtualDevices.devices) {
+		return nil
+	}
+	info := *virtualDevices.devices[position-first]
+	return &info
+}
+
+// virtualDevice returns the virtual device of the stream, or nil if it uses PortAudio devices.
+// Combining a virtual device with a PortAudio device or another virtual device is reported as an error.
+func (p *StreamParameters) virtualDevice() (virtualDevice, error) {
+	var in, out virtualDevice
+	if p.Input.Exists() {
+		in = p.Input.Device.virtual
+	}
+	if p.Output.Exists() {
+		out = p.Output.Device.virtual
+	}
+	switch {
+	case in == nil && out == nil:
+		return nil, nil
+	case p.Input.Exists() && p.Output.Exists() && in != out:
+		return nil, BadIODeviceCombination
+	case in != nil:
+		return in, nil
+	default:
+		return out, nil
+	}
+}
+
+// deviceBuffer is a buffer in the layout PortAudio uses for a sample format:
+// interleaved samples or, for NonInterleaved formats, an array of pointers to the channels.
+type deviceBuffer struct {
+	ptr      unsafe.Pointer
+	format   SampleFormat
+	channels int
+}
+
+// channel returns the first sample of a channel and the distance between its samples in bytes.
+func (b deviceBuffer) channel(c int) (unsafe.Pointer, int) {
+	size := sampleBytes(b.format)
+	if b.format.IsNonInterleaved() {
+		return unsafe.Slice((*unsafe.Pointer)(b.ptr), b.channels)[c], size
+	}
+	return unsafe.Add(b.ptr, c*size), size * b.channels
+}
+
+// load converts frames of the buffer into interleaved float32 samples.
+func (b deviceBuffer) load(dst []float32, frames int) {
+	for c := range b.channels {
+		p, stride := b.channel(c)
+		for f := range frames {
+			dst[f*b.channels+c] = float32(loadSample(unsafe.Add(p, f*stride), b.format))
+		}
+	}
+}
+
+// store converts interleaved float32 samples into frames of the buffer.
+func (b deviceBuffer) store(src []float32, frames int) {
+	for c := range b.channels {
+		p, stride := b.channel(c)
+		for f := range frames {
+			storeSample(unsafe.Add(p, f*stride), b.format, float64(src[f*b.channels+c]))
+		}
+	}
+}
+
+// allocDeviceBuffer allocates a deviceBuffer holding frames frames.
+func allocDeviceBuffer(format SampleFormat, channels, frames int) deviceBuffer {
+	size := sampleBytes(format) * frames
+	if !format.IsNonInterleaved() {
+		data := make([]byte, size*channels)
+		return deviceBuffer{unsafe.Pointer(unsafe.SliceData(data)), format, channels}
+	}
+	ptrs := make([]unsafe.Pointer, channels)
+	for c := range ptrs {
+		ptrs[c] = unsafe.Pointer(unsafe.SliceData(make([]byte, size)))
+	}
+	return deviceBuffer{unsafe.Pointer(unsafe.SliceData(ptrs)), format, channels}
+}
+
+// sampleBytes returns the size of a sample of a format supported by virtual devices, or zero.
+func sampleBytes(format SampleFormat) int {
+	switch format &^ NonInterleaved {
+	case Float32, Int32:
+		return 4
+	case Int24:
+		return 3
+	case Int16:
+		return 2
+	case Int8, UInt8:
+		return 1
+	}
+	return 0
+}
+
+func loadSample(p unsafe.Pointer, format SampleFormat) float64 {
+	switch format &^ NonInterleaved {
+	case Float32:
+		return float64(*(*float32)(p))
+	case Int32:
+		return ToFloat64(*(*int32)(p))
+	case Int24:
+		b := unsafe.Slice((*byte)(p), 3)
+		return ToFloat64(int32(uint32(b[0])<<8 | uint32(b[1])<<16 | uint32(b[2])<<24))
+	case Int16:
+		return ToFloat64(*(*int16)(p))
+	case Int8:
+		return ToFloat64(*(*int8)(p))
+	case UInt8:
+		return ToFloat64(*(*uint8)(p))
+	}
+	return 0
+}
+
+func storeSample(p unsafe.Pointer, format SampleFormat, v float64) {
+	switch format &^ NonInterleaved {
+	case Float32:
+		*(*float32)(p) = float32(v)
+	case Int32:
+		*(*int32)(p) = FromFloat64[int32](v)
+	case Int24:
+		x := uint32(FromFloat64[int32](v))
+		b := unsafe.Slice((*byte)(p), 3)
+		b[0], b[1], b[2] = byte(x>>8), byte(x>>16), byte(x>>24)
+	case Int16:
+		*(*int16)(p) = FromFloat64[int16](v)
+	case Int8:
+		*(*int8)(p) = FromFloat64[int8](v)
+	case UInt8:
+		*(*uint8)(p) = FromFloat64[uint8](v)
+	}
+}
+
+// callbackTimeInfo creates the time information passed to the callback of a virtual stream.
+func callbackTimeInfo(adc, current, dac time.Duration) C.PaStreamCallbackTimeInfo {
+	return C.PaStreamCallbackTimeInfo{
+		inputBufferAdcTime:  C.PaTime(adc.Seconds()),
+		currentTime:         C.PaTime(current.Seconds()),
+		outputBufferDacTime: C.PaTime(dac.Seconds()),
+	}
+}
+
+// framesAt returns the number of frames a clock of the given rate has produced after elapsed.
+func framesAt(elapsed time.Duration, rate float64) int64 {
+	return int64(math.Floor(elapsed.Seconds() * rate))
+}
+
+// timeAt returns the time a clock of the given rate reaches frame.
+func timeAt(frame int64, rate float64) time.Duration {
+	return time.Duration(float64(frame) / rate * float64(time.Second))
+}
@@ -0,0 +1,353 @@
+// Package vorbis decodes Ogg Vorbis files to float32 samples with libvorbisfile.
+package vorbis
+
+/*
+#cgo pkg-config: vorbisfile
+#include <stdint.h>
+#include <stdlib.h>
+#include <vorbis/vorbisfile.h>
+
+extern size_t vorbisRead(void *ptr, size_t size, size_t nmemb, void *handle);
+extern int vorbisSeek(void *handle, ogg_int64_t offset, int whence);
+extern long vorbisTell(void *handle);
+
+static int openCallbacks(OggVorbis_File *vf, uintptr_t h, int seekable) {
+	ov_callbacks callbacks = {vorbisRead, NULL, NULL, vorbisTell};
+	if (seekable) {
+		callbacks.seek_func = vorbisSeek;
+	}
+	return ov_open_callbacks((void*)h, vf, NULL, 0, callbacks);
+}
+
+static float *channel(float **pcm, int i) {
+	return pcm[i];
+}
+
+static char *comment(vorbis_comment *vc, int i) {
+	return vc->user_comments[i];
+}
+
+static int commentLength(vorbis_comment *vc, int i) {
+	return vc->comment_lengths[i];
+}
+*/
+import "C"
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime/cgo"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Error is an error code returned by libvorbisfile.
+type Error C.int
+
+// libvorbisfile errors.
+const (
+	ErrRead      Error = C.OV_EREAD
+	ErrFault     Error = C.OV_EFAULT
+	ErrImpl      Error = C.OV_EIMPL
+	ErrInvalid   Error = C.OV_EINVAL
+	ErrNotVorbis Error = C.OV_ENOTVORBIS
+	ErrBadHeader Error = C.OV_EBADHEADER
+	ErrVersion   Error = C.OV_EVERSION
+	ErrNotAudio  Error = C.OV_ENOTAUDIO
+	ErrBadPacket Error = C.OV_EBADPACKET
+	ErrBadLink   Error = C.OV_EBADLINK
+	ErrNoSeek    Error = C.OV_ENOSEEK
+	ErrHole      Error = C.OV_HOLE
+	ErrFalse     Error = C.OV_FALSE
+	errEndOfFile Error = C.OV_EOF
+)
+
+var errorStrings = map[Error]string{
+	ErrRead:      "read error",
+	ErrFault:     "internal error",
+	ErrImpl:      "unimplemented feature",
+	ErrInvalid:   "invalid argument",
+	ErrNotVorbis: "not a Vorbis stream",
+	ErrBadHeader: "bad header",
+	ErrVersion:   "unsupported Vorbis version",
+	ErrNotAudio:  "not audio data",
+	ErrBadPacket: "bad packet",
+	ErrBadLink:   "bad link",
+	ErrNoSeek:    "stream is not seekable",
+	ErrHole:      "interruption in the data",
+	ErrFalse:     "operation failed",
+	errEndOfFile: "end of file",
+}
+
+func (err Error) Error() string {
+	if s, ok := errorStrings[err]; ok {
+		return "vorbis: " + s
+	}
+	return "vorbis: error " + strconv.Itoa(int(err))
+}
+
+// ErrFormatChange is returned by Read when a chained stream changes its channel count or rate.
+var ErrFormatChange = errors.New("vorbis: chained stream changes format")
+
+// Decoder decodes an Ogg Vorbis stream into interleaved float32 samples at its native rate.
+// It must not be used concurrently.
+type Decoder struct {
+	vf         *C.OggVorbis_File
+	handle     cgo.Handle
+	src        *source
+	closer     io.Closer
+	sampleRate int
+	channels   int
+	length     int64
+	vendor     string
+	comments   []string
+	pcm        [][]float32 // planar samples decoded but not yet returned
+}
+
+// source is the reader behind the callbacks of libvorbisfile.
+type source struct {
+	r   io.Reader
+	pos int64
+	err error // the last error of r other than io.EOF
+}
+
+// NewDecoder creates a decoder reading from r. If r is an io.Seeker,
+// the length is known and Seek can be used.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{
+		vf:     (*C.OggVorbis_File)(C.calloc(1, C.sizeof_OggVorbis_File)),
+		src:    &source{r: r},
+		length: -1,
+	}
+	d.handle = cgo.NewHandle(d.src)
+	_, seekable := r.(io.Seeker)
+	if seekable {
+		pos, err := r.(io.Seeker).Seek(0, io.SeekCurrent)
+		if err != nil {
+			d.release()
+			return nil, err
+		}
+		d.src.pos = pos
+	}
+	if code := C.openCallbacks(d.vf, C.uintptr_t(d.handle), C.int(b2i(seekable))); code != 0 {
+		// ov_open_callbacks only leaves the file for ov_clear on success.
+		err := d.error(C.long(code))
+		d.release()
+		return nil, err
+	}
+	info := C.ov_info(d.vf, -1)
+	d.sampleRate, d.channels = int(info.rate), int(info.channels)
+	if n := C.ov_pcm_total(d.vf, -1); n >= 0 {
+		d.length = int64(n)
+	}
+	if vc := C.ov_comment(d.vf, -1); vc != nil {
+		d.vendor = C.GoString(vc.vendor)
+		for i := range C.int(vc.comments) {
+			d.comments = append(d.comments, C.GoStringN(C.comment(vc, i), C.commentLength(vc, i)))
+		}
+	}
+	return d, nil
+}
+
+// Open opens and decodes a file. Close closes the file.
+func Open(name string) (*Decoder, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d.closer = f
+	return d, nil
+}
+
+// error converts a libvorbisfile result code, preferring the error of the reader.
+func (d *Decoder) error(code C.long) error {
+	if d.src.err != nil {
+		return d.src.err
+	}
+	return Error(code)
+}
+
+// SampleRate returns the native rate of the stream.
+func (d *Decoder) SampleRate() int {
+	return d.sampleRate
+}
+
+// Channels returns the number of channels.
+func (d *Decoder) Channels() int {
+	return d.channels
+}
+
+// Length returns the number of frames, or -1 if it is unknown.
+func (d *Decoder) Length() int64 {
+	return d.length
+}
+
+// Duration returns the length of the stream, or zero if it is unknown.
+func (d *Decoder) Duration() time.Duration {
+	if d.length < 0 {
+		return 0
+	}
+	return time.Duration(d.length) * time.Second / time.Duration(d.sampleRate)
+}
+
+// Position returns the index of the next frame.
+func (d *Decoder) Position() int64 {
+	pos := int64(C.ov_pcm_tell(d.vf))
+	if len(d.pcm) > 0 {
+		pos -= int64(len(d.pcm[0]))
+	}
+	return pos
+}
+
+// Vendor returns the vendor string of the encoder.
+func (d *Decoder) Vendor() string {
+	return d.vendor
+}
+
+// Comments returns the user comments, e.g. "TITLE=Take 1".
+func (d *Decoder) Comments() []string {
+	return d.comments
+}
+
+// Comment returns the value of the first comment with the given name, ignoring case.
+func (d *Decoder) Comment(name string) string {
+	for _, c := range d.comments {
+		if k, v, ok := strings.Cut(c, "="); ok && strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// Read decodes interleaved samples into buf and returns their number,
+// a multiple of the channel count. It returns io.EOF at the end of the stream.
+// Interruptions in the data are skipped.
+func (d *Decoder) Read(buf []float32) (int, error) {
+	c := d.channels
+	n := 0
+	for n+c <= len(buf) {
+		if len(d.pcm) == 0 || len(d.pcm[0]) == 0 {
+			if err := d.decode((len(buf) - n) / c); err != nil {
+				return n, err
+			}
+			continue
+		}
+		frames := min(len(d.pcm[0]), (len(buf)-n)/c)
+		for ch, samples := range d.pcm {
+			for i, v := range samples[:frames] {
+				buf[n+i*c+ch] = v
+			}
+			d.pcm[ch] = samples[frames:]
+		}
+		n += frames * c
+	}
+	return n, nil
+}
+
+// decode decodes up to the given number of frames into d.pcm.
+func (d *Decoder) decode(frames int) error {
+	var pcm **C.float
+	var link C.int
+	for {
+		n := C.ov_read_float(d.vf, &pcm, C.int(frames), &link)
+		switch {
+		case n == 0:
+			return io.EOF
+		case n == C.OV_HOLE:
+			continue
+		case n < 0:
+			return d.error(n)
+		}
+		if info := C.ov_info(d.vf, link); int(info.channels) != d.channels || int(info.rate) != d.sampleRate {
+			return ErrFormatChange
+		}
+		d.pcm = d.pcm[:0]
+		for ch := range d.channels {
+			d.pcm = append(d.pcm, unsafe.Slice((*float32)(C.channel(pcm, C.int(ch))), int(n)))
+		}
+		return nil
+	}
+}
+
+// ReadSamples is Read, making the decoder a portaudio.Source[float32].
+func (d *Decoder) ReadSamples(buf []float32) (int, error) {
+	return d.Read(buf)
+}
+
+// SeekFrame moves to the given frame.
+func (d *Decoder) SeekFrame(frame int64) error {
+	if code := C.ov_pcm_seek(d.vf, C.ogg_int64_t(frame)); code != 0 {
+		return d.error(C.long(code))
+	}
+	d.pcm = d.pcm[:0]
+	return nil
+}
+
+// Seek moves to the given time.
+func (d *Decoder) Seek(t time.Duration) error {
+	return d.SeekFrame(int64(t * time.Duration(d.sampleRate) / time.Second))
+}
+
+// Close releases the decoder and closes the file opened by Open.
+func (d *Decoder) Close() error {
+	if d.vf == nil {
+		return nil
+	}
+	C.ov_clear(d.vf)
+	d.release()
+	if d.closer != nil {
+		return d.closer.Close()
+	}
+	return nil
+}
+
+func (d *Decoder) release() {
+	C.free(unsafe.Pointer(d.vf))
+	d.vf = nil
+	d.pcm = nil
+	d.handle.Delete()
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+//export vorbisRead
+func vorbisRead(ptr unsafe.Pointer, size, nmemb C.size_t, handle unsafe.Pointer) C.size_t {
+	s := cgo.Handle(uintptr(handle)).Value().(*source)
+	if size == 0 {
+		return 0
+	}
+	n, err := io.ReadFull(s.r, unsafe.Slice((*byte)(ptr), int(size*nmemb)))
+	s.pos += int64(n)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.err = err
+	}
+	return C.size_t(n) / size
+}
+
+//export vorbisSeek
+func vorbisSeek(handle unsafe.Pointer, offset C.ogg_int64_t, whence C.int) C.int {
+	s := cgo.Handle(uintptr(handle)).Value().(*source)
+	pos, err := s.r.(io.Seeker).Seek(int64(offset), int(whence))
+	if err != nil {
+		return -1
+	}
+	s.pos = pos
+	return 0
+}
+
+//export vorbisTell
+func vorbisTell(handle unsafe.Pointer) C.long {
+	return C.long(cgo.Handle(uintptr(handle)).Value().(*source).pos)
+}
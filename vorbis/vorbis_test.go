@@ -0,0 +1,253 @@
+package vorbis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// bitWriter packs values least significant bit first, as Vorbis headers do.
+type bitWriter struct {
+	buf  []byte
+	bits int
+}
+
+func (w *bitWriter) write(v uint32, n int) {
+	for i := range n {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(v>>i&1) << (w.bits % 8)
+		w.bits++
+	}
+}
+
+// setup returns the smallest useful setup header: one codebook of two one-bit
+// entries, a floor 1 without partitions, a residue 0 and a single short-block mode.
+func setup() []byte {
+	var w bitWriter
+	w.write(0, 8)         // one codebook
+	w.write(0x564342, 24) // codebook sync
+	w.write(1, 16)        // dimensions
+	w.write(2, 24)        // entries
+	w.write(0, 2)         // neither ordered nor sparse
+	w.write(0, 5)         // lengths of 1 bit
+	w.write(0, 5)
+	w.write(0, 4)  // no lookup
+	w.write(0, 6)  // one time domain transform
+	w.write(0, 16) // placeholder
+	w.write(0, 6)  // one floor
+	w.write(1, 16) // of type 1
+	w.write(0, 5)  // without partitions
+	w.write(0, 2)  // multiplier 1
+	w.write(7, 4)  // range of 128
+	w.write(0, 6)  // one residue
+	w.write(0, 16) // of type 0
+	w.write(0, 24) // begin
+	w.write(0, 24) // end
+	w.write(0, 24) // partitions of 1
+	w.write(0, 6)  // one classification
+	w.write(0, 8)  // classbook
+	w.write(0, 4)  // no cascade
+	w.write(0, 6)  // one mapping
+	w.write(0, 16) // of type 0
+	w.write(0, 4)  // one submap, no coupling, reserved
+	w.write(0, 8)  // time, floor and residue of the submap
+	w.write(0, 8)
+	w.write(0, 8)
+	w.write(0, 6)  // one mode
+	w.write(0, 1)  // short blocks
+	w.write(0, 32) // window and transform types
+	w.write(0, 8)  // mapping
+	w.write(1, 1)  // framing
+	return append([]byte("\x05vorbis"), w.buf...)
+}
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// page returns an Ogg page of the given packets.
+func page(flags byte, granule int64, seq uint32, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for _, p := range packets {
+		for n := len(p); ; n -= 255 {
+			lacing = append(lacing, byte(min(n, 255)))
+			if n < 255 {
+				break
+			}
+		}
+		body = append(body, p...)
+	}
+	b := append([]byte("OggS\x00"), flags)
+	b = binary.LittleEndian.AppendUint64(b, uint64(granule))
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, seq)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(append(append(b, byte(len(lacing))), lacing...), body...)
+	var crc uint32
+	for _, v := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^v]
+	}
+	binary.LittleEndian.PutUint32(b[22:], crc)
+	return b
+}
+
+// silence returns an Ogg Vorbis stream of the given number of silent frames
+// in blocks of 256 frames. Each audio packet after the first adds 128 frames,
+// pages hold 8 packets, and the last page trims the stream to its length.
+func silence(channels, rate, frames int, vendor string, comments ...string) []byte {
+	id := append([]byte("\x01vorbis\x00\x00\x00\x00"), byte(channels))
+	id = binary.LittleEndian.AppendUint32(id, uint32(rate))
+	id = append(id, make([]byte, 12)...)
+	id = append(id, 0x88, 1)
+
+	comment := binary.LittleEndian.AppendUint32([]byte("\x03vorbis"), uint32(len(vendor)))
+	comment = binary.LittleEndian.AppendUint32(append(comment, vendor...), uint32(len(comments)))
+	for _, c := range comments {
+		comment = append(binary.LittleEndian.AppendUint32(comment, uint32(len(c))), c...)
+	}
+	comment = append(comment, 1)
+
+	b := append(page(2, 0, 0, id), page(0, 0, 1, comment, setup())...)
+	// A zero byte is an audio packet of mode 0 with unused floors, i.e. silence.
+	packets := (frames+127)/128 + 1
+	for i, seq := 0, uint32(2); i < packets; i, seq = i+8, seq+1 {
+		n := min(8, packets-i)
+		flags, granule := byte(0), int64(128*(i+n-1))
+		if i+n == packets {
+			flags, granule = 4, int64(frames)
+		}
+		b = append(b, page(flags, granule, seq, slices.Repeat([][]byte{{0}}, n)...)...)
+	}
+	return b
+}
+
+// stream hides the io.Seeker of a reader.
+type stream struct{ io.Reader }
+
+// readAll reads d in buffers of size samples and returns the number of frames
+// and the error ending the stream.
+func readAll(t *testing.T, d *Decoder, size int) (int64, error) {
+	t.Helper()
+	buf := make([]float32, size)
+	var samples int64
+	for {
+		n, err := d.Read(buf)
+		if n%d.Channels() != 0 {
+			t.Fatalf("Read returned %d samples of %d channels", n, d.Channels())
+		}
+		if slices.ContainsFunc(buf[:n], func(v float32) bool { return v != 0 }) {
+			t.Fatal("silence decodes to non-zero samples")
+		}
+		samples += int64(n)
+		if err != nil {
+			return samples / int64(d.Channels()), err
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	d, err := NewDecoder(bytes.NewReader(silence(2, 22050, 1000, "test", "TITLE=Take 1", "artist=Someone")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.SampleRate() != 22050 || d.Channels() != 2 {
+		t.Errorf("format %dHz, %d channels, want 22050Hz, 2", d.SampleRate(), d.Channels())
+	}
+	if d.Length() != 1000 || d.Duration() != 1000*time.Second/22050 {
+		t.Errorf("Length = %d, Duration = %v, want 1000 frames", d.Length(), d.Duration())
+	}
+	if d.Vendor() != "test" || !slices.Equal(d.Comments(), []string{"TITLE=Take 1", "artist=Someone"}) {
+		t.Errorf("Vendor = %q, Comments = %q", d.Vendor(), d.Comments())
+	}
+	if d.Comment("title") != "Take 1" || d.Comment("ARTIST") != "Someone" || d.Comment("album") != "" {
+		t.Errorf("Comment: title %q, artist %q, album %q", d.Comment("title"), d.Comment("ARTIST"), d.Comment("album"))
+	}
+	if frames, err := readAll(t, d, 777); frames != 1000 || err != io.EOF {
+		t.Errorf("read %d frames, %v, want 1000, %v", frames, err, io.EOF)
+	}
+	if d.Position() != 1000 {
+		t.Errorf("Position = %d at the end", d.Position())
+	}
+}
+
+func TestSeek(t *testing.T) {
+	d, err := NewDecoder(bytes.NewReader(silence(1, 8000, 1000, "test")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	buf := make([]float32, 10)
+	if n, err := d.Read(buf); n != 10 || err != nil {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	if d.Position() != 10 {
+		t.Errorf("Position = %d after 10 frames", d.Position())
+	}
+	if err = d.SeekFrame(300); err != nil {
+		t.Fatal(err)
+	}
+	if d.Position() != 300 {
+		t.Errorf("Position = %d after SeekFrame(300)", d.Position())
+	}
+	if frames, err := readAll(t, d, 64); frames != 700 || err != io.EOF {
+		t.Errorf("read %d frames, %v after SeekFrame(300)", frames, err)
+	}
+	if err = d.Seek(10 * time.Millisecond); err != nil || d.Position() != 80 {
+		t.Errorf("Seek(10ms) = %v, Position = %d, want 80", err, d.Position())
+	}
+}
+
+func TestNotSeekable(t *testing.T) {
+	d, err := NewDecoder(stream{bytes.NewReader(silence(2, 22050, 1000, "test"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Length() != -1 || d.Duration() != 0 {
+		t.Errorf("Length = %d, Duration = %v of a stream", d.Length(), d.Duration())
+	}
+	if err = d.SeekFrame(100); !errors.Is(err, ErrNoSeek) {
+		t.Errorf("SeekFrame = %v, want %v", err, ErrNoSeek)
+	}
+	if frames, err := readAll(t, d, 4096); frames != 1000 || err != io.EOF {
+		t.Errorf("read %d frames, %v, want 1000, %v", frames, err, io.EOF)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	// The last page of 1000 frames holds the packet after frame 896.
+	data := silence(2, 22050, 1000, "test")
+	d, err := NewDecoder(bytes.NewReader(data[:len(data)-5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if frames, err := readAll(t, d, 4096); frames != 896 || err != io.EOF {
+		t.Errorf("read %d frames, %v, want 896, %v", frames, err, io.EOF)
+	}
+}
+
+func TestNotVorbis(t *testing.T) {
+	if d, err := NewDecoder(bytes.NewReader(make([]byte, 1000))); err == nil {
+		d.Close()
+		t.Error("NewDecoder accepts zeros")
+	}
+}
@@ -0,0 +1,69 @@
+package portaudio
+
+import (
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// callbackWatchdog counts stream callbacks that take longer than
+// a configured fraction of the buffer period.
+type callbackWatchdog struct {
+	fraction atomic.Uint64 // math.Float64bits of the fraction, 0 if disabled
+	slow     atomic.Uint64
+	maxLoad  atomic.Uint64 // math.Float64bits of the largest observed load
+	limiter  rateLimiter
+}
+
+func (w *callbackWatchdog) enabled() bool {
+	return w.fraction.Load() != 0
+}
+
+// SetCallbackWatchdog makes the stream flag callbacks that take longer than
+// the given fraction of the buffer period (frame count / sample rate).
+// Slow callbacks are counted, see SlowCallbacks, and logged with rate limiting.
+// A fraction of zero or less disables the watchdog.
+// It may be called while the stream is running.
+func (s *Stream[T]) SetCallbackWatchdog(fraction float64) {
+	if fraction <= 0 {
+		s.watchdog.fraction.Store(0)
+		return
+	}
+	s.watchdog.fraction.Store(math.Float64bits(fraction))
+}
+
+// SlowCallbacks returns the number of callbacks flagged by the watchdog.
+func (s *Stream[T]) SlowCallbacks() uint64 {
+	return s.watchdog.slow.Load()
+}
+
+// MaxCallbackLoad returns the largest ratio of callback time to buffer period
+// observed while the watchdog was enabled.
+func (s *Stream[T]) MaxCallbackLoad() float64 {
+	return math.Float64frombits(s.watchdog.maxLoad.Load())
+}
+
+func (s *Stream[T]) checkCallbackTime(elapsed time.Duration) {
+	w := &s.watchdog
+	if s.frameCount == 0 || s.params.SampleRate <= 0 {
+		return
+	}
+	period := float64(s.frameCount) / s.params.SampleRate
+	load := elapsed.Seconds() / period
+	if maxLoad := w.maxLoad.Load(); load > math.Float64frombits(maxLoad) {
+		w.maxLoad.CompareAndSwap(maxLoad, math.Float64bits(load))
+	}
+	if fraction := w.fraction.Load(); fraction == 0 || load <= math.Float64frombits(fraction) {
+		return
+	}
+	w.slow.Add(1)
+	if suppressed, ok := w.limiter.allow(); ok {
+		s.logger().Warn(
+			"stream callback too slow",
+			slog.Duration("elapsed", elapsed),
+			slog.Float64("load", load),
+			slog.Uint64("suppressed", suppressed),
+		)
+	}
+}
@@ -0,0 +1,142 @@
+package portaudio
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// panicIn runs fn like the stream callback runs the user callback.
+func panicIn(s *Stream[float32], fn func()) (result StreamCallbackResult) {
+	defer s.recoverCallback("callback", &result)
+	fn()
+	return Continue
+}
+
+func TestRecoverCallback(t *testing.T) {
+	s := newStream[float32](&StreamParameters{})
+	if r := panicIn(s, func() {}); r != Continue || s.Err() != nil {
+		t.Fatalf("without a panic: result %v, Err = %v", r, s.Err())
+	}
+	errCallback := errors.New("callback failed")
+	if r := panicIn(s, func() { panic(errCallback) }); r != Abort {
+		t.Errorf("result %v after a panic, want %v", r, Abort)
+	}
+	var pe *CallbackPanicError
+	if !errors.As(s.Err(), &pe) || pe.Callback != "callback" || len(pe.Stack) == 0 || !errors.Is(pe, errCallback) {
+		t.Fatalf("Err = %v", s.Err())
+	}
+	// The first panic is kept.
+	panicIn(s, func() { panic("again") })
+	if s.Err() != pe {
+		t.Errorf("Err = %v after a second panic", s.Err())
+	}
+}
+
+func TestCheckCallbackTime(t *testing.T) {
+	s := newStream[float32](&StreamParameters{SampleRate: 48000})
+	s.frameCount = 480
+	s.SetCallbackWatchdog(0.5)
+	s.checkCallbackTime(4 * time.Millisecond)
+	if s.SlowCallbacks() != 0 || s.MaxCallbackLoad() != 0.4 {
+		t.Errorf("after 4ms: %d slow callbacks, max load %v", s.SlowCallbacks(), s.MaxCallbackLoad())
+	}
+	s.checkCallbackTime(6 * time.Millisecond)
+	s.checkCallbackTime(5 * time.Millisecond)
+	if s.SlowCallbacks() != 1 || s.MaxCallbackLoad() != 0.6 {
+		t.Errorf("after 6ms and 5ms: %d slow callbacks, max load %v", s.SlowCallbacks(), s.MaxCallbackLoad())
+	}
+}
+
+// openFinishingStream opens an output stream on a loopback with the callbacks
+// and returns a channel closed after the finished callback.
+func openFinishingStream(t *testing.T, callback func(*Stream[float32]) StreamCallbackResult, finished func(*Stream[float32])) (*Stream[float32], chan struct{}) {
+	t.Helper()
+	params := loopbackParams(t, Float32, 1)
+	params.Input = StreamDeviceParameters{}
+	done := make(chan struct{})
+	s, err := OpenStream(params, callback, func(s *Stream[float32]) {
+		defer close(done)
+		if finished != nil {
+			finished(s)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s, done
+}
+
+func waitDone(t *testing.T, done chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream does not finish")
+	}
+}
+
+func TestCallbackPanic(t *testing.T) {
+	errCallback := errors.New("callback failed")
+	var finishedErr atomic.Value
+	s, done := openFinishingStream(t, func(*Stream[float32]) StreamCallbackResult {
+		panic(errCallback)
+	}, func(s *Stream[float32]) {
+		finishedErr.Store(s.Err())
+	})
+	waitDone(t, done)
+	var pe *CallbackPanicError
+	if !errors.As(s.Err(), &pe) || pe.Callback != "callback" || len(pe.Stack) == 0 {
+		t.Fatalf("Err = %v, want a CallbackPanicError of the callback", s.Err())
+	}
+	if !errors.Is(s.Err(), errCallback) {
+		t.Errorf("%v does not wrap the panic value", s.Err())
+	}
+	if err, _ := finishedErr.Load().(error); err != s.Err() {
+		t.Errorf("finished callback saw Err = %v", err)
+	}
+	waitFor(t, "the stream to stop", func() bool { return !s.IsActive() })
+}
+
+func TestFinishedCallbackPanic(t *testing.T) {
+	s, done := openFinishingStream(t, func(*Stream[float32]) StreamCallbackResult {
+		return Complete
+	}, func(*Stream[float32]) {
+		panic("finished")
+	})
+	waitDone(t, done)
+	var pe *CallbackPanicError
+	if !errors.As(s.Err(), &pe) || pe.Callback != "finished callback" || pe.Value != "finished" {
+		t.Fatalf("Err = %v, want a CallbackPanicError of the finished callback", s.Err())
+	}
+	if errors.Unwrap(pe) != nil {
+		t.Errorf("a panic with a string unwraps to %v", errors.Unwrap(pe))
+	}
+}
+
+func TestCallbackWatchdog(t *testing.T) {
+	s, _ := openFinishingStream(t, func(*Stream[float32]) StreamCallbackResult {
+		// A buffer of 256 frames at 48000Hz lasts 5.3ms.
+		time.Sleep(8 * time.Millisecond)
+		return Continue
+	}, nil)
+	s.SetCallbackWatchdog(0.8)
+	waitFor(t, "slow callbacks", func() bool { return s.SlowCallbacks() >= 3 })
+	if load := s.MaxCallbackLoad(); load < 1.4 {
+		t.Errorf("MaxCallbackLoad = %v, want at least 1.4", load)
+	}
+	s.SetCallbackWatchdog(0)
+	n := s.SlowCallbacks()
+	time.Sleep(50 * time.Millisecond)
+	if s.SlowCallbacks() != n {
+		t.Error("slow callbacks counted with the watchdog disabled")
+	}
+	if s.Err() != nil {
+		t.Errorf("Err = %v", s.Err())
+	}
+}